@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuración de la aplicación. Se arma una sola vez al arrancar, en este
+// orden (cada paso reemplaza al anterior):
+//
+//  1. valores por defecto (etiqueta def),
+//  2. archivo YAML o TOML opcional (-config o CONFIG_FILE),
+//  3. variables de entorno, incluido un .env opcional (etiqueta env),
+//  4. flags de la línea de comandos.
+//
+// Los campos con secreto:"true" no se imprimen. Si falta algo obligatorio o un
+// valor no es válido, Cargar devuelve todos los problemas juntos.
+
+// Config agrupa todos los ajustes
+type Config struct {
+	Servidor  Servidor  `yaml:"servidor" toml:"servidor"`
+	BaseDatos BaseDatos `yaml:"base_datos" toml:"base_datos"`
+	JWT       JWT       `yaml:"jwt" toml:"jwt"`
+	Correo    Correo    `yaml:"correo" toml:"correo"`
+	OIDC      OIDC      `yaml:"oidc" toml:"oidc"`
+	WebAuthn  WebAuthn  `yaml:"webauthn" toml:"webauthn"`
+	Seguridad Seguridad `yaml:"seguridad" toml:"seguridad"`
+}
+
+type Servidor struct {
+	Puerto                int      `yaml:"puerto" toml:"puerto" env:"PORT" def:"3000"`
+	Entorno               string   `yaml:"entorno" toml:"entorno" env:"ENVIRONMENT" def:"development"`
+	CORSOrigenes          []string `yaml:"cors_origenes" toml:"cors_origenes" env:"CORS_ORIGINS" def:"http://localhost:4200"`
+	LimiteSolicitudes     int      `yaml:"limite_solicitudes" toml:"limite_solicitudes" env:"RATE_LIMIT_MAX" def:"200"`
+	LimiteVentanaSegundos int      `yaml:"limite_ventana_segundos" toml:"limite_ventana_segundos" env:"RATE_LIMIT_VENTANA_SEGUNDOS" def:"60"`
+	ApagadoSegundos       int      `yaml:"apagado_segundos" toml:"apagado_segundos" env:"SHUTDOWN_TIMEOUT_SEGUNDOS" def:"20"`
+	ReadyPoolPorcentaje   int      `yaml:"ready_pool_porcentaje" toml:"ready_pool_porcentaje" env:"READY_POOL_PORCENTAJE" def:"90"`
+}
+
+type BaseDatos struct {
+	// Almacen es postgres o memory (datos de ejemplo en memoria, sin base de datos)
+	Almacen       string `yaml:"almacen" toml:"almacen" env:"STORE" def:"postgres"`
+	URL           string `yaml:"url" toml:"url" env:"DATABASE_URL" secreto:"dsn"`
+	MaxConexiones int    `yaml:"max_conexiones" toml:"max_conexiones" env:"DB_MAX_CONEXIONES" def:"25"`
+	MaxInactivas  int    `yaml:"max_inactivas" toml:"max_inactivas" env:"DB_MAX_INACTIVAS" def:"10"`
+}
+
+type JWT struct {
+	KeysDir   string `yaml:"keys_dir" toml:"keys_dir" env:"JWT_KEYS_DIR"`
+	ActiveKID string `yaml:"active_kid" toml:"active_kid" env:"JWT_ACTIVE_KID"`
+}
+
+type Correo struct {
+	AppBaseURL   string `yaml:"app_base_url" toml:"app_base_url" env:"APP_BASE_URL" def:"http://localhost:4200"`
+	Driver       string `yaml:"driver" toml:"driver" env:"MAIL_DRIVER" def:"archivo"`
+	OutboxDir    string `yaml:"outbox_dir" toml:"outbox_dir" env:"MAIL_OUTBOX_DIR" def:"outbox"`
+	SMTPHost     string `yaml:"smtp_host" toml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     string `yaml:"smtp_port" toml:"smtp_port" env:"SMTP_PORT" def:"587"`
+	SMTPUsuario  string `yaml:"smtp_usuario" toml:"smtp_usuario" env:"SMTP_USER"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password" env:"SMTP_PASSWORD" secreto:"true"`
+	From         string `yaml:"from" toml:"from" env:"MAIL_FROM"`
+}
+
+type OIDC struct {
+	Issuer   string `yaml:"issuer" toml:"issuer" env:"OIDC_ISSUER" def:"http://localhost:3000"`
+	LoginURL string `yaml:"login_url" toml:"login_url" env:"OIDC_LOGIN_URL"`
+}
+
+type WebAuthn struct {
+	RPID     string   `yaml:"rp_id" toml:"rp_id" env:"WEBAUTHN_RP_ID" def:"localhost"`
+	RPNombre string   `yaml:"rp_nombre" toml:"rp_nombre" env:"WEBAUTHN_RP_NAME" def:"Menchaca System"`
+	Origenes []string `yaml:"origenes" toml:"origenes" env:"WEBAUTHN_ORIGINS" def:"http://localhost:4200"`
+}
+
+type Seguridad struct {
+	MFARolesObligatorios    []string `yaml:"mfa_roles_obligatorios" toml:"mfa_roles_obligatorios" env:"MFA_REQUIRED_ROLES" def:"doctor,administrador"`
+	MFAMaxIntentos          int      `yaml:"mfa_max_intentos" toml:"mfa_max_intentos" env:"MFA_MAX_INTENTOS" def:"5"`
+	StepUpMinutos           int      `yaml:"step_up_minutos" toml:"step_up_minutos" env:"STEP_UP_MINUTOS" def:"5"`
+	LoginMaxFallos          int      `yaml:"login_max_fallos" toml:"login_max_fallos" env:"LOGIN_MAX_FALLOS" def:"5"`
+	LoginBloqueoMinutos     int      `yaml:"login_bloqueo_minutos" toml:"login_bloqueo_minutos" env:"LOGIN_BLOQUEO_MINUTOS" def:"15"`
+	LoginMaxFallosIP        int      `yaml:"login_max_fallos_ip" toml:"login_max_fallos_ip" env:"LOGIN_MAX_FALLOS_IP" def:"20"`
+	PasswordHistory         int      `yaml:"password_history" toml:"password_history" env:"PASSWORD_HISTORY" def:"5"`
+	PasswordMaxDiasEmpleado int      `yaml:"password_max_dias_empleado" toml:"password_max_dias_empleado" env:"PASSWORD_MAX_DIAS_EMPLEADO" def:"90"`
+	PasswordHashAlgo        string   `yaml:"password_hash_algo" toml:"password_hash_algo" env:"PASSWORD_HASH_ALGO" def:"argon2id"`
+	Argon2MemoriaKB         int      `yaml:"argon2_memoria_kb" toml:"argon2_memoria_kb" env:"ARGON2_MEMORIA_KB" def:"65536"`
+	Argon2Iteraciones       int      `yaml:"argon2_iteraciones" toml:"argon2_iteraciones" env:"ARGON2_ITERACIONES" def:"3"`
+	Argon2Paralelismo       int      `yaml:"argon2_paralelismo" toml:"argon2_paralelismo" env:"ARGON2_PARALELISMO" def:"2"`
+	EmergenciaMinutos       int      `yaml:"emergencia_minutos" toml:"emergencia_minutos" env:"EMERGENCIA_DURACION_MINUTOS" def:"60"`
+	ComplianceEmail         string   `yaml:"compliance_email" toml:"compliance_email" env:"COMPLIANCE_EMAIL"`
+	PoliticaRecargaSegundos int      `yaml:"politica_recarga_segundos" toml:"politica_recarga_segundos" env:"POLITICA_RECARGA_SEGUNDOS" def:"30"`
+}
+
+// ErrorConfiguracion reúne todos los problemas encontrados al cargar
+type ErrorConfiguracion struct {
+	Problemas []string
+}
+
+func (e *ErrorConfiguracion) Error() string {
+	return "configuración inválida:\n  - " + strings.Join(e.Problemas, "\n  - ")
+}
+
+var actual = porDefecto()
+
+// Actual devuelve la configuración cargada (los valores por defecto si aún no
+// se llama a Cargar)
+func Actual() *Config {
+	return actual
+}
+
+// Produccion indica si el entorno es producción
+func (c *Config) Produccion() bool {
+	return c.Servidor.Entorno == "production"
+}
+
+func porDefecto() *Config {
+	c := &Config{}
+	recorrer(c, func(f campo) {
+		if def := f.tag.Get("def"); def != "" {
+			asignar(f.valor, def)
+		}
+	})
+	return c
+}
+
+// Cargar arma la configuración a partir de los argumentos de la línea de
+// comandos (sin el nombre del programa) y la deja como la actual. Los flags
+// pueden ir antes o después del subcomando. Devuelve los argumentos que no
+// son flags.
+func Cargar(args []string) (*Config, []string, error) {
+	fl := flag.NewFlagSet("back-menchaca", flag.ContinueOnError)
+	archivo := fl.String("config", os.Getenv("CONFIG_FILE"), "archivo de configuración YAML o TOML")
+	envFile := fl.String("env-file", ".env", "archivo .env opcional")
+	puerto := fl.Int("puerto", 0, "puerto HTTP (PORT)")
+	entorno := fl.String("entorno", "", "entorno: development | production (ENVIRONMENT)")
+	almacen := fl.String("store", "", "almacenamiento: postgres | memory (STORE)")
+	if err := fl.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	resto := fl.Args()
+	if fl.NArg() > 0 {
+		comando := fl.Arg(0)
+		if err := fl.Parse(fl.Args()[1:]); err != nil {
+			return nil, nil, err
+		}
+		resto = append([]string{comando}, fl.Args()...)
+	}
+
+	// El .env es opcional; las variables ya definidas en el entorno tienen prioridad
+	if err := godotenv.Load(*envFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("leyendo %s: %w", *envFile, err)
+	}
+
+	c := porDefecto()
+	if *archivo != "" {
+		if err := leerArchivo(*archivo, c); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var problemas []string
+	recorrer(c, func(f campo) {
+		if v, ok := os.LookupEnv(f.env); ok && strings.TrimSpace(v) != "" {
+			if err := asignar(f.valor, v); err != nil {
+				problemas = append(problemas, fmt.Sprintf("%s: %v", f.env, err))
+			}
+		}
+	})
+
+	if *puerto != 0 {
+		c.Servidor.Puerto = *puerto
+	}
+	if *entorno != "" {
+		c.Servidor.Entorno = *entorno
+	}
+	if *almacen != "" {
+		c.BaseDatos.Almacen = *almacen
+	}
+
+	problemas = append(problemas, c.validar()...)
+	if len(problemas) > 0 {
+		return nil, nil, &ErrorConfiguracion{Problemas: problemas}
+	}
+
+	actual = c
+	return c, resto, nil
+}
+
+func leerArchivo(ruta string, c *Config) error {
+	datos, err := os.ReadFile(ruta)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(ruta)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(datos, c)
+	case ".toml":
+		_, err = toml.Decode(string(datos), c)
+	default:
+		return fmt.Errorf("formato de configuración no soportado: %s (usa .yaml, .yml o .toml)", ruta)
+	}
+	if err != nil {
+		return fmt.Errorf("leyendo %s: %w", ruta, err)
+	}
+	return nil
+}
+
+// validar devuelve todos los problemas de la configuración
+func (c *Config) validar() []string {
+	var problemas []string
+	// Los campos obligatorios dependen de STORE, MAIL_DRIVER o ENVIRONMENT y se
+	// revisan uno por uno más abajo
+	recorrer(c, func(f campo) {
+		if f.valor.Kind() == reflect.Int && f.valor.Int() <= 0 {
+			problemas = append(problemas, f.env+" debe ser un entero positivo")
+		}
+	})
+
+	if c.Servidor.Puerto > 65535 {
+		problemas = append(problemas, "PORT debe estar entre 1 y 65535")
+	}
+	if c.Servidor.Entorno != "development" && c.Servidor.Entorno != "production" {
+		problemas = append(problemas, "ENVIRONMENT debe ser development o production")
+	}
+	switch c.BaseDatos.Almacen {
+	case "postgres":
+		if strings.TrimSpace(c.BaseDatos.URL) == "" {
+			problemas = append(problemas, "DATABASE_URL es obligatorio con STORE=postgres")
+		}
+	case "memory":
+		if c.Correo.Driver == "bd" {
+			problemas = append(problemas, "MAIL_DRIVER=bd necesita STORE=postgres")
+		}
+	default:
+		problemas = append(problemas, fmt.Sprintf("STORE desconocido: %q (postgres | memory)", c.BaseDatos.Almacen))
+	}
+	if c.Servidor.ReadyPoolPorcentaje > 100 {
+		problemas = append(problemas, "READY_POOL_PORCENTAJE debe estar entre 1 y 100")
+	}
+	if len(c.Servidor.CORSOrigenes) == 0 {
+		problemas = append(problemas, "CORS_ORIGINS necesita al menos un origen")
+	}
+	if c.Produccion() && c.JWT.KeysDir == "" {
+		problemas = append(problemas, "JWT_KEYS_DIR es obligatorio en producción (sin él las llaves son temporales)")
+	}
+	switch c.Correo.Driver {
+	case "archivo", "bd":
+	case "smtp":
+		if c.Correo.SMTPHost == "" {
+			problemas = append(problemas, "SMTP_HOST es obligatorio con MAIL_DRIVER=smtp")
+		}
+		if c.Correo.From == "" {
+			problemas = append(problemas, "MAIL_FROM es obligatorio con MAIL_DRIVER=smtp")
+		}
+	default:
+		problemas = append(problemas, fmt.Sprintf("MAIL_DRIVER desconocido: %q (smtp | archivo | bd)", c.Correo.Driver))
+	}
+	if len(c.WebAuthn.Origenes) == 0 {
+		problemas = append(problemas, "WEBAUTHN_ORIGINS necesita al menos un origen")
+	}
+	if c.Seguridad.PasswordHashAlgo != "argon2id" && c.Seguridad.PasswordHashAlgo != "bcrypt" {
+		problemas = append(problemas, "PASSWORD_HASH_ALGO debe ser argon2id o bcrypt")
+	}
+	if c.Seguridad.Argon2Paralelismo > 255 {
+		problemas = append(problemas, "ARGON2_PARALELISMO no puede ser mayor que 255")
+	}
+	return problemas
+}
+
+// String imprime la configuración como variables de entorno, sin secretos
+func (c *Config) String() string {
+	var b strings.Builder
+	recorrer(c, func(f campo) {
+		valor := ""
+		switch f.valor.Kind() {
+		case reflect.Slice:
+			valor = strings.Join(f.valor.Interface().([]string), ",")
+		default:
+			valor = fmt.Sprint(f.valor.Interface())
+		}
+		switch f.tag.Get("secreto") {
+		case "true":
+			if valor != "" {
+				valor = "****"
+			}
+		case "dsn":
+			valor = RedactarDSN(valor)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", f.env, valor)
+	})
+	return b.String()
+}
+
+var passwordDSN = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// RedactarDSN oculta la contraseña de una cadena de conexión (URL o key=value)
+func RedactarDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "****")
+		}
+		q := u.Query()
+		if q.Has("password") {
+			q.Set("password", "****")
+			u.RawQuery = q.Encode()
+		}
+		return strings.Replace(u.String(), "%2A%2A%2A%2A", "****", 1)
+	}
+	return passwordDSN.ReplaceAllString(dsn, "${1}****")
+}
+
+type campo struct {
+	env   string
+	tag   reflect.StructTag
+	valor reflect.Value
+}
+
+// recorrer visita los campos con etiqueta env de todas las secciones
+func recorrer(c *Config, fn func(campo)) {
+	secciones := reflect.ValueOf(c).Elem()
+	for i := 0; i < secciones.NumField(); i++ {
+		seccion := secciones.Field(i)
+		for j := 0; j < seccion.NumField(); j++ {
+			tag := seccion.Type().Field(j).Tag
+			if env := tag.Get("env"); env != "" {
+				fn(campo{env: env, tag: tag, valor: seccion.Field(j)})
+			}
+		}
+	}
+}
+
+// asignar convierte el texto al tipo del campo (las listas van separadas por comas)
+func asignar(v reflect.Value, texto string) error {
+	texto = strings.TrimSpace(texto)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(texto)
+	case reflect.Int:
+		n, err := strconv.Atoi(texto)
+		if err != nil {
+			return fmt.Errorf("%q no es un entero", texto)
+		}
+		v.SetInt(int64(n))
+	case reflect.Slice:
+		var lista []string
+		for _, s := range strings.Split(texto, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				lista = append(lista, s)
+			}
+		}
+		v.Set(reflect.ValueOf(lista))
+	default:
+		return fmt.Errorf("tipo no soportado: %s", v.Kind())
+	}
+	return nil
+}
@@ -3,7 +3,6 @@ package config
 import (
 	"database/sql"
 	"log"
-	"os"
 	"strings"
 	"time"
 
@@ -13,8 +12,9 @@ import (
 var DB *sql.DB
 
 func ConnectDB() {
-	dsn := os.Getenv("DATABASE_URL")
-	
+	bd := Actual().BaseDatos
+	dsn := bd.URL
+
 	// Añadir parámetros importantes a la cadena de conexión
 	if !strings.Contains(dsn, "?") {
 		dsn += "?"
@@ -23,7 +23,7 @@ func ConnectDB() {
 	}
 	dsn += "binary_parameters=yes&connect_timeout=5"
 
-	log.Println("DSN utilizado:", dsn)
+	log.Println("DSN utilizado:", RedactarDSN(dsn))
 
 	var err error
 	DB, err = sql.Open("postgres", dsn)
@@ -32,8 +32,8 @@ func ConnectDB() {
 	}
 
 	// Configuración óptima del pool de conexiones
-	DB.SetMaxOpenConns(25)
-	DB.SetMaxIdleConns(10)
+	DB.SetMaxOpenConns(bd.MaxConexiones)
+	DB.SetMaxIdleConns(bd.MaxInactivas)
 	DB.SetConnMaxLifetime(30 * time.Minute)
 	DB.SetConnMaxIdleTime(5 * time.Minute)
 
@@ -53,4 +53,12 @@ func ConnectDB() {
 	}
 
 	log.Println("✅ Conexión a Supabase exitosa")
-}
\ No newline at end of file
+}
+
+// CloseDB cierra el pool de conexiones
+func CloseDB() error {
+	if DB == nil {
+		return nil
+	}
+	return DB.Close()
+}
@@ -1,22 +1,32 @@
 package handlers
 
 import (
-	"back-menchaca/config"
 	"back-menchaca/models"
+	"back-menchaca/repository"
 	"back-menchaca/utils"
-	"database/sql"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 )
 
 const mod = "ANT"
 
-func CrearAntecedente(c *fiber.Ctx) error {
+// AntecedenteHandler atiende /antecedentes
+type AntecedenteHandler struct {
+	antecedentes repository.Antecedentes
+	expedientes  repository.Expedientes
+}
+
+func NuevoAntecedenteHandler(antecedentes repository.Antecedentes, expedientes repository.Expedientes) *AntecedenteHandler {
+	return &AntecedenteHandler{antecedentes: antecedentes, expedientes: expedientes}
+}
+
+func (h *AntecedenteHandler) CrearAntecedente(c *fiber.Ctx) error {
 	var a models.Antecedente
 	if err := c.BodyParser(&a); err != nil {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, "Datos inválidos")
 	}
 
-	if !utils.ExisteID("Expediente", "id_expediente", a.IDExpediente) {
+	if ok, err := h.expedientes.Existe(a.IDExpediente); err != nil || !ok {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, "ID de expediente no válido")
 	}
 
@@ -24,34 +34,22 @@ func CrearAntecedente(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, err.Error())
 	}
 
-	query := `INSERT INTO Antecedentes (id_expediente, diagnostico, descripcion, fecha)
-	          VALUES ($1, $2, $3, $4) RETURNING id_antecedente`
-	err := config.DB.QueryRow(query, a.IDExpediente, a.Diagnostico, a.Descripcion, a.Fecha).Scan(&a.ID)
-	if err != nil {
+	if err := h.antecedentes.Crear(&a); err != nil {
 		return utils.Responder(c, "06", mod, "antecedente-service", nil, "Error al crear antecedente")
 	}
 
 	return utils.Responder(c, "01", mod, "antecedente-service", a)
 }
 
-func ObtenerAntecedentes(c *fiber.Ctx) error {
-	rows, err := config.DB.Query(`SELECT id_antecedente, id_expediente, diagnostico, descripcion, fecha FROM Antecedentes`)
+func (h *AntecedenteHandler) ObtenerAntecedentes(c *fiber.Ctx) error {
+	antecedentes, err := h.antecedentes.Listar()
 	if err != nil {
 		return utils.Responder(c, "06", mod, "antecedente-service", nil, "Error al obtener antecedentes")
 	}
-	defer rows.Close()
-
-	var antecedentes []models.Antecedente
-	for rows.Next() {
-		var a models.Antecedente
-		if err := rows.Scan(&a.ID, &a.IDExpediente, &a.Diagnostico, &a.Descripcion, &a.Fecha); err == nil {
-			antecedentes = append(antecedentes, a)
-		}
-	}
 	return utils.Responder(c, "01", mod, "antecedente-service", antecedentes)
 }
 
-func ObtenerAntecedentePorID(c *fiber.Ctx) error {
+func (h *AntecedenteHandler) ObtenerAntecedentePorID(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_antecedente"`
 	}
@@ -59,10 +57,8 @@ func ObtenerAntecedentePorID(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, "ID inválido")
 	}
 
-	var a models.Antecedente
-	err := config.DB.QueryRow(`SELECT id_antecedente, id_expediente, diagnostico, descripcion, fecha FROM Antecedentes WHERE id_antecedente=$1`, body.ID).
-		Scan(&a.ID, &a.IDExpediente, &a.Diagnostico, &a.Descripcion, &a.Fecha)
-	if err == sql.ErrNoRows {
+	a, err := h.antecedentes.Obtener(body.ID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
 		return utils.Responder(c, "05", mod, "antecedente-service", nil, "Antecedente no encontrado")
 	} else if err != nil {
 		return utils.Responder(c, "06", mod, "antecedente-service", nil, "Error al buscar antecedente")
@@ -70,16 +66,14 @@ func ObtenerAntecedentePorID(c *fiber.Ctx) error {
 	return utils.Responder(c, "01", mod, "antecedente-service", a)
 }
 
-func ActualizarAntecedente(c *fiber.Ctx) error {
+func (h *AntecedenteHandler) ActualizarAntecedente(c *fiber.Ctx) error {
 	var a models.Antecedente
 	if err := c.BodyParser(&a); err != nil || a.ID == 0 {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, "Datos inválidos")
 	}
 
-	var actual models.Antecedente
-	err := config.DB.QueryRow(`SELECT id_expediente, diagnostico, descripcion, fecha FROM Antecedentes WHERE id_antecedente=$1`, a.ID).
-		Scan(&actual.IDExpediente, &actual.Diagnostico, &actual.Descripcion, &actual.Fecha)
-	if err == sql.ErrNoRows {
+	actual, err := h.antecedentes.Obtener(a.ID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
 		return utils.Responder(c, "05", mod, "antecedente-service", nil, "Antecedente no encontrado")
 	} else if err != nil {
 		return utils.Responder(c, "06", mod, "antecedente-service", nil, "Error al buscar antecedente")
@@ -87,7 +81,7 @@ func ActualizarAntecedente(c *fiber.Ctx) error {
 
 	if a.IDExpediente == 0 {
 		a.IDExpediente = actual.IDExpediente
-	} else if !utils.ExisteID("Expediente", "id_expediente", a.IDExpediente) {
+	} else if ok, err := h.expedientes.Existe(a.IDExpediente); err != nil || !ok {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, "ID de expediente no válido")
 	}
 
@@ -105,15 +99,13 @@ func ActualizarAntecedente(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, err.Error())
 	}
 
-	_, err = config.DB.Exec(`UPDATE Antecedentes SET id_expediente=$1, diagnostico=$2, descripcion=$3, fecha=$4 WHERE id_antecedente=$5`,
-		a.IDExpediente, a.Diagnostico, a.Descripcion, a.Fecha, a.ID)
-	if err != nil {
+	if err := h.antecedentes.Actualizar(a); err != nil {
 		return utils.Responder(c, "06", mod, "antecedente-service", nil, "Error al actualizar antecedente")
 	}
 	return utils.Responder(c, "01", mod, "antecedente-service", fiber.Map{"mensaje": "Antecedente actualizado"})
 }
 
-func EliminarAntecedente(c *fiber.Ctx) error {
+func (h *AntecedenteHandler) EliminarAntecedente(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_antecedente"`
 	}
@@ -121,8 +113,7 @@ func EliminarAntecedente(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", mod, "antecedente-service", nil, "ID inválido")
 	}
 
-	_, err := config.DB.Exec("DELETE FROM Antecedentes WHERE id_antecedente=$1", body.ID)
-	if err != nil {
+	if err := h.antecedentes.Eliminar(body.ID); err != nil {
 		return utils.Responder(c, "06", mod, "antecedente-service", nil, "Error al eliminar antecedente")
 	}
 	return utils.Responder(c, "01", mod, "antecedente-service", fiber.Map{"mensaje": "Antecedente eliminado"})
@@ -1,19 +1,14 @@
 package handlers
 
 import (
-	"fmt"
+	"back-menchaca/config"
+	"encoding/json"
 	"log"
-	"os"
+	"strconv"
 	"time"
-    "database/sql"
-	"back-menchaca/config"
 	"back-menchaca/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/go-playground/validator/v10"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/pquerna/otp"
-	"github.com/pquerna/otp/totp"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var validate = validator.New()
@@ -39,6 +34,8 @@ func Login(c *fiber.Ctx) error {
 		Correo     string `json:"correo" validate:"required,email"`
 		Contrasena string `json:"contrasena" validate:"required,min=6"`
 		TOTP       string `json:"totp,omitempty"` // Opcional en primer paso
+		CodigoRecuperacion string `json:"codigoRecuperacion,omitempty"` // En lugar del TOTP
+		Rol        string `json:"rol,omitempty"` // Obligatorio si la cuenta tiene varios roles
 	}
 
 	
@@ -60,60 +57,73 @@ func Login(c *fiber.Ctx) error {
 		})
 	}
 
-	var (
-		id string 
-		rol        string
-		hash       string
-		mfaEnabled bool
-		mfaSecret  sql.NullString
-	)
-
 	log.Printf("Intentando login con correo: %s", input.Correo)
 
-	err := config.DB.QueryRow(`
-    SELECT id_empleado, tipo_empleado as rol, contraseña, mfa_enabled, mfa_secret 
-    FROM empleado WHERE correo=$1`, input.Correo).Scan(&id, &rol, &hash, &mfaEnabled, &mfaSecret)
+	// Protección contra fuerza bruta: espera progresiva y bloqueo temporal
+	hasta, bloqueada, err := utils.VerificarIntentoLogin(input.Correo, c.IP())
+	if err != nil {
+		// Sin poder consultar los fallos no se puede aplicar el bloqueo, así
+		// que no se aceptan contraseñas
+		log.Println("Error verificando intentos de login:", err)
+		return loginNoDisponible(c)
+	}
+	if !hasta.IsZero() {
+		return responderLoginBloqueado(c, hasta, bloqueada)
+	}
 
-	// Si falla, intentar en pacientes
+	cuenta, err := utils.BuscarCuentaPorCorreo(input.Correo)
 	if err != nil {
-		err = config.DB.QueryRow(`
-			SELECT id_paciente, 'paciente' as rol, contraseña, mfa_enabled, mfa_secret 
-			FROM paciente WHERE correo=$1`, input.Correo).Scan(&id, &rol, &hash, &mfaEnabled, &mfaSecret)
+		if err != utils.ErrCuentaNoEncontrada {
+			log.Println("Error buscando cuenta:", err)
+		}
+		return fallarLogin(c, input.Correo, "A01", "Credenciales inválidas")
+	}
 
+	// Verificar contraseña
+	ok, rehash := utils.VerifyPassword(input.Contrasena, cuenta.Hash)
+	if !ok {
+		return fallarLogin(c, input.Correo, "A01", "Credenciales inválidas")
+	}
+	if rehash {
+		// Hash con algoritmo o parámetros viejos: se actualiza sin pedir nada al usuario
+		if err := utils.RehashContrasena(cuenta, input.Contrasena); err != nil {
+			log.Printf("Error actualizando hash de contraseña: %v", err)
+		}
+	}
 
-		if err != nil {
-			log.Println("Tampoco en paciente:", err)
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"statusCode": fiber.StatusUnauthorized,
-				"intCode":    "A01",
-				"message":    "Credenciales inválidas",
+	// Si la identidad tiene varios roles (p. ej. doctor y paciente) el cliente debe elegir uno
+	if !cuenta.SeleccionarRol(input.Rol) {
+		if input.Rol != "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"statusCode": fiber.StatusForbidden,
+				"intCode":    "R02",
+				"message":    "La cuenta no tiene el rol solicitado",
 				"from":       "auth-service",
 			})
 		}
-	}
-
-	// Mapear rol a código interno
-	intCodeRol := mapRolToIntCode(rol)
-
-	// Verificar contraseña
-	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(input.Contrasena)); err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"statusCode": fiber.StatusUnauthorized,
-			"intCode":    "A01",
-			"message":    "Credenciales inválidas",
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"statusCode": fiber.StatusConflict,
+			"intCode":    "R01",
+			"message":    "La cuenta tiene varios roles, indica con cuál deseas iniciar sesión",
 			"from":       "auth-service",
+			"data":       fiber.Map{"roles": cuenta.NombresRoles()},
 		})
 	}
 
-	// Convertir mfaSecret a string si es válido
-	secret := ""
-	if mfaSecret.Valid {
-		secret = mfaSecret.String
+	// Los pacientes no pueden entrar hasta verificar su correo
+	if !cuenta.CorreoVerificado && !cuenta.EsPersonal() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"statusCode": fiber.StatusForbidden,
+			"intCode":    "V01",
+			"message":    "Debes verificar tu correo antes de iniciar sesión",
+			"from":       "auth-service",
+		})
 	}
 
-	// Si MFA está activado pero no se ha enviado TOTP, pedir MFA
-	if mfaEnabled && input.TOTP == "" {
-		tempToken, err := utils.GenerateTempToken(input.Correo, rol)
+	// Si MFA está activado pero no se ha enviado TOTP, pedir MFA (TOTP o WebAuthn
+	// con /auth/mfa/webauthn/login/begin y /auth/verify-mfa)
+	if cuenta.TieneMFA() && input.TOTP == "" && input.CodigoRecuperacion == "" {
+		tempToken, err := utils.GenerateTempToken(cuenta.Principal())
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"statusCode": fiber.StatusInternalServerError,
@@ -131,93 +141,198 @@ func Login(c *fiber.Ctx) error {
 			"data": fiber.Map{
 				"tempToken":  tempToken,
 				"mfaRequired": true,
+				"metodos":     cuenta.MetodosMFA(),
 			},
 		})
 	}
 
-    // Si MFA aún no ha sido activado, generarlo automáticamente
-// Si MFA aún no ha sido activado, generarlo automáticamente
-if !mfaEnabled {
-	key, err := totp.Generate(totp.GenerateOpts{
-		Issuer:      "Menchaca System",
-		AccountName: input.Correo,
-	})
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"statusCode": fiber.StatusInternalServerError,
-			"intCode":    "A03",
-			"message":    "Error generando secreto MFA",
+	// Si la política exige MFA para el rol y aún no lo configura, solo se entrega
+	// un token de enrolamiento para /auth/mfa/enroll y /auth/mfa/confirm
+	if !cuenta.TieneMFA() && utils.MFAObligatorioCuenta(cuenta) {
+		enrollToken, err := utils.GenerateEnrollToken(cuenta.Principal())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"statusCode": fiber.StatusInternalServerError,
+				"intCode":    "A03",
+				"message":    "Error generando token de enrolamiento MFA",
+				"from":       "auth-service",
+			})
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"statusCode": fiber.StatusAccepted,
+			"intCode":    "MFA02",
+			"message":    "Tu rol requiere autenticación de dos factores. Configúrala para continuar.",
 			"from":       "auth-service",
+			"data": fiber.Map{
+				"enrollToken":           enrollToken,
+				"mfaEnrollmentRequired": true,
+			},
 		})
 	}
 
-	// Guardar nuevo secreto y activar MFA
-	table := "Empleado"
-	if rol == "paciente" {
-		table = "Paciente"
+	// Validar código TOTP (o de recuperación) si MFA está activado
+	auth := autenticadoAhora(utils.AMRContrasena)
+	if cuenta.TieneMFA() {
+		metodo, ok, err := verificarSegundoFactor(cuenta, input.TOTP, input.CodigoRecuperacion, nil, "")
+		if err != nil {
+			log.Printf("Error verificando segundo factor: %v", err)
+		}
+		if !ok {
+			return fallarLogin(c, input.Correo, "A02", "Código de autenticación inválido")
+		}
+		auth = autenticadoAhora(utils.AMRContrasena, amrDeMetodo(metodo), utils.AMRMultiple)
 	}
-	_, err = config.DB.Exec(
-		fmt.Sprintf("UPDATE %s SET mfa_secret=$1, mfa_enabled=true WHERE correo=$2", table),
-		key.Secret(),
-		input.Correo,
-	)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"statusCode": fiber.StatusInternalServerError,
-			"intCode":    "A03",
-			"message":    "Error guardando secreto MFA",
-			"from":       "auth-service",
-		})
+
+	return emitirSesion(c, cuenta, auth, "Autenticación exitosa", nil)
+}
+
+// autenticacion indica cuándo y con qué métodos se autenticó el usuario; se
+// guarda en los claims auth_time y amr de los tokens que emite emitirSesion
+type autenticacion struct {
+	momento time.Time
+	metodos []string
+}
+
+func autenticadoAhora(metodos ...string) autenticacion {
+	return autenticacion{momento: time.Now(), metodos: metodos}
+}
+
+// autenticacionActual conserva la autenticación del token con el que se hizo la solicitud
+func autenticacionActual(c *fiber.Ctx) autenticacion {
+	momento, _ := c.Locals("auth_time").(time.Time)
+	metodos, _ := c.Locals("amr").([]string)
+	return autenticacion{momento: momento, metodos: metodos}
+}
+
+// amrDeMetodo traduce el método de verificarSegundoFactor a su valor de amr
+func amrDeMetodo(metodo string) string {
+	if metodo == "webauthn" {
+		return utils.AMRLlave
 	}
+	return utils.AMRCodigo
+}
 
-	// Generar tempToken para MFA
-	tempToken, err := utils.GenerateTempToken(input.Correo, rol)
+// fallarLogin registra un intento fallido y responde 401, o 423 si con él se bloqueó la cuenta
+func fallarLogin(c *fiber.Ctx, correo, intCode, message string) error {
+	hasta, err := utils.RegistrarFalloLogin(correo, c.IP())
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"statusCode": fiber.StatusInternalServerError,
-			"intCode":    "A03",
-			"message":    "Error generando token temporal MFA",
+		log.Println("Error registrando intento de login fallido:", err)
+	}
+	if !hasta.IsZero() {
+		log.Printf("[ALERTA] Cuenta %s bloqueada por intentos fallidos desde %s", correo, c.IP())
+		return responderLoginBloqueado(c, hasta, true)
+	}
+
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"statusCode": fiber.StatusUnauthorized,
+		"intCode":    intCode,
+		"message":    message,
+		"from":       "auth-service",
+	})
+}
+
+// responderLoginBloqueado indica al cliente cuánto debe esperar antes de reintentar
+func responderLoginBloqueado(c *fiber.Ctx, hasta time.Time, bloqueada bool) error {
+	espera := int(time.Until(hasta).Seconds()) + 1
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(espera))
+
+	if bloqueada {
+		return c.Status(fiber.StatusLocked).JSON(fiber.Map{
+			"statusCode": fiber.StatusLocked,
+			"intCode":    "L01",
+			"message":    "Cuenta bloqueada temporalmente por intentos fallidos",
 			"from":       "auth-service",
+			"data":       fiber.Map{"reintentarEn": espera},
 		})
 	}
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"statusCode": fiber.StatusTooManyRequests,
+		"intCode":    "L02",
+		"message":    "Demasiados intentos, espera antes de volver a intentar",
+		"from":       "auth-service",
+		"data":       fiber.Map{"reintentarEn": espera},
+	})
+}
 
-	// Responder con QR y tempToken para que el frontend pueda continuar
-	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
-		"statusCode": fiber.StatusAccepted,
-		"intCode":    "MFA01",
-		"message":    "Autenticación de dos factores configurada. Escanea el QR para activarla.",
+// loginNoDisponible responde cuando no se pueden consultar los intentos
+// fallidos: sin ese límite no se comprueba ninguna contraseña
+func loginNoDisponible(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"statusCode": fiber.StatusServiceUnavailable,
+		"intCode":    "L03",
+		"message":    "Inicio de sesión no disponible, intenta más tarde",
 		"from":       "auth-service",
-		"data": fiber.Map{
-			"qrUrl":       key.URL(),
-			"secret":      key.Secret(),
-			"mfaConfigured": true,
-			"tempToken":   tempToken,
-		},
 	})
 }
 
+// comprobarContrasena verifica de nuevo la contraseña de una cuenta ya
+// autenticada con los mismos límites de intentos que el login. Si no es
+// correcta devuelve false y la respuesta (la de incorrecta o la de bloqueo).
+func comprobarContrasena(c *fiber.Ctx, cuenta *utils.Cuenta, contrasena string, incorrecta func() error) (bool, error) {
+	hasta, bloqueada, err := utils.VerificarIntentoLogin(cuenta.Correo, c.IP())
+	if err != nil {
+		log.Printf("Error verificando intentos de login: %v", err)
+		return false, loginNoDisponible(c)
+	}
+	if !hasta.IsZero() {
+		return false, responderLoginBloqueado(c, hasta, bloqueada)
+	}
+	if !utils.CheckPasswordHash(contrasena, cuenta.Hash) {
+		hasta, err := utils.RegistrarFalloLogin(cuenta.Correo, c.IP())
+		if err != nil {
+			log.Printf("Error registrando intento fallido: %v", err)
+		}
+		if !hasta.IsZero() {
+			return false, responderLoginBloqueado(c, hasta, true)
+		}
+		return false, incorrecta()
+	}
+	if err := utils.RegistrarLoginExitoso(cuenta.Correo, c.IP()); err != nil {
+		log.Printf("Error registrando login exitoso: %v", err)
+	}
+	return true, nil
+}
+
+// emitirSesion genera el access token y el refresh token de una cuenta ya autenticada
+func emitirSesion(c *fiber.Ctx, cuenta *utils.Cuenta, auth autenticacion, message string, extra fiber.Map) error {
+	principal := cuenta.Principal()
+	principal.AuthTime = auth.momento
+	principal.AMR = auth.metodos
 
-	// Validar código TOTP si MFA está activado
-	if mfaEnabled {
-		if !utils.ValidateTOTP(input.TOTP, secret) {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"statusCode": fiber.StatusUnauthorized,
-				"intCode":    "A02",
-				"message":    "Código de autenticación inválido",
+	if err := utils.RegistrarLoginExitoso(cuenta.Correo, c.IP()); err != nil {
+		log.Printf("Error registrando login exitoso: %v", err)
+	}
+
+	// Si la contraseña del personal expiró solo se entrega un token para cambiarla
+	if utils.ContrasenaExpirada(cuenta) {
+		changeToken, err := utils.GeneratePasswordChangeToken(principal)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"statusCode": fiber.StatusInternalServerError,
+				"intCode":    "A03",
+				"message":    "Error generando token de cambio de contraseña",
 				"from":       "auth-service",
 			})
 		}
-	}
-	log.Printf("Generando JWT para id: %s, email: %s, rol: %s", id, input.Correo, rol)
-	if id == "" {
-		log.Println("ERROR: id vacío, no se puede generar token")
-		// Maneja error
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"statusCode": fiber.StatusAccepted,
+			"intCode":    "PWD02",
+			"message":    "Tu contraseña expiró, debes cambiarla para continuar",
+			"from":       "auth-service",
+			"data": fiber.Map{
+				"passwordChangeToken":    changeToken,
+				"passwordChangeRequired": true,
+			},
+		})
 	}
 
-	// Generar tokens
-	accessToken, err := utils.GenerateJWT(id, input.Correo, rol)
-	
+	log.Printf("Generando JWT para id: %s, email: %s, rol: %s", cuenta.ID, cuenta.Correo, cuenta.Rol)
+
+	accessToken, err := utils.GenerateJWT(principal)
 	if err != nil {
+		log.Printf("Error generando access token: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"statusCode": fiber.StatusInternalServerError,
 			"intCode":    "A03",
@@ -226,8 +341,9 @@ if !mfaEnabled {
 		})
 	}
 
-	refreshToken, err := utils.GenerateRefreshToken(id, input.Correo, rol)
+	refreshToken, err := utils.GenerateRefreshToken(principal)
 	if err != nil {
+		log.Printf("Error generando refresh token: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"statusCode": fiber.StatusInternalServerError,
 			"intCode":    "A03",
@@ -237,36 +353,36 @@ if !mfaEnabled {
 	}
 
 	// Configurar cookie segura
-	c.Cookie(&fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   os.Getenv("ENVIRONMENT") == "production",
-		SameSite: "Lax",
-		Path:     "/",
-	})
+	setRefreshCookie(c, refreshToken)
+
+	data := fiber.Map{
+		"token":        accessToken,
+		"tokenType":    "Bearer",
+		"expiresIn":    int(utils.AccessTokenTTL.Seconds()),
+		"refreshToken": refreshToken,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
 
 	return c.JSON(fiber.Map{
 		"statusCode": fiber.StatusOK,
-		"intCode":    intCodeRol,
-		"message":    "Autenticación exitosa",
+		"intCode":    mapRolToIntCode(cuenta.Rol),
+		"message":    message,
 		"from":       "auth-service",
-		"data": fiber.Map{
-			"token":        accessToken,
-			"tokenType":    "Bearer",
-			"expiresIn":    1800,
-			"refreshToken": refreshToken,
-		},
+		"data":       data,
 	})
 }
 
 
-// VerifyMFA valida el token temporal y el código TOTP para completar la MFA
+// VerifyMFA valida el token temporal y el segundo factor (código TOTP, código de
+// recuperación o aserción WebAuthn) para completar la MFA
 func VerifyMFA(c *fiber.Ctx) error {
 	var input struct {
-		TempToken string `json:"tempToken" validate:"required"`
-		TOTP      string `json:"totp" validate:"required,len=6,numeric"`
+		TempToken          string          `json:"tempToken" validate:"required"`
+		TOTP               string          `json:"totp" validate:"required_without_all=CodigoRecuperacion WebAuthn,omitempty,len=6,numeric"`
+		CodigoRecuperacion string          `json:"codigoRecuperacion"`
+		WebAuthn           json.RawMessage `json:"webauthn"` // respuesta de navigator.credentials.get()
 	}
 
 	if err := c.BodyParser(&input); err != nil {
@@ -287,14 +403,8 @@ func VerifyMFA(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := jwt.Parse(input.TempToken, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("método de firma inesperado: %v", t.Header["alg"])
-		}
-		return []byte(os.Getenv("TEMP_SECRET")), nil
-	})
-
-	if err != nil || !token.Valid {
+	claims, err := utils.ParseToken(input.TempToken, "mfa")
+	if err != nil {
 		log.Printf("Error token temporal: %v", err)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"statusCode": fiber.StatusUnauthorized,
@@ -304,162 +414,108 @@ func VerifyMFA(c *fiber.Ctx) error {
 		})
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"statusCode": fiber.StatusInternalServerError,
-			"intCode":    "A03",
-			"message":    "Error al procesar los claims del token",
+	// El token temporal es de un solo uso: se consume antes de revisar el código,
+	// así dos solicitudes simultáneas no pueden usarlo las dos. Un código
+	// incorrecto lo libera mientras queden intentos.
+	jti, _ := claims["jti"].(string)
+	expira := time.Now().Add(5 * time.Minute)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expira = exp.Time
+	}
+	consumido := false
+	if jti != "" {
+		if consumido, err = utils.ConsumirTokenDeUso(jti, expira); err != nil {
+			log.Printf("Error consumiendo token temporal: %v", err)
+		}
+	}
+	if !consumido {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Token temporal inválido o expirado",
 			"from":       "auth-service",
 		})
 	}
 
-	email, ok := claims["email"].(string)
-	if !ok || email == "" {
+	principal := utils.PrincipalDesdeClaims(claims)
+	if principal.IdentidadID == "" || principal.Rol == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"statusCode": fiber.StatusBadRequest,
 			"intCode":    "A01",
-			"message":    "Token no contiene email válido",
+			"message":    "Token temporal incompleto",
 			"from":       "auth-service",
 		})
 	}
 
-	rol, ok := claims["rol"].(string)
-	if !ok || rol == "" {
+	cuenta, err := utils.ObtenerCuenta(principal.IdentidadID, principal.Rol)
+	if err != nil {
+		log.Printf("Error obteniendo cuenta %s (%s): %v", principal.IdentidadID, principal.Rol, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	if !cuenta.TieneMFA() {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"statusCode": fiber.StatusBadRequest,
 			"intCode":    "A01",
-			"message":    "Token no contiene rol válido",
+			"message":    "La autenticación de dos factores no está activada",
 			"from":       "auth-service",
 		})
 	}
 
-	// Obtener secreto MFA actual
-	var mfaSecret string
-
-	var id string
-	err = config.DB.QueryRow(`
-		SELECT id, mfa_secret FROM (
-			SELECT id_paciente as id, correo, mfa_secret FROM Paciente WHERE correo = $1
-			UNION
-			SELECT id_empleado as id, correo, mfa_secret FROM Empleado WHERE correo = $1
-		) AS usuarios LIMIT 1`, email).Scan(&id, &mfaSecret)
-
+	// Validar código TOTP, código de recuperación o aserción WebAuthn
+	metodo, ok, err := verificarSegundoFactor(cuenta, input.TOTP, input.CodigoRecuperacion, input.WebAuthn, jti)
+	if err != nil || !ok {
+		log.Printf("Validación MFA fallida para %s: %v", cuenta.Correo, err)
+		if err == utils.ErrWebAuthnClonada {
+			utils.RegistrarEventoSeguridad("webauthn_clonada", cuenta.Correo, c.IP(), nil)
+		}
 
-	isNewMFA := false
-	if err != nil || mfaSecret == "" {
-		// Generar nuevo secreto MFA
-		key, err := totp.Generate(totp.GenerateOpts{
-			Issuer:      "SistemaHospitalario",
-			AccountName: email,
-		})
+		// Al agotar los intentos el token queda consumido
+		fallidos, err := utils.RegistrarFalloMFA(jti, expira)
 		if err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"statusCode": fiber.StatusInternalServerError,
-				"intCode":    "A03",
-				"message":    "Error generando secreto MFA",
+			log.Printf("Error registrando intento MFA fallido: %v", err)
+		}
+		if err != nil || fallidos >= utils.MaxIntentosMFA() {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"statusCode": fiber.StatusTooManyRequests,
+				"intCode":    "A04",
+				"message":    "Demasiados intentos fallidos, inicia sesión de nuevo",
 				"from":       "auth-service",
 			})
 		}
 
-		mfaSecret = key.Secret()
-		isNewMFA = true
-	}
-
-	intCodeRol := mapRolToIntCode(rol)
-
-	// Validar código TOTP
-	valid, err := totp.ValidateCustom(input.TOTP, mfaSecret, time.Now(), totp.ValidateOpts{
-		Period:    30,
-		Skew:      1,
-		Digits:    otp.DigitsSix,
-		Algorithm: otp.AlgorithmSHA1,
-	})
-
-	if err != nil || !valid {
-		log.Printf("Validación MFA fallida - Código: %s, Secreto: %s, err: %v", input.TOTP, mfaSecret, err)
+		if err := utils.LiberarTokenDeUso(jti); err != nil {
+			log.Printf("Error liberando token temporal: %v", err)
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"statusCode": fiber.StatusUnauthorized,
 			"intCode":    "A02",
 			"message":    "Código de autenticación inválido o expirado",
 			"from":       "auth-service",
+			"data":       fiber.Map{"intentosRestantes": utils.MaxIntentosMFA() - fallidos},
 		})
 	}
 
-	// Guardar secreto MFA y activar MFA si es nuevo
-	if isNewMFA {
-		var updateQuery string
-		if rol == "empleado" {
-			updateQuery = `UPDATE Empleado SET mfa_secret=$1, mfa_enabled=true WHERE correo=$2`
-		} else {
-			updateQuery = `UPDATE Paciente SET mfa_secret=$1, mfa_enabled=true WHERE correo=$2`
-		}
-
-		_, err := config.DB.Exec(updateQuery, mfaSecret, email)
+	auth := autenticadoAhora(utils.AMRContrasena, amrDeMetodo(metodo), utils.AMRMultiple)
+	if metodo == "recovery" {
+		restantes, err := utils.ContarCodigosRecuperacion(cuenta)
 		if err != nil {
-			log.Printf("Error guardando MFA en BD: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"statusCode": fiber.StatusInternalServerError,
-				"intCode":    "A03",
-				"message":    "Error guardando configuración MFA",
-				"from":       "auth-service",
-			})
+			log.Printf("Error contando códigos de recuperación: %v", err)
 		}
+		return emitirSesion(c, cuenta, auth, "Autenticación con código de recuperación exitosa", fiber.Map{"codigosRestantes": restantes})
 	}
 
-	// Generar tokens finales
-	accessToken, err := utils.GenerateJWT(id, email, rol)
-	if err != nil {
-		log.Printf("Error generando access token: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"statusCode": fiber.StatusInternalServerError,
-			"intCode":    "A03",
-			"message":    "Error generando token de acceso",
-			"from":       "auth-service",
-		})
-	}
-
-	refreshToken, err := utils.GenerateRefreshToken(id, email, rol)
-	if err != nil {
-		log.Printf("Error generando refresh token: %v", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"statusCode": fiber.StatusInternalServerError,
-			"intCode":    "A03",
-			"message":    "Error generando token de refresco",
-			"from":       "auth-service",
-		})
-	}
-
-	// Configurar cookie para refresh token
-	c.Cookie(&fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		Expires:  time.Now().Add(7 * 24 * time.Hour),
-		HTTPOnly: true,
-		Secure:   os.Getenv("ENVIRONMENT") == "production",
-		SameSite: "Lax",
-		Path:     "/",
-	})
-
-	return c.JSON(fiber.Map{
-		"statusCode": fiber.StatusOK,
-		"intCode":    intCodeRol,
-		"message":    "Autenticación MFA exitosa",
-		"from":       "auth-service",
-		"data": fiber.Map{
-			"token":        accessToken,
-			"tokenType":    "Bearer",
-			"expiresIn":    1800,
-			"refreshToken": refreshToken,
-			"mfaActivated": isNewMFA,
-		},
-	})
+	return emitirSesion(c, cuenta, auth, "Autenticación MFA exitosa", nil)
 }
 
-// RefreshToken genera un nuevo access token dado un refresh token válido
+// RefreshToken rota el refresh token recibido y genera un nuevo access token
 func RefreshToken(c *fiber.Ctx) error {
 	refreshToken := c.Cookies("refresh_token")
-	log.Println("[DEBUG] Cookie refresh_token:", refreshToken)
 
 	if refreshToken == "" {
 		var input struct {
@@ -486,7 +542,6 @@ func RefreshToken(c *fiber.Ctx) error {
 		}
 
 		refreshToken = input.RefreshToken
-		log.Println("[DEBUG] Refresh token recibido en body:", refreshToken)
 	}
 
 	if refreshToken == "" {
@@ -499,11 +554,20 @@ func RefreshToken(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := jwt.Parse(refreshToken, func(t *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("REFRESH_SECRET")), nil
-	})
-	if err != nil || !token.Valid {
-		log.Printf("[ERROR] Token inválido o expirado: %v", err)
+	sesion, nuevoRefresh, err := utils.RotateRefreshToken(refreshToken, "")
+	if err == utils.ErrRefreshReutilizado {
+		log.Printf("[ALERTA] Reutilización de refresh token detectada para %s (familia %s), familia revocada", sesion.Email, sesion.FamiliaID)
+		utils.RegistrarEventoSeguridad("refresh_reutilizado", sesion.Email, c.IP(), map[string]interface{}{"familia": sesion.FamiliaID})
+		clearRefreshCookie(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Refresh token reutilizado, la sesión fue revocada",
+			"from":       "auth-service",
+		})
+	}
+	if err == utils.ErrRefreshInvalido {
+		clearRefreshCookie(c)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"statusCode": fiber.StatusUnauthorized,
 			"intCode":    "A01",
@@ -511,167 +575,204 @@ func RefreshToken(c *fiber.Ctx) error {
 			"from":       "auth-service",
 		})
 	}
-
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		log.Println("[ERROR] Claims no válidos")
+	if err != nil {
+		log.Println("[ERROR] Error rotando refresh token:", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"statusCode": fiber.StatusInternalServerError,
 			"intCode":    "A03",
-			"message":    "Error al procesar los claims del token",
+			"message":    "Error generando token de refresco",
 			"from":       "auth-service",
 		})
 	}
 
-	email, okEmail := claims["email"].(string)
-	rol, okRol := claims["rol"].(string)
-	log.Println("[DEBUG] Claims extraídos - Email:", email, "Rol:", rol)
-
-	if !okEmail || !okRol || email == "" || rol == "" {
-		log.Println("[ERROR] Email o rol inválido en el token")
+	// Se vuelve a cargar la cuenta para no refrescar un rol que la identidad ya no tiene
+	cuenta, err := utils.ObtenerCuenta(sesion.IdentidadID, sesion.Rol)
+	if err == utils.ErrCuentaNoEncontrada {
+		if err := utils.RevokeRefreshFamily(sesion.FamiliaID); err != nil {
+			log.Println("[ERROR] Error revocando familia de refresh tokens:", err)
+		}
+		clearRefreshCookie(c)
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"statusCode": fiber.StatusUnauthorized,
 			"intCode":    "A01",
-			"message":    "Refresh token inválido",
+			"message":    "La cuenta ya no tiene acceso con este rol",
+			"from":       "auth-service",
+		})
+	}
+	var newToken string
+	if err == nil {
+		newToken, err = utils.GenerateJWT(cuenta.Principal())
+	}
+	if err != nil {
+		log.Println("[ERROR] Error generando nuevo token:", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error generando nuevo token",
 			"from":       "auth-service",
 		})
 	}
 
-	var id string
-	err = config.DB.QueryRow(`
-		SELECT id FROM (
-			SELECT id_paciente AS id, correo FROM paciente WHERE correo = $1
-			UNION
-			SELECT id_empleado AS id, correo FROM empleado WHERE correo = $1
-		) AS usuarios LIMIT 1`, email).Scan(&id)
+	setRefreshCookie(c, nuevoRefresh)
 
-	if err != nil || id == "" {
-		log.Println("[ERROR] Usuario no encontrado en DB para el email:", email)
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"statusCode": fiber.StatusUnauthorized,
-			"intCode":    "A01",
-			"message":    "Usuario no encontrado",
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Token refrescado exitosamente",
+		"from":       "auth-service",
+		"data": fiber.Map{
+			"token":        newToken,
+			"tokenType":    "Bearer",
+			"expiresIn":    int(utils.AccessTokenTTL.Seconds()),
+			"refreshToken": nuevoRefresh,
+		},
+	})
+}
+
+// setRefreshCookie guarda el refresh token en una cookie segura
+func setRefreshCookie(c *fiber.Ctx, refreshToken string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Expires:  time.Now().Add(utils.RefreshTokenTTL),
+		HTTPOnly: true,
+		Secure:   config.Actual().Produccion(),
+		SameSite: "Lax",
+		Path:     "/",
+	})
+}
+
+// clearRefreshCookie elimina la cookie del refresh token
+func clearRefreshCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		Secure:   config.Actual().Produccion(),
+		SameSite: "Lax",
+		Path:     "/",
+	})
+}
+
+// Logout revoca el access token actual y la sesión de refresco asociada
+func Logout(c *fiber.Ctx) error {
+	jti, _ := c.Locals("jti").(string)
+	exp, ok := c.Locals("exp").(time.Time)
+	if !ok {
+		exp = time.Now().Add(60 * time.Minute)
+	}
+
+	if err := utils.RevokeAccessToken(jti, exp); err != nil {
+		log.Printf("Error revocando access token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error cerrando sesión",
 			"from":       "auth-service",
 		})
 	}
 
-	log.Println("[DEBUG] Usuario encontrado. ID:", id)
+	refreshToken := c.Cookies("refresh_token")
+	if refreshToken == "" {
+		var input struct {
+			RefreshToken string `json:"refreshToken"`
+		}
+		_ = c.BodyParser(&input)
+		refreshToken = input.RefreshToken
+	}
+	if refreshToken != "" {
+		if err := utils.RevokeRefreshToken(refreshToken); err != nil {
+			log.Printf("Error revocando refresh token: %v", err)
+		}
+	}
+
+	clearRefreshCookie(c)
 
-	newToken, err := utils.GenerateJWT(id, email, rol)
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Sesión cerrada exitosamente",
+		"from":       "auth-service",
+	})
+}
+
+// LogoutAll cierra todas las sesiones del usuario autenticado en todos sus
+// dispositivos y con todos sus roles
+func LogoutAll(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err == nil {
+		err = utils.RevocarSesionesCuenta(cuenta)
+	}
 	if err != nil {
-		log.Println("[ERROR] Error generando nuevo token:", err)
+		log.Printf("Error cerrando todas las sesiones de %v: %v", c.Locals("email"), err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"statusCode": fiber.StatusInternalServerError,
 			"intCode":    "A03",
-			"message":    "Error generando nuevo token",
+			"message":    "Error cerrando las sesiones",
 			"from":       "auth-service",
 		})
 	}
 
-	log.Println("[DEBUG] Nuevo token generado para:", email)
+	clearRefreshCookie(c)
 
 	return c.JSON(fiber.Map{
 		"statusCode": fiber.StatusOK,
 		"intCode":    "S01",
-		"message":    "Token refrescado exitosamente",
+		"message":    "Se cerraron todas las sesiones",
 		"from":       "auth-service",
-		"data": fiber.Map{
-			"token":     newToken,
-			"tokenType": "Bearer",
-			"expiresIn": 1800,
-		},
 	})
 }
 
-// ActivateMFA genera y activa MFA para un usuario autenticado
-/*
-func ActivateMFA(c *fiber.Ctx) error {
-	userToken, ok := c.Locals("user").(*jwt.Token)
-	if !ok || userToken == nil {
+// RevocarSesionesUsuario permite a un administrador cerrar todas las sesiones
+// de otro usuario (baja de un empleado, dispositivo extraviado, etc.)
+func RevocarSesionesUsuario(c *fiber.Ctx) error {
+	var input struct {
+		IDUsuario   string `json:"id_usuario" validate:"required"`
+		TipoUsuario string `json:"tipo_usuario" validate:"required,oneof=paciente empleado"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Datos de entrada inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Validación fallida: " + err.Error(),
+			"from":       "auth-service",
+		})
+	}
+
+	// Se cierran las sesiones de todos los roles de la persona
+	cuenta, err := utils.ObtenerCuentaDeUsuario(input.TipoUsuario, input.IDUsuario)
+	if err == utils.ErrCuentaNoEncontrada {
+		err = utils.RevokeAllSessions(input.IDUsuario, input.TipoUsuario)
+	} else if err == nil {
+		err = utils.RevocarSesionesCuenta(cuenta)
+	}
+	if err != nil {
+		log.Printf("Error revocando sesiones de %s:%s: %v", input.TipoUsuario, input.IDUsuario, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"statusCode": fiber.StatusInternalServerError,
-            "intCode":    "A03",
-            "message":    "Error al obtener información del usuario",
-            "from":       "auth-service",
-        })
-    }
-
-    // Obtener claims del token
-    claims, ok := userToken.Claims.(jwt.MapClaims)
-    if !ok {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode":    "A03",
-            "message":    "Error al procesar los claims del token",
-            "from":       "auth-service",
-        })
-    }
-
-    // Extraer email y rol de los claims
-    email, ok := claims["email"].(string)
-    if !ok || email == "" {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode":    "A03",
-            "message":    "Error al obtener el email del usuario",
-            "from":       "auth-service",
-        })
-    }
-
-    rol, ok := claims["rol"].(string)
-    if !ok || rol == "" {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode":    "A03",
-            "message":    "Error al obtener el rol del usuario",
-            "from":       "auth-service",
-        })
-    }
-
-    // Generar nuevo secreto MFA
-    key, err := totp.Generate(totp.GenerateOpts{
-        Issuer:      "Menchaca System",
-        AccountName: email,
-        SecretSize:  20,
-    })
-    if err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode":    "A03",
-            "message":    "Error generando secreto MFA",
-            "from":       "auth-service",
-        })
-    }
-
-    // Determinar la tabla según el rol
-    tableName := "Paciente"
-    if rol == "empleado" {
-        tableName = "Empleado"
-    }
-
-    // Actualizar el usuario en la base de datos (activando MFA)
-    _, err = config.DB.Exec(
-        fmt.Sprintf("UPDATE %s SET mfa_secret = $1, mfa_enabled = true WHERE correo = $2", tableName),
-        key.Secret(),
-        email,
-    )
-    if err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode":    "A03",
-            "message":    "Error actualizando usuario en la base de datos",
-            "from":       "auth-service",
-        })
-    }
-
-    return c.JSON(fiber.Map{
-        "statusCode": fiber.StatusOK,
-        "intCode":    "S01",
-        "message":    "MFA configurado exitosamente",
-        "from":       "auth-service",
-        "data": fiber.Map{
-            "secret": key.Secret(),
-            "qrUrl":  key.URL(),
-        },
-    })
-}*/
\ No newline at end of file
+			"intCode":    "A03",
+			"message":    "Error revocando las sesiones",
+			"from":       "auth-service",
+		})
+	}
+
+	log.Printf("Sesiones de %s:%s revocadas por %v", input.TipoUsuario, input.IDUsuario, c.Locals("email"))
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Sesiones del usuario revocadas",
+		"from":       "auth-service",
+	})
+}
@@ -0,0 +1,50 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"back-menchaca/routes"
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// expiresIn del login coincide con la vigencia real del access token
+func TestLoginExpiresInDelToken(t *testing.T) {
+	nuevosRepos(t)
+	app := fiber.New()
+	routes.SetupAuthRoutes(app.Group("/api"))
+
+	datos, _ := json.Marshal(map[string]string{"correo": "juan.perez@menchaca.demo", "contrasena": "Menchaca#2025"})
+	req := httptest.NewRequest(fiber.MethodPost, "/api/auth/login", bytes.NewReader(datos))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	res, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cuerpo struct {
+		Data struct {
+			Token     string `json:"token"`
+			ExpiresIn int    `json:"expiresIn"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&cuerpo); err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("login respondió %d", res.StatusCode)
+	}
+
+	claims, err := utils.ParseToken(cuerpo.Data.Token, "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	iat, _ := claims.GetIssuedAt()
+	exp, _ := claims.GetExpirationTime()
+	if vigencia := int(exp.Sub(iat.Time).Seconds()); vigencia != cuerpo.Data.ExpiresIn {
+		t.Errorf("expiresIn = %d, el token vence a los %d segundos", cuerpo.Data.ExpiresIn, vigencia)
+	}
+}
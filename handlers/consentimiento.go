@@ -1,13 +1,22 @@
 package handlers
 
 import (
-	"back-menchaca/config"
+	"back-menchaca/repository"
 	"github.com/gofiber/fiber/v2"
 	"time"
-	"back-menchaca/utils"
 )
 
-func ObtenerAvisoPrivacidad(c *fiber.Ctx) error {
+// ConsentimientoHandler atiende /consentimiento
+type ConsentimientoHandler struct {
+	consentimientos repository.Consentimientos
+	pacientes       repository.Pacientes
+}
+
+func NuevoConsentimientoHandler(consentimientos repository.Consentimientos, pacientes repository.Pacientes) *ConsentimientoHandler {
+	return &ConsentimientoHandler{consentimientos: consentimientos, pacientes: pacientes}
+}
+
+func (h *ConsentimientoHandler) ObtenerAvisoPrivacidad(c *fiber.Ctx) error {
 	aviso := `
 <h2>Aviso de Privacidad</h2>
 <p>Este hospital garantiza la protección de sus datos personales conforme a lo establecido por la Ley Federal de Protección de Datos Personales.</p>
@@ -17,7 +26,7 @@ func ObtenerAvisoPrivacidad(c *fiber.Ctx) error {
 	return c.Type("html").SendString(aviso)
 }
 
-func RegistrarConsentimiento(c *fiber.Ctx) error {
+func (h *ConsentimientoHandler) RegistrarConsentimiento(c *fiber.Ctx) error {
 	var body struct {
 		IDPaciente int `json:"id_paciente"`
 	}
@@ -25,13 +34,11 @@ func RegistrarConsentimiento(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "ID de paciente inválido"})
 	}
 
-	if !utils.ExisteID("Paciente", "id_paciente", body.IDPaciente) {
+	if ok, err := h.pacientes.Existe(body.IDPaciente); err != nil || !ok {
 		return c.Status(404).JSON(fiber.Map{"error": "Paciente no encontrado"})
 	}
 
-	_, err := config.DB.Exec(`INSERT INTO Consentimientos (id_paciente, fecha_hora) VALUES ($1, $2)`,
-		body.IDPaciente, time.Now())
-	if err != nil {
+	if err := h.consentimientos.Registrar(body.IDPaciente, time.Now()); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "Error al registrar consentimiento"})
 	}
 
@@ -1,118 +1,81 @@
 package handlers
 
 import (
-	"back-menchaca/config"
 	"back-menchaca/models"
+	"back-menchaca/repository"
 	"back-menchaca/utils"
-	"database/sql"
+	"errors"
 	 "fmt"
-	 "time"
-	 "log"
+	"strconv"
 	"github.com/gofiber/fiber/v2"
 )
 
 const modConsul = "Consul"
 
-func AgendarConsulta(c *fiber.Ctx) error {
+// ConsultaHandler atiende /consultas
+type ConsultaHandler struct {
+	consultas    repository.Consultas
+	pacientes    repository.Pacientes
+	horarios     repository.Horarios
+	consultorios repository.Consultorios
+}
+
+func NuevoConsultaHandler(consultas repository.Consultas, pacientes repository.Pacientes, horarios repository.Horarios, consultorios repository.Consultorios) *ConsultaHandler {
+	return &ConsultaHandler{consultas: consultas, pacientes: pacientes, horarios: horarios, consultorios: consultorios}
+}
+
+func (h *ConsultaHandler) AgendarConsulta(c *fiber.Ctx) error {
 	var cons models.Consulta
 	if err := c.BodyParser(&cons); err != nil {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "Datos inválidos")
 	}
 
+	// Un paciente solo agenda sus propias consultas; el personal con
+	// solicitar_cita agenda para cualquier paciente
+	if tipo, _ := c.Locals("tipo").(string); tipo == "paciente" {
+		id, _ := c.Locals("id").(string)
+		propio, err := strconv.Atoi(id)
+		if err != nil {
+			return utils.Responder(c, "04", modConsul, "consulta-service", nil)
+		}
+		if cons.IDPaciente == 0 {
+			cons.IDPaciente = propio
+		} else if cons.IDPaciente != propio {
+			return utils.Responder(c, "04", modConsul, "consulta-service", nil, "Solo puedes agendar tus propias consultas")
+		}
+	}
+
 	if err := utils.ValidarConsulta(cons); err != nil {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, err.Error())
 	}
 
-	if !utils.ExisteID("Paciente", "id_paciente", cons.IDPaciente) {
+	if ok, err := h.pacientes.Existe(cons.IDPaciente); err != nil || !ok {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "ID de paciente no válido")
 	}
-	if !utils.ExisteID("Horarios", "id_horario", cons.IDHorario) {
+	if ok, err := h.horarios.Existe(cons.IDHorario); err != nil || !ok {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "ID de horario no válido")
 	}
-	if !utils.ExisteID("Consultorios", "id_consultorio", cons.IDConsultorio) {
+	if ok, err := h.consultorios.Existe(cons.IDConsultorio); err != nil || !ok {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "ID de consultorio no válido")
 	}
 
-	err := config.DB.QueryRow(`
-		INSERT INTO Consultas (id_paciente, tipo, id_receta, id_horario, id_consultorio, diagnostico, costo, fecha_hora)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id_consulta`,
-		cons.IDPaciente, cons.Tipo, cons.IDReceta, cons.IDHorario, cons.IDConsultorio, cons.Diagnostico, cons.Costo, cons.FechaHora,
-	).Scan(&cons.ID)
-
-	if err != nil {
+	if err := h.consultas.Crear(&cons); err != nil {
 		return utils.Responder(c, "06", modConsul, "consulta-service", nil, "Error al agendar consulta: "+err.Error())
 	}
 
 	return utils.Responder(c, "01", modConsul, "consulta-service", cons)
 }
 
-func ObtenerConsultas(c *fiber.Ctx) error {
-	rows, err := config.DB.Query(`
-		SELECT 
-			c.id_consulta,
-			p.nombre AS nombre_paciente, p.appaterno AS app_paterno_paciente, p.apmaterno AS ap_materno_paciente,
-			r.fecha AS fecha_receta, r.medicamento, r.dosis,
-			h.turno,
-			e.nombre AS nombre_empleado, e.appaterno AS app_paterno_empleado, e.apmaterno AS ap_materno_empleado, e.area AS area_empleado,
-			co.tipo AS tipo_consultorio, co.nombre AS nombre_consultorio,
-			c.tipo, c.diagnostico, c.costo, c.fecha_hora
-		FROM Consultas c
-		LEFT JOIN Paciente p ON c.id_paciente = p.id_paciente
-		LEFT JOIN Recetas r ON c.id_receta = r.id_receta
-		LEFT JOIN Horarios h ON c.id_horario = h.id_horario
-		LEFT JOIN Empleado e ON h.id_empleado = e.id_empleado
-		LEFT JOIN Consultorios co ON c.id_consultorio = co.id_consultorio
-
-	`)
+func (h *ConsultaHandler) ObtenerConsultas(c *fiber.Ctx) error {
+	consultas, err := h.consultas.Listar()
 	if err != nil {
 		return utils.Responder(c, "06", modConsul, "consulta-service", nil, "Error al obtener consultas")
 	}
-	defer rows.Close()
-
-	type ConsultaDetallada struct {
-		IDConsulta     int        `json:"id_consulta"`
-		NombrePaciente string     `json:"nombre_paciente"`
-		AppPaterno     string     `json:"appaterno_paciente"`
-		AppMaterno     string     `json:"apmaterno_paciente"`
-		FechaReceta    sql.NullTime   `json:"fecha_receta"`
-		Medicamento    sql.NullString `json:"medicamento"`
-		Dosis          sql.NullString `json:"dosis"`
-		Turno          string     `json:"turno"`
-		EmpleadoNombre string     `json:"nombre_empleado"`
-		EmpleadoAppPat string     `json:"appaterno_empleado"`
-		EmpleadoAppMat string     `json:"apmaterno_empleado"`
-		AreaEmpleado   string     `json:"area_empleado"`
-		TipoConsul     string     `json:"tipo_consultorio"`
-		NombreConsul   string     `json:"nombre_consultorio"`
-		TipoConsulta   string     `json:"tipo"`
-		Diagnostico    sql.NullString     `json:"diagnostico"`
-		Costo          sql.NullFloat64    `json:"costo"`
-		FechaHora      *time.Time `json:"fecha_hora"`      // CAMBIO: *time.Time
-	}
-
-
-	var consultas []ConsultaDetallada
-	for rows.Next() {
-	var cons ConsultaDetallada
-		if err := rows.Scan(
-			&cons.IDConsulta,
-			&cons.NombrePaciente, &cons.AppPaterno, &cons.AppMaterno,
-			&cons.FechaReceta, &cons.Medicamento, &cons.Dosis,
-			&cons.Turno,
-			&cons.EmpleadoNombre, &cons.EmpleadoAppPat, &cons.EmpleadoAppMat, &cons.AreaEmpleado,
-			&cons.TipoConsul, &cons.NombreConsul,
-			&cons.TipoConsulta, &cons.Diagnostico, &cons.Costo, &cons.FechaHora,
-		); err != nil {
-			log.Printf("❌ Error en rows.Scan: %v", err)
-		} else {
-			consultas = append(consultas, cons)
-		}
-	}
 
 	return utils.Responder(c, "01", modConsul, "consulta-service", consultas)
 }
 
-func ObtenerConsultasPorEmpleado(c *fiber.Ctx) error {
+func (h *ConsultaHandler) ObtenerConsultasPorEmpleado(c *fiber.Ctx) error {
 	// 1. Leer el ID del empleado desde el body
 	var body struct {
 		IDEmpleado int `json:"id_empleado" validate:"required"`
@@ -121,77 +84,18 @@ func ObtenerConsultasPorEmpleado(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "JSON inválido")
 	}
 
-	// 2. Ejecutar consulta filtrando por id_empleado
-	rows, err := config.DB.Query(`
-		SELECT 
-			c.id_consulta,
-			co.id_consultorio,
-			p.nombre AS nombre_paciente, p.appaterno AS app_paterno_paciente, p.apmaterno AS ap_materno_paciente,
-			r.fecha AS fecha_receta, r.medicamento, r.dosis,
-			h.turno,
-			e.nombre AS nombre_empleado, e.appaterno AS app_paterno_empleado, e.apmaterno AS ap_materno_empleado, e.area AS area_empleado,
-			co.tipo AS tipo_consultorio, co.nombre AS nombre_consultorio,
-			c.tipo, c.diagnostico, c.costo, c.fecha_hora
-		FROM Consultas c
-		LEFT JOIN Paciente p ON c.id_paciente = p.id_paciente
-		LEFT JOIN Recetas r ON c.id_receta = r.id_receta
-		LEFT JOIN Horarios h ON c.id_horario = h.id_horario
-		LEFT JOIN Empleado e ON h.id_empleado = e.id_empleado
-		LEFT JOIN Consultorios co ON c.id_consultorio = co.id_consultorio
-		WHERE e.id_empleado = $1
-	`, body.IDEmpleado)
+	// 2. Buscar las consultas de los horarios del empleado
+	consultas, err := h.consultas.ListarPorEmpleado(body.IDEmpleado)
 	if err != nil {
 		return utils.Responder(c, "06", modConsul, "consulta-service", nil, "Error al obtener consultas")
 	}
-	defer rows.Close()
-
-	type ConsultaDetallada struct {
-		IDConsulta     int             `json:"id_consulta"`
-		IDConsultorio  int             `json:"id_consultorio"`
-		NombrePaciente string          `json:"nombre_paciente"`
-		AppPaterno     string          `json:"appaterno_paciente"`
-		AppMaterno     string          `json:"apmaterno_paciente"`
-		FechaReceta    sql.NullTime    `json:"fecha_receta"`
-		Medicamento    sql.NullString  `json:"medicamento"`
-		Dosis          sql.NullString  `json:"dosis"`
-		Turno          string          `json:"turno"`
-		EmpleadoNombre string          `json:"nombre_empleado"`
-		EmpleadoAppPat string          `json:"appaterno_empleado"`
-		EmpleadoAppMat string          `json:"apmaterno_empleado"`
-		AreaEmpleado   string          `json:"area_empleado"`
-		TipoConsul     string          `json:"tipo_consultorio"`
-		NombreConsul   string          `json:"nombre_consultorio"`
-		TipoConsulta   string          `json:"tipo"`
-		Diagnostico    sql.NullString  `json:"diagnostico"`
-		Costo          sql.NullFloat64 `json:"costo"`
-		FechaHora      *time.Time      `json:"fecha_hora"`
-	}
-
-	var consultas []ConsultaDetallada
-	for rows.Next() {
-		var cons ConsultaDetallada
-		if err := rows.Scan(
-			&cons.IDConsulta,
-			&cons.IDConsultorio,
-			&cons.NombrePaciente, &cons.AppPaterno, &cons.AppMaterno,
-			&cons.FechaReceta, &cons.Medicamento, &cons.Dosis,
-			&cons.Turno,
-			&cons.EmpleadoNombre, &cons.EmpleadoAppPat, &cons.EmpleadoAppMat, &cons.AreaEmpleado,
-			&cons.TipoConsul, &cons.NombreConsul,
-			&cons.TipoConsulta, &cons.Diagnostico, &cons.Costo, &cons.FechaHora,
-		); err != nil {
-			log.Printf("❌ Error en rows.Scan: %v", err)
-		} else {
-			consultas = append(consultas, cons)
-		}
-	}
 
 	return utils.Responder(c, "01", modConsul, "consulta-service", consultas)
 }
 
 
 
-func ObtenerConsultaPorID(c *fiber.Ctx) error {
+func (h *ConsultaHandler) ObtenerConsultaPorID(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_consulta"`
 	}
@@ -199,13 +103,8 @@ func ObtenerConsultaPorID(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "ID inválido")
 	}
 
-	var cons models.Consulta
-	err := config.DB.QueryRow(
-		`SELECT id_consulta, id_paciente, tipo, id_receta, id_horario, id_consultorio, diagnostico, costo, fecha_hora
-		 FROM Consultas WHERE id_consulta = $1`, body.ID,
-	).Scan(&cons.ID, &cons.IDPaciente, &cons.Tipo, &cons.IDReceta, &cons.IDHorario, &cons.IDConsultorio, &cons.Diagnostico, &cons.Costo, &cons.FechaHora)
-
-	if err == sql.ErrNoRows {
+	cons, err := h.consultas.Obtener(body.ID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
 		return utils.Responder(c, "05", modConsul, "consulta-service", nil, "Consulta no encontrada")
 	} else if err != nil {
 		return utils.Responder(c, "06", modConsul, "consulta-service", nil, "Error al buscar consulta")
@@ -214,24 +113,30 @@ func ObtenerConsultaPorID(c *fiber.Ctx) error {
 	return utils.Responder(c, "01", modConsul, "consulta-service", cons)
 }
 
-func ActualizarConsulta(c *fiber.Ctx) error {
+func (h *ConsultaHandler) ActualizarConsulta(c *fiber.Ctx) error {
 	var cons models.Consulta
 	if err := c.BodyParser(&cons); err != nil || cons.ID == 0 {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "Datos inválidos")
 	}
 
-	var actual models.Consulta
-	err := config.DB.QueryRow(`SELECT id_paciente, tipo, id_receta, id_horario, id_consultorio, diagnostico, costo, fecha_hora FROM Consultas WHERE id_consulta=$1`, cons.ID).
-		Scan(&actual.IDPaciente, &actual.Tipo, &actual.IDReceta, &actual.IDHorario, &actual.IDConsultorio, &actual.Diagnostico, &actual.Costo, &actual.FechaHora)
-	
-	if err == sql.ErrNoRows {
+	actual, err := h.consultas.Obtener(cons.ID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
 		return utils.Responder(c, "05", modConsul, "consulta-service", nil, "Consulta no encontrada")
 	} else if err != nil {
 		return utils.Responder(c, "06", modConsul, "consulta-service", nil, "Error al buscar consulta")
 	}
 
+	// AccesoPaciente revisó el alcance sobre el paciente actual: la consulta
+	// no puede pasar a otro paciente ni tomar la receta de otro
 	if cons.IDPaciente == 0 {
 		cons.IDPaciente = actual.IDPaciente
+	} else if cons.IDPaciente != actual.IDPaciente {
+		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "No se puede cambiar el paciente de la consulta")
+	}
+	if cons.IDReceta != nil && (actual.IDReceta == nil || *cons.IDReceta != *actual.IDReceta) {
+		if p, err := utils.PacienteDeReceta(*cons.IDReceta); err == nil && p != actual.IDPaciente {
+			return utils.Responder(c, "02", modConsul, "consulta-service", nil, "La receta pertenece a otro paciente")
+		}
 	}
 	if cons.Tipo == "" {
 		cons.Tipo = actual.Tipo
@@ -255,17 +160,14 @@ func ActualizarConsulta(c *fiber.Ctx) error {
 		cons.FechaHora = actual.FechaHora
 	}
 
-	_, err = config.DB.Exec(`UPDATE Consultas SET id_paciente=$1, tipo=$2, id_receta=$3, id_horario=$4, id_consultorio=$5, diagnostico=$6, costo=$7, fecha_hora=$8 WHERE id_consulta=$9`,
-		cons.IDPaciente, cons.Tipo, cons.IDReceta, cons.IDHorario, cons.IDConsultorio, cons.Diagnostico, cons.Costo, cons.FechaHora, cons.ID,
-	)
-	if err != nil {
+	if err := h.consultas.Actualizar(cons); err != nil {
 		return utils.Responder(c, "06", modConsul, "consulta-service", nil, "Error al actualizar consulta")
 	}
 
 	return utils.Responder(c, "01", modConsul, "consulta-service", fiber.Map{"mensaje": "Consulta actualizada"})
 }
 
-func EliminarConsulta(c *fiber.Ctx) error {
+func (h *ConsultaHandler) EliminarConsulta(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_consulta"`
 	}
@@ -273,15 +175,14 @@ func EliminarConsulta(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modConsul, "consulta-service", nil, "ID inválido")
 	}
 
-	_, err := config.DB.Exec("DELETE FROM Consultas WHERE id_consulta=$1", body.ID)
-	if err != nil {
+	if err := h.consultas.Eliminar(body.ID); err != nil {
 		return utils.Responder(c, "06", modConsul, "consulta-service", nil, "Error al eliminar consulta")
 	}
 	return utils.Responder(c, "01", modConsul, "consulta-service", fiber.Map{"mensaje": "Consulta eliminada"})
 }
 
 
-func ObtenerConsultasPaciente(c *fiber.Ctx) error {
+func (h *ConsultaHandler) ObtenerConsultasPaciente(c *fiber.Ctx) error {
     var reqBody struct {
         IdPaciente int `json:"id_paciente"`
     }
@@ -304,88 +205,16 @@ func ObtenerConsultasPaciente(c *fiber.Ctx) error {
         })
     }
 
-    // Ejecutar consulta SQL
-    rows, err := config.DB.Query(`
-        SELECT 
-            id_consulta, 
-            id_paciente, 
-            tipo, 
-            id_receta, 
-            id_horario, 
-            id_consultorio, 
-            diagnostico, 
-            costo, 
-            fecha_hora 
-        FROM Consultas 
-        WHERE id_paciente = $1`, 
-        reqBody.IdPaciente)
+    // Buscar las consultas del paciente
+    consultas, err := h.consultas.ListarPorPaciente(reqBody.IdPaciente)
     if err != nil {
-        fmt.Println("Error en consulta SQL:", err)
+        fmt.Println("Error al obtener consultas:", err)
         return c.Status(500).JSON(fiber.Map{
             "statusCode": 500,
             "message":    "Error al obtener consultas",
             "error":      err.Error(),
         })
     }
-    defer rows.Close()
-
-    var consultas []models.Consulta
-    for rows.Next() {
-        var cons models.Consulta
-        var (
-            diagnostico sql.NullString
-            idReceta    sql.NullInt64
-            costo       sql.NullFloat64
-        )
-        
-        // Escanear los valores, incluyendo los que pueden ser NULL
-        if err := rows.Scan(
-            &cons.ID,
-            &cons.IDPaciente,
-            &cons.Tipo,
-            &idReceta,
-            &cons.IDHorario,
-            &cons.IDConsultorio,
-            &diagnostico,
-            &costo,
-            &cons.FechaHora,
-        ); err != nil {
-            fmt.Println("Error al escanear fila:", err)
-            continue
-        }
-        
-        // Asignar valores NULLables con sus valores por defecto
-        if diagnostico.Valid {
-            cons.Diagnostico = diagnostico.String
-        } else {
-            cons.Diagnostico = "" // Valor por defecto para string
-        }
-        
-        if idReceta.Valid {
-            val := int(idReceta.Int64)
-            cons.IDReceta = &val
-        } else {
-            cons.IDReceta = nil // Valor por defecto para puntero
-        }
-        
-        if costo.Valid {
-            cons.Costo = costo.Float64
-        } else {
-            cons.Costo = 0.0 // Valor por defecto para float
-        }
-        
-        consultas = append(consultas, cons)
-    }
-
-    // Verificar si hubo errores después de iterar
-    if err = rows.Err(); err != nil {
-        fmt.Println("Error después de iterar filas:", err)
-        return c.Status(500).JSON(fiber.Map{
-            "statusCode": 500,
-            "message":    "Error al procesar resultados",
-            "error":      err.Error(),
-        })
-    }
 
     return c.Status(200).JSON(fiber.Map{
         "data":       consultas,
@@ -0,0 +1,96 @@
+package handlers_test
+
+import (
+	"testing"
+	"time"
+
+	"back-menchaca/handlers"
+	"back-menchaca/models"
+	"back-menchaca/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func appConsultas(t *testing.T) (*fiber.App, func() int) {
+	t.Helper()
+	repos := nuevosRepos(t)
+	app := fiber.New()
+	routes.ConsultasRoutes(app.Group("/api"),
+		handlers.NuevoConsultaHandler(repos.Consultas, repos.Pacientes, repos.Horarios, repos.Consultorios))
+	total := func() int {
+		consultas, err := repos.Consultas.Listar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(consultas)
+	}
+	return app, total
+}
+
+// Juan (paciente 1) no puede agendar a nombre de María (paciente 2)
+func TestAgendarConsultaDeOtroPaciente(t *testing.T) {
+	app, total := appConsultas(t)
+	token := tokenDe(t, "juan.perez@menchaca.demo")
+
+	cita := models.Consulta{IDPaciente: 2, Tipo: "general", IDHorario: 1, IDConsultorio: 1,
+		FechaHora: time.Now().Add(48 * time.Hour)}
+	r := solicitar(t, app, fiber.MethodPost, "/api/consultas/", token, cita)
+	if r.StatusCode != fiber.StatusForbidden {
+		t.Errorf("agendar para otro paciente: %d %q, se esperaba 403", r.StatusCode, r.Message)
+	}
+	if n := total(); n != 3 {
+		t.Fatalf("hay %d consultas, se esperaban las 3 de ejemplo", n)
+	}
+
+	// Sin id_paciente la consulta queda a su nombre
+	cita.IDPaciente = 0
+	if r := solicitar(t, app, fiber.MethodPost, "/api/consultas/", token, cita); r.StatusCode != fiber.StatusOK {
+		t.Errorf("agendar su propia consulta: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+	if n := total(); n != 4 {
+		t.Errorf("hay %d consultas, se esperaba una nueva", n)
+	}
+}
+
+// El personal con solicitar_cita agenda para cualquier paciente
+func TestAgendarConsultaPersonal(t *testing.T) {
+	app, total := appConsultas(t)
+
+	cita := models.Consulta{IDPaciente: 3, Tipo: "general", IDHorario: 1, IDConsultorio: 1,
+		FechaHora: time.Now().Add(48 * time.Hour)}
+	r := solicitar(t, app, fiber.MethodPost, "/api/consultas/", tokenDe(t, "ana.lopez@menchaca.demo"), cita)
+	if r.StatusCode != fiber.StatusOK {
+		t.Errorf("agendar como enfermera: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+	if n := total(); n != 4 {
+		t.Errorf("hay %d consultas, se esperaba una nueva", n)
+	}
+}
+
+// Carlos (Pediatría) no atiende a Juan (paciente 1) y Laura (Medicina general)
+// sí, pero no puede pasar su consulta a otro paciente
+func TestActualizarConsultaFueraDeAlcance(t *testing.T) {
+	app, total := appConsultas(t)
+	carlos := tokenDe(t, "carlos.ramirez@menchaca.demo")
+	laura := tokenDe(t, "laura.garcia@menchaca.demo")
+
+	cambio := map[string]interface{}{"id_consulta": 1, "diagnostico": "Alta"}
+	if r := solicitar(t, app, fiber.MethodPut, "/api/consultas/update", carlos, cambio); r.StatusCode != fiber.StatusForbidden {
+		t.Errorf("actualizar fuera del área: %d %q, se esperaba 403", r.StatusCode, r.Message)
+	}
+	if r := solicitar(t, app, fiber.MethodDelete, "/api/consultas/delete", carlos, cambio); r.StatusCode != fiber.StatusForbidden {
+		t.Errorf("eliminar fuera del área: %d %q, se esperaba 403", r.StatusCode, r.Message)
+	}
+	if n := total(); n != 3 {
+		t.Fatalf("hay %d consultas, se esperaban las 3 de ejemplo", n)
+	}
+
+	cambio["id_paciente"] = 2
+	if r := solicitar(t, app, fiber.MethodPut, "/api/consultas/update", laura, cambio); r.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("pasar la consulta a otro paciente: %d %q, se esperaba 400", r.StatusCode, r.Message)
+	}
+	delete(cambio, "id_paciente")
+	if r := solicitar(t, app, fiber.MethodPut, "/api/consultas/update", laura, cambio); r.StatusCode != fiber.StatusOK {
+		t.Errorf("actualizar en su área: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+}
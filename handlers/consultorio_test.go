@@ -0,0 +1,37 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"back-menchaca/handlers"
+	"back-menchaca/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Un consultorio con horarios, recetas o consultas no se puede eliminar (las
+// llaves foráneas no tienen ON DELETE); uno sin referencias sí
+func TestEliminarConsultorioReferenciado(t *testing.T) {
+	repos := nuevosRepos(t)
+	h := handlers.NuevoConsultorioHandler(repos.Consultorios)
+
+	r := enviar(t, fiber.MethodDelete, h.EliminarConsultorio, map[string]int{"id_consultorio": 1})
+	if r.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("eliminar consultorio referenciado: %d %q, se esperaba 500", r.StatusCode, r.Message)
+	}
+	if ok, err := repos.Consultorios.Existe(1); err != nil || !ok {
+		t.Errorf("el consultorio referenciado se eliminó (existe=%v, err=%v)", ok, err)
+	}
+
+	libre := models.Consultorio{Nombre: "Consultorio 4", Tipo: "Dermatología"}
+	if err := repos.Consultorios.Crear(&libre); err != nil {
+		t.Fatal(err)
+	}
+	r = enviar(t, fiber.MethodDelete, h.EliminarConsultorio, map[string]int{"id_consultorio": libre.ID})
+	if r.StatusCode != fiber.StatusOK {
+		t.Errorf("eliminar consultorio sin referencias: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+	if ok, err := repos.Consultorios.Existe(libre.ID); err != nil || ok {
+		t.Errorf("el consultorio sin referencias sigue existiendo (existe=%v, err=%v)", ok, err)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"back-menchaca/config"
+	"back-menchaca/mail"
+	"back-menchaca/utils"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const modEmg = "EMG"
+
+// principalActual arma el principal con los datos que dejó el middleware JWT
+func principalActual(c *fiber.Ctx) utils.Principal {
+	p := utils.Principal{}
+	p.IdentidadID, _ = c.Locals("identidad").(string)
+	p.ID, _ = c.Locals("id").(string)
+	p.Tipo, _ = c.Locals("tipo").(string)
+	p.Correo, _ = c.Locals("email").(string)
+	p.Rol, _ = c.Locals("rol").(string)
+	return p
+}
+
+// SolicitarAccesoEmergencia otorga acceso temporal a los registros de un paciente
+// con una justificación obligatoria y avisa al oficial de cumplimiento
+func SolicitarAccesoEmergencia(c *fiber.Ctx) error {
+	var input struct {
+		IDPaciente    int    `json:"id_paciente" validate:"required,gt=0"`
+		Justificacion string `json:"justificacion" validate:"required,min=20,max=1000"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", modEmg, "emergencia-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", modEmg, "emergencia-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	p := principalActual(c)
+	if p.Tipo != "empleado" {
+		return utils.Responder(c, "04", modEmg, "emergencia-service", nil, "Solo el personal puede solicitar acceso de emergencia")
+	}
+	if !utils.ExisteID("Paciente", "id_paciente", input.IDPaciente) {
+		return utils.Responder(c, "05", modEmg, "emergencia-service", nil, "Paciente no encontrado")
+	}
+
+	acceso, err := utils.OtorgarAccesoEmergencia(p, input.IDPaciente, utils.SanitizarInput(input.Justificacion), c.IP())
+	if err != nil {
+		log.Println("Error otorgando acceso de emergencia:", err)
+		return utils.Responder(c, "06", modEmg, "emergencia-service", nil)
+	}
+
+	log.Printf("[ALERTA] Acceso de emergencia de %s al paciente %d", p.Correo, input.IDPaciente)
+	utils.RegistrarEventoSeguridad("acceso_emergencia", p.Correo, c.IP(), map[string]interface{}{
+		"acceso":      acceso.ID,
+		"id_paciente": acceso.IDPaciente,
+	})
+	go notificarAccesoEmergencia(*acceso)
+
+	return utils.Responder(c, "01", modEmg, "emergencia-service", acceso,
+		"Acceso de emergencia otorgado. Quedó registrado y será revisado por un supervisor.")
+}
+
+// notificarAccesoEmergencia envía el aviso al oficial de cumplimiento (COMPLIANCE_EMAIL)
+func notificarAccesoEmergencia(a utils.AccesoEmergencia) {
+	destino := config.Actual().Seguridad.ComplianceEmail
+	if destino == "" {
+		log.Println("⚠️ COMPLIANCE_EMAIL no configurado, no se notificó el acceso de emergencia", a.ID)
+		return
+	}
+
+	err := mail.Enviar(mail.Mensaje{
+		Para:   destino,
+		Asunto: "Acceso de emergencia a expediente",
+		Cuerpo: fmt.Sprintf("Se otorgó un acceso de emergencia.\n\n"+
+			"Empleado: %s (%s, id %s)\nPaciente: %d\nVigente hasta: %s\nIP: %s\n\nJustificación:\n%s\n\n"+
+			"El acceso queda pendiente de revisión en /api/emergencia/revision (id %s).",
+			a.Correo, a.Rol, a.IDEmpleado, a.IDPaciente, a.ExpiraEn.Format("2006-01-02 15:04"), a.IP, a.Justificacion, a.ID),
+	})
+	if err != nil {
+		log.Println("Error notificando acceso de emergencia:", err)
+	}
+}
+
+// ListarMisAccesosEmergencia devuelve los accesos de emergencia vigentes del empleado
+func ListarMisAccesosEmergencia(c *fiber.Ctx) error {
+	accesos, err := utils.ListarAccesosEmergenciaEmpleado(principalActual(c).ID)
+	if err != nil {
+		log.Println("Error listando accesos de emergencia:", err)
+		return utils.Responder(c, "06", modEmg, "emergencia-service", nil)
+	}
+	return utils.Responder(c, "01", modEmg, "emergencia-service", accesos)
+}
+
+// ListarRevisionEmergencia devuelve la cola de revisión (?todos=true incluye los ya revisados)
+func ListarRevisionEmergencia(c *fiber.Ctx) error {
+	limite, err := strconv.Atoi(c.Query("limite", "100"))
+	if err != nil || limite <= 0 || limite > 1000 {
+		limite = 100
+	}
+
+	accesos, err := utils.ListarAccesosEmergenciaRevision(c.Query("todos") != "true", limite)
+	if err != nil {
+		log.Println("Error listando cola de revisión de emergencias:", err)
+		return utils.Responder(c, "06", modEmg, "emergencia-service", nil)
+	}
+	return utils.Responder(c, "01", modEmg, "emergencia-service", accesos)
+}
+
+// RevisarAccesoEmergencia registra la revisión del supervisor; puede además revocar el acceso
+func RevisarAccesoEmergencia(c *fiber.Ctx) error {
+	var input struct {
+		Comentario string `json:"comentario" validate:"required,max=1000"`
+		Revocar    bool   `json:"revocar"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", modEmg, "emergencia-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", modEmg, "emergencia-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	supervisor := principalActual(c)
+	acceso, err := utils.RevisarAccesoEmergencia(c.Params("id"), supervisor, utils.SanitizarInput(input.Comentario), input.Revocar)
+	switch err {
+	case nil:
+	case utils.ErrAccesoEmergenciaNoEncontrado:
+		return utils.Responder(c, "05", modEmg, "emergencia-service", nil, "Acceso de emergencia no encontrado")
+	case utils.ErrAccesoEmergenciaPropio:
+		return utils.Responder(c, "04", modEmg, "emergencia-service", nil, err.Error())
+	default:
+		log.Println("Error revisando acceso de emergencia:", err)
+		return utils.Responder(c, "06", modEmg, "emergencia-service", nil)
+	}
+
+	utils.RegistrarEventoSeguridad("acceso_emergencia_revisado", supervisor.Correo, c.IP(), map[string]interface{}{
+		"acceso":  acceso.ID,
+		"revocar": input.Revocar,
+	})
+	return utils.Responder(c, "01", modEmg, "emergencia-service", acceso, "Acceso de emergencia revisado")
+}
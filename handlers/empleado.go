@@ -1,17 +1,28 @@
 package handlers
 
 import (
-	"database/sql"
-
+	"errors"
+	"log"
+	"strconv"
 	"strings"
 	"github.com/gofiber/fiber/v2"
-	"back-menchaca/config"
 	"back-menchaca/models"
+	"back-menchaca/repository"
 	"back-menchaca/utils"
 )
 
 const modEmpl = "EMPL"
-func CrearEmpleado(c *fiber.Ctx) error {
+
+// EmpleadoHandler atiende /empleados
+type EmpleadoHandler struct {
+	empleados repository.Empleados
+}
+
+func NuevoEmpleadoHandler(empleados repository.Empleados) *EmpleadoHandler {
+	return &EmpleadoHandler{empleados: empleados}
+}
+
+func (h *EmpleadoHandler) CrearEmpleado(c *fiber.Ctx) error {
 	var e models.Empleado
 
 	if err := c.BodyParser(&e); err != nil {
@@ -21,6 +32,11 @@ func CrearEmpleado(c *fiber.Ctx) error {
 	if err := utils.ValidarEmpleado(e.Nombre, e.Appaterno, e.Tipo, e.Area, e.Correo, e.Contrasena); err != nil {
 		return utils.Responder(c, "02", modEmpl, "empleado-service", nil, err.Error())
 	}
+	if err := utils.ValidarTipoEmpleado(e.Tipo); errors.Is(err, utils.ErrTipoEmpleadoInvalido) {
+		return utils.Responder(c, "02", modEmpl, "empleado-service", nil, err.Error())
+	} else if err != nil {
+		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al verificar el tipo de empleado")
+	}
 
 	e.Nombre = utils.SanitizarInput(e.Nombre)
 	e.Appaterno = utils.SanitizarInput(e.Appaterno)
@@ -29,26 +45,32 @@ func CrearEmpleado(c *fiber.Ctx) error {
 	e.Area = utils.SanitizarInput(e.Area)
 	e.Correo = utils.SanitizarInput(strings.ToLower(e.Correo))
 
-	var count int
-	err := config.DB.QueryRow("SELECT COUNT(*) FROM Empleado WHERE correo = $1", e.Correo).Scan(&count)
-	if err != nil {
+	// Si el correo ya tiene cuenta (p. ej. un paciente que ahora es empleado) se
+	// vincula solo si se pide explícitamente y se confirma su contraseña
+	existente, err := utils.BuscarCuentaPorCorreo(e.Correo)
+	if err != nil && err != utils.ErrCuentaNoEncontrada {
 		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al verificar correo")
 	}
-	if count > 0 {
-		return utils.Responder(c, "07", modEmpl, "empleado-service", nil, "El correo ya está registrado")
+	if existente != nil {
+		if existente.EsPersonal() || !e.VincularIdentidad || !utils.CheckPasswordHash(e.Contrasena, existente.Hash) {
+			return utils.Responder(c, "07", modEmpl, "empleado-service", nil, "El correo ya está registrado")
+		}
 	}
 
-	hashed, err := utils.HashPassword(e.Contrasena)
-	if err != nil {
-		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al encriptar contraseña")
+	var hashed string
+	if existente == nil {
+		hashed, err = utils.HashPassword(e.Contrasena)
+		if err != nil {
+			return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al encriptar contraseña")
+		}
 	}
 
-	err = config.DB.QueryRow(
-		`INSERT INTO Empleado (nombre, appaterno, apmaterno, tipo_empleado, area, correo, contraseña)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id_empleado`,
-		e.Nombre, e.Appaterno, e.Apmaterno, e.Tipo, e.Area, e.Correo, hashed,
-	).Scan(&e.ID)
-	if err != nil {
+	// Las cuentas del personal las crea un administrador, el correo se da por verificado
+	alta := repository.AltaIdentidad{Correo: e.Correo, Hash: hashed, Verificado: true}
+	if existente != nil {
+		alta.IdentidadID = existente.IdentidadID
+	}
+	if _, err := h.empleados.Crear(&e, alta); err != nil {
 		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al registrar empleado: "+err.Error())
 	}
 
@@ -56,24 +78,15 @@ func CrearEmpleado(c *fiber.Ctx) error {
 	return utils.Responder(c, "01", modEmpl, "empleado-service", e)
 }
 
-func ObtenerEmpleados(c *fiber.Ctx) error {
-	rows, err := config.DB.Query("SELECT id_empleado, nombre, appaterno, apmaterno, tipo_empleado, area, correo FROM Empleado")
+func (h *EmpleadoHandler) ObtenerEmpleados(c *fiber.Ctx) error {
+	empleados, err := h.empleados.Listar()
 	if err != nil {
 		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al obtener empleados")
 	}
-	defer rows.Close()
-
-	empleados := []models.Empleado{}
-	for rows.Next() {
-		var e models.Empleado
-		if err := rows.Scan(&e.ID, &e.Nombre, &e.Appaterno, &e.Apmaterno, &e.Tipo, &e.Area, &e.Correo); err == nil {
-			empleados = append(empleados, e)
-		}
-	}
 	return utils.Responder(c, "01", modEmpl, "empleado-service", empleados)
 }
 
-func ObtenerEmpleadoPorID(c *fiber.Ctx) error {
+func (h *EmpleadoHandler) ObtenerEmpleadoPorID(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_empleado"`
 	}
@@ -81,14 +94,9 @@ func ObtenerEmpleadoPorID(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modEmpl, "empleado-service", nil, "ID inválido")
 	}
 
-	var e models.Empleado
-	err := config.DB.QueryRow(
-		"SELECT id_empleado, nombre, appaterno, apmaterno, tipo_empleado, area, correo FROM Empleado WHERE id_empleado = $1",
-		body.ID,
-	).Scan(&e.ID, &e.Nombre, &e.Appaterno, &e.Apmaterno, &e.Tipo, &e.Area, &e.Correo)
-
+	e, err := h.empleados.Obtener(body.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNoEncontrado) {
 			return utils.Responder(c, "05", modEmpl, "empleado-service", nil, "Empleado no encontrado")
 		}
 		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al buscar empleado")
@@ -96,16 +104,13 @@ func ObtenerEmpleadoPorID(c *fiber.Ctx) error {
 	return utils.Responder(c, "01", modEmpl, "empleado-service", e)
 }
 
-func ActualizarEmpleado(c *fiber.Ctx) error {
+func (h *EmpleadoHandler) ActualizarEmpleado(c *fiber.Ctx) error {
 	var e models.Empleado
 	if err := c.BodyParser(&e); err != nil || e.ID == 0 {
 		return utils.Responder(c, "02", modEmpl, "empleado-service", nil, "Datos inválidos")
 	}
 
-	var current models.Empleado
-	err := config.DB.QueryRow(
-		"SELECT nombre, appaterno, apmaterno, tipo_empleado, area, correo FROM Empleado WHERE id_empleado = $1", e.ID,
-	).Scan(&current.Nombre, &current.Appaterno, &current.Apmaterno, &current.Tipo, &current.Area, &current.Correo)
+	current, err := h.empleados.Obtener(e.ID)
 	if err != nil {
 		return utils.Responder(c, "05", modEmpl, "empleado-service", nil, "Empleado no encontrado")
 	}
@@ -121,6 +126,10 @@ func ActualizarEmpleado(c *fiber.Ctx) error {
 	}
 	if e.Tipo == "" {
 		e.Tipo = current.Tipo
+	} else if err := utils.ValidarTipoEmpleado(e.Tipo); errors.Is(err, utils.ErrTipoEmpleadoInvalido) {
+		return utils.Responder(c, "02", modEmpl, "empleado-service", nil, err.Error())
+	} else if err != nil {
+		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al verificar el tipo de empleado")
 	}
 	if e.Area == "" {
 		e.Area = current.Area
@@ -136,19 +145,23 @@ func ActualizarEmpleado(c *fiber.Ctx) error {
 	e.Area = utils.SanitizarInput(e.Area)
 	e.Correo = utils.SanitizarInput(strings.ToLower(e.Correo))
 
-	_, err = config.DB.Exec(
-		`UPDATE Empleado SET nombre=$1, appaterno=$2, apmaterno=$3, tipo_empleado=$4, area=$5, correo=$6
-		 WHERE id_empleado=$7`,
-		e.Nombre, e.Appaterno, e.Apmaterno, e.Tipo, e.Area, e.Correo, e.ID,
-	)
-	if err != nil {
+	// El correo de acceso es el de la identidad; si cambia hay que volver a verificarlo
+	identidadID, err := h.empleados.Actualizar(e)
+	if errors.Is(err, utils.ErrCorreoRegistrado) {
+		return utils.Responder(c, "02", modEmpl, "empleado-service", nil, "El correo ya está registrado")
+	} else if err != nil {
 		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al actualizar empleado")
 	}
+	if identidadID != "" {
+		if err := enviarCorreoVerificacion(identidadID, e.Correo); err != nil {
+			log.Printf("Error enviando correo de verificación: %v", err)
+		}
+	}
 
 	return utils.Responder(c, "01", modEmpl, "empleado-service", fiber.Map{"mensaje": "Empleado actualizado"})
 }
 
-func EliminarEmpleado(c *fiber.Ctx) error {
+func (h *EmpleadoHandler) EliminarEmpleado(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_empleado"`
 	}
@@ -156,10 +169,13 @@ func EliminarEmpleado(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modEmpl, "empleado-service", nil, "ID inválido")
 	}
 
-	_, err := config.DB.Exec("DELETE FROM Empleado WHERE id_empleado = $1", body.ID)
-	if err != nil {
+	if err := h.empleados.Eliminar(body.ID); err != nil {
 		return utils.Responder(c, "06", modEmpl, "empleado-service", nil, "Error al eliminar empleado: "+err.Error())
 	}
 
+	if err := utils.EliminarRolIdentidad("empleado", strconv.Itoa(body.ID)); err != nil {
+		log.Printf("Error eliminando rol de empleado de la identidad: %v", err)
+	}
+
 	return utils.Responder(c, "01", modEmpl, "empleado-service", fiber.Map{"mensaje": "Empleado eliminado"})
 }
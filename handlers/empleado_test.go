@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"back-menchaca/handlers"
+	"back-menchaca/models"
+	"back-menchaca/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Registrar empleados exige administrar_empleados: el personal clínico no
+// puede crear cuentas, en particular de administrador
+func TestCrearEmpleadoSoloAdministrador(t *testing.T) {
+	repos := nuevosRepos(t)
+	app := fiber.New()
+	routes.SetupEmpleadoRoutes(app.Group("/api"), handlers.NuevoEmpleadoHandler(repos.Empleados))
+
+	nuevo := models.Empleado{Nombre: "Rosa", Appaterno: "Flores", Apmaterno: "Mora", Tipo: "administrador",
+		Area: "Dirección", Correo: "rosa.flores@menchaca.demo", Contrasena: "Menchaca#2025"}
+
+	for _, correo := range []string{"laura.garcia@menchaca.demo", "ana.lopez@menchaca.demo"} {
+		t.Run(correo, func(t *testing.T) {
+			r := solicitar(t, app, fiber.MethodPost, "/api/empleados/", tokenDe(t, correo), nuevo)
+			if r.StatusCode != fiber.StatusForbidden {
+				t.Errorf("se esperaba 403, llegó %d %q", r.StatusCode, r.Message)
+			}
+		})
+	}
+	empleados, err := repos.Empleados.Listar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empleados) != 4 {
+		t.Fatalf("hay %d empleados, se esperaban solo los 4 de ejemplo", len(empleados))
+	}
+
+	r := solicitar(t, app, fiber.MethodPost, "/api/empleados/", tokenDe(t, "admin@menchaca.demo"), nuevo)
+	if r.StatusCode != fiber.StatusOK {
+		t.Errorf("el administrador recibió %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+}
+
+// El tipo de empleado es cualquier rol registrado, incluidos los creados en
+// /api/seguridad/roles, pero no paciente ni servicio
+func TestCrearEmpleadoConRolNuevo(t *testing.T) {
+	repos := nuevosRepos(t)
+	app := fiber.New()
+	api := app.Group("/api")
+	routes.SetupSeguridadRoutes(api)
+	routes.SetupEmpleadoRoutes(api, handlers.NuevoEmpleadoHandler(repos.Empleados))
+	admin := tokenDe(t, "admin@menchaca.demo")
+
+	nuevo := models.Empleado{Nombre: "Rosa", Appaterno: "Flores", Apmaterno: "Mora", Tipo: "laboratorista",
+		Area: "Laboratorio", Correo: "rosa.flores@menchaca.demo", Contrasena: "Menchaca#2025"}
+	if r := solicitar(t, app, fiber.MethodPost, "/api/empleados/", admin, nuevo); r.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("rol sin registrar: %d %q, se esperaba 400", r.StatusCode, r.Message)
+	}
+
+	rol := map[string]string{"rol": "laboratorista", "descripcion": "Toma y análisis de muestras"}
+	if r := solicitar(t, app, fiber.MethodPost, "/api/seguridad/roles", admin, rol); r.StatusCode != fiber.StatusOK {
+		t.Fatalf("crear rol: %d %q", r.StatusCode, r.Message)
+	}
+	if r := solicitar(t, app, fiber.MethodPost, "/api/empleados/", admin, nuevo); r.StatusCode != fiber.StatusOK {
+		t.Fatalf("empleado con el rol nuevo: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+
+	for _, tipo := range []string{"paciente", "servicio"} {
+		e := nuevo
+		e.Tipo = tipo
+		e.Correo = tipo + "@menchaca.demo"
+		if r := solicitar(t, app, fiber.MethodPost, "/api/empleados/", admin, e); r.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("empleado de tipo %s: %d %q, se esperaba 400", tipo, r.StatusCode, r.Message)
+		}
+	}
+}
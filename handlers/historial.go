@@ -1,16 +1,27 @@
 package handlers
 
 import (
-	"back-menchaca/config"
 	"back-menchaca/models"
+	"back-menchaca/repository"
 	"back-menchaca/utils"
-	"database/sql"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 )
 
 const modHis = "HIST"
 
-func CrearHistorialClinico(c *fiber.Ctx) error {
+// HistorialHandler atiende /historial
+type HistorialHandler struct {
+	historial   repository.Historial
+	expedientes repository.Expedientes
+	consultas   repository.Consultas
+}
+
+func NuevoHistorialHandler(historial repository.Historial, expedientes repository.Expedientes, consultas repository.Consultas) *HistorialHandler {
+	return &HistorialHandler{historial: historial, expedientes: expedientes, consultas: consultas}
+}
+
+func (hh *HistorialHandler) CrearHistorialClinico(c *fiber.Ctx) error {
 	var h models.HistorialClinico
 	if err := c.BodyParser(&h); err != nil {
 		return utils.Responder(c, "02", modHis, "historial-service", nil, "Datos inválidos")
@@ -20,42 +31,28 @@ func CrearHistorialClinico(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modHis, "historial-service", nil, "Faltan campos obligatorios")
 	}
 
-	if !utils.ExisteIDHisotial("Expediente", "id_expediente", h.IDExpediente) {
+	if ok, err := hh.expedientes.Existe(h.IDExpediente); err != nil || !ok {
 		return utils.Responder(c, "02", modHis, "historial-service", nil, "ID de expediente no válido")
 	}
-	if !utils.ExisteIDHisotial("Consultas", "id_consulta", h.IDConsulta) {
+	if ok, err := hh.consultas.Existe(h.IDConsulta); err != nil || !ok {
 		return utils.Responder(c, "02", modHis, "historial-service", nil, "ID de consulta no válido")
 	}
 
-	err := config.DB.QueryRow(`
-		INSERT INTO Historial_Clinico (id_expediente, id_consultas)
-		VALUES ($1, $2) RETURNING id_historial`,
-		h.IDExpediente, h.IDConsulta,
-	).Scan(&h.ID)
-	if err != nil {
+	if err := hh.historial.Crear(&h); err != nil {
 		return utils.Responder(c, "06", modHis, "historial-service", nil, "Error al crear historial clínico")
 	}
 	return utils.Responder(c, "01", modHis, "historial-service", h)
 }
 
-func ObtenerHistorialesClinicos(c *fiber.Ctx) error {
-	rows, err := config.DB.Query("SELECT id_historial, id_expediente, id_consultas FROM Historial_Clinico")
+func (hh *HistorialHandler) ObtenerHistorialesClinicos(c *fiber.Ctx) error {
+	historiales, err := hh.historial.Listar()
 	if err != nil {
 		return utils.Responder(c, "06", modHis, "historial-service", nil, "Error al obtener historiales")
 	}
-	defer rows.Close()
-
-	var historiales []models.HistorialClinico
-	for rows.Next() {
-		var h models.HistorialClinico
-		if err := rows.Scan(&h.ID, &h.IDExpediente, &h.IDConsulta); err == nil {
-			historiales = append(historiales, h)
-		}
-	}
 	return utils.Responder(c, "01", modHis, "historial-service", historiales)
 }
 
-func ObtenerHistorialClinicoPorID(c *fiber.Ctx) error {
+func (hh *HistorialHandler) ObtenerHistorialClinicoPorID(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_historial"`
 	}
@@ -64,12 +61,8 @@ func ObtenerHistorialClinicoPorID(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modHis, "historial-service", nil, "ID inválido")
 	}
 
-	var h models.HistorialClinico
-	err := config.DB.QueryRow(
-		"SELECT id_historial, id_expediente, id_consultas FROM Historial_Clinico WHERE id_historial = $1",
-		body.ID).Scan(&h.ID, &h.IDExpediente, &h.IDConsulta)
-
-	if err == sql.ErrNoRows {
+	h, err := hh.historial.Obtener(body.ID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
 		return utils.Responder(c, "05", modHis, "historial-service", nil, "Historial no encontrado")
 	} else if err != nil {
 		return utils.Responder(c, "06", modHis, "historial-service", nil, "Error al buscar historial")
@@ -78,16 +71,14 @@ func ObtenerHistorialClinicoPorID(c *fiber.Ctx) error {
 	return utils.Responder(c, "01", modHis, "historial-service", h)
 }
 
-func ActualizarHistorialClinico(c *fiber.Ctx) error {
+func (hh *HistorialHandler) ActualizarHistorialClinico(c *fiber.Ctx) error {
 	var h models.HistorialClinico
 	if err := c.BodyParser(&h); err != nil || h.ID == 0 {
 		return utils.Responder(c, "02", modHis, "historial-service", nil, "Datos inválidos")
 	}
 
-	var actual models.HistorialClinico
-	err := config.DB.QueryRow("SELECT id_expediente, id_consultas FROM Historial_Clinico WHERE id_historial = $1", h.ID).
-		Scan(&actual.IDExpediente, &actual.IDConsulta)
-	if err == sql.ErrNoRows {
+	actual, err := hh.historial.Obtener(h.ID)
+	if errors.Is(err, repository.ErrNoEncontrado) {
 		return utils.Responder(c, "05", modHis, "historial-service", nil, "Historial no encontrado")
 	} else if err != nil {
 		return utils.Responder(c, "06", modHis, "historial-service", nil, "Error al buscar historial")
@@ -100,17 +91,13 @@ func ActualizarHistorialClinico(c *fiber.Ctx) error {
 		h.IDConsulta = actual.IDConsulta
 	}
 
-	_, err = config.DB.Exec(
-		`UPDATE Historial_Clinico SET id_expediente=$1, id_consultas=$2 WHERE id_historial=$3`,
-		h.IDExpediente, h.IDConsulta, h.ID,
-	)
-	if err != nil {
+	if err := hh.historial.Actualizar(h); err != nil {
 		return utils.Responder(c, "06", modHis, "historial-service", nil, "Error al actualizar historial clínico")
 	}
 	return utils.Responder(c, "01", modHis, "historial-service", fiber.Map{"mensaje": "Historial actualizado"})
 }
 
-func EliminarHistorialClinico(c *fiber.Ctx) error {
+func (hh *HistorialHandler) EliminarHistorialClinico(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_historial"`
 	}
@@ -118,8 +105,7 @@ func EliminarHistorialClinico(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modHis, "historial-service", nil, "ID inválido")
 	}
 
-	_, err := config.DB.Exec("DELETE FROM Historial_Clinico WHERE id_historial = $1", body.ID)
-	if err != nil {
+	if err := hh.historial.Eliminar(body.ID); err != nil {
 		return utils.Responder(c, "06", modHis, "historial-service", nil, "Error al eliminar historial clínico")
 	}
 	return utils.Responder(c, "01", modHis, "historial-service", fiber.Map{"mensaje": "Historial eliminado"})
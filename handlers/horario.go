@@ -1,8 +1,8 @@
 package handlers
 
 import (
-	"back-menchaca/config"
 	"back-menchaca/models"
+	"back-menchaca/repository"
 	"back-menchaca/utils"
 	"github.com/gofiber/fiber/v2"
 	"strings"
@@ -10,7 +10,18 @@ import (
 
 const modHor = "HOR"
 
-func CrearHorario(c *fiber.Ctx) error {
+// HorarioHandler atiende /horarios
+type HorarioHandler struct {
+	horarios     repository.Horarios
+	empleados    repository.Empleados
+	consultorios repository.Consultorios
+}
+
+func NuevoHorarioHandler(horarios repository.Horarios, empleados repository.Empleados, consultorios repository.Consultorios) *HorarioHandler {
+	return &HorarioHandler{horarios: horarios, empleados: empleados, consultorios: consultorios}
+}
+
+func (hh *HorarioHandler) CrearHorario(c *fiber.Ctx) error {
 	var h models.Horario
 	if err := c.BodyParser(&h); err != nil {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "Datos inválidos")
@@ -21,46 +32,32 @@ func CrearHorario(c *fiber.Ctx) error {
 	}
 	h.Turno = utils.SanitizarInput(strings.ToLower(h.Turno))
 
-	var empExists bool
-	err := config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM Empleado WHERE id_empleado=$1)", h.IDEmpleado).Scan(&empExists)
+	empExists, err := hh.empleados.Existe(h.IDEmpleado)
 	if err != nil || !empExists {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "Empleado no encontrado")
 	}
 
-	var consExists bool
-	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM Consultorios WHERE id_consultorio=$1)", h.IDConsultorio).Scan(&consExists)
+	consExists, err := hh.consultorios.Existe(h.IDConsultorio)
 	if err != nil || !consExists {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "Consultorio no encontrado")
 	}
 
-	query := `INSERT INTO Horarios (id_consultorio, turno, id_empleado) 
-	          VALUES ($1, $2, $3) RETURNING id_horario`
-	err = config.DB.QueryRow(query, h.IDConsultorio, h.Turno, h.IDEmpleado).Scan(&h.ID)
-	if err != nil {
+	if err := hh.horarios.Crear(&h); err != nil {
 		return utils.Responder(c, "06", modHor, "horario-service", nil, "Error al crear horario: "+err.Error())
 	}
 
 	return utils.Responder(c, "01", modHor, "horario-service", h)
 }
 
-func ObtenerHorarios(c *fiber.Ctx) error {
-	rows, err := config.DB.Query("SELECT id_horario, id_consultorio, turno, id_empleado FROM Horarios")
+func (hh *HorarioHandler) ObtenerHorarios(c *fiber.Ctx) error {
+	lista, err := hh.horarios.Listar()
 	if err != nil {
 		return utils.Responder(c, "06", modHor, "horario-service", nil, "Error al obtener horarios")
 	}
-	defer rows.Close()
-
-	var lista []models.Horario
-	for rows.Next() {
-		var h models.Horario
-		if err := rows.Scan(&h.ID, &h.IDConsultorio, &h.Turno, &h.IDEmpleado); err == nil {
-			lista = append(lista, h)
-		}
-	}
 	return utils.Responder(c, "01", modHor, "horario-service", lista)
 }
 
-func ObtenerHorarioPorID(c *fiber.Ctx) error {
+func (hh *HorarioHandler) ObtenerHorarioPorID(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_horario"`
 	}
@@ -68,27 +65,20 @@ func ObtenerHorarioPorID(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "ID inválido")
 	}
 
-	var h models.Horario
-	err := config.DB.QueryRow(
-		"SELECT id_horario, id_consultorio, turno, id_empleado FROM Horarios WHERE id_horario = $1",
-		body.ID).Scan(&h.ID, &h.IDConsultorio, &h.Turno, &h.IDEmpleado)
-
+	h, err := hh.horarios.Obtener(body.ID)
 	if err != nil {
 		return utils.Responder(c, "05", modHor, "horario-service", nil, "Horario no encontrado")
 	}
 	return utils.Responder(c, "01", modHor, "horario-service", h)
 }
 
-func ActualizarHorario(c *fiber.Ctx) error {
+func (hh *HorarioHandler) ActualizarHorario(c *fiber.Ctx) error {
 	var h models.Horario
 	if err := c.BodyParser(&h); err != nil || h.ID == 0 {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "Datos inválidos")
 	}
 
-	var actual models.Horario
-	err := config.DB.QueryRow(
-		"SELECT id_consultorio, turno, id_empleado FROM Horarios WHERE id_horario=$1", h.ID,
-	).Scan(&actual.IDConsultorio, &actual.Turno, &actual.IDEmpleado)
+	actual, err := hh.horarios.Obtener(h.ID)
 	if err != nil {
 		return utils.Responder(c, "05", modHor, "horario-service", nil, "Horario no encontrado")
 	}
@@ -105,23 +95,17 @@ func ActualizarHorario(c *fiber.Ctx) error {
 		h.IDEmpleado = actual.IDEmpleado
 	}
 
-	var consExists bool
-	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM Consultorios WHERE id_consultorio=$1)", h.IDConsultorio).Scan(&consExists)
+	consExists, err := hh.consultorios.Existe(h.IDConsultorio)
 	if err != nil || !consExists {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "Consultorio no existe")
 	}
 
-	var empExists bool
-	err = config.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM Empleado WHERE id_empleado=$1)", h.IDEmpleado).Scan(&empExists)
+	empExists, err := hh.empleados.Existe(h.IDEmpleado)
 	if err != nil || !empExists {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "Empleado no existe")
 	}
 
-	_, err = config.DB.Exec(
-		"UPDATE Horarios SET id_consultorio=$1, turno=$2, id_empleado=$3 WHERE id_horario=$4",
-		h.IDConsultorio, h.Turno, h.IDEmpleado, h.ID,
-	)
-	if err != nil {
+	if err := hh.horarios.Actualizar(h); err != nil {
 		return utils.Responder(c, "06", modHor, "horario-service", nil, "Error al actualizar horario: "+err.Error())
 	}
 
@@ -129,7 +113,7 @@ func ActualizarHorario(c *fiber.Ctx) error {
 }
 
 
-func EliminarHorario(c *fiber.Ctx) error {
+func (hh *HorarioHandler) EliminarHorario(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_horario"`
 	}
@@ -137,8 +121,7 @@ func EliminarHorario(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modHor, "horario-service", nil, "ID inválido")
 	}
 
-	_, err := config.DB.Exec("DELETE FROM Horarios WHERE id_horario=$1", body.ID)
-	if err != nil {
+	if err := hh.horarios.Eliminar(body.ID); err != nil {
 		return utils.Responder(c, "06", modHor, "horario-service", nil, "Error al eliminar horario")
 	}
 	return utils.Responder(c, "01", modHor, "horario-service", fiber.Map{"mensaje": "Horario eliminado"})
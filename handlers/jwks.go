@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWKS publica las llaves públicas con las que otros servicios pueden verificar nuestros tokens
+func JWKS(c *fiber.Ctx) error {
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(utils.JWKS())
+}
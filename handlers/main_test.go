@@ -0,0 +1,107 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"back-menchaca/mail"
+	"back-menchaca/repository"
+	"back-menchaca/repository/memoria"
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Los handlers se prueban contra el almacenamiento en memoria, sin rutas ni
+// middleware: cada prueba arranca con sus propios datos de ejemplo.
+
+func TestMain(m *testing.M) {
+	outbox, err := os.MkdirTemp("", "outbox")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mail.Default = &mail.ArchivoSender{Dir: outbox}
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal(err)
+	}
+	codigo := m.Run()
+	os.RemoveAll(outbox)
+	os.Exit(codigo)
+}
+
+// nuevosRepos crea un almacenamiento en memoria y lo instala también como el
+// almacenamiento de seguridad de utils
+func nuevosRepos(t *testing.T) *repository.Repositorios {
+	t.Helper()
+	repos, err := memoria.Nuevo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	utils.UsarAlmacen(repos.Seguridad)
+	if err := utils.RecargarPolitica(); err != nil {
+		t.Fatal(err)
+	}
+	return repos
+}
+
+// tokenDe genera un access token para la cuenta de ejemplo del correo
+func tokenDe(t *testing.T, correo string) string {
+	t.Helper()
+	cuenta, err := utils.BuscarCuentaPorCorreo(correo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cuenta.SeleccionarRol("") {
+		t.Fatalf("%s tiene más de un rol", correo)
+	}
+	token, err := utils.GenerateJWT(cuenta.Principal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+type respuesta struct {
+	StatusCode int    `json:"statusCode"`
+	IntCode    string `json:"intCode"`
+	Message    string `json:"message"`
+}
+
+// enviar llama al handler con el cuerpo en JSON y devuelve la respuesta
+func enviar(t *testing.T, metodo string, handler fiber.Handler, cuerpo interface{}) respuesta {
+	t.Helper()
+	app := fiber.New()
+	app.Add(metodo, "/", handler)
+	return solicitar(t, app, metodo, "/", "", cuerpo)
+}
+
+// solicitar hace la solicitud a la app con el token (si no es vacío) y el
+// cuerpo en JSON, y devuelve la respuesta
+func solicitar(t *testing.T, app *fiber.App, metodo, ruta, token string, cuerpo interface{}) respuesta {
+	t.Helper()
+	datos, err := json.Marshal(cuerpo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(metodo, ruta, bytes.NewReader(datos))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	res, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r respuesta
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != res.StatusCode {
+		t.Fatalf("statusCode del cuerpo (%d) distinto del de la respuesta (%d)", r.StatusCode, res.StatusCode)
+	}
+	return r
+}
@@ -0,0 +1,383 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"bytes"
+	"image/png"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// cuentaActual carga la cuenta del usuario identificado por el middleware
+func cuentaActual(c *fiber.Ctx) (*utils.Cuenta, error) {
+	identidad, _ := c.Locals("identidad").(string)
+	rol, _ := c.Locals("rol").(string)
+	return utils.ObtenerCuenta(identidad, rol)
+}
+
+// verificarSegundoFactor acepta un código TOTP, una aserción WebAuthn (ligada al
+// token temporal jti) o, en su lugar, un código de recuperación. Devuelve el
+// método usado ("otp", "webauthn" o "recovery") cuando el factor es válido.
+func verificarSegundoFactor(cuenta *utils.Cuenta, totpCode, codigoRecuperacion string, asercion []byte, jti string) (string, bool, error) {
+	if len(asercion) > 0 && jti != "" {
+		ok, err := utils.VerificarAsercionWebAuthn(cuenta, jti, asercion)
+		return "webauthn", ok, err
+	}
+	if totpCode != "" {
+		ok, err := utils.VerificarTOTP(cuenta, totpCode)
+		return "otp", ok, err
+	}
+	if codigoRecuperacion != "" {
+		ok, err := utils.UsarCodigoRecuperacion(cuenta, codigoRecuperacion)
+		return "recovery", ok, err
+	}
+	return "", false, nil
+}
+
+// EnrollMFA genera un secreto TOTP pendiente y devuelve su código QR en PNG.
+// El secreto no se activa hasta que se confirma con /auth/mfa/confirm.
+func EnrollMFA(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		log.Printf("Error obteniendo cuenta: %v", err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	if cuenta.MFAEnabled {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"statusCode": fiber.StatusConflict,
+			"intCode":    "MFA03",
+			"message":    "La autenticación de dos factores ya está activada",
+			"from":       "auth-service",
+		})
+	}
+
+	key, err := utils.GenerateMFASecret(cuenta.Correo)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error generando secreto MFA",
+			"from":       "auth-service",
+		})
+	}
+
+	img, err := key.Image(256, 256)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error generando código QR",
+			"from":       "auth-service",
+		})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error generando código QR",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := utils.GuardarMFAPendiente(cuenta, key.Secret()); err != nil {
+		log.Printf("Error guardando secreto MFA pendiente: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error guardando secreto MFA",
+			"from":       "auth-service",
+		})
+	}
+
+	c.Set("Cache-Control", "no-store")
+	c.Type("png")
+	return c.Send(buf.Bytes())
+}
+
+// ConfirmMFA activa el secreto pendiente después de validar un código generado con él
+func ConfirmMFA(c *fiber.Ctx) error {
+	var input struct {
+		TOTP string `json:"totp" validate:"required,len=6,numeric"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Datos de entrada inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Validación fallida: " + err.Error(),
+			"from":       "auth-service",
+		})
+	}
+
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	if cuenta.MFASecretPendiente == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "No hay una configuración MFA pendiente, usa /auth/mfa/enroll",
+			"from":       "auth-service",
+		})
+	}
+
+	paso, ok := utils.ValidarTOTPPaso(input.TOTP, cuenta.MFASecretPendiente, time.Now())
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A02",
+			"message":    "Código de autenticación inválido",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := utils.ConfirmarMFA(cuenta, paso); err != nil {
+		log.Printf("Error confirmando MFA: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error guardando configuración MFA",
+			"from":       "auth-service",
+		})
+	}
+
+	codigos, err := utils.GenerarCodigosRecuperacion(cuenta)
+	if err != nil {
+		log.Printf("Error generando códigos de recuperación: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error generando códigos de recuperación",
+			"from":       "auth-service",
+		})
+	}
+
+	extra := fiber.Map{
+		"mfaActivated":        true,
+		"codigosRecuperacion": codigos,
+	}
+
+	// Si venía del login con token de enrolamiento se completa el inicio de sesión
+	// y el token de enrolamiento deja de servir
+	if enrolamiento, _ := c.Locals("enrolamiento").(bool); enrolamiento {
+		revocarTokenEnrolamiento(c)
+		auth := autenticadoAhora(utils.AMRContrasena, utils.AMRCodigo, utils.AMRMultiple)
+		return emitirSesion(c, cuenta, auth, "MFA activado, autenticación exitosa", extra)
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Autenticación de dos factores activada. Guarda tus códigos de recuperación.",
+		"from":       "auth-service",
+		"data":       extra,
+	})
+}
+
+// revocarTokenEnrolamiento invalida el token de enrolamiento de la solicitud
+func revocarTokenEnrolamiento(c *fiber.Ctx) {
+	jti, _ := c.Locals("jti").(string)
+	exp, ok := c.Locals("exp").(time.Time)
+	if !ok {
+		exp = time.Now().Add(10 * time.Minute)
+	}
+	if err := utils.RevokeAccessToken(jti, exp); err != nil {
+		log.Println("Error invalidando token de enrolamiento MFA:", err)
+	}
+}
+
+// DisableMFA desactiva MFA; requiere volver a autenticarse con contraseña y código
+func DisableMFA(c *fiber.Ctx) error {
+	var input struct {
+		Contrasena string `json:"contrasena" validate:"required"`
+		TOTP       string `json:"totp" validate:"required,len=6,numeric"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Datos de entrada inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Validación fallida: " + err.Error(),
+			"from":       "auth-service",
+		})
+	}
+
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	if !cuenta.MFAEnabled {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "La autenticación de dos factores no está activada",
+			"from":       "auth-service",
+		})
+	}
+
+	// Con llaves WebAuthn registradas la cuenta conserva un segundo factor
+	if utils.MFAObligatorioCuenta(cuenta) && cuenta.Passkeys == 0 {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"statusCode": fiber.StatusForbidden,
+			"intCode":    "MFA04",
+			"message":    "La autenticación de dos factores es obligatoria para tu rol",
+			"from":       "auth-service",
+		})
+	}
+
+	if ok, resp := comprobarContrasena(c, cuenta, input.Contrasena, func() error {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A02",
+			"message":    "Credenciales o código inválidos",
+			"from":       "auth-service",
+		})
+	}); !ok {
+		return resp
+	}
+	if ok, err := utils.VerificarTOTP(cuenta, input.TOTP); err != nil || !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A02",
+			"message":    "Credenciales o código inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := utils.DesactivarMFA(cuenta); err != nil {
+		log.Printf("Error desactivando MFA: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error desactivando MFA",
+			"from":       "auth-service",
+		})
+	}
+	if cuenta.Passkeys == 0 {
+		if err := utils.EliminarCodigosRecuperacion(cuenta); err != nil {
+			log.Printf("Error eliminando códigos de recuperación: %v", err)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Autenticación de dos factores desactivada",
+		"from":       "auth-service",
+	})
+}
+
+// RegenerarCodigosRecuperacion invalida los códigos anteriores y entrega un
+// juego nuevo. La ruta exige step-up, así que sirve cualquier segundo factor
+// (TOTP, código de recuperación o llave de seguridad)
+func RegenerarCodigosRecuperacion(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	if !cuenta.MFAEnabled && cuenta.Passkeys == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "La autenticación de dos factores no está activada",
+			"from":       "auth-service",
+		})
+	}
+
+	codigos, err := utils.GenerarCodigosRecuperacion(cuenta)
+	if err != nil {
+		log.Printf("Error generando códigos de recuperación: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error generando códigos de recuperación",
+			"from":       "auth-service",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Códigos de recuperación regenerados",
+		"from":       "auth-service",
+		"data":       fiber.Map{"codigosRecuperacion": codigos},
+	})
+}
+
+// ContarCodigosRecuperacion indica cuántos códigos de recuperación le quedan al usuario
+func ContarCodigosRecuperacion(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	restantes, err := utils.ContarCodigosRecuperacion(cuenta)
+	if err != nil {
+		log.Printf("Error contando códigos de recuperación: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error consultando códigos de recuperación",
+			"from":       "auth-service",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Operación realizada exitosamente",
+		"from":       "auth-service",
+		"data":       fiber.Map{"codigosRestantes": restantes},
+	})
+}
@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"back-menchaca/config"
+	"back-menchaca/utils"
+	"encoding/base64"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Proveedor OpenID Connect. El front end hace de pantalla de login y de
+// consentimiento: /oauth/authorize (GET) lo redirige con la solicitud original,
+// el usuario se autentica con /api/auth/login (incluida la MFA) y el front end
+// completa la autorización con POST /oauth/authorize y /oauth/consent.
+
+// solicitudAutorizacion son los parámetros de una solicitud de autorización
+type solicitudAutorizacion struct {
+	ResponseType        string `query:"response_type" json:"response_type" form:"response_type"`
+	ClientID            string `query:"client_id" json:"client_id" form:"client_id"`
+	RedirectURI         string `query:"redirect_uri" json:"redirect_uri" form:"redirect_uri"`
+	Scope               string `query:"scope" json:"scope" form:"scope"`
+	State               string `query:"state" json:"state" form:"state"`
+	Nonce               string `query:"nonce" json:"nonce" form:"nonce"`
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge" form:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method" form:"code_challenge_method"`
+}
+
+var descripcionScopes = map[string]string{
+	"openid":  "Confirmar tu identidad",
+	"profile": "Ver tu nombre",
+	"email":   "Ver tu correo electrónico",
+	"roles":   "Ver tu rol en el hospital",
+}
+
+// errorAutorizacion es un error que se devuelve al cliente en su redirect_uri.
+// Si redirigible es false el cliente o la redirect_uri no son confiables.
+type errorAutorizacion struct {
+	codigo      string
+	descripcion string
+	redirigible bool
+}
+
+// validar comprueba el cliente, la redirect_uri, los scopes y PKCE
+func (s *solicitudAutorizacion) validar() (*utils.ClienteOAuth, []string, *errorAutorizacion) {
+	cliente, err := utils.BuscarClienteOAuth(s.ClientID)
+	if err != nil {
+		if err != utils.ErrClienteOAuthInvalido {
+			log.Println("Error buscando cliente OAuth:", err)
+		}
+		return nil, nil, &errorAutorizacion{"invalid_client", "Cliente no registrado", false}
+	}
+	if !cliente.RedirectURIValida(s.RedirectURI) {
+		return nil, nil, &errorAutorizacion{"invalid_request", "redirect_uri no registrada para el cliente", false}
+	}
+
+	if s.ResponseType != "code" {
+		return nil, nil, &errorAutorizacion{"unsupported_response_type", "Solo se soporta response_type=code", true}
+	}
+	if s.CodeChallenge == "" || s.CodeChallengeMethod != "S256" {
+		return nil, nil, &errorAutorizacion{"invalid_request", "PKCE con code_challenge_method=S256 es obligatorio", true}
+	}
+
+	scopes := cliente.FiltrarScopes(s.Scope)
+	if len(scopes) == 0 {
+		return nil, nil, &errorAutorizacion{"invalid_scope", "Ninguno de los scopes solicitados está permitido", true}
+	}
+	return cliente, scopes, nil
+}
+
+// redireccion arma la URL de regreso al cliente con los parámetros indicados y el state
+func (s *solicitudAutorizacion) redireccion(params url.Values) string {
+	if s.State != "" {
+		params.Set("state", s.State)
+	}
+	sep := "?"
+	if strings.Contains(s.RedirectURI, "?") {
+		sep = "&"
+	}
+	return s.RedirectURI + sep + params.Encode()
+}
+
+func (s *solicitudAutorizacion) redireccionError(e *errorAutorizacion) string {
+	return s.redireccion(url.Values{"error": {e.codigo}, "error_description": {e.descripcion}})
+}
+
+// OAuthAuthorize valida la solicitud y envía al usuario a la pantalla de login del front end
+func OAuthAuthorize(c *fiber.Ctx) error {
+	var s solicitudAutorizacion
+	if err := c.QueryParser(&s); err != nil {
+		return utils.Responder(c, "02", "OA", "oauth-service", nil)
+	}
+
+	if _, _, e := s.validar(); e != nil {
+		if !e.redirigible {
+			return utils.Responder(c, "02", "OA", "oauth-service", fiber.Map{"error": e.codigo}, e.descripcion)
+		}
+		return c.Redirect(s.redireccionError(e), fiber.StatusFound)
+	}
+
+	loginURL := config.Actual().OIDC.LoginURL
+	if loginURL == "" {
+		loginURL = config.Actual().Correo.AppBaseURL + "/oauth/login"
+	}
+	return c.Redirect(loginURL+"?"+string(c.Request().URI().QueryString()), fiber.StatusFound)
+}
+
+// OAuthAuthorizeSesion completa la autorización para el usuario ya autenticado.
+// Si aún no consiente los scopes devuelve los datos para la pantalla de consentimiento.
+func OAuthAuthorizeSesion(c *fiber.Ctx) error {
+	var s solicitudAutorizacion
+	if err := c.BodyParser(&s); err != nil {
+		return utils.Responder(c, "02", "OA", "oauth-service", nil)
+	}
+
+	cliente, scopes, e := s.validar()
+	if e != nil {
+		return responderErrorAutorizacion(c, &s, e)
+	}
+
+	identidad, _ := c.Locals("identidad").(string)
+	consentido, err := utils.TieneConsentimiento(identidad, cliente.ClientID, scopes)
+	if err != nil {
+		log.Println("Error consultando consentimiento:", err)
+		return utils.Responder(c, "06", "OA", "oauth-service", nil)
+	}
+	if !consentido {
+		detalle := make([]fiber.Map, 0, len(scopes))
+		for _, sc := range scopes {
+			detalle = append(detalle, fiber.Map{"scope": sc, "descripcion": descripcionScopes[sc]})
+		}
+		return c.JSON(fiber.Map{
+			"statusCode": fiber.StatusOK,
+			"intCode":    "OA10",
+			"message":    "Se requiere el consentimiento del usuario",
+			"from":       "oauth-service",
+			"data": fiber.Map{
+				"consentRequired": true,
+				"cliente":         fiber.Map{"client_id": cliente.ClientID, "nombre": cliente.Nombre},
+				"scopes":          detalle,
+			},
+		})
+	}
+
+	return emitirCodigoAutorizacion(c, &s, scopes)
+}
+
+// OAuthConsent registra la decisión del usuario en la pantalla de consentimiento
+func OAuthConsent(c *fiber.Ctx) error {
+	var input struct {
+		solicitudAutorizacion
+		Aprobado bool `json:"aprobado"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "OA", "oauth-service", nil)
+	}
+	s := &input.solicitudAutorizacion
+
+	cliente, scopes, e := s.validar()
+	if e != nil {
+		return responderErrorAutorizacion(c, s, e)
+	}
+	if !input.Aprobado {
+		return responderErrorAutorizacion(c, s, &errorAutorizacion{"access_denied", "El usuario rechazó la solicitud", true})
+	}
+
+	identidad, _ := c.Locals("identidad").(string)
+	if err := utils.GuardarConsentimiento(identidad, cliente.ClientID, scopes); err != nil {
+		log.Println("Error guardando consentimiento:", err)
+		return utils.Responder(c, "06", "OA", "oauth-service", nil)
+	}
+
+	return emitirCodigoAutorizacion(c, s, scopes)
+}
+
+func responderErrorAutorizacion(c *fiber.Ctx, s *solicitudAutorizacion, e *errorAutorizacion) error {
+	if !e.redirigible {
+		return utils.Responder(c, "02", "OA", "oauth-service", fiber.Map{"error": e.codigo}, e.descripcion)
+	}
+	return utils.Responder(c, "01", "OA", "oauth-service", fiber.Map{"redirectTo": s.redireccionError(e)}, e.descripcion)
+}
+
+// emitirCodigoAutorizacion genera el código y devuelve la URL a la que el front end debe redirigir
+func emitirCodigoAutorizacion(c *fiber.Ctx, s *solicitudAutorizacion, scopes []string) error {
+	identidad, _ := c.Locals("identidad").(string)
+	rol, _ := c.Locals("rol").(string)
+	authTime, ok := c.Locals("auth_time").(time.Time)
+	if !ok {
+		authTime, ok = c.Locals("iat").(time.Time)
+	}
+	if !ok {
+		authTime = time.Now()
+	}
+
+	codigo, err := utils.CrearCodigoAutorizacion(utils.CodigoAutorizacion{
+		ClientID:    s.ClientID,
+		IdentidadID: identidad,
+		Rol:         rol,
+		RedirectURI: s.RedirectURI,
+		Scope:       strings.Join(scopes, " "),
+		Nonce:       s.Nonce,
+		Challenge:   s.CodeChallenge,
+		AuthTime:    authTime,
+	})
+	if err != nil {
+		log.Println("Error generando código de autorización:", err)
+		return utils.Responder(c, "06", "OA", "oauth-service", nil)
+	}
+
+	return utils.Responder(c, "01", "OA", "oauth-service", fiber.Map{
+		"redirectTo": s.redireccion(url.Values{"code": {codigo}}),
+	})
+}
+
+// errorToken responde con el formato de error de RFC 6749 §5.2
+func errorToken(c *fiber.Ctx, status int, codigo, descripcion string) error {
+	return c.Status(status).JSON(fiber.Map{"error": codigo, "error_description": descripcion})
+}
+
+// credencialesCliente obtiene client_id y client_secret del encabezado Basic o de los campos del formulario
+func credencialesCliente(c *fiber.Ctx) (clientID, secreto string) {
+	clientID, secreto = c.FormValue("client_id"), c.FormValue("client_secret")
+	if auth := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(auth, "Basic ") {
+		if raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic ")); err == nil {
+			if id, sec, ok := strings.Cut(string(raw), ":"); ok {
+				clientID, _ = url.QueryUnescape(id)
+				secreto, _ = url.QueryUnescape(sec)
+			}
+		}
+	}
+	return clientID, secreto
+}
+
+// autenticarClienteOAuth autentica a un cliente registrado en oauth_clientes
+func autenticarClienteOAuth(c *fiber.Ctx) (*utils.ClienteOAuth, bool) {
+	clientID, secreto := credencialesCliente(c)
+	cliente, err := utils.BuscarClienteOAuth(clientID)
+	if err != nil {
+		if err != utils.ErrClienteOAuthInvalido {
+			log.Println("Error buscando cliente OAuth:", err)
+		}
+		return nil, false
+	}
+	return cliente, cliente.VerificarSecreto(secreto)
+}
+
+// OAuthToken intercambia un código de autorización o un refresh token por tokens,
+// o una API key de cuenta de servicio por un access token (client_credentials)
+func OAuthToken(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	if c.FormValue("grant_type") == "client_credentials" {
+		return tokenPorCredencialesCliente(c)
+	}
+
+	cliente, ok := autenticarClienteOAuth(c)
+	if !ok {
+		return errorToken(c, fiber.StatusUnauthorized, "invalid_client", "Autenticación del cliente fallida")
+	}
+
+	switch c.FormValue("grant_type") {
+	case "authorization_code":
+		return tokenPorCodigo(c, cliente)
+	case "refresh_token":
+		return tokenPorRefresh(c, cliente)
+	default:
+		return errorToken(c, fiber.StatusBadRequest, "unsupported_grant_type", "grant_type no soportado")
+	}
+}
+
+func tokenPorCodigo(c *fiber.Ctx, cliente *utils.ClienteOAuth) error {
+	ca, err := utils.ConsumirCodigoAutorizacion(c.FormValue("code"))
+	if err != nil {
+		if err != utils.ErrCodigoInvalido {
+			log.Println("Error consumiendo código de autorización:", err)
+		}
+		return errorToken(c, fiber.StatusBadRequest, "invalid_grant", "Código inválido o expirado")
+	}
+	if ca.ClientID != cliente.ClientID || ca.RedirectURI != c.FormValue("redirect_uri") {
+		return errorToken(c, fiber.StatusBadRequest, "invalid_grant", "El código no corresponde al cliente o a la redirect_uri")
+	}
+	if !utils.VerificarPKCE(c.FormValue("code_verifier"), ca.Challenge) {
+		return errorToken(c, fiber.StatusBadRequest, "invalid_grant", "code_verifier inválido")
+	}
+
+	cuenta, err := utils.ObtenerCuenta(ca.IdentidadID, ca.Rol)
+	if err != nil {
+		log.Println("Error obteniendo cuenta para el código de autorización:", err)
+		return errorToken(c, fiber.StatusBadRequest, "invalid_grant", "La cuenta ya no está disponible")
+	}
+
+	return responderTokensOAuth(c, cliente, cuenta, ca)
+}
+
+func tokenPorRefresh(c *fiber.Ctx, cliente *utils.ClienteOAuth) error {
+	sesion, nuevoRefresh, err := utils.RotateRefreshToken(c.FormValue("refresh_token"), cliente.ClientID)
+	if err == utils.ErrRefreshReutilizado {
+		log.Printf("[ALERTA] Reutilización de refresh token del cliente %s para %s, familia revocada", cliente.ClientID, sesion.Email)
+		utils.RegistrarEventoSeguridad("refresh_reutilizado", sesion.Email, c.IP(), map[string]interface{}{
+			"familia":   sesion.FamiliaID,
+			"client_id": cliente.ClientID,
+		})
+	}
+	if err != nil {
+		if err != utils.ErrRefreshInvalido && err != utils.ErrRefreshReutilizado {
+			log.Println("Error rotando refresh token OAuth:", err)
+		}
+		return errorToken(c, fiber.StatusBadRequest, "invalid_grant", "Refresh token inválido o expirado")
+	}
+
+	cuenta, err := utils.ObtenerCuenta(sesion.IdentidadID, sesion.Rol)
+	if err != nil {
+		utils.RevokeRefreshFamily(sesion.FamiliaID)
+		return errorToken(c, fiber.StatusBadRequest, "invalid_grant", "La cuenta ya no está disponible")
+	}
+
+	// Se conservan los scopes que el usuario consintió al cliente (si retiró el
+	// consentimiento o el cliente ya no los tiene permitidos, se pierden)
+	consentidos, err := utils.ScopesConsentidos(cuenta.IdentidadID, cliente.ClientID)
+	if err != nil {
+		log.Println("Error consultando consentimiento:", err)
+		return errorToken(c, fiber.StatusInternalServerError, "server_error", "Error generando el token")
+	}
+	scope := strings.Join(cliente.FiltrarScopes(strings.Join(consentidos, " ")), " ")
+	if scope == "" {
+		utils.RevokeRefreshFamily(sesion.FamiliaID)
+		return errorToken(c, fiber.StatusBadRequest, "invalid_grant", "El usuario ya no autoriza al cliente")
+	}
+	accessToken, err := utils.GenerateOAuthAccessToken(cuenta.Principal(), cliente.ClientID, scope)
+	if err != nil {
+		log.Println("Error generando access token OAuth:", err)
+		return errorToken(c, fiber.StatusInternalServerError, "server_error", "Error generando el token")
+	}
+
+	return c.JSON(fiber.Map{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token": nuevoRefresh,
+		"scope":         scope,
+	})
+}
+
+// tokenPorCredencialesCliente emite un access token para una cuenta de servicio.
+// client_id es el id de la cuenta y client_secret una de sus API keys. El scope
+// opcional limita el token a algunos de los permisos de la cuenta.
+func tokenPorCredencialesCliente(c *fiber.Ctx) error {
+	clientID, llave := credencialesCliente(c)
+	cuenta, idLlave, err := utils.AutenticarAPIKey(llave)
+	if err != nil && err != utils.ErrAPIKeyInvalida {
+		log.Println("Error verificando API key:", err)
+		return errorToken(c, fiber.StatusInternalServerError, "server_error", "Error generando el token")
+	}
+	if err != nil || cuenta.ID != clientID {
+		utils.RegistrarEventoSeguridad("api_key_rechazada", "servicio:"+clientID, c.IP(), nil)
+		return errorToken(c, fiber.StatusUnauthorized, "invalid_client", "Autenticación del cliente fallida")
+	}
+
+	permisos := cuenta.Permisos
+	if scope := c.FormValue("scope"); scope != "" {
+		propios := map[string]bool{}
+		for _, p := range cuenta.Permisos {
+			propios[p] = true
+		}
+		permisos = strings.Fields(scope)
+		for _, p := range permisos {
+			if !propios[p] {
+				return errorToken(c, fiber.StatusBadRequest, "invalid_scope", "La cuenta de servicio no tiene el permiso "+p)
+			}
+		}
+	}
+
+	accessToken, err := utils.GenerateServiceToken(cuenta, permisos)
+	if err != nil {
+		log.Println("Error generando token de cuenta de servicio:", err)
+		return errorToken(c, fiber.StatusInternalServerError, "server_error", "Error generando el token")
+	}
+	log.Printf("Token emitido a la cuenta de servicio %s con la llave %s", cuenta.Nombre, idLlave)
+
+	return c.JSON(fiber.Map{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(utils.ServicioTokenTTL.Seconds()),
+		"scope":        strings.Join(permisos, " "),
+	})
+}
+
+func responderTokensOAuth(c *fiber.Ctx, cliente *utils.ClienteOAuth, cuenta *utils.Cuenta, ca *utils.CodigoAutorizacion) error {
+	principal := cuenta.Principal()
+
+	accessToken, err := utils.GenerateOAuthAccessToken(principal, cliente.ClientID, ca.Scope)
+	if err != nil {
+		log.Println("Error generando access token OAuth:", err)
+		return errorToken(c, fiber.StatusInternalServerError, "server_error", "Error generando el token")
+	}
+	refreshToken, err := utils.GenerateRefreshTokenOAuth(principal, cliente.ClientID)
+	if err != nil {
+		log.Println("Error generando refresh token OAuth:", err)
+		return errorToken(c, fiber.StatusInternalServerError, "server_error", "Error generando el token")
+	}
+
+	resp := fiber.Map{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(utils.AccessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"scope":         ca.Scope,
+	}
+	if strings.Contains(" "+ca.Scope+" ", " openid ") {
+		idToken, err := utils.GenerateIDToken(cuenta, ca)
+		if err != nil {
+			log.Println("Error generando id_token:", err)
+			return errorToken(c, fiber.StatusInternalServerError, "server_error", "Error generando el token")
+		}
+		resp["id_token"] = idToken
+	}
+	return c.JSON(resp)
+}
+
+// OAuthUserInfo devuelve los claims del usuario permitidos por el scope del token
+func OAuthUserInfo(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return errorToken(c, fiber.StatusUnauthorized, "invalid_token", "Cuenta no encontrada")
+	}
+
+	// Los tokens del front end propio no tienen scope y ven todos los claims
+	scope, _ := c.Locals("scope").(string)
+	scopes := utils.ScopesSoportados
+	if scope != "" {
+		scopes = strings.Fields(scope)
+	}
+	return c.JSON(utils.ClaimsUserInfo(cuenta, scopes))
+}
+
+// OpenIDConfiguration publica los metadatos de descubrimiento del proveedor
+func OpenIDConfiguration(c *fiber.Ctx) error {
+	iss := utils.IssuerOIDC()
+	c.Set("Cache-Control", "public, max-age=300")
+	return c.JSON(fiber.Map{
+		"issuer":                                iss,
+		"authorization_endpoint":                iss + "/oauth/authorize",
+		"token_endpoint":                        iss + "/oauth/token",
+		"userinfo_endpoint":                     iss + "/oauth/userinfo",
+		"jwks_uri":                              iss + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"EdDSA", "RS256"},
+		"scopes_supported":                      utils.ScopesSoportados,
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"claims_supported":                      []string{"sub", "email", "email_verified", "name", "rol", "roles", "auth_time", "nonce"},
+	})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"log"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CrearClienteOAuth registra una aplicación cliente. El secreto solo se muestra en esta respuesta.
+func CrearClienteOAuth(c *fiber.Ctx) error {
+	var input struct {
+		Nombre       string   `json:"nombre" validate:"required"`
+		RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,dive,url"`
+		Scopes       []string `json:"scopes" validate:"required,min=1,dive,oneof=openid profile email roles"`
+		Publico      bool     `json:"publico"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "OA", "oauth-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "OA", "oauth-service", nil, "Validación fallida: "+err.Error())
+	}
+	for _, uri := range input.RedirectURIs {
+		if u, err := url.Parse(uri); err != nil || u.Fragment != "" {
+			return utils.Responder(c, "02", "OA", "oauth-service", nil, "redirect_uri inválida: "+uri)
+		}
+	}
+
+	cliente, secreto, err := utils.CrearClienteOAuth(utils.SanitizarInput(input.Nombre), input.RedirectURIs, input.Scopes, input.Publico)
+	if err != nil {
+		log.Println("Error registrando cliente OAuth:", err)
+		return utils.Responder(c, "06", "OA", "oauth-service", nil)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("oauth_cliente_creado", admin, c.IP(), map[string]interface{}{"client_id": cliente.ClientID})
+
+	data := fiber.Map{"cliente": cliente}
+	if secreto != "" {
+		data["client_secret"] = secreto
+	}
+	return utils.Responder(c, "01", "OA", "oauth-service", data, "Cliente registrado, guarda el secreto: no se volverá a mostrar")
+}
+
+// ListarClientesOAuth devuelve los clientes registrados
+func ListarClientesOAuth(c *fiber.Ctx) error {
+	clientes, err := utils.ListarClientesOAuth()
+	if err != nil {
+		log.Println("Error listando clientes OAuth:", err)
+		return utils.Responder(c, "06", "OA", "oauth-service", nil)
+	}
+	return utils.Responder(c, "01", "OA", "oauth-service", clientes)
+}
+
+// EliminarClienteOAuth da de baja un cliente y sus consentimientos
+func EliminarClienteOAuth(c *fiber.Ctx) error {
+	clientID := c.Params("clientID")
+	ok, err := utils.EliminarClienteOAuth(clientID)
+	if err != nil {
+		log.Println("Error eliminando cliente OAuth:", err)
+		return utils.Responder(c, "06", "OA", "oauth-service", nil)
+	}
+	if !ok {
+		return utils.Responder(c, "05", "OA", "oauth-service", nil, "Cliente no encontrado")
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("oauth_cliente_eliminado", admin, c.IP(), map[string]interface{}{"client_id": clientID})
+	return utils.Responder(c, "01", "OA", "oauth-service", nil, "Cliente eliminado")
+}
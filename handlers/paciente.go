@@ -1,19 +1,28 @@
 package handlers
 
 import (
-	"database/sql"
+	"errors"
+	"strconv"
 	"strings"
 	"github.com/gofiber/fiber/v2"
-	"back-menchaca/config"
 	"back-menchaca/models"
+	"back-menchaca/repository"
 	"back-menchaca/utils"
     "log"
-	"github.com/pquerna/otp/totp"
 )
 
 const modPac = "PAC"
 
-func CrearPaciente(c *fiber.Ctx) error {
+// PacienteHandler atiende /pacientes
+type PacienteHandler struct {
+	pacientes repository.Pacientes
+}
+
+func NuevoPacienteHandler(pacientes repository.Pacientes) *PacienteHandler {
+	return &PacienteHandler{pacientes: pacientes}
+}
+
+func (h *PacienteHandler) CrearPaciente(c *fiber.Ctx) error {
     var p models.Paciente
 
     if err := c.BodyParser(&p); err != nil {
@@ -41,15 +50,10 @@ func CrearPaciente(c *fiber.Ctx) error {
     p.Apmaterno = utils.SanitizarInput(p.Apmaterno)
     p.Correo = strings.ToLower(utils.SanitizarInput(p.Correo))
 
-    // Verificación de correo único
-    var count int
-    if err := config.DB.QueryRow(
-        `SELECT COUNT(*) FROM (
-            SELECT correo FROM Paciente 
-            UNION 
-            SELECT correo FROM Empleado
-        ) AS usuarios WHERE correo = $1`, p.Correo).Scan(&count); err != nil {
-        
+    // Un correo corresponde a una sola identidad. Si ya existe (p. ej. un empleado que
+    // también se atiende como paciente) se vincula solo si lo pide y confirma su contraseña
+    existente, err := utils.BuscarCuentaPorCorreo(p.Correo)
+    if err != nil && err != utils.ErrCuentaNoEncontrada {
         log.Printf("Error verificando correo: %v", err)
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "statusCode": fiber.StatusInternalServerError,
@@ -59,67 +63,63 @@ func CrearPaciente(c *fiber.Ctx) error {
         })
     }
 
-    if count > 0 {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-            "statusCode": fiber.StatusBadRequest,
-            "intCode": "A01",
-            "message": "El correo ya está registrado",
-            "from": "paciente-service",
-        })
-    }
-
-    // Hash de contraseña
-    hashed, err := utils.HashPassword(p.Contrasena)
-    if err != nil {
-        log.Printf("Error hashing password: %v", err)
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode": "A03",
-            "message": "Error al proteger la contraseña",
-            "from": "paciente-service",
-        })
+    if existente != nil {
+        yaEsPaciente := false
+        for _, r := range existente.Roles {
+            yaEsPaciente = yaEsPaciente || r.Tipo == "paciente"
+        }
+        if yaEsPaciente || !p.VincularIdentidad {
+            return correoYaRegistrado(c)
+        }
+
+        // La contraseña se comprueba con los mismos límites de intentos que el login
+        hasta, bloqueada, err := utils.VerificarIntentoLogin(p.Correo, c.IP())
+        if err != nil {
+            log.Printf("Error verificando intentos de login: %v", err)
+            return loginNoDisponible(c)
+        }
+        if !hasta.IsZero() {
+            return responderLoginBloqueado(c, hasta, bloqueada)
+        }
+        if !utils.CheckPasswordHash(p.Contrasena, existente.Hash) {
+            hasta, err := utils.RegistrarFalloLogin(p.Correo, c.IP())
+            if err != nil {
+                log.Printf("Error registrando intento fallido: %v", err)
+            }
+            if !hasta.IsZero() {
+                return responderLoginBloqueado(c, hasta, true)
+            }
+            return correoYaRegistrado(c)
+        }
+        if err := utils.RegistrarLoginExitoso(p.Correo, c.IP()); err != nil {
+            log.Printf("Error registrando login exitoso: %v", err)
+        }
     }
 
-    // Generar secreto MFA
-    mfaKey, err := totp.Generate(totp.GenerateOpts{
-        Issuer:      "Menchaca System",
-        AccountName: p.Correo,
-        SecretSize:  20,
-    })
-    if err != nil {
-        log.Printf("Error generando secreto MFA: %v", err)
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode": "A03",
-            "message": "Error configurando autenticación de dos factores",
-            "from": "paciente-service",
-        })
+    // Hash de contraseña (solo para identidades nuevas)
+    var hashed string
+    if existente == nil {
+        hashed, err = utils.HashPassword(p.Contrasena)
+        if err != nil {
+            log.Printf("Error hashing password: %v", err)
+            return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+                "statusCode": fiber.StatusInternalServerError,
+                "intCode": "A03",
+                "message": "Error al proteger la contraseña",
+                "from": "paciente-service",
+            })
+        }
     }
 
-    // Creación en BD con transacción
-    tx, err := config.DB.Begin()
-    if err != nil {
-        log.Printf("Error iniciando transacción: %v", err)
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode": "A03",
-            "message": "Error al iniciar transacción",
-            "from": "paciente-service",
-        })
+    // Creación en BD con transacción; las credenciales viven en la identidad y
+    // MFA se configura después con /auth/mfa/enroll y /auth/mfa/confirm
+    alta := repository.AltaIdentidad{Correo: p.Correo, Hash: hashed}
+    if existente != nil {
+        alta.IdentidadID = existente.IdentidadID
     }
-    defer tx.Rollback()
-
-    // Insertar paciente
-    query := `INSERT INTO Paciente 
-              (nombre, appaterno, apmaterno, correo, contraseña, mfa_secret, mfa_enabled) 
-              VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id_paciente`
-    
-    err = tx.QueryRow(query, 
-        p.Nombre, p.Appaterno, p.Apmaterno, p.Correo, hashed, mfaKey.Secret(), true,
-    ).Scan(&p.ID)
-    
+    identidadID, err := h.pacientes.Crear(&p, alta)
     if err != nil {
-        log.Printf("Error insertando paciente: %v", err)
+        log.Printf("Error registrando paciente: %v", err)
         return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
             "statusCode": fiber.StatusInternalServerError,
             "intCode": "A03",
@@ -128,14 +128,12 @@ func CrearPaciente(c *fiber.Ctx) error {
         })
     }
 
-    if err := tx.Commit(); err != nil {
-        log.Printf("Error cometiendo transacción: %v", err)
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-            "statusCode": fiber.StatusInternalServerError,
-            "intCode": "A03",
-            "message": "Error al confirmar registro",
-            "from": "paciente-service",
-        })
+    // La cuenta no puede iniciar sesión hasta verificar el correo
+    mensaje := "Paciente creado exitosamente, revisa tu correo para verificar la cuenta"
+    if existente != nil && existente.CorreoVerificado {
+        mensaje = "Paciente creado y vinculado a tu cuenta, inicia sesión con el rol paciente"
+    } else if err := enviarCorreoVerificacion(identidadID, p.Correo); err != nil {
+        log.Printf("Error enviando correo de verificación: %v", err)
     }
 
     // Limpiar datos sensibles antes de responder
@@ -144,39 +142,35 @@ func CrearPaciente(c *fiber.Ctx) error {
     return c.Status(fiber.StatusCreated).JSON(fiber.Map{
         "statusCode": fiber.StatusCreated,
         "intCode": "S01",
-        "message": "Paciente creado exitosamente",
+        "message": mensaje,
         "from": "paciente-service",
         "data": fiber.Map{
             "id":        p.ID,
             "nombre":    p.Nombre,
             "correo":    p.Correo,
-            "mfaSecret": mfaKey.Secret(),
-            "mfaUrl":    mfaKey.URL(),
         },
     })
 }
 
+func correoYaRegistrado(c *fiber.Ctx) error {
+    return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+        "statusCode": fiber.StatusBadRequest,
+        "intCode": "A01",
+        "message": "El correo ya está registrado",
+        "from": "paciente-service",
+    })
+}
 
-func ObtenerPacientes(c *fiber.Ctx) error {
-	rows, err := config.DB.Query("SELECT id_paciente, nombre, appaterno, apmaterno, correo FROM Paciente")
+func (h *PacienteHandler) ObtenerPacientes(c *fiber.Ctx) error {
+	pacientes, err := h.pacientes.Listar()
 	if err != nil {
 		return utils.Responder(c, "06", modPac, "paciente-service", nil, "Error al obtener pacientes")
 	}
-	defer rows.Close()
-
-	var pacientes []models.Paciente
-	for rows.Next() {
-		var p models.Paciente
-		if err := rows.Scan(&p.ID, &p.Nombre, &p.Appaterno, &p.Apmaterno, &p.Correo); err != nil {
-			continue
-		}
-		pacientes = append(pacientes, p)
-	}
 
 	return utils.Responder(c, "01", modPac, "paciente-service", pacientes)
 }
 
-func ObtenerPacientePorID(c *fiber.Ctx) error {
+func (h *PacienteHandler) ObtenerPacientePorID(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_paciente"`
 	}
@@ -184,11 +178,9 @@ func ObtenerPacientePorID(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modPac, "paciente-service", nil, "ID inválido")
 	}
 
-	var p models.Paciente
-	err := config.DB.QueryRow("SELECT id_paciente, nombre, appaterno, apmaterno, correo FROM Paciente WHERE id_paciente = $1", body.ID).
-		Scan(&p.ID, &p.Nombre, &p.Appaterno, &p.Apmaterno, &p.Correo)
+	p, err := h.pacientes.Obtener(body.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, repository.ErrNoEncontrado) {
 			return utils.Responder(c, "05", modPac, "paciente-service", nil, "Paciente no encontrado")
 		}
 		return utils.Responder(c, "06", modPac, "paciente-service", nil, "Error al buscar paciente")
@@ -197,17 +189,13 @@ func ObtenerPacientePorID(c *fiber.Ctx) error {
 	return utils.Responder(c, "01", modPac, "paciente-service", p)
 }
 
-func ActualizarPaciente(c *fiber.Ctx) error {
+func (h *PacienteHandler) ActualizarPaciente(c *fiber.Ctx) error {
 	var p models.Paciente
 	if err := c.BodyParser(&p); err != nil || p.ID == 0 {
 		return utils.Responder(c, "02", modPac, "paciente-service", nil, "Datos inválidos")
 	}
 
-	var current models.Paciente
-	err := config.DB.QueryRow(
-		"SELECT nombre, appaterno, apmaterno, correo FROM Paciente WHERE id_paciente = $1", p.ID,
-	).Scan(&current.Nombre, &current.Appaterno, &current.Apmaterno, &current.Correo)
-	if err != nil {
+	if _, err := h.pacientes.Obtener(p.ID); err != nil {
 		return utils.Responder(c, "05", modPac, "paciente-service", nil, "Paciente no encontrado")
 	}
 
@@ -230,18 +218,23 @@ func ActualizarPaciente(c *fiber.Ctx) error {
 	p.Apmaterno = utils.SanitizarInput(p.Apmaterno)
 	p.Correo = utils.SanitizarInput(strings.ToLower(p.Correo))
 
-	query := `UPDATE Paciente 
-	          SET nombre=$1, appaterno=$2, apmaterno=$3, correo=$4 
-	          WHERE id_paciente=$5`
-	_, err = config.DB.Exec(query, p.Nombre, p.Appaterno, p.Apmaterno, p.Correo, p.ID)
-	if err != nil {
+	// El correo de acceso es el de la identidad; si cambia hay que volver a verificarlo
+	identidadID, err := h.pacientes.Actualizar(p)
+	if errors.Is(err, utils.ErrCorreoRegistrado) {
+		return utils.Responder(c, "02", modPac, "paciente-service", nil, "El correo ya está registrado")
+	} else if err != nil {
 		return utils.Responder(c, "06", modPac, "paciente-service", nil, "Error al actualizar paciente")
 	}
+	if identidadID != "" {
+		if err := enviarCorreoVerificacion(identidadID, p.Correo); err != nil {
+			log.Printf("Error enviando correo de verificación: %v", err)
+		}
+	}
 
 	return utils.Responder(c, "01", modPac, "paciente-service", fiber.Map{"mensaje": "Paciente actualizado"})
 }
 
-func EliminarPaciente(c *fiber.Ctx) error {
+func (h *PacienteHandler) EliminarPaciente(c *fiber.Ctx) error {
 	var body struct {
 		ID int `json:"id_paciente"`
 	}
@@ -249,11 +242,14 @@ func EliminarPaciente(c *fiber.Ctx) error {
 		return utils.Responder(c, "02", modPac, "paciente-service", nil, "ID inválido")
 	}
 
-	_, err := config.DB.Exec("DELETE FROM Paciente WHERE id_paciente = $1", body.ID)
-	if err != nil {
+	if err := h.pacientes.Eliminar(body.ID); err != nil {
 		return utils.Responder(c, "06", modPac, "paciente-service", nil, "Error al eliminar paciente")
 	}
 
+	if err := utils.EliminarRolIdentidad("paciente", strconv.Itoa(body.ID)); err != nil {
+		log.Printf("Error eliminando rol de paciente de la identidad: %v", err)
+	}
+
 	return utils.Responder(c, "01", modPac, "paciente-service", fiber.Map{"mensaje": "Paciente eliminado"})
 }
 
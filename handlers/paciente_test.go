@@ -0,0 +1,46 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"back-menchaca/handlers"
+	"back-menchaca/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCrearPacienteCorreoDuplicado(t *testing.T) {
+	repos := nuevosRepos(t)
+	h := handlers.NuevoPacienteHandler(repos.Pacientes)
+
+	nuevo := models.Paciente{Nombre: "Lucía", Appaterno: "Ortega", Apmaterno: "Ramos",
+		Correo: "lucia.ortega@menchaca.demo", Contrasena: "Menchaca#2025"}
+	if r := enviar(t, fiber.MethodPost, h.CrearPaciente, nuevo); r.StatusCode != fiber.StatusCreated {
+		t.Fatalf("alta de un correo nuevo: %d %s", r.StatusCode, r.Message)
+	}
+
+	casos := map[string]string{
+		"mismo correo":          "lucia.ortega@menchaca.demo",
+		"mayúsculas":            "Lucia.Ortega@Menchaca.demo",
+		"paciente de ejemplo":   "juan.perez@menchaca.demo",
+		"empleado sin vincular": "laura.garcia@menchaca.demo",
+	}
+	for nombre, correo := range casos {
+		t.Run(nombre, func(t *testing.T) {
+			p := nuevo
+			p.Correo = correo
+			r := enviar(t, fiber.MethodPost, h.CrearPaciente, p)
+			if r.StatusCode != fiber.StatusBadRequest || r.Message != "El correo ya está registrado" {
+				t.Errorf("se esperaba 400 por correo duplicado, llegó %d %q", r.StatusCode, r.Message)
+			}
+		})
+	}
+
+	pacientes, err := repos.Pacientes.Listar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pacientes) != 4 {
+		t.Errorf("hay %d pacientes, se esperaban los 3 de ejemplo más el nuevo", len(pacientes))
+	}
+}
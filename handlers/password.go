@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"back-menchaca/config"
+	"back-menchaca/mail"
+	"back-menchaca/utils"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ForgotPassword envía un enlace de restablecimiento si el correo existe.
+// Siempre responde lo mismo para no revelar qué correos están registrados.
+func ForgotPassword(c *fiber.Ctx) error {
+	var input struct {
+		Correo string `json:"correo" validate:"required,email"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	ip := c.IP()
+	// Se envía en segundo plano para que el tiempo de respuesta no dependa de si la cuenta existe
+	go enviarCorreoReset(input.Correo, ip)
+
+	return utils.Responder(c, "01", "PWD", "auth-service", nil,
+		"Si el correo está registrado recibirás instrucciones para restablecer tu contraseña")
+}
+
+func enviarCorreoReset(correo, ip string) {
+	cuenta, err := utils.BuscarCuentaPorCorreo(correo)
+	if err != nil {
+		if err != utils.ErrCuentaNoEncontrada {
+			log.Println("Error buscando cuenta para restablecimiento:", err)
+		}
+		return
+	}
+
+	token, err := utils.GenerarTokenReset(cuenta)
+	if err != nil {
+		log.Println("Error generando token de restablecimiento:", err)
+		return
+	}
+
+	enlace := config.Actual().Correo.AppBaseURL + "/reset-password?token=" + url.QueryEscape(token)
+	err = mail.Enviar(mail.Mensaje{
+		Para:   cuenta.Correo,
+		Asunto: "Restablecer contraseña",
+		Cuerpo: fmt.Sprintf("Recibimos una solicitud para restablecer tu contraseña.\n\n"+
+			"Usa este enlace en los próximos %d minutos:\n%s\n\n"+
+			"Si no fuiste tú, ignora este mensaje.", int(utils.ResetTokenTTL.Minutes()), enlace),
+	})
+	if err != nil {
+		log.Println("Error enviando correo de restablecimiento:", err)
+		return
+	}
+
+	utils.RegistrarEventoSeguridad("password_reset_solicitado", cuenta.Correo, ip, nil)
+}
+
+// ResetPassword cambia la contraseña con un token de restablecimiento y cierra todas las sesiones
+func ResetPassword(c *fiber.Ctx) error {
+	var input struct {
+		Token      string `json:"token" validate:"required"`
+		Contrasena string `json:"contrasena" validate:"required"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil, "Validación fallida: "+err.Error())
+	}
+	if err := utils.ValidarContrasena(input.Contrasena); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil, err.Error())
+	}
+
+	cuenta, err := utils.ConsumirTokenReset(input.Token)
+	if err == utils.ErrResetInvalido || err == utils.ErrCuentaNoEncontrada {
+		return utils.Responder(c, "03", "PWD", "auth-service", nil, "Token de restablecimiento inválido o expirado")
+	}
+	if err != nil {
+		log.Println("Error validando token de restablecimiento:", err)
+		return utils.Responder(c, "06", "PWD", "auth-service", nil)
+	}
+
+	if err := utils.ActualizarContrasena(cuenta, input.Contrasena); err == utils.ErrContrasenaReutilizada {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil, "No puedes reutilizar una de tus últimas contraseñas")
+	} else if err != nil {
+		log.Println("Error actualizando contraseña:", err)
+		return utils.Responder(c, "06", "PWD", "auth-service", nil)
+	}
+
+	if err := utils.RevocarSesionesCuenta(cuenta); err != nil {
+		log.Println("Error revocando sesiones tras restablecer contraseña:", err)
+	}
+	if _, err := utils.DesbloquearCuenta(cuenta.Correo); err != nil {
+		log.Println("Error desbloqueando cuenta tras restablecer contraseña:", err)
+	}
+	utils.RegistrarEventoSeguridad("password_reset", cuenta.Correo, c.IP(), nil)
+
+	return utils.Responder(c, "01", "PWD", "auth-service", nil, "Contraseña actualizada, inicia sesión de nuevo")
+}
+
+// ChangePassword cambia la contraseña del usuario autenticado. Con
+// cerrarOtrasSesiones se revocan el resto de sus sesiones y se emite una nueva.
+func ChangePassword(c *fiber.Ctx) error {
+	var input struct {
+		ContrasenaActual    string `json:"contrasenaActual" validate:"required"`
+		ContrasenaNueva     string `json:"contrasenaNueva" validate:"required"`
+		CerrarOtrasSesiones bool   `json:"cerrarOtrasSesiones"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		log.Println("Error obteniendo cuenta:", err)
+		return utils.Responder(c, "03", "PWD", "auth-service", nil, "Usuario no encontrado")
+	}
+
+	if ok, resp := comprobarContrasena(c, cuenta, input.ContrasenaActual, func() error {
+		return utils.Responder(c, "03", "PWD", "auth-service", nil, "La contraseña actual es incorrecta")
+	}); !ok {
+		return resp
+	}
+	if err := utils.ValidarContrasena(input.ContrasenaNueva); err != nil {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil, err.Error())
+	}
+
+	if err := utils.ActualizarContrasena(cuenta, input.ContrasenaNueva); err == utils.ErrContrasenaReutilizada {
+		return utils.Responder(c, "02", "PWD", "auth-service", nil, "No puedes reutilizar una de tus últimas contraseñas")
+	} else if err != nil {
+		log.Println("Error actualizando contraseña:", err)
+		return utils.Responder(c, "06", "PWD", "auth-service", nil)
+	}
+	utils.RegistrarEventoSeguridad("password_cambiado", cuenta.Correo, c.IP(), fiber.Map{"cerrarOtrasSesiones": input.CerrarOtrasSesiones})
+
+	// El token de cambio obligatorio es de un solo uso y al terminar se inicia la sesión
+	if obligatorio, _ := c.Locals("cambioObligatorio").(bool); obligatorio {
+		jti, _ := c.Locals("jti").(string)
+		exp, _ := c.Locals("exp").(time.Time)
+		if err := utils.RevokeAccessToken(jti, exp); err != nil {
+			log.Println("Error invalidando token de cambio de contraseña:", err)
+		}
+		return emitirSesion(c, cuenta, autenticacionActual(c), "Contraseña actualizada", nil)
+	}
+
+	if input.CerrarOtrasSesiones {
+		if err := utils.RevocarSesionesCuenta(cuenta); err != nil {
+			log.Println("Error revocando sesiones:", err)
+			return utils.Responder(c, "06", "PWD", "auth-service", nil)
+		}
+		return emitirSesion(c, cuenta, autenticacionActual(c), "Contraseña actualizada, se cerraron las demás sesiones", nil)
+	}
+
+	return utils.Responder(c, "01", "PWD", "auth-service", nil, "Contraseña actualizada")
+}
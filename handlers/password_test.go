@@ -0,0 +1,31 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"back-menchaca/routes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Confirmar la contraseña actual cuenta los fallos como el login: tras el
+// tercer fallo hay que esperar antes de reintentar, incluso con la correcta
+func TestCambiarContrasenaLimitaIntentos(t *testing.T) {
+	nuevosRepos(t)
+	app := fiber.New()
+	routes.SetupAuthRoutes(app.Group("/api"))
+	token := tokenDe(t, "juan.perez@menchaca.demo")
+
+	cambio := map[string]string{"contrasenaActual": "Incorrecta#2025", "contrasenaNueva": "Otra#Segura2026"}
+	for i := 1; i <= 3; i++ {
+		if r := solicitar(t, app, fiber.MethodPost, "/api/auth/password/change", token, cambio); r.StatusCode != fiber.StatusUnauthorized {
+			t.Fatalf("intento %d: %d %q, se esperaba 401", i, r.StatusCode, r.Message)
+		}
+	}
+
+	cambio["contrasenaActual"] = "Menchaca#2025"
+	if r := solicitar(t, app, fiber.MethodPost, "/api/auth/password/change", token, cambio); r.StatusCode != fiber.StatusTooManyRequests {
+		t.Errorf("contraseña correcta sin esperar: %d %q, se esperaba 429", r.StatusCode, r.Message)
+	}
+
+}
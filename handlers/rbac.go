@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Administración de roles y permisos. Los cambios aplican en cuanto se recarga
+// la política; con forzar_renovacion los access tokens ya emitidos a los roles
+// afectados dejan de servir y el cliente obtiene uno nuevo con su refresh token.
+
+// responderErrorRBAC traduce los errores de utils/rbac.go a la respuesta estándar
+func responderErrorRBAC(c *fiber.Ctx, err error, accion string) error {
+	switch err {
+	case utils.ErrNombreInvalido, utils.ErrRolReservado:
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, err.Error())
+	case utils.ErrRolNoEncontrado, utils.ErrPermisoNoExiste:
+		return utils.Responder(c, "05", "SEG", "seguridad-service", nil, err.Error())
+	case utils.ErrRolExiste, utils.ErrPermisoExiste, utils.ErrRolEnUso, utils.ErrPermisoEnUso, utils.ErrUltimoAdministrador:
+		return utils.Responder(c, "07", "SEG", "seguridad-service", nil, err.Error())
+	}
+	log.Printf("Error %s: %v", accion, err)
+	return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+}
+
+// forzarRenovacion invalida los access tokens de los roles indicados; un
+// fallo solo se registra porque el cambio de permisos ya se aplicó
+func forzarRenovacion(roles ...string) []string {
+	renovados := []string{}
+	for _, rol := range roles {
+		if rol == utils.RolServicio {
+			continue
+		}
+		if err := utils.ForzarRenovacionRol(rol); err != nil {
+			log.Printf("Error forzando la renovación de tokens del rol %s: %v", rol, err)
+			continue
+		}
+		renovados = append(renovados, rol)
+	}
+	return renovados
+}
+
+// ListarRoles devuelve los roles con sus permisos
+func ListarRoles(c *fiber.Ctx) error {
+	roles, err := utils.ListarRoles()
+	if err != nil {
+		log.Println("Error listando roles:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	return utils.Responder(c, "01", "SEG", "seguridad-service", roles)
+}
+
+// CrearRol registra un rol de empleado nuevo
+func CrearRol(c *fiber.Ctx) error {
+	var input struct {
+		Rol         string `json:"rol" validate:"required,max=50"`
+		Descripcion string `json:"descripcion" validate:"max=255"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	rol := strings.TrimSpace(input.Rol)
+	if err := utils.CrearRol(rol, utils.SanitizarInput(input.Descripcion)); err != nil {
+		return responderErrorRBAC(c, err, "creando rol")
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("rol_creado", admin, c.IP(), map[string]interface{}{"rol": rol})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", fiber.Map{"rol": rol}, "Rol creado")
+}
+
+// EliminarRol borra un rol que ningún empleado tiene
+func EliminarRol(c *fiber.Ctx) error {
+	rol := c.Params("rol")
+	if err := utils.EliminarRol(rol); err != nil {
+		return responderErrorRBAC(c, err, "eliminando rol")
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("rol_eliminado", admin, c.IP(), map[string]interface{}{"rol": rol})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Rol eliminado")
+}
+
+// ListarPermisos devuelve el catálogo de permisos
+func ListarPermisos(c *fiber.Ctx) error {
+	permisos, err := utils.ListarPermisos()
+	if err != nil {
+		log.Println("Error listando permisos:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	return utils.Responder(c, "01", "SEG", "seguridad-service", permisos)
+}
+
+// CrearPermiso agrega un permiso al catálogo
+func CrearPermiso(c *fiber.Ctx) error {
+	var input struct {
+		Permiso     string `json:"permiso" validate:"required,max=50"`
+		Descripcion string `json:"descripcion" validate:"max=255"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	permiso := strings.TrimSpace(input.Permiso)
+	if err := utils.CrearPermiso(permiso, utils.SanitizarInput(input.Descripcion)); err != nil {
+		return responderErrorRBAC(c, err, "creando permiso")
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("permiso_creado", admin, c.IP(), map[string]interface{}{"permiso": permiso})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", fiber.Map{"permiso": permiso}, "Permiso creado")
+}
+
+// EliminarPermiso borra un permiso que ninguna ruta exige, incluso de los
+// roles y cuentas de servicio que lo tenían (?forzar_renovacion=true)
+func EliminarPermiso(c *fiber.Ctx) error {
+	permiso := c.Params("permiso")
+	roles, err := utils.EliminarPermiso(permiso)
+	if err != nil {
+		return responderErrorRBAC(c, err, "eliminando permiso")
+	}
+
+	var renovados []string
+	if c.QueryBool("forzar_renovacion") {
+		renovados = forzarRenovacion(roles...)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("permiso_eliminado", admin, c.IP(), map[string]interface{}{
+		"permiso":   permiso,
+		"roles":     roles,
+		"renovados": renovados,
+	})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", fiber.Map{"roles": roles, "renovados": renovados}, "Permiso eliminado")
+}
+
+// AsignarPermisoRol da un permiso a un rol, opcionalmente limitado a ciertas rutas
+func AsignarPermisoRol(c *fiber.Ctx) error {
+	var input struct {
+		Reglas []struct {
+			Metodo    string `json:"metodo" validate:"omitempty,oneof=GET POST PUT DELETE PATCH get post put delete patch *"`
+			Ruta      string `json:"ruta" validate:"required,startswith=/,max=255"`
+			Permitido *bool  `json:"permitido"`
+		} `json:"reglas" validate:"dive"`
+		ForzarRenovacion bool `json:"forzar_renovacion"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&input); err != nil {
+			return utils.Responder(c, "02", "SEG", "seguridad-service", nil)
+		}
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	reglas := make([]utils.ReglaPermiso, 0, len(input.Reglas))
+	for _, r := range input.Reglas {
+		reglas = append(reglas, utils.ReglaPermiso{
+			Metodo:    r.Metodo,
+			Ruta:      r.Ruta,
+			Permitido: r.Permitido == nil || *r.Permitido,
+		})
+	}
+
+	rol, permiso := c.Params("rol"), c.Params("permiso")
+	if err := utils.AsignarPermisoRol(rol, permiso, reglas); err != nil {
+		return responderErrorRBAC(c, err, "asignando permiso")
+	}
+
+	var renovados []string
+	if input.ForzarRenovacion {
+		renovados = forzarRenovacion(rol)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("permiso_asignado", admin, c.IP(), map[string]interface{}{
+		"rol":       rol,
+		"permiso":   permiso,
+		"reglas":    reglas,
+		"renovados": renovados,
+	})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Permiso asignado")
+}
+
+// QuitarPermisoRol retira un permiso de un rol (?forzar_renovacion=true)
+func QuitarPermisoRol(c *fiber.Ctx) error {
+	rol, permiso := c.Params("rol"), c.Params("permiso")
+	if err := utils.QuitarPermisoRol(rol, permiso); err != nil {
+		return responderErrorRBAC(c, err, "retirando permiso")
+	}
+
+	var renovados []string
+	if c.QueryBool("forzar_renovacion") {
+		renovados = forzarRenovacion(rol)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("permiso_retirado", admin, c.IP(), map[string]interface{}{
+		"rol":       rol,
+		"permiso":   permiso,
+		"renovados": renovados,
+	})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Permiso retirado")
+}
+
+// ForzarRenovacionRol invalida los access tokens emitidos a un rol para que
+// los clientes los renueven con los permisos actuales
+func ForzarRenovacionRol(c *fiber.Ctx) error {
+	rol := c.Params("rol")
+	if rol == utils.RolServicio {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, utils.ErrRolReservado.Error())
+	}
+	if err := utils.ForzarRenovacionRol(rol); err != nil {
+		log.Println("Error forzando la renovación de tokens:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("rol_renovacion_forzada", admin, c.IP(), map[string]interface{}{"rol": rol})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Los tokens del rol deben renovarse")
+}
+
+// PermisosEfectivosUsuario muestra los permisos vigentes de un paciente,
+// empleado o cuenta de servicio (/usuarios/:tipo/:id/permisos)
+func PermisosEfectivosUsuario(c *fiber.Ctx) error {
+	tipo, id := c.Params("tipo"), c.Params("id")
+	if tipo != "paciente" && tipo != "empleado" && tipo != utils.RolServicio {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "El tipo debe ser paciente, empleado o servicio")
+	}
+
+	efectivos, err := utils.PermisosEfectivosUsuario(tipo, id)
+	switch err {
+	case nil:
+	case utils.ErrCuentaNoEncontrada, utils.ErrCuentaServicioNoEncontrada:
+		return utils.Responder(c, "05", "SEG", "seguridad-service", nil, "Usuario no encontrado")
+	default:
+		log.Println("Error obteniendo permisos efectivos:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	return utils.Responder(c, "01", "SEG", "seguridad-service", efectivos)
+}
@@ -0,0 +1,101 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"back-menchaca/handlers"
+	"back-menchaca/models"
+	"back-menchaca/routes"
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestCrearRecetaConsultorioInexistente(t *testing.T) {
+	repos := nuevosRepos(t)
+	h := handlers.NuevoRecetaHandler(repos.Recetas, repos.Consultorios)
+
+	receta := recetaNueva{Receta: models.Receta{Medicamento: "Ibuprofeno", Dosis: "400 mg cada 12 horas",
+		IDConsultorio: 99}, IDConsulta: 2}
+	r := enviar(t, fiber.MethodPost, h.CrearReceta, receta)
+	if r.StatusCode != fiber.StatusBadRequest || r.Message != utils.ErrConsultorioInvalido.Error() {
+		t.Errorf("consultorio inexistente: %d %q, se esperaba 400", r.StatusCode, r.Message)
+	}
+
+	receta.IDConsultorio = 1
+	if r := enviar(t, fiber.MethodPost, h.CrearReceta, receta); r.StatusCode != fiber.StatusOK {
+		t.Errorf("consultorio existente: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+
+	recetas, err := repos.Recetas.Listar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recetas) != 2 {
+		t.Fatalf("hay %d recetas, se esperaban la de ejemplo y la nueva", len(recetas))
+	}
+	consulta, err := repos.Consultas.Obtener(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consulta.IDReceta == nil {
+		t.Error("la receta nueva no quedó en la consulta 2")
+	}
+}
+
+// recetaNueva es el cuerpo de POST /recetas/
+type recetaNueva struct {
+	models.Receta
+	IDConsulta int `json:"id_consulta"`
+}
+
+// Laura (Medicina general) atiende a los pacientes 1 y 3, pero no a María
+// (paciente 2), que solo tiene consulta en Pediatría
+func TestRecetasFueraDeAlcance(t *testing.T) {
+	repos := nuevosRepos(t)
+	app := fiber.New()
+	routes.SetupRecetasRoutes(app.Group("/api"), handlers.NuevoRecetaHandler(repos.Recetas, repos.Consultorios))
+	laura := tokenDe(t, "laura.garcia@menchaca.demo")
+
+	receta := recetaNueva{Receta: models.Receta{Medicamento: "Ibuprofeno", Dosis: "400 mg cada 12 horas",
+		IDConsultorio: 1}, IDConsulta: 2}
+	if r := solicitar(t, app, fiber.MethodPost, "/api/recetas/", laura, receta); r.StatusCode != fiber.StatusForbidden {
+		t.Errorf("receta en la consulta de María: %d %q, se esperaba 403", r.StatusCode, r.Message)
+	}
+	if r := solicitar(t, app, fiber.MethodPost, "/api/recetas/", tokenDe(t, "juan.perez@menchaca.demo"), receta); r.StatusCode != fiber.StatusForbidden {
+		t.Errorf("receta emitida por un paciente: %d %q, se esperaba 403", r.StatusCode, r.Message)
+	}
+	receta.IDConsulta = 3
+	if r := solicitar(t, app, fiber.MethodPost, "/api/recetas/", laura, receta); r.StatusCode != fiber.StatusOK {
+		t.Errorf("receta en la consulta de Pedro: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+
+	// Carlos (Pediatría) no atiende a Juan, dueño de la receta 1
+	cambio := map[string]interface{}{"id_receta": 1, "dosis": "1 g cada 8 horas"}
+	if r := solicitar(t, app, fiber.MethodPut, "/api/recetas/update", tokenDe(t, "carlos.ramirez@menchaca.demo"), cambio); r.StatusCode != fiber.StatusForbidden {
+		t.Errorf("actualizar la receta de Juan: %d %q, se esperaba 403", r.StatusCode, r.Message)
+	}
+	if rec, _ := repos.Recetas.Obtener(1); rec.Dosis != "500 mg cada 8 horas" {
+		t.Errorf("la actualización rechazada cambió la dosis a %q", rec.Dosis)
+	}
+}
+
+// La receta de ejemplo está en la primera consulta; al eliminarla la consulta
+// se queda sin receta (ON DELETE SET NULL)
+func TestEliminarRecetaReferenciada(t *testing.T) {
+	repos := nuevosRepos(t)
+	h := handlers.NuevoRecetaHandler(repos.Recetas, repos.Consultorios)
+
+	r := enviar(t, fiber.MethodDelete, h.EliminarReceta, map[string]int{"id_receta": 1})
+	if r.StatusCode != fiber.StatusOK {
+		t.Fatalf("eliminar receta referenciada: %d %q, se esperaba 200", r.StatusCode, r.Message)
+	}
+
+	consulta, err := repos.Consultas.Obtener(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consulta.IDReceta != nil {
+		t.Errorf("la consulta conserva la receta eliminada %d", *consulta.IDReceta)
+	}
+}
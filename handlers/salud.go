@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"back-menchaca/config"
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apagando se activa al recibir la señal de apagado para que /readyz deje de
+// recibir tráfico mientras se drenan las conexiones
+var apagando atomic.Bool
+
+// MarcarApagando indica que el servidor se está apagando
+func MarcarApagando() {
+	apagando.Store(true)
+}
+
+// Healthz responde mientras el proceso esté vivo
+func Healthz(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"statusCode": 200,
+		"status":     "ok",
+		"from":       "salud-service",
+	})
+}
+
+// Readyz indica si el servidor puede atender: la base de datos responde y el
+// pool no está saturado (READY_POOL_PORCENTAJE de las conexiones en uso). Con
+// STORE=memory no hay base de datos que revisar.
+func Readyz(c *fiber.Ctx) error {
+	noListo := func(motivo string, datos fiber.Map) error {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"statusCode": 503,
+			"status":     "no_listo",
+			"message":    motivo,
+			"data":       datos,
+			"from":       "salud-service",
+		})
+	}
+	if apagando.Load() {
+		return noListo("El servidor se está apagando", nil)
+	}
+
+	if config.DB == nil {
+		return c.JSON(fiber.Map{
+			"statusCode": 200,
+			"status":     "ok",
+			"data":       fiber.Map{"almacen": config.Actual().BaseDatos.Almacen},
+			"from":       "salud-service",
+		})
+	}
+
+	stats := config.DB.Stats()
+	datos := fiber.Map{
+		"conexiones_abiertas": stats.OpenConnections,
+		"en_uso":              stats.InUse,
+		"inactivas":           stats.Idle,
+		"max_conexiones":      stats.MaxOpenConnections,
+		"esperas":             stats.WaitCount,
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 2*time.Second)
+	defer cancel()
+	if err := config.DB.PingContext(ctx); err != nil {
+		datos["error"] = err.Error()
+		return noListo("La base de datos no responde", datos)
+	}
+
+	if maxConex := stats.MaxOpenConnections; maxConex > 0 {
+		if stats.InUse*100 >= maxConex*config.Actual().Servidor.ReadyPoolPorcentaje {
+			return noListo("El pool de conexiones está saturado", datos)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": 200,
+		"status":     "ok",
+		"data":       datos,
+		"from":       "salud-service",
+	})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListarBloqueos devuelve las cuentas bloqueadas por intentos de login fallidos
+func ListarBloqueos(c *fiber.Ctx) error {
+	cuentas, err := utils.ListarCuentasBloqueadas()
+	if err != nil {
+		log.Println("Error listando cuentas bloqueadas:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	return utils.Responder(c, "01", "SEG", "seguridad-service", cuentas)
+}
+
+// DesbloquearCuenta elimina el bloqueo de una cuenta y deja constancia del administrador
+func DesbloquearCuenta(c *fiber.Ctx) error {
+	var input struct {
+		Correo string `json:"correo" validate:"required,email"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	existia, err := utils.DesbloquearCuenta(input.Correo)
+	if err != nil {
+		log.Println("Error desbloqueando cuenta:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	if !existia {
+		return utils.Responder(c, "05", "SEG", "seguridad-service", nil, "La cuenta no tiene intentos fallidos registrados")
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("cuenta_desbloqueada", input.Correo, c.IP(), map[string]interface{}{"por": admin})
+
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Cuenta desbloqueada")
+}
+
+// ListarEventosSeguridad devuelve los eventos de seguridad para revisión (?tipo=&correo=&limite=)
+func ListarEventosSeguridad(c *fiber.Ctx) error {
+	limite, err := strconv.Atoi(c.Query("limite", "100"))
+	if err != nil || limite <= 0 || limite > 1000 {
+		limite = 100
+	}
+
+	eventos, err := utils.ListarEventosSeguridad(c.Query("tipo"), c.Query("correo"), limite)
+	if err != nil {
+		log.Println("Error listando eventos de seguridad:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	return utils.Responder(c, "01", "SEG", "seguridad-service", eventos)
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CrearCuentaServicio registra una cuenta de servicio con un subconjunto de los permisos
+func CrearCuentaServicio(c *fiber.Ctx) error {
+	var input struct {
+		Nombre      string   `json:"nombre" validate:"required,max=100"`
+		Descripcion string   `json:"descripcion" validate:"max=255"`
+		Permisos    []string `json:"permisos" validate:"required,min=1"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	cuenta, err := utils.CrearCuentaServicio(utils.SanitizarInput(input.Nombre), utils.SanitizarInput(input.Descripcion), input.Permisos)
+	if err == utils.ErrPermisoDesconocido || err == utils.ErrPermisoReservado {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, err.Error())
+	} else if err != nil {
+		log.Println("Error creando cuenta de servicio:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("cuenta_servicio_creada", admin, c.IP(), map[string]interface{}{
+		"cuenta":   cuenta.ID,
+		"permisos": cuenta.Permisos,
+	})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", cuenta, "Cuenta de servicio creada")
+}
+
+// ListarCuentasServicio devuelve las cuentas de servicio
+func ListarCuentasServicio(c *fiber.Ctx) error {
+	cuentas, err := utils.ListarCuentasServicio()
+	if err != nil {
+		log.Println("Error listando cuentas de servicio:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	return utils.Responder(c, "01", "SEG", "seguridad-service", cuentas)
+}
+
+// ActualizarPermisosServicio reemplaza los permisos de una cuenta de servicio.
+// Los tokens emitidos con los permisos anteriores dejan de servir.
+func ActualizarPermisosServicio(c *fiber.Ctx) error {
+	var input struct {
+		Permisos []string `json:"permisos" validate:"required,min=1"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	id := c.Params("id")
+	err := utils.ActualizarPermisosServicio(id, input.Permisos)
+	switch err {
+	case nil:
+	case utils.ErrPermisoDesconocido, utils.ErrPermisoReservado:
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, err.Error())
+	case utils.ErrCuentaServicioNoEncontrada:
+		return utils.Responder(c, "05", "SEG", "seguridad-service", nil, "Cuenta de servicio no encontrada")
+	default:
+		log.Println("Error actualizando permisos de cuenta de servicio:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("cuenta_servicio_permisos", admin, c.IP(), map[string]interface{}{
+		"cuenta":   id,
+		"permisos": input.Permisos,
+	})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Permisos actualizados")
+}
+
+// DesactivarCuentaServicio desactiva una cuenta de servicio y revoca todas sus llaves
+func DesactivarCuentaServicio(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if err := utils.DesactivarCuentaServicio(id); err == utils.ErrCuentaServicioNoEncontrada {
+		return utils.Responder(c, "05", "SEG", "seguridad-service", nil, "Cuenta de servicio no encontrada")
+	} else if err != nil {
+		log.Println("Error desactivando cuenta de servicio:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("cuenta_servicio_desactivada", admin, c.IP(), map[string]interface{}{"cuenta": id})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Cuenta de servicio desactivada")
+}
+
+// CrearAPIKey emite una API key para la cuenta de servicio. La llave solo se muestra en esta respuesta.
+func CrearAPIKey(c *fiber.Ctx) error {
+	var input struct {
+		Nombre     string `json:"nombre" validate:"required,max=100"`
+		ExpiraDias int    `json:"expira_dias" validate:"min=0,max=730"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "SEG", "seguridad-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	cuenta, err := utils.ObtenerCuentaServicio(c.Params("id"))
+	if err == utils.ErrCuentaServicioNoEncontrada || (err == nil && !cuenta.Activa) {
+		return utils.Responder(c, "05", "SEG", "seguridad-service", nil, "Cuenta de servicio no encontrada o inactiva")
+	} else if err != nil {
+		log.Println("Error obteniendo cuenta de servicio:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+
+	// Sin expira_dias la llave no expira; se recomienda rotarla
+	var expira *time.Time
+	if input.ExpiraDias > 0 {
+		t := time.Now().AddDate(0, 0, input.ExpiraDias)
+		expira = &t
+	}
+
+	llave, secreto, err := utils.CrearAPIKey(cuenta.ID, utils.SanitizarInput(input.Nombre), expira)
+	if err != nil {
+		log.Println("Error creando API key:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("api_key_creada", admin, c.IP(), map[string]interface{}{
+		"cuenta":  cuenta.ID,
+		"prefijo": llave.Prefijo,
+	})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", fiber.Map{
+		"llave":   llave,
+		"api_key": secreto,
+	}, "Llave creada, guárdala: no se volverá a mostrar")
+}
+
+// ListarAPIKeys devuelve las llaves de una cuenta de servicio (sin secretos)
+func ListarAPIKeys(c *fiber.Ctx) error {
+	llaves, err := utils.ListarAPIKeys(c.Params("id"))
+	if err != nil {
+		log.Println("Error listando API keys:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	return utils.Responder(c, "01", "SEG", "seguridad-service", llaves)
+}
+
+// RevocarAPIKey revoca una llave. Los tokens que ya se emitieron con ella
+// siguen vigentes hasta expirar; para cortarlos se desactiva la cuenta.
+func RevocarAPIKey(c *fiber.Ctx) error {
+	id, idLlave := c.Params("id"), c.Params("idLlave")
+	ok, err := utils.RevocarAPIKey(id, idLlave)
+	if err != nil {
+		log.Println("Error revocando API key:", err)
+		return utils.Responder(c, "06", "SEG", "seguridad-service", nil)
+	}
+	if !ok {
+		return utils.Responder(c, "05", "SEG", "seguridad-service", nil, "Llave no encontrada o ya revocada")
+	}
+
+	admin, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("api_key_revocada", admin, c.IP(), map[string]interface{}{
+		"cuenta": id,
+		"llave":  idLlave,
+	})
+	return utils.Responder(c, "01", "SEG", "seguridad-service", nil, "Llave revocada")
+}
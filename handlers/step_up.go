@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StepUp confirma el segundo factor del usuario ya autenticado y entrega un
+// access token nuevo con auth_time actual, que sirve para las operaciones
+// sensibles protegidas con RequiereStepUp durante STEP_UP_MINUTOS
+func StepUp(c *fiber.Ctx) error {
+	var input struct {
+		TOTP               string          `json:"totp" validate:"required_without_all=CodigoRecuperacion WebAuthn,omitempty,len=6,numeric"`
+		CodigoRecuperacion string          `json:"codigoRecuperacion"`
+		WebAuthn           json.RawMessage `json:"webauthn"` // respuesta de navigator.credentials.get()
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Datos de entrada inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Validación fallida: " + err.Error(),
+			"from":       "auth-service",
+		})
+	}
+
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	if !cuenta.TieneMFA() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"statusCode": fiber.StatusForbidden,
+			"intCode":    "SU02",
+			"message":    "Configura la autenticación de dos factores para realizar esta operación",
+			"from":       "auth-service",
+		})
+	}
+
+	// Los fallos se cuentan por identidad con el mismo límite que el login, así
+	// que pedir tokens nuevos no da más intentos
+	hasta, bloqueada, err := utils.VerificarIntentoLogin(cuenta.Correo, c.IP())
+	if err != nil {
+		log.Printf("Error verificando intentos de login: %v", err)
+		return loginNoDisponible(c)
+	}
+	if !hasta.IsZero() {
+		return responderLoginBloqueado(c, hasta, bloqueada)
+	}
+
+	jti, _ := c.Locals("jti").(string)
+	exp, _ := c.Locals("exp").(time.Time)
+	metodo, ok, err := verificarSegundoFactor(cuenta, input.TOTP, input.CodigoRecuperacion, input.WebAuthn, jti)
+	if err != nil || !ok {
+		log.Printf("Step-up fallido para %s: %v", cuenta.Correo, err)
+
+		hasta, err := utils.RegistrarFalloLogin(cuenta.Correo, c.IP())
+		if err != nil {
+			log.Printf("Error registrando intento de step-up fallido: %v", err)
+		}
+		if !hasta.IsZero() {
+			// La cuenta quedó bloqueada: se cierran también las sesiones abiertas
+			if err := utils.RevokeAccessToken(jti, exp); err != nil {
+				log.Printf("Error revocando access token: %v", err)
+			}
+			if err := utils.RevocarSesionesCuenta(cuenta); err != nil {
+				log.Printf("Error revocando sesiones: %v", err)
+			}
+			utils.RegistrarEventoSeguridad("step_up_bloqueado", cuenta.Correo, c.IP(), nil)
+			return responderLoginBloqueado(c, hasta, true)
+		}
+
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A02",
+			"message":    "Código de autenticación inválido o expirado",
+			"from":       "auth-service",
+		})
+	}
+	if err := utils.RegistrarLoginExitoso(cuenta.Correo, c.IP()); err != nil {
+		log.Printf("Error registrando login exitoso: %v", err)
+	}
+
+	// Se conservan los métodos con los que inició la sesión y se agrega el nuevo factor
+	auth := autenticacionActual(c)
+	principal := cuenta.Principal()
+	principal.AuthTime = time.Now()
+	principal.AMR = agregarAMR(auth.metodos, amrDeMetodo(metodo), utils.AMRMultiple)
+
+	accessToken, err := utils.GenerateJWT(principal)
+	if err != nil {
+		log.Printf("Error generando access token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error generando token de acceso",
+			"from":       "auth-service",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Segundo factor confirmado",
+		"from":       "auth-service",
+		"data": fiber.Map{
+			"token":          accessToken,
+			"tokenType":      "Bearer",
+			"expiresIn":      int(utils.AccessTokenTTL.Seconds()),
+			"ventanaMinutos": int(utils.VentanaStepUp().Minutes()),
+		},
+	})
+}
+
+func agregarAMR(metodos []string, nuevos ...string) []string {
+	resultado := append([]string{}, metodos...)
+	for _, n := range nuevos {
+		existe := false
+		for _, m := range resultado {
+			if m == n {
+				existe = true
+				break
+			}
+		}
+		if !existe {
+			resultado = append(resultado, n)
+		}
+	}
+	return resultado
+}
+
+// IniciarStepUpWebAuthn devuelve las opciones de navigator.credentials.get() para
+// confirmar el segundo factor con una llave de seguridad en /auth/step-up
+func IniciarStepUpWebAuthn(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+	if cuenta.Passkeys == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "La cuenta no tiene llaves de seguridad registradas",
+			"from":       "auth-service",
+		})
+	}
+
+	jti, _ := c.Locals("jti").(string)
+	opciones, err := utils.IniciarAsercionWebAuthn(cuenta, jti)
+	if err != nil {
+		log.Printf("Error iniciando aserción WebAuthn: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error iniciando la verificación con llave de seguridad",
+			"from":       "auth-service",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Usa tu llave de seguridad o passkey para continuar",
+		"from":       "auth-service",
+		"data":       opciones,
+	})
+}
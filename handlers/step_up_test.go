@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"testing"
+
+	"back-menchaca/config"
+	"back-menchaca/routes"
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Los fallos de step-up se cuentan por identidad: un access token nuevo no da
+// más intentos y al llegar al límite se bloquea la cuenta y se cierran sus sesiones
+func TestStepUpCuentaFallosPorIdentidad(t *testing.T) {
+	nuevosRepos(t)
+	app := fiber.New()
+	routes.SetupAuthRoutes(app.Group("/api"))
+
+	seguridad := &config.Actual().Seguridad
+	defer func(max int) { seguridad.LoginMaxFallos = max }(seguridad.LoginMaxFallos)
+	seguridad.LoginMaxFallos = 3
+
+	cuenta, err := utils.BuscarCuentaPorCorreo("juan.perez@menchaca.demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clave, err := utils.GenerateMFASecret(cuenta.Correo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.GuardarMFAPendiente(cuenta, clave.Secret()); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.ConfirmarMFA(cuenta, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	codigo := map[string]string{"totp": "000000"}
+	primero := tokenDe(t, cuenta.Correo)
+	for i := 1; i <= 2; i++ {
+		if r := solicitar(t, app, fiber.MethodPost, "/api/auth/step-up", primero, codigo); r.StatusCode != fiber.StatusUnauthorized {
+			t.Fatalf("intento %d: %d %q, se esperaba 401", i, r.StatusCode, r.Message)
+		}
+	}
+
+	if r := solicitar(t, app, fiber.MethodPost, "/api/auth/step-up", tokenDe(t, cuenta.Correo), codigo); r.StatusCode != fiber.StatusLocked {
+		t.Fatalf("tercer intento con otro token: %d %q, se esperaba 423", r.StatusCode, r.Message)
+	}
+	if r := solicitar(t, app, fiber.MethodPost, "/api/auth/step-up", primero, codigo); r.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("token anterior al bloqueo: %d %q, se esperaba 401 por sesión cerrada", r.StatusCode, r.Message)
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"back-menchaca/config"
+	"back-menchaca/mail"
+	"back-menchaca/utils"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// enviarCorreoVerificacion manda el enlace firmado para verificar el correo de una identidad
+func enviarCorreoVerificacion(identidadID, correo string) error {
+	token, err := utils.GenerateEmailVerifyToken(identidadID, correo)
+	if err != nil {
+		return err
+	}
+
+	enlace := config.Actual().Correo.AppBaseURL + "/verificar-correo?token=" + url.QueryEscape(token)
+	return mail.Enviar(mail.Mensaje{
+		Para:   correo,
+		Asunto: "Verifica tu correo",
+		Cuerpo: fmt.Sprintf("Gracias por registrarte. Para activar tu cuenta abre este enlace "+
+			"(válido por 24 horas):\n%s\n\nSi no creaste una cuenta, ignora este mensaje.", enlace),
+	})
+}
+
+// VerificarCorreo marca como verificado el correo del enlace (?token=)
+func VerificarCorreo(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if token == "" {
+		var input struct {
+			Token string `json:"token"`
+		}
+		c.BodyParser(&input)
+		token = input.Token
+	}
+
+	claims, err := utils.ParseToken(token, "email_verify")
+	if err != nil {
+		return utils.Responder(c, "03", "VER", "auth-service", nil, "Enlace de verificación inválido o expirado")
+	}
+
+	identidad, _ := claims["idn"].(string)
+	correo, _ := claims["email"].(string)
+	ok, err := utils.MarcarCorreoVerificado(identidad, correo)
+	if err != nil {
+		log.Println("Error verificando correo:", err)
+		return utils.Responder(c, "06", "VER", "auth-service", nil)
+	}
+	if !ok {
+		return utils.Responder(c, "05", "VER", "auth-service", nil, "El correo del enlace ya no corresponde a la cuenta")
+	}
+
+	return utils.Responder(c, "01", "VER", "auth-service", nil, "Correo verificado, ya puedes iniciar sesión")
+}
+
+// ReenviarVerificacion vuelve a enviar el enlace de verificación. Siempre
+// responde lo mismo para no revelar qué correos están registrados.
+func ReenviarVerificacion(c *fiber.Ctx) error {
+	var input struct {
+		Correo string `json:"correo" validate:"required,email"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return utils.Responder(c, "02", "VER", "auth-service", nil)
+	}
+	if err := validate.Struct(input); err != nil {
+		return utils.Responder(c, "02", "VER", "auth-service", nil, "Validación fallida: "+err.Error())
+	}
+
+	go func(correo string) {
+		cuenta, err := utils.BuscarCuentaPorCorreo(correo)
+		if err != nil || cuenta.CorreoVerificado {
+			return
+		}
+		if err := enviarCorreoVerificacion(cuenta.IdentidadID, cuenta.Correo); err != nil {
+			log.Println("Error reenviando correo de verificación:", err)
+		}
+	}(input.Correo)
+
+	return utils.Responder(c, "01", "VER", "auth-service", nil,
+		"Si el correo está registrado y sin verificar recibirás un nuevo enlace")
+}
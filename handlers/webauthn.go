@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"back-menchaca/utils"
+	"encoding/json"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// IniciarRegistroWebAuthn devuelve las opciones para navigator.credentials.create().
+// Acepta el token de enrolamiento, así que sirve como alternativa al TOTP para
+// los roles que exigen MFA.
+func IniciarRegistroWebAuthn(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	opciones, err := utils.IniciarRegistroWebAuthn(cuenta)
+	if err != nil {
+		log.Printf("Error iniciando registro WebAuthn: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error iniciando el registro de la llave de seguridad",
+			"from":       "auth-service",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Usa tu llave de seguridad o passkey para completar el registro",
+		"from":       "auth-service",
+		"data":       opciones,
+	})
+}
+
+// FinalizarRegistroWebAuthn guarda el autenticador a partir de la respuesta del navegador.
+// Si es el primer segundo factor de la cuenta se entregan códigos de recuperación.
+func FinalizarRegistroWebAuthn(c *fiber.Ctx) error {
+	var input struct {
+		Nombre     string          `json:"nombre" validate:"required,max=60"`
+		Credencial json.RawMessage `json:"credencial" validate:"required"`
+	}
+
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Datos de entrada inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	if err := validate.Struct(input); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Validación fallida: " + err.Error(),
+			"from":       "auth-service",
+		})
+	}
+
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+	primerFactor := !cuenta.TieneMFA()
+
+	credencial, err := utils.FinalizarRegistroWebAuthn(cuenta, utils.SanitizarInput(input.Nombre), input.Credencial)
+	if err == utils.ErrWebAuthnSinSesion {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "No hay un registro pendiente, usa /auth/mfa/webauthn/register/begin",
+			"from":       "auth-service",
+		})
+	} else if err != nil {
+		log.Printf("Registro WebAuthn rechazado para %s: %v", cuenta.Correo, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A02",
+			"message":    "No se pudo verificar la llave de seguridad",
+			"from":       "auth-service",
+		})
+	}
+
+	email, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("webauthn_registrada", email, c.IP(), map[string]interface{}{"credencial": credencial.Nombre})
+
+	extra := fiber.Map{
+		"mfaActivated": true,
+		"credencial":   credencial,
+	}
+	if primerFactor {
+		codigos, err := utils.GenerarCodigosRecuperacion(cuenta)
+		if err != nil {
+			log.Printf("Error generando códigos de recuperación: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"statusCode": fiber.StatusInternalServerError,
+				"intCode":    "A03",
+				"message":    "Error generando códigos de recuperación",
+				"from":       "auth-service",
+			})
+		}
+		extra["codigosRecuperacion"] = codigos
+	}
+
+	// Si venía del login con token de enrolamiento se completa el inicio de sesión
+	// y el token de enrolamiento deja de servir
+	if enrolamiento, _ := c.Locals("enrolamiento").(bool); enrolamiento {
+		revocarTokenEnrolamiento(c)
+		auth := autenticadoAhora(utils.AMRContrasena, utils.AMRLlave, utils.AMRMultiple)
+		return emitirSesion(c, cuenta, auth, "Llave de seguridad registrada, autenticación exitosa", extra)
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Llave de seguridad registrada",
+		"from":       "auth-service",
+		"data":       extra,
+	})
+}
+
+// IniciarLoginWebAuthn devuelve las opciones para navigator.credentials.get() con
+// el token temporal del login. La respuesta se envía a /auth/verify-mfa.
+func IniciarLoginWebAuthn(c *fiber.Ctx) error {
+	var input struct {
+		TempToken string `json:"tempToken" validate:"required"`
+	}
+
+	if err := c.BodyParser(&input); err != nil || validate.Struct(input) != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Datos de entrada inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	claims, err := utils.ParseToken(input.TempToken, "mfa")
+	jti, _ := claims["jti"].(string)
+	if err == nil && jti != "" {
+		var revocado bool
+		if revocado, err = utils.IsJTIRevoked(jti); err == nil && revocado {
+			err = utils.ErrWebAuthnSinSesion
+		}
+	}
+	if err != nil || jti == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Token temporal inválido o expirado",
+			"from":       "auth-service",
+		})
+	}
+
+	principal := utils.PrincipalDesdeClaims(claims)
+	if principal.IdentidadID == "" || principal.Rol == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Token temporal inválido o expirado",
+			"from":       "auth-service",
+		})
+	}
+	cuenta, err := utils.ObtenerCuenta(principal.IdentidadID, principal.Rol)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+	if cuenta.Passkeys == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "La cuenta no tiene llaves de seguridad registradas",
+			"from":       "auth-service",
+		})
+	}
+
+	opciones, err := utils.IniciarAsercionWebAuthn(cuenta, jti)
+	if err != nil {
+		log.Printf("Error iniciando aserción WebAuthn: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error iniciando la verificación con llave de seguridad",
+			"from":       "auth-service",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Usa tu llave de seguridad o passkey para continuar",
+		"from":       "auth-service",
+		"data":       opciones,
+	})
+}
+
+// ListarCredencialesWebAuthn devuelve las llaves de seguridad del usuario
+func ListarCredencialesWebAuthn(c *fiber.Ctx) error {
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	credenciales, err := utils.ListarCredencialesWebAuthn(cuenta)
+	if err != nil {
+		log.Printf("Error listando credenciales WebAuthn: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error consultando llaves de seguridad",
+			"from":       "auth-service",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Llaves de seguridad registradas",
+		"from":       "auth-service",
+		"data":       credenciales,
+	})
+}
+
+// EliminarCredencialWebAuthn borra una llave de seguridad; requiere la contraseña.
+// No se permite quitar el último segundo factor si el rol exige MFA.
+func EliminarCredencialWebAuthn(c *fiber.Ctx) error {
+	var input struct {
+		Contrasena string `json:"contrasena" validate:"required"`
+	}
+
+	if err := c.BodyParser(&input); err != nil || validate.Struct(input) != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"statusCode": fiber.StatusBadRequest,
+			"intCode":    "A01",
+			"message":    "Datos de entrada inválidos",
+			"from":       "auth-service",
+		})
+	}
+
+	cuenta, err := cuentaActual(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A01",
+			"message":    "Usuario no encontrado",
+			"from":       "auth-service",
+		})
+	}
+
+	if !utils.CheckPasswordHash(input.Contrasena, cuenta.Hash) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "A02",
+			"message":    "Credenciales inválidas",
+			"from":       "auth-service",
+		})
+	}
+
+	if !cuenta.MFAEnabled && cuenta.Passkeys == 1 && utils.MFAObligatorioCuenta(cuenta) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"statusCode": fiber.StatusForbidden,
+			"intCode":    "MFA04",
+			"message":    "La autenticación de dos factores es obligatoria para tu rol, registra otro factor antes de quitar esta llave",
+			"from":       "auth-service",
+		})
+	}
+
+	eliminada, err := utils.EliminarCredencialWebAuthn(cuenta, c.Params("id"))
+	if err != nil {
+		log.Printf("Error eliminando credencial WebAuthn: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"statusCode": fiber.StatusInternalServerError,
+			"intCode":    "A03",
+			"message":    "Error eliminando la llave de seguridad",
+			"from":       "auth-service",
+		})
+	}
+	if !eliminada {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"statusCode": fiber.StatusNotFound,
+			"intCode":    "A01",
+			"message":    "Llave de seguridad no encontrada",
+			"from":       "auth-service",
+		})
+	}
+
+	// Sin ningún segundo factor los códigos de recuperación ya no sirven
+	if !cuenta.TieneMFA() {
+		if err := utils.EliminarCodigosRecuperacion(cuenta); err != nil {
+			log.Printf("Error eliminando códigos de recuperación: %v", err)
+		}
+	}
+	utils.RegistrarEventoSeguridad("webauthn_eliminada", cuenta.Correo, c.IP(), nil)
+
+	return c.JSON(fiber.Map{
+		"statusCode": fiber.StatusOK,
+		"intCode":    "S01",
+		"message":    "Llave de seguridad eliminada",
+		"from":       "auth-service",
+	})
+}
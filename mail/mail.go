@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"back-menchaca/config"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Envío de correos del sistema. El transporte se elige con MAIL_DRIVER:
+//   - smtp:    servidor SMTP (SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD, MAIL_FROM)
+//   - archivo: escribe cada mensaje en MAIL_OUTBOX_DIR (por defecto ./outbox), útil en local
+//   - bd:      guarda los mensajes en la tabla correos_salientes
+
+// Mensaje es un correo de texto plano
+type Mensaje struct {
+	Para   string
+	Asunto string
+	Cuerpo string
+}
+
+// Sender envía mensajes por algún transporte
+type Sender interface {
+	Enviar(m Mensaje) error
+}
+
+var Default Sender = &ArchivoSender{Dir: "outbox"}
+
+// Configurar selecciona el Sender por defecto según el driver configurado (MAIL_DRIVER)
+func Configurar() error {
+	cfg := config.Actual().Correo
+	driver := strings.ToLower(cfg.Driver)
+	switch driver {
+	case "smtp":
+		s, err := NuevoSMTPSender()
+		if err != nil {
+			return err
+		}
+		Default = s
+	case "bd":
+		Default = &BDSender{}
+	case "", "archivo":
+		Default = &ArchivoSender{Dir: cfg.OutboxDir}
+	default:
+		return fmt.Errorf("MAIL_DRIVER desconocido: %q", driver)
+	}
+
+	log.Printf("📧 Envío de correos con driver %T", Default)
+	return nil
+}
+
+// Enviar envía un mensaje con el Sender por defecto
+func Enviar(m Mensaje) error {
+	return Default.Enviar(m)
+}
@@ -0,0 +1,35 @@
+package mail
+
+import (
+	"back-menchaca/config"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ArchivoSender escribe cada mensaje como un archivo .txt en Dir
+type ArchivoSender struct {
+	Dir string
+}
+
+func (s *ArchivoSender) Enviar(m Mensaje) error {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return err
+	}
+
+	nombre := fmt.Sprintf("%s-%s.txt", time.Now().Format("20060102-150405"), uuid.NewString()[:8])
+	contenido := fmt.Sprintf("Para: %s\nAsunto: %s\nFecha: %s\n\n%s\n", m.Para, m.Asunto, time.Now().Format(time.RFC3339), m.Cuerpo)
+	return os.WriteFile(filepath.Join(s.Dir, nombre), []byte(contenido), 0o600)
+}
+
+// BDSender guarda los mensajes en la tabla correos_salientes
+type BDSender struct{}
+
+func (s *BDSender) Enviar(m Mensaje) error {
+	_, err := config.DB.Exec(`INSERT INTO correos_salientes (para, asunto, cuerpo) VALUES ($1, $2, $3)`,
+		m.Para, m.Asunto, m.Cuerpo)
+	return err
+}
@@ -0,0 +1,55 @@
+package mail
+
+import (
+	"back-menchaca/config"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPSender envía los mensajes a través de un servidor SMTP con STARTTLS
+type SMTPSender struct {
+	Host     string
+	Port     string
+	Usuario  string
+	Password string
+	From     string
+}
+
+// NuevoSMTPSender construye el sender a partir de las variables SMTP_*
+func NuevoSMTPSender() (*SMTPSender, error) {
+	cfg := config.Actual().Correo
+	s := &SMTPSender{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Usuario:  cfg.SMTPUsuario,
+		Password: cfg.SMTPPassword,
+		From:     cfg.From,
+	}
+	if s.Port == "" {
+		s.Port = "587"
+	}
+	if s.Host == "" || s.From == "" {
+		return nil, errors.New("SMTP_HOST y MAIL_FROM son obligatorios con MAIL_DRIVER=smtp")
+	}
+	return s, nil
+}
+
+func (s *SMTPSender) Enviar(m Mensaje) error {
+	if strings.ContainsAny(m.Para+m.Asunto, "\r\n") {
+		return errors.New("encabezados de correo inválidos")
+	}
+
+	var auth smtp.Auth
+	if s.Usuario != "" {
+		auth = smtp.PlainAuth("", s.Usuario, s.Password, s.Host)
+	}
+
+	cuerpo := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		s.From, m.Para, m.Asunto, time.Now().Format(time.RFC1123Z), m.Cuerpo)
+
+	return smtp.SendMail(net.JoinHostPort(s.Host, s.Port), auth, s.From, []string{m.Para}, []byte(cuerpo))
+}
@@ -1,40 +1,103 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
-	"github.com/joho/godotenv"
 	"back-menchaca/middleware"
 	"back-menchaca/config"
+	"back-menchaca/handlers"
+	"back-menchaca/mail"
+	"back-menchaca/migrations"
+	"back-menchaca/repository"
+	"back-menchaca/repository/memoria"
 	"back-menchaca/routes"
+	"back-menchaca/utils"
 )
 
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error cargando archivo .env")
+	cfg, args, err := config.Cargar(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
 	}
+	log.Printf("Configuración:\n%s", cfg)
 
-	config.ConnectDB()
+	// Subcomandos: serve (por defecto) y migrate up|down|status
+	comando := "serve"
+	if len(args) > 0 {
+		comando, args = args[0], args[1:]
+	}
+	switch comando {
+	case "serve":
+	case "migrate":
+		if cfg.BaseDatos.Almacen == "memory" {
+			log.Fatal("migrate necesita STORE=postgres: el almacenamiento en memoria no tiene esquema")
+		}
+		config.ConnectDB()
+		err := migrar(args)
+		config.CloseDB()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("Comando desconocido %q: usa serve o migrate up|down|status", comando)
+	}
+
+	var repos *repository.Repositorios
+	if cfg.BaseDatos.Almacen == "memory" {
+		log.Println("⚠️ STORE=memory: datos de ejemplo en memoria, se pierden al detener el servidor")
+		if repos, err = memoria.Nuevo(); err != nil {
+			log.Fatal("Error cargando el almacenamiento en memoria: ", err)
+		}
+	} else {
+		config.ConnectDB()
+
+		if err := migrations.VerificarEsquema(config.DB); err != nil {
+			log.Fatal(err, ". Ejecuta `back-menchaca migrate up` antes de iniciar el servidor")
+		}
+
+		repos = repository.NuevoPostgres(config.DB)
+	}
+	utils.UsarAlmacen(repos.Seguridad)
+
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal("Error cargando llaves JWT: ", err)
+	}
+
+	if err := utils.IniciarPolitica(); err != nil {
+		log.Fatal("Error cargando la política de permisos: ", err)
+	}
+
+	if err := mail.Configurar(); err != nil {
+		log.Fatal("Error configurando el envío de correos: ", err)
+	}
 
 	app := fiber.New()
 
+	routes.SetupSaludRoutes(app)
 	
-	app.Use(middleware.Logger())
+	app.Use(middleware.Logger(repos.Logs))
 
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "http://localhost:4200",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowOrigins: strings.Join(cfg.Servidor.CORSOrigenes, ", "),
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-API-Key",
 		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
 	}))
 	
 	app.Use(limiter.New(limiter.Config{
-		Max:        200,                
-		Expiration: 1 * time.Minute,   
+		Max:        cfg.Servidor.LimiteSolicitudes,
+		Expiration: time.Duration(cfg.Servidor.LimiteVentanaSegundos) * time.Second,
 		LimitReached: func(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
 				"statusCode": 429,
@@ -44,22 +107,52 @@ func main() {
 	}))
 
 
+	routes.SetupWellKnownRoutes(app)
+	routes.SetupOAuthRoutes(app)
+
 	api := app.Group("/api")
 	routes.SetupAuthRoutes(api)
-	routes.SetupPacienteRoutes(api)
-	routes.SetupEmpleadoRoutes(api)
-	routes.SetupConsultorioRoutes(api)
-	routes.ConsultasRoutes(api)
-	routes.SetupHorarioRoutes(api)
-	routes.HistorialRoutes(api)
-	routes.SetupRecetasRoutes(api)
-	routes.ExpedienteRoutes(api)
-	routes.AntecedentesRoutes(api)
-	routes.ReportesRoutes(api)
-	routes.AvisoRoutes(api)
-
-
-log.Println(" Servidor iniciado en http://localhost:3000")
-log.Fatal(app.Listen(":3000"))
+	routes.SetupSeguridadRoutes(api)
+	routes.SetupEmergenciaRoutes(api)
+	routes.SetupPacienteRoutes(api, handlers.NuevoPacienteHandler(repos.Pacientes))
+	routes.SetupEmpleadoRoutes(api, handlers.NuevoEmpleadoHandler(repos.Empleados))
+	routes.SetupConsultorioRoutes(api, handlers.NuevoConsultorioHandler(repos.Consultorios))
+	routes.ConsultasRoutes(api, handlers.NuevoConsultaHandler(repos.Consultas, repos.Pacientes, repos.Horarios, repos.Consultorios))
+	routes.SetupHorarioRoutes(api, handlers.NuevoHorarioHandler(repos.Horarios, repos.Empleados, repos.Consultorios))
+	routes.HistorialRoutes(api, handlers.NuevoHistorialHandler(repos.Historial, repos.Expedientes, repos.Consultas))
+	routes.SetupRecetasRoutes(api, handlers.NuevoRecetaHandler(repos.Recetas, repos.Consultorios))
+	routes.ExpedienteRoutes(api, handlers.NuevoExpedienteHandler(repos.Expedientes, repos.Pacientes))
+	routes.AntecedentesRoutes(api, handlers.NuevoAntecedenteHandler(repos.Antecedentes, repos.Expedientes))
+	routes.ReportesRoutes(api, handlers.NuevoReporteHandler(repos.Reportes))
+	routes.AvisoRoutes(api, handlers.NuevoConsentimientoHandler(repos.Consentimientos, repos.Pacientes))
+
+
+	direccion := ":" + strconv.Itoa(cfg.Servidor.Puerto)
+	go func() {
+		log.Println(" Servidor iniciado en http://localhost" + direccion)
+		if err := app.Listen(direccion); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// Apagado ordenado: /readyz deja de responder ok, se terminan las solicitudes
+	// en curso, se escriben los logs pendientes y se cierra el pool
+	senal := make(chan os.Signal, 1)
+	signal.Notify(senal, syscall.SIGINT, syscall.SIGTERM)
+	log.Printf("Señal %v recibida, apagando el servidor", <-senal)
+	handlers.MarcarApagando()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Servidor.ApagadoSegundos)*time.Second)
+	defer cancel()
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		log.Println("⚠️ Error cerrando las conexiones HTTP:", err)
+	}
+	if err := middleware.CerrarLogger(ctx); err != nil {
+		log.Println("⚠️", err)
+	}
+	if err := config.CloseDB(); err != nil {
+		log.Println("⚠️ Error cerrando la base de datos:", err)
+	}
+	log.Println("Servidor detenido")
 }
 
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// usarAccesoEmergencia verifica si el empleado tiene un acceso de emergencia
+// vigente al paciente y, si lo tiene, registra su uso
+func usarAccesoEmergencia(c *fiber.Ctx, idEmpleado string, idPaciente int) (bool, error) {
+	acceso, err := utils.AccesoEmergenciaVigente(idEmpleado, idPaciente)
+	if err != nil || acceso == "" {
+		return false, err
+	}
+
+	email, _ := c.Locals("email").(string)
+	utils.RegistrarEventoSeguridad("acceso_emergencia_uso", email, c.IP(), map[string]interface{}{
+		"acceso":      acceso,
+		"id_paciente": idPaciente,
+		"ruta":        c.Method() + " " + c.Path(),
+	})
+	c.Locals("acceso_emergencia", acceso)
+	return true, nil
+}
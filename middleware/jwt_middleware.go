@@ -1,88 +1,178 @@
 package middleware
 
 import (
+	"back-menchaca/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
-	"os"
+	"log"
 	"strings"
 )
 
+// JWTProtected autentica la solicitud (Bearer o X-API-Key) y verifica con la
+// política de permisos que el sujeto tenga alguno de los permisos requeridos
+// para el método y la ruta. Sin permisos requeridos basta con estar autenticado.
+// Los access tokens emitidos a clientes OAuth no sirven aquí.
 func JWTProtected(requiredPerms ...string) fiber.Handler {
+	utils.DeclararPermisos(requiredPerms...)
 	return func(c *fiber.Ctx) error {
-		auth := c.Get("Authorization")
-		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
-			return c.Status(401).JSON(fiber.Map{
-				"statusCode": 401,
-				"message":    "Token requerido",
-				"from":       "auth-service",
-			})
-		}
+		return autenticar(c, []string{"access"}, requiredPerms)
+	}
+}
+
+// JWTUserInfo autentica /oauth/userinfo, que acepta tanto los access tokens
+// del front end como los emitidos a clientes OAuth
+func JWTUserInfo() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return autenticar(c, []string{"access", utils.TipoTokenOAuth}, nil)
+	}
+}
 
-		tokenStr := strings.TrimPrefix(auth, "Bearer ")
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
+func autenticar(c *fiber.Ctx, tipos []string, requiredPerms []string) error {
+	auth := c.Get("Authorization")
+	if llave := c.Get("X-API-Key"); llave != "" && auth == "" {
+		return autenticarAPIKey(c, llave, requiredPerms)
+	}
+	if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
+		return c.Status(401).JSON(fiber.Map{
+			"statusCode": 401,
+			"message":    "Token requerido",
+			"from":       "auth-service",
 		})
+	}
 
-		if err != nil || !token.Valid {
-			return c.Status(401).JSON(fiber.Map{
-				"statusCode": 401,
-				"message":    "Token inválido o expirado",
-				"from":       "auth-service",
-			})
+	tokenStr := strings.TrimPrefix(auth, "Bearer ")
+	var (
+		claims jwt.MapClaims
+		err    error
+	)
+	for _, tipo := range tipos {
+		if claims, err = utils.ParseToken(tokenStr, tipo); err == nil {
+			break
 		}
+	}
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{
+			"statusCode": 401,
+			"message":    "Token inválido o expirado",
+			"from":       "auth-service",
+		})
+	}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(401).JSON(fiber.Map{
-				"statusCode": 401,
-				"message":    "Claims inválidos",
-			})
-		}
+	// Verificar que el token no haya sido revocado (logout o cierre de todas las sesiones)
+	jti, _ := claims["jti"].(string)
+	id, _ := claims["id"].(string)
+	rol, _ := claims["rol"].(string)
+	iat, errIat := claims.GetIssuedAt()
+	if jti == "" || id == "" || errIat != nil || iat == nil {
+		return c.Status(401).JSON(fiber.Map{
+			"statusCode": 401,
+			"message":    "Token inválido o expirado",
+			"from":       "auth-service",
+		})
+	}
 
-		// Obtener permisos desde el token
-		// Obtener permisos desde el token
-		// Obtener permisos desde el token (manejo robusto)
-permisosToken := map[string]bool{}
-if permsRaw, ok := claims["permisos"]; ok {
-	permsIface, ok := permsRaw.([]interface{})
-	if !ok {
+	revocado, err := utils.IsAccessTokenRevoked(jti, id, rol, iat.Time)
+	if err != nil {
+		log.Printf("Error verificando revocación del token: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"statusCode": 500,
+			"message":    "Error verificando el token",
+			"from":       "auth-service",
+		})
+	}
+	if revocado {
 		return c.Status(401).JSON(fiber.Map{
 			"statusCode": 401,
-			"message":    "Formato inválido en permisos",
+			"message":    "Token revocado",
+			"from":       "auth-service",
 		})
 	}
 
-	for _, p := range permsIface {
-		permStr, ok := p.(string)
-		if !ok {
-			continue
+	// Los permisos de un usuario salen de la política vigente, no del token: un
+	// cambio de permisos del rol aplica sin esperar a que el token expire. Los
+	// de una cuenta de servicio son los del token que la cuenta aún conserva.
+	politica := utils.PoliticaActual()
+	var permisosToken map[string]bool
+	if rol == utils.RolServicio {
+		permisosToken, err = utils.PermisosTokenServicio(claims)
+		if err != nil {
+			log.Printf("Error cargando permisos de la cuenta de servicio: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"statusCode": 500,
+				"message":    "Error verificando el token",
+				"from":       "auth-service",
+			})
+		}
+	} else {
+		permisosToken = map[string]bool{}
+		for _, p := range politica.PermisosDeRol(rol) {
+			permisosToken[p] = true
 		}
-		permisosToken[permStr] = true
 	}
-}
+	if !politica.Autoriza(rol, permisosToken, requiredPerms, c.Method(), c.Path()) {
+		return c.Status(403).JSON(fiber.Map{
+			"statusCode": 403,
+			"message":    "Permiso insuficiente",
+		})
+	}
 
+	// Guardar info útil en el contexto
+	c.Locals("email", claims["email"])
+	c.Locals("id", id)
+	c.Locals("identidad", claims["idn"])
+	c.Locals("tipo", claims["tipo"])
+	c.Locals("rol", rol)
+	c.Locals("jti", jti)
+	c.Locals("permisos", permisosToken)
+	c.Locals("iat", iat.Time)
+	c.Locals("scope", claims["scope"])
+	c.Locals("client_id", claims["client_id"])
+	guardarAutenticacion(c, claims)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		c.Locals("exp", exp.Time)
+	}
 
-		
-		// Verifica si el usuario tiene al menos uno de los permisos requeridos
-		autorizado := false
-		for _, reqPerm := range requiredPerms {
-			if permisosToken[reqPerm] {
-				autorizado = true
-				break
-			}
-		}
+	return c.Next()
+}
 
-		if !autorizado {
-			return c.Status(403).JSON(fiber.Map{
-				"statusCode": 403,
-				"message":    "Permiso insuficiente",
+// autenticarAPIKey autentica a una cuenta de servicio por su API key. Los
+// permisos son los de la cuenta de servicio.
+func autenticarAPIKey(c *fiber.Ctx, llave string, requiredPerms []string) error {
+	cuenta, idLlave, err := utils.AutenticarAPIKey(llave)
+	if err != nil {
+		if err != utils.ErrAPIKeyInvalida {
+			log.Printf("Error verificando API key: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"statusCode": 500,
+				"message":    "Error verificando la API key",
+				"from":       "auth-service",
 			})
 		}
+		return c.Status(401).JSON(fiber.Map{
+			"statusCode": 401,
+			"message":    "API key inválida o expirada",
+			"from":       "auth-service",
+		})
+	}
 
-		// Guardar info útil en el contexto
-		c.Locals("email", claims["email"])
-		c.Locals("permisos", permisosToken)
-
-		return c.Next()
+	permisos := map[string]bool{}
+	for _, p := range cuenta.Permisos {
+		permisos[p] = true
 	}
+	if !utils.PoliticaActual().Autoriza(utils.RolServicio, permisos, requiredPerms, c.Method(), c.Path()) {
+		return c.Status(403).JSON(fiber.Map{
+			"statusCode": 403,
+			"message":    "Permiso insuficiente",
+		})
+	}
+
+	p := cuenta.Principal()
+	c.Locals("email", p.Correo)
+	c.Locals("id", p.ID)
+	c.Locals("tipo", p.Tipo)
+	c.Locals("rol", p.Rol)
+	c.Locals("permisos", permisos)
+	c.Locals("client_id", cuenta.ID)
+	c.Locals("api_key", idLlave)
+	return c.Next()
 }
@@ -0,0 +1,154 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"back-menchaca/handlers"
+	"back-menchaca/middleware"
+	"back-menchaca/repository/memoria"
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMain(m *testing.M) {
+	repos, err := memoria.Nuevo()
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.UsarAlmacen(repos.Seguridad)
+	if err := utils.LoadJWTKeys(); err != nil {
+		log.Fatal(err)
+	}
+	if err := utils.RecargarPolitica(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(m.Run())
+}
+
+// appServicio monta el endpoint de token y dos rutas con permisos distintos
+func appServicio() *fiber.App {
+	app := fiber.New()
+	app.Post("/oauth/token", handlers.OAuthToken)
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Get("/api/consultas", middleware.JWTProtected("ver_citas"), ok)
+	app.Get("/api/recetas", middleware.JWTProtected("ver_recetas"), ok)
+	app.Get("/api/seguridad/bloqueos", middleware.JWTProtected("administrar_seguridad"), ok)
+	return app
+}
+
+func cuentaConLlave(t *testing.T, nombre string, permisos []string) (*utils.CuentaServicio, string) {
+	t.Helper()
+	cuenta, err := utils.CrearCuentaServicio(nombre, "", permisos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, llave, err := utils.CrearAPIKey(cuenta.ID, "pruebas", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cuenta, llave
+}
+
+func estado(t *testing.T, app *fiber.App, ruta string, encabezados map[string]string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, ruta, nil)
+	for k, v := range encabezados {
+		req.Header.Set(k, v)
+	}
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func TestTokenServicioLimitadoASuScope(t *testing.T) {
+	app := appServicio()
+	cuenta, llave := cuentaConLlave(t, "laboratorio", []string{"ver_citas", "ver_recetas"})
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {cuenta.ID},
+		"client_secret": {llave},
+		"scope":         {"ver_citas"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Fatalf("client_credentials respondió %d", res.StatusCode)
+	}
+	var cuerpo struct {
+		AccessToken string `json:"access_token"`
+		Scope       string `json:"scope"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&cuerpo); err != nil {
+		t.Fatal(err)
+	}
+	if cuerpo.Scope != "ver_citas" {
+		t.Fatalf("scope = %q, se esperaba ver_citas", cuerpo.Scope)
+	}
+
+	bearer := map[string]string{"Authorization": "Bearer " + cuerpo.AccessToken}
+	if got := estado(t, app, "/api/consultas", bearer); got != fiber.StatusOK {
+		t.Errorf("permiso dentro del scope: %d, se esperaba 200", got)
+	}
+	if got := estado(t, app, "/api/recetas", bearer); got != fiber.StatusForbidden {
+		t.Errorf("permiso de la cuenta fuera del scope: %d, se esperaba 403", got)
+	}
+
+	// La API key conserva todos los permisos de la cuenta
+	apiKey := map[string]string{"X-API-Key": llave}
+	if got := estado(t, app, "/api/recetas", apiKey); got != fiber.StatusOK {
+		t.Errorf("API key con ver_recetas: %d, se esperaba 200", got)
+	}
+}
+
+func TestTokenServicioNoAmpliaLosPermisosDeLaCuenta(t *testing.T) {
+	app := appServicio()
+	cuenta, llave := cuentaConLlave(t, "facturacion", []string{"ver_citas"})
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {cuenta.ID},
+		"client_secret": {llave},
+		"scope":         {"ver_citas ver_recetas"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationForm)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("scope con un permiso ajeno: %d, se esperaba 400 invalid_scope", res.StatusCode)
+	}
+
+	// Un token con permisos que la cuenta no tiene solo vale por los que sí tiene
+	token, err := utils.GenerateServiceToken(cuenta, []string{"ver_citas", "ver_recetas", "administrar_seguridad"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer := map[string]string{"Authorization": "Bearer " + token}
+	casos := map[string]int{
+		"/api/consultas":          fiber.StatusOK,
+		"/api/recetas":            fiber.StatusForbidden,
+		"/api/seguridad/bloqueos": fiber.StatusForbidden,
+	}
+	for ruta, esperado := range casos {
+		if got := estado(t, app, ruta, bearer); got != esperado {
+			t.Errorf("%s: %d, se esperaba %d", ruta, got, esperado)
+		}
+	}
+}
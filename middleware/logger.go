@@ -1,116 +1,103 @@
 package middleware
 
 import (
-	"back-menchaca/config"
+	"back-menchaca/models"
+	"back-menchaca/repository"
 	"encoding/json"
 	"github.com/gofiber/fiber/v2"
 	"log"
+	"net/url"
 	"runtime"
 	"strings"
     "fmt"
 	"time"
-    "math"
     "context"
+	"sync"
 )
 
-func Logger() fiber.Handler {
+// Los logs se guardan en segundo plano para no retrasar la respuesta. Al
+// apagar el servidor CerrarLogger espera a que se escriban los pendientes.
+const tamanoColaLogs = 1000
+
+var (
+	colaLogs       = make(chan models.Log, tamanoColaLogs)
+	colaLogsMu     sync.RWMutex
+	colaCerrada    bool
+	iniciarLogs    sync.Once
+	logsTerminados = make(chan struct{})
+)
+
+func Logger(logs repository.Logs) fiber.Handler {
+	iniciarLogs.Do(func() {
+		go escribirLogs(logs)
+	})
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
 		err := c.Next()
 		status := c.Response().StatusCode()
 
-		logEntry := map[string]interface{}{
-			"timestamp":     time.Now(),
-			"method":        c.Method(),
-			"path":          c.Path(),
-			"status":        status,
-			"response_time": time.Since(start).Milliseconds(),
-			"ip":            c.IP(),
-			"user_agent":    c.Get("User-Agent"),
-			"level":         getLevel(status),
-			"system": map[string]interface{}{
+		logEntry := models.Log{
+			Timestamp:    time.Now(),
+			Method:       c.Method(),
+			Path:         c.Path(),
+			Status:       status,
+			ResponseTime: time.Since(start).Milliseconds(),
+			IP:           c.IP(),
+			UserAgent:    c.Get("User-Agent"),
+			Level:        getLevel(status),
+			System: map[string]interface{}{
 				"goVersion": strings.TrimPrefix(runtime.Version(), "go"),
 			},
-			"body": safeGetBody(c),
+			Body: safeGetBody(c),
 		}
 
-		// Usar consulta directa con parámetros posicionales
-		query := `
-			INSERT INTO logs (
-				timestamp, method, path, status, response_time, ip, 
-				user_agent, level, system, body
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		`
-
-		_, execErr := config.DB.Exec(query,
-			logEntry["timestamp"],
-			logEntry["method"],
-			logEntry["path"],
-			logEntry["status"],
-			logEntry["response_time"],
-			logEntry["ip"],
-			logEntry["user_agent"],
-			logEntry["level"],
-			toJSON(logEntry["system"]),
-			toJSON(logEntry["body"]),
-		)
-
-		if execErr != nil {
-			log.Printf("⚠️ Error insertando log (query directa): %v", execErr)
-		}
+		encolarLog(logEntry)
 
 		return err
 	}
 }
 
-func saveLogWithRetry(logEntry map[string]interface{}) error {
-	// Consulta SQL directa (sin prepared statement)
-	query := `
-		INSERT INTO logs (
-			timestamp, method, path, status, response_time, ip, 
-			user_agent, level, system, body
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
-
-	maxRetries := 2
-	var lastError error
-
-	for i := 0; i < maxRetries; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-
-		// Usar QueryRowContext en lugar de Exec para mejor manejo de errores
-		_, err := config.DB.ExecContext(ctx, query,
-			logEntry["timestamp"],
-			logEntry["method"],
-			logEntry["path"],
-			logEntry["status"],
-			logEntry["response_time"],
-			logEntry["ip"],
-			logEntry["user_agent"],
-			logEntry["level"],
-			toJSON(logEntry["system"]),
-			toJSON(logEntry["body"]),
-		)
-
-		if err == nil {
-			return nil
-		}
-
-		lastError = err
+// encolarLog deja el registro para el escritor; si la cola está llena o
+// cerrada se descarta para no bloquear la solicitud
+func encolarLog(logEntry models.Log) {
+	colaLogsMu.RLock()
+	defer colaLogsMu.RUnlock()
+	if colaCerrada {
+		return
+	}
+	select {
+	case colaLogs <- logEntry:
+	default:
+		log.Printf("⚠️ Cola de logs llena, se descartó el log de %v %v", logEntry.Method, logEntry.Path)
+	}
+}
 
-		// Manejar específicamente el error de prepared statement
-		if strings.Contains(err.Error(), "unnamed prepared statement does not exist") {
-			// Reintentar inmediatamente con nueva conexión
-			time.Sleep(100 * time.Millisecond)
-			continue
+func escribirLogs(logs repository.Logs) {
+	defer close(logsTerminados)
+	for logEntry := range colaLogs {
+		if err := logs.Guardar(logEntry); err != nil {
+			log.Printf("⚠️ Error insertando log: %v", err)
 		}
+	}
+}
 
-		// Para otros errores, hacer backoff exponencial
-		time.Sleep(time.Duration(math.Pow(2, float64(i))) * time.Second)
+// CerrarLogger deja de aceptar logs y espera a que se escriban los pendientes
+// o a que venza el contexto
+func CerrarLogger(ctx context.Context) error {
+	colaLogsMu.Lock()
+	if !colaCerrada {
+		colaCerrada = true
+		close(colaLogs)
 	}
+	colaLogsMu.Unlock()
 
-	return fmt.Errorf("after %d attempts: %v", maxRetries, lastError)
+	iniciarLogs.Do(func() { close(logsTerminados) })
+	select {
+	case <-logsTerminados:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("quedaron %d logs sin escribir: %w", len(colaLogs), ctx.Err())
+	}
 }
 
 // safeGetBody obtiene el cuerpo de forma segura
@@ -127,6 +114,18 @@ func safeGetBody(c *fiber.Ctx) interface{} {
 
 	var body interface{}
 	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		// El endpoint de token de OAuth recibe un formulario con client_secret,
+		// code_verifier y refresh_token; se limpia igual que un JSON
+		if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationForm) {
+			if valores, err := url.ParseQuery(string(c.Body())); err == nil {
+				for campo := range valores {
+					if campoSensible(campo) {
+						valores.Del(campo)
+					}
+				}
+				return sanitizeBody(valores.Encode())
+			}
+		}
 		// Si falla el unmarshal, devolver como string (limitado a 1KB)
 		bodyStr := string(c.Body())
 		if len(bodyStr) > 1024 {
@@ -134,7 +133,7 @@ func safeGetBody(c *fiber.Ctx) interface{} {
 		}
 		return bodyStr
 	}
-	return body
+	return sanitizeBody(body)
 }
 
 
@@ -148,63 +147,6 @@ func getLevel(status int) string {
 	return "info"
 }
 
-// toJSON convierte a JSON de forma segura
-func toJSON(value interface{}) string {
-	if value == nil {
-		return "null"
-	}
-	data, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Sprintf(`{"json_error":"%v"}`, err.Error())
-	}
-	return string(data)
-}
-
-
-// Función separada para guardar logs con reintentos
-func saveLogEntry(entry map[string]interface{}) error {
-	stmt := `
-		INSERT INTO logs (
-			timestamp, method, path, status, response_time, ip, 
-			user_agent, level, request_id, system, body
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`
-
-	maxRetries := 3
-	var lastError error
-
-	for i := 0; i < maxRetries; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
-
-		_, err := config.DB.ExecContext(ctx, stmt,
-			entry["timestamp"],
-			entry["method"],
-			entry["path"],
-			entry["status"],
-			entry["response_time"],
-			entry["ip"],
-			entry["user_agent"],
-			entry["level"],
-			entry["request_id"],
-			toJSON(entry["system"]),
-			toJSON(entry["body"]),
-		)
-
-		if err == nil {
-			return nil
-		}
-
-		lastError = err
-		log.Printf("Intento %d fallido: %v", i+1, err)
-
-		// Esperar antes de reintentar (backoff exponencial)
-		time.Sleep(time.Duration(math.Pow(2, float64(i))) * time.Second)
-	}
-
-	return fmt.Errorf("fallo después de %d intentos: %v", maxRetries, lastError)
-}
-
 // Sanitizar el body para evitar problemas
 func sanitizeBody(body interface{}) interface{} {
 	if body == nil {
@@ -218,16 +160,48 @@ func sanitizeBody(body interface{}) interface{} {
 		}
 		return v
 	case map[string]interface{}:
-		// Eliminar campos sensibles
-		delete(v, "password")
-		delete(v, "token")
-		delete(v, "access_token")
-		delete(v, "refresh_token")
+		// Eliminar campos sensibles, también dentro de objetos anidados
+		for campo, valor := range v {
+			if campoSensible(campo) {
+				delete(v, campo)
+				continue
+			}
+			v[campo] = sanitizeBody(valor)
+		}
+		return v
+	case []interface{}:
+		for i, valor := range v {
+			v[i] = sanitizeBody(valor)
+		}
 		return v
 	default:
 		return body
 	}
 }
 
+// camposSensibles son los nombres (en minúsculas) que nunca se guardan en los
+// logs: contraseñas, tokens, códigos de recuperación y secretos de OAuth
+var camposSensibles = map[string]bool{
+	"password":           true,
+	"contrasena":         true,
+	"contrasenaactual":   true,
+	"contrasenanueva":    true,
+	"nuevacontrasena":    true,
+	"token":              true,
+	"temptoken":          true,
+	"access_token":       true,
+	"refresh_token":      true,
+	"refreshtoken":       true,
+	"codigorecuperacion": true,
+	"totp":               true,
+	"code":               true,
+	"code_verifier":      true,
+	"client_secret":      true,
+}
+
+func campoSensible(campo string) bool {
+	return camposSensibles[strings.ToLower(campo)]
+}
+
 
 
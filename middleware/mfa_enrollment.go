@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"back-menchaca/utils"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"strings"
+)
+
+// JWTOEnrolamientoMFA acepta un access token normal o el token de enrolamiento
+// que entrega el login cuando el rol exige MFA y el usuario aún no lo configura.
+// El token de enrolamiento deja de servir al revocarse su jti (al completar el
+// enrolamiento) o al cerrar todas las sesiones del usuario.
+func JWTOEnrolamientoMFA() fiber.Handler {
+	protegido := JWTProtected()
+	return func(c *fiber.Ctx) error {
+		tokenStr := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+
+		claims, err := utils.ParseToken(tokenStr, "mfa_enroll")
+		if err != nil {
+			return protegido(c)
+		}
+
+		jti, _ := claims["jti"].(string)
+		id, _ := claims["id"].(string)
+		rol, _ := claims["rol"].(string)
+		iat, errIat := claims.GetIssuedAt()
+		if jti == "" || id == "" || errIat != nil || iat == nil {
+			return c.Status(401).JSON(fiber.Map{
+				"statusCode": 401,
+				"message":    "Token inválido o expirado",
+				"from":       "auth-service",
+			})
+		}
+		revocado, err := utils.IsAccessTokenRevoked(jti, id, rol, iat.Time)
+		if err != nil {
+			log.Printf("Error verificando revocación del token de enrolamiento: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"statusCode": 500,
+				"message":    "Error verificando el token",
+				"from":       "auth-service",
+			})
+		}
+		if revocado {
+			return c.Status(401).JSON(fiber.Map{
+				"statusCode": 401,
+				"message":    "Token revocado",
+				"from":       "auth-service",
+			})
+		}
+
+		c.Locals("id", id)
+		c.Locals("identidad", claims["idn"])
+		c.Locals("tipo", claims["tipo"])
+		c.Locals("email", claims["email"])
+		c.Locals("rol", rol)
+		c.Locals("jti", jti)
+		if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+			c.Locals("exp", exp.Time)
+		}
+		c.Locals("enrolamiento", true)
+		return c.Next()
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"back-menchaca/utils"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"strings"
+)
+
+// JWTOCambioContrasena acepta un access token normal o el token de cambio de
+// contraseña que entrega el login cuando la contraseña del personal expiró
+func JWTOCambioContrasena() fiber.Handler {
+	protegido := JWTProtected()
+	return func(c *fiber.Ctx) error {
+		tokenStr := strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+
+		claims, err := utils.ParseToken(tokenStr, "pwd_change")
+		if err != nil {
+			return protegido(c)
+		}
+
+		jti, _ := claims["jti"].(string)
+		id, _ := claims["id"].(string)
+		rol, _ := claims["rol"].(string)
+		iat, errIat := claims.GetIssuedAt()
+		exp, errExp := claims.GetExpirationTime()
+		if jti == "" || id == "" || errIat != nil || iat == nil || errExp != nil || exp == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"statusCode": fiber.StatusUnauthorized,
+				"intCode":    "A01",
+				"message":    "Token de cambio de contraseña inválido",
+				"from":       "auth-service",
+			})
+		}
+		// Como el token de enrolamiento, deja de servir al usarse (jti) o al
+		// cerrar todas las sesiones del usuario
+		revocado, err := utils.IsAccessTokenRevoked(jti, id, rol, iat.Time)
+		if err != nil {
+			log.Printf("Error verificando revocación del token de cambio de contraseña: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"statusCode": fiber.StatusInternalServerError,
+				"intCode":    "A03",
+				"message":    "Error verificando el token",
+				"from":       "auth-service",
+			})
+		}
+		if revocado {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"statusCode": fiber.StatusUnauthorized,
+				"intCode":    "A01",
+				"message":    "Token de cambio de contraseña inválido",
+				"from":       "auth-service",
+			})
+		}
+
+		c.Locals("id", id)
+		c.Locals("identidad", claims["idn"])
+		c.Locals("tipo", claims["tipo"])
+		c.Locals("email", claims["email"])
+		c.Locals("rol", rol)
+		c.Locals("jti", jti)
+		c.Locals("exp", exp.Time)
+		c.Locals("cambioObligatorio", true)
+		guardarAutenticacion(c, claims)
+		return c.Next()
+	}
+}
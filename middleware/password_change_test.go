@@ -0,0 +1,41 @@
+package middleware_test
+
+import (
+	"testing"
+
+	"back-menchaca/middleware"
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// El token de cambio de contraseña deja de servir al cerrar todas las sesiones
+// del usuario, igual que un access token
+func TestTokenCambioContrasenaRevocadoConLasSesiones(t *testing.T) {
+	app := fiber.New()
+	app.Post("/cambio", middleware.JWTOCambioContrasena(), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	cuenta, err := utils.BuscarCuentaPorCorreo("laura.garcia@menchaca.demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cuenta.SeleccionarRol("") {
+		t.Fatal("laura.garcia tiene más de un rol")
+	}
+	token, err := utils.GeneratePasswordChangeToken(cuenta.Principal())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := enviarJSON(t, app, fiber.MethodPost, "/cambio", token, `{}`); got != fiber.StatusOK {
+		t.Fatalf("token vigente: %d, se esperaba 200", got)
+	}
+	if err := utils.RevocarSesionesCuenta(cuenta); err != nil {
+		t.Fatal(err)
+	}
+	if got := enviarJSON(t, app, fiber.MethodPost, "/cambio", token, `{}`); got != fiber.StatusUnauthorized {
+		t.Errorf("token tras cerrar las sesiones: %d, se esperaba 401", got)
+	}
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"back-menchaca/utils"
+	"log"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PacienteDeSolicitud obtiene el paciente al que se refiere una solicitud
+type PacienteDeSolicitud func(c *fiber.Ctx) (int, bool)
+
+// PacienteDelCuerpo lee id_paciente del cuerpo JSON
+func PacienteDelCuerpo(c *fiber.Ctx) (int, bool) {
+	var body struct {
+		IDPaciente int `json:"id_paciente"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.IDPaciente <= 0 {
+		return 0, false
+	}
+	return body.IDPaciente, true
+}
+
+// PacienteDelExpediente lee id_expediente del cuerpo JSON y busca a su paciente
+func PacienteDelExpediente(c *fiber.Ctx) (int, bool) {
+	var body struct {
+		IDExpediente int `json:"id_expediente"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.IDExpediente <= 0 {
+		return 0, false
+	}
+	idPaciente, err := utils.PacienteDeExpediente(body.IDExpediente)
+	return idPaciente, err == nil
+}
+
+// PacienteDeLaConsulta lee id_consulta del cuerpo JSON y busca a su paciente
+func PacienteDeLaConsulta(c *fiber.Ctx) (int, bool) {
+	var body struct {
+		IDConsulta int `json:"id_consulta"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.IDConsulta <= 0 {
+		return 0, false
+	}
+	idPaciente, err := utils.PacienteDeConsulta(body.IDConsulta)
+	return idPaciente, err == nil
+}
+
+// PacienteDeLaReceta lee id_receta del cuerpo JSON y busca a su paciente
+func PacienteDeLaReceta(c *fiber.Ctx) (int, bool) {
+	var body struct {
+		IDReceta int `json:"id_receta"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.IDReceta <= 0 {
+		return 0, false
+	}
+	idPaciente, err := utils.PacienteDeReceta(body.IDReceta)
+	return idPaciente, err == nil
+}
+
+// PacienteDelAntecedente lee id_antecedente del cuerpo JSON y busca a su paciente
+func PacienteDelAntecedente(c *fiber.Ctx) (int, bool) {
+	var body struct {
+		IDAntecedente int `json:"id_antecedente"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.IDAntecedente <= 0 {
+		return 0, false
+	}
+	idPaciente, err := utils.PacienteDeAntecedente(body.IDAntecedente)
+	return idPaciente, err == nil
+}
+
+// PacienteDelHistorial lee id_historial del cuerpo JSON y busca a su paciente
+func PacienteDelHistorial(c *fiber.Ctx) (int, bool) {
+	var body struct {
+		IDHistorial int `json:"id_historial"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.IDHistorial <= 0 {
+		return 0, false
+	}
+	idPaciente, err := utils.PacienteDeHistorial(body.IDHistorial)
+	return idPaciente, err == nil
+}
+
+// AccesoPaciente se usa después de JWTProtected() en las rutas que leen o
+// modifican los registros de un paciente. Exige alguno de los permisos y que
+// el registro esté al alcance del usuario:
+//   - un paciente solo accede a sus propios registros (su claim id);
+//   - un empleado, a los pacientes que atiende él o su área, salvo que su rol
+//     tenga ver_todos_los_pacientes;
+//   - las cuentas de servicio no tienen límite por paciente.
+//
+// Sin el permiso responde 403 antes de buscar el registro.
+func AccesoPaciente(paciente PacienteDeSolicitud, requiredPerms ...string) fiber.Handler {
+	return accesoPaciente(paciente, false, requiredPerms)
+}
+
+// LecturaPaciente es AccesoPaciente para las rutas que solo leen: si el
+// empleado tiene el permiso pero el paciente está fuera de su alcance, un
+// acceso de emergencia vigente a ese paciente permite la solicitud y su uso
+// queda registrado.
+func LecturaPaciente(paciente PacienteDeSolicitud, requiredPerms ...string) fiber.Handler {
+	return accesoPaciente(paciente, true, requiredPerms)
+}
+
+func accesoPaciente(paciente PacienteDeSolicitud, lectura bool, requiredPerms []string) fiber.Handler {
+	utils.DeclararPermisos(requiredPerms...)
+	return func(c *fiber.Ctx) error {
+		rol, _ := c.Locals("rol").(string)
+		tipo, _ := c.Locals("tipo").(string)
+		id, _ := c.Locals("id").(string)
+		permisos, _ := c.Locals("permisos").(map[string]bool)
+
+		politica := utils.PoliticaActual()
+		if !politica.Autoriza(rol, permisos, requiredPerms, c.Method(), c.Path()) {
+			return c.Status(403).JSON(fiber.Map{
+				"statusCode": 403,
+				"message":    "Permiso insuficiente",
+			})
+		}
+
+		idPaciente, ok := paciente(c)
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{
+				"statusCode": 404,
+				"message":    "Registro no encontrado",
+			})
+		}
+
+		alcance, err := pacienteAlAlcance(politica, tipo, rol, id, idPaciente)
+		if err != nil {
+			log.Printf("Error verificando el alcance del usuario: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"statusCode": 500,
+				"message":    "Error verificando permisos",
+			})
+		}
+		if alcance {
+			return c.Next()
+		}
+
+		if lectura && tipo == "empleado" {
+			usado, err := usarAccesoEmergencia(c, id, idPaciente)
+			if err != nil {
+				log.Printf("Error verificando acceso de emergencia: %v", err)
+				return c.Status(500).JSON(fiber.Map{
+					"statusCode": 500,
+					"message":    "Error verificando permisos",
+				})
+			}
+			if usado {
+				return c.Next()
+			}
+		}
+
+		email, _ := c.Locals("email").(string)
+		utils.RegistrarEventoSeguridad("acceso_paciente_denegado", email, c.IP(), map[string]interface{}{
+			"id_paciente": idPaciente,
+			"ruta":        c.Method() + " " + c.Path(),
+		})
+		return c.Status(403).JSON(fiber.Map{
+			"statusCode": 403,
+			"message":    "Permiso insuficiente",
+		})
+	}
+}
+
+// pacienteAlAlcance aplica el límite por paciente según el tipo de usuario
+func pacienteAlAlcance(politica *utils.Politica, tipo, rol, id string, idPaciente int) (bool, error) {
+	switch tipo {
+	case "paciente":
+		return id == strconv.Itoa(idPaciente), nil
+	case "empleado":
+		if politica.TienePermiso(rol, utils.PermisoTodosLosPacientes) {
+			return true, nil
+		}
+		return utils.EmpleadoAtiendePaciente(id, idPaciente)
+	case utils.RolServicio:
+		return true, nil
+	}
+	return false, nil
+}
+
+// SinPacientes se usa después de JWTProtected() en los listados completos de
+// registros de pacientes: un paciente debe usar las rutas que reciben su id
+func SinPacientes() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if tipo, _ := c.Locals("tipo").(string); tipo == "paciente" {
+			return c.Status(403).JSON(fiber.Map{
+				"statusCode": 403,
+				"message":    "Permiso insuficiente",
+			})
+		}
+		return c.Next()
+	}
+}
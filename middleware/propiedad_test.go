@@ -0,0 +1,86 @@
+package middleware_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"back-menchaca/middleware"
+	"back-menchaca/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// appPaciente monta una ruta de lectura y dos de escritura sobre los datos de
+// ejemplo: Carlos (doctor de Pediatría) no atiende a Juan (paciente 1) y Ana
+// (enfermera) no tiene actualizar_citas
+func appPaciente() *fiber.App {
+	app := fiber.New()
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app.Post("/lectura", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelCuerpo, "ver_pacientes"), ok)
+	app.Put("/paciente", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelCuerpo, "actualizar_pacientes"), ok)
+	app.Put("/consulta", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDeLaConsulta, "actualizar_citas"), ok)
+	return app
+}
+
+// empleadoConEmergencia genera el token del empleado y le otorga un acceso de
+// emergencia al paciente
+func empleadoConEmergencia(t *testing.T, correo string, idPaciente int) string {
+	t.Helper()
+	cuenta, err := utils.BuscarCuentaPorCorreo(correo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cuenta.SeleccionarRol("") {
+		t.Fatalf("%s tiene más de un rol", correo)
+	}
+	if _, err := utils.OtorgarAccesoEmergencia(cuenta.Principal(), idPaciente, "Urgencia en pruebas", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	token, err := utils.GenerateJWT(cuenta.Principal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func enviarJSON(t *testing.T, app *fiber.App, metodo, ruta, token, cuerpo string) int {
+	t.Helper()
+	req := httptest.NewRequest(metodo, ruta, strings.NewReader(cuerpo))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	res, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+// El acceso de emergencia reemplaza el alcance por paciente solo en lecturas
+func TestAccesoEmergenciaSoloEnLecturas(t *testing.T) {
+	app := appPaciente()
+	carlos := empleadoConEmergencia(t, "carlos.ramirez@menchaca.demo", 1)
+
+	if got := enviarJSON(t, app, fiber.MethodPost, "/lectura", carlos, `{"id_paciente":1}`); got != fiber.StatusOK {
+		t.Errorf("lectura con acceso de emergencia: %d, se esperaba 200", got)
+	}
+	if got := enviarJSON(t, app, fiber.MethodPut, "/paciente", carlos, `{"id_paciente":1}`); got != fiber.StatusForbidden {
+		t.Errorf("escritura con acceso de emergencia: %d, se esperaba 403", got)
+	}
+	if got := enviarJSON(t, app, fiber.MethodPost, "/lectura", carlos, `{"id_paciente":3}`); got != fiber.StatusForbidden {
+		t.Errorf("lectura de otro paciente fuera del área: %d, se esperaba 403", got)
+	}
+}
+
+// El permiso se revisa antes que el registro y el acceso de emergencia no lo reemplaza
+func TestAccesoPacienteExigePermisoPrimero(t *testing.T) {
+	app := appPaciente()
+	ana := empleadoConEmergencia(t, "ana.lopez@menchaca.demo", 2)
+
+	if got := enviarJSON(t, app, fiber.MethodPut, "/consulta", ana, `{"id_consulta":2}`); got != fiber.StatusForbidden {
+		t.Errorf("sin actualizar_citas y con acceso de emergencia: %d, se esperaba 403", got)
+	}
+	if got := enviarJSON(t, app, fiber.MethodPut, "/consulta", ana, `{"id_consulta":99}`); got != fiber.StatusForbidden {
+		t.Errorf("sin actualizar_citas y con un registro inexistente: %d, se esperaba 403", got)
+	}
+}
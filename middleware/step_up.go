@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"back-menchaca/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// guardarAutenticacion deja en el contexto cuándo y cómo se autenticó el
+// usuario (claims auth_time y amr) para RequiereStepUp
+func guardarAutenticacion(c *fiber.Ctx, claims jwt.MapClaims) {
+	p := utils.PrincipalDesdeClaims(claims)
+	c.Locals("amr", p.AMR)
+	if !p.AuthTime.IsZero() {
+		c.Locals("auth_time", p.AuthTime)
+	}
+}
+
+// RequiereStepUp se usa después de JWTProtected() en operaciones sensibles:
+// exige que el token se haya emitido con un segundo factor (TOTP, código de
+// recuperación o llave de seguridad) en los últimos minutos. Con ventana 0 se
+// usa STEP_UP_MINUTOS. Si no se cumple responde SU01 para que el front end pida
+// el código y obtenga un token nuevo en /api/auth/step-up.
+func RequiereStepUp(ventana time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		v := ventana
+		if v == 0 {
+			v = utils.VentanaStepUp()
+		}
+
+		amr, _ := c.Locals("amr").([]string)
+		authTime, _ := c.Locals("auth_time").(time.Time)
+		p := utils.Principal{AMR: amr, AuthTime: authTime}
+		if p.TieneSegundoFactor() && time.Since(authTime) <= v {
+			return c.Next()
+		}
+
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"statusCode": fiber.StatusUnauthorized,
+			"intCode":    "SU01",
+			"message":    "Esta operación requiere confirmar tu segundo factor de autenticación",
+			"from":       "auth-service",
+			"data": fiber.Map{
+				"stepUpRequired": true,
+				"ventanaMinutos": int(v.Minutes()),
+			},
+		})
+	}
+}
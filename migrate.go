@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"back-menchaca/config"
+	"back-menchaca/migrations"
+)
+
+const usoMigrate = "uso: back-menchaca migrate up | down [n] | status"
+
+// migrar ejecuta el subcomando migrate con la base ya conectada
+func migrar(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(usoMigrate)
+	}
+
+	switch args[0] {
+	case "up":
+		aplicadas, err := migrations.Subir(config.DB)
+		for _, m := range aplicadas {
+			fmt.Printf("aplicada  %04d_%s\n", m.Version, m.Nombre)
+		}
+		if err != nil {
+			return err
+		}
+		if len(aplicadas) == 0 {
+			fmt.Println("El esquema ya está al día")
+		}
+		return nil
+
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("down espera un número de migraciones mayor a 0 (%s)", usoMigrate)
+			}
+		}
+		revertidas, err := migrations.Bajar(config.DB, n)
+		for _, m := range revertidas {
+			fmt.Printf("revertida %04d_%s\n", m.Version, m.Nombre)
+		}
+		if err != nil {
+			return err
+		}
+		if len(revertidas) == 0 {
+			fmt.Println("No hay migraciones aplicadas")
+		}
+		return nil
+
+	case "status":
+		estado, err := migrations.Estado(config.DB)
+		if err != nil {
+			return err
+		}
+		conocidas, err := migrations.Todas()
+		if err != nil {
+			return err
+		}
+		enBinario := map[int]bool{}
+		for _, m := range conocidas {
+			enBinario[m.Version] = true
+		}
+		for _, e := range estado {
+			switch {
+			case !enBinario[e.Version]:
+				fmt.Printf("%04d_%-32s aplicada %s (no está en este binario)\n", e.Version, e.Nombre, e.AplicadaEn.Format("2006-01-02 15:04:05"))
+			case e.AplicadaEn != nil:
+				fmt.Printf("%04d_%-32s aplicada %s\n", e.Version, e.Nombre, e.AplicadaEn.Format("2006-01-02 15:04:05"))
+			default:
+				fmt.Printf("%04d_%-32s pendiente\n", e.Version, e.Nombre)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("subcomando desconocido %q (%s)", args[0], usoMigrate)
+}
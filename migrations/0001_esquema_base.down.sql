@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS logs;
+DROP TABLE IF EXISTS permisos;
+DROP TABLE IF EXISTS Consentimientos;
+DROP TABLE IF EXISTS Historial_Clinico;
+DROP TABLE IF EXISTS Consultas;
+DROP TABLE IF EXISTS Antecedentes;
+DROP TABLE IF EXISTS Expediente;
+DROP TABLE IF EXISTS Recetas;
+DROP TABLE IF EXISTS Horarios;
+DROP TABLE IF EXISTS Consultorios;
+DROP TABLE IF EXISTS Empleado;
+DROP TABLE IF EXISTS Paciente;
@@ -0,0 +1,109 @@
+-- Esquema de la versión 1.0: módulos clínicos, permisos y logs.
+-- Las credenciales todavía viven en Paciente y Empleado (ver 0003).
+
+CREATE TABLE IF NOT EXISTS Paciente (
+    id_paciente SERIAL PRIMARY KEY,
+    nombre      TEXT NOT NULL,
+    appaterno   TEXT NOT NULL,
+    apmaterno   TEXT,
+    correo      TEXT NOT NULL UNIQUE,
+    contraseña  TEXT,
+    mfa_enabled BOOLEAN NOT NULL DEFAULT false,
+    mfa_secret  TEXT
+);
+
+CREATE TABLE IF NOT EXISTS Empleado (
+    id_empleado   SERIAL PRIMARY KEY,
+    nombre        TEXT NOT NULL,
+    appaterno     TEXT NOT NULL,
+    apmaterno     TEXT,
+    tipo_empleado TEXT NOT NULL,
+    area          TEXT,
+    correo        TEXT NOT NULL UNIQUE,
+    contraseña    TEXT,
+    mfa_enabled   BOOLEAN NOT NULL DEFAULT false,
+    mfa_secret    TEXT
+);
+
+CREATE TABLE IF NOT EXISTS Consultorios (
+    id_consultorio SERIAL PRIMARY KEY,
+    nombre         TEXT NOT NULL,
+    tipo           TEXT
+);
+
+CREATE TABLE IF NOT EXISTS Horarios (
+    id_horario     SERIAL PRIMARY KEY,
+    id_consultorio INT NOT NULL REFERENCES Consultorios (id_consultorio),
+    turno          TEXT NOT NULL,
+    id_empleado    INT NOT NULL REFERENCES Empleado (id_empleado)
+);
+
+CREATE TABLE IF NOT EXISTS Recetas (
+    id_receta      SERIAL PRIMARY KEY,
+    fecha          DATE NOT NULL,
+    medicamento    TEXT NOT NULL,
+    dosis          TEXT NOT NULL,
+    id_consultorio INT NOT NULL REFERENCES Consultorios (id_consultorio)
+);
+
+CREATE TABLE IF NOT EXISTS Expediente (
+    id_expediente  SERIAL PRIMARY KEY,
+    id_paciente    INT NOT NULL REFERENCES Paciente (id_paciente) ON DELETE CASCADE,
+    seguro         TEXT,
+    fecha_creacion DATE NOT NULL DEFAULT CURRENT_DATE
+);
+
+CREATE TABLE IF NOT EXISTS Antecedentes (
+    id_antecedente SERIAL PRIMARY KEY,
+    id_expediente  INT NOT NULL REFERENCES Expediente (id_expediente) ON DELETE CASCADE,
+    diagnostico    TEXT NOT NULL,
+    descripcion    TEXT,
+    fecha          DATE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Consultas (
+    id_consulta    SERIAL PRIMARY KEY,
+    id_paciente    INT NOT NULL REFERENCES Paciente (id_paciente) ON DELETE CASCADE,
+    tipo           TEXT NOT NULL,
+    id_receta      INT REFERENCES Recetas (id_receta) ON DELETE SET NULL,
+    id_horario     INT NOT NULL REFERENCES Horarios (id_horario),
+    id_consultorio INT NOT NULL REFERENCES Consultorios (id_consultorio),
+    diagnostico    TEXT,
+    costo          NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    fecha_hora     TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Historial_Clinico (
+    id_historial  SERIAL PRIMARY KEY,
+    id_expediente INT NOT NULL REFERENCES Expediente (id_expediente) ON DELETE CASCADE,
+    id_consultas  INT NOT NULL REFERENCES Consultas (id_consulta) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Consentimientos (
+    id          SERIAL PRIMARY KEY,
+    id_paciente INT NOT NULL REFERENCES Paciente (id_paciente) ON DELETE CASCADE,
+    fecha_hora  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS permisos (
+    id      SERIAL PRIMARY KEY,
+    rol     TEXT NOT NULL,
+    permiso TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS logs (
+    id            BIGSERIAL PRIMARY KEY,
+    timestamp     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    method        TEXT,
+    path          TEXT,
+    status        INT,
+    response_time BIGINT,
+    ip            TEXT,
+    user_agent    TEXT,
+    level         TEXT,
+    request_id    TEXT,
+    system        JSONB,
+    body          JSONB
+);
+
+CREATE INDEX IF NOT EXISTS logs_timestamp_idx ON logs (timestamp DESC);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS correos_salientes;
+DROP TABLE IF EXISTS eventos_seguridad;
+DROP TABLE IF EXISTS bloqueos_login;
+DROP TABLE IF EXISTS intentos_login;
+DROP TABLE IF EXISTS mfa_intentos;
+DROP TABLE IF EXISTS revocaciones_usuario;
+DROP TABLE IF EXISTS tokens_revocados;
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,72 @@
+-- Refresh tokens, revocación, intentos de MFA y de login, eventos de seguridad
+-- y bandeja de correos salientes.
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token_hash   TEXT PRIMARY KEY,
+    familia      TEXT NOT NULL,
+    id_usuario   TEXT NOT NULL,
+    tipo_usuario TEXT NOT NULL,
+    correo       TEXT,
+    rol          TEXT NOT NULL,
+    expira_en    TIMESTAMPTZ NOT NULL,
+    usado_en     TIMESTAMPTZ,
+    revocado_en  TIMESTAMPTZ,
+    creado_en    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_familia_idx ON refresh_tokens (familia);
+CREATE INDEX IF NOT EXISTS refresh_tokens_usuario_idx ON refresh_tokens (tipo_usuario, id_usuario);
+
+CREATE TABLE IF NOT EXISTS tokens_revocados (
+    jti       TEXT PRIMARY KEY,
+    expira_en TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS revocaciones_usuario (
+    tipo_usuario   TEXT NOT NULL,
+    id_usuario     TEXT NOT NULL,
+    revocado_desde TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (tipo_usuario, id_usuario)
+);
+
+CREATE TABLE IF NOT EXISTS mfa_intentos (
+    jti       TEXT PRIMARY KEY,
+    fallidos  INT NOT NULL DEFAULT 0,
+    expira_en TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS intentos_login (
+    id        BIGSERIAL PRIMARY KEY,
+    correo    TEXT NOT NULL,
+    ip        TEXT NOT NULL,
+    exitoso   BOOLEAN NOT NULL,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS intentos_login_ip_idx ON intentos_login (ip, creado_en);
+
+CREATE TABLE IF NOT EXISTS bloqueos_login (
+    correo          TEXT PRIMARY KEY,
+    fallos          INT NOT NULL DEFAULT 0,
+    ultimo_fallo    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    bloqueado_hasta TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS eventos_seguridad (
+    id        BIGSERIAL PRIMARY KEY,
+    tipo      TEXT NOT NULL,
+    correo    TEXT,
+    ip        TEXT,
+    detalle   JSONB,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS eventos_seguridad_creado_idx ON eventos_seguridad (creado_en DESC);
+
+CREATE TABLE IF NOT EXISTS correos_salientes (
+    id        BIGSERIAL PRIMARY KEY,
+    para      TEXT NOT NULL,
+    asunto    TEXT NOT NULL,
+    cuerpo    TEXT NOT NULL,
+    creado_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,24 @@
+-- Devuelve las credenciales a Paciente y Empleado antes de borrar identidades.
+
+ALTER TABLE Paciente ADD COLUMN IF NOT EXISTS contraseña TEXT;
+ALTER TABLE Paciente ADD COLUMN IF NOT EXISTS mfa_enabled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE Paciente ADD COLUMN IF NOT EXISTS mfa_secret TEXT;
+ALTER TABLE Empleado ADD COLUMN IF NOT EXISTS contraseña TEXT;
+ALTER TABLE Empleado ADD COLUMN IF NOT EXISTS mfa_enabled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE Empleado ADD COLUMN IF NOT EXISTS mfa_secret TEXT;
+
+UPDATE Paciente p SET contraseña = i.contraseña, mfa_enabled = i.mfa_enabled, mfa_secret = i.mfa_secret
+FROM identidad_roles ir JOIN identidades i ON i.id_identidad = ir.id_identidad
+WHERE ir.tipo_usuario = 'paciente' AND ir.id_usuario = p.id_paciente::text;
+
+UPDATE Empleado e SET contraseña = i.contraseña, mfa_enabled = i.mfa_enabled, mfa_secret = i.mfa_secret
+FROM identidad_roles ir JOIN identidades i ON i.id_identidad = ir.id_identidad
+WHERE ir.tipo_usuario = 'empleado' AND ir.id_usuario = e.id_empleado::text;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS id_identidad;
+
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS historial_contrasenas;
+DROP TABLE IF EXISTS mfa_codigos_recuperacion;
+DROP TABLE IF EXISTS identidad_roles;
+DROP TABLE IF EXISTS identidades;
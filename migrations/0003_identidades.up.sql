@@ -0,0 +1,100 @@
+-- Identidad única por correo: las credenciales pasan de Paciente y Empleado a
+-- identidades e identidad_roles indica qué paciente o empleado es cada rol.
+
+CREATE TABLE IF NOT EXISTS identidades (
+    id_identidad              TEXT PRIMARY KEY,
+    correo                    TEXT NOT NULL UNIQUE,
+    contraseña                TEXT NOT NULL,
+    mfa_enabled               BOOLEAN NOT NULL DEFAULT false,
+    mfa_secret                TEXT,
+    mfa_secret_pendiente      TEXT,
+    mfa_ultimo_paso           BIGINT,
+    contrasena_actualizada_en TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    correo_verificado         BOOLEAN NOT NULL DEFAULT false
+);
+
+CREATE TABLE IF NOT EXISTS identidad_roles (
+    id_identidad TEXT NOT NULL REFERENCES identidades (id_identidad) ON DELETE CASCADE,
+    tipo_usuario TEXT NOT NULL,
+    id_usuario   TEXT NOT NULL,
+    PRIMARY KEY (tipo_usuario, id_usuario)
+);
+
+CREATE INDEX IF NOT EXISTS identidad_roles_identidad_idx ON identidad_roles (id_identidad);
+
+CREATE TABLE IF NOT EXISTS mfa_codigos_recuperacion (
+    id           BIGSERIAL PRIMARY KEY,
+    id_identidad TEXT NOT NULL REFERENCES identidades (id_identidad) ON DELETE CASCADE,
+    codigo_hash  TEXT NOT NULL,
+    usado_en     TIMESTAMPTZ,
+    creado_en    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS historial_contrasenas (
+    id           BIGSERIAL PRIMARY KEY,
+    id_identidad TEXT NOT NULL REFERENCES identidades (id_identidad) ON DELETE CASCADE,
+    hash         TEXT NOT NULL,
+    creado_en    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    token_hash   TEXT PRIMARY KEY,
+    id_identidad TEXT NOT NULL REFERENCES identidades (id_identidad) ON DELETE CASCADE,
+    expira_en    TIMESTAMPTZ NOT NULL,
+    usado_en     TIMESTAMPTZ
+);
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS id_identidad TEXT;
+
+-- Bases que todavía guardan las credenciales en Paciente y Empleado: se crea
+-- una identidad por correo (si el correo está en ambas tablas se conservan las
+-- credenciales del empleado) y se borran las columnas viejas.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_name = 'paciente' AND column_name = 'contraseña') THEN
+        RETURN;
+    END IF;
+
+    ALTER TABLE Paciente ADD COLUMN IF NOT EXISTS mfa_secret_pendiente TEXT;
+    ALTER TABLE Paciente ADD COLUMN IF NOT EXISTS mfa_ultimo_paso BIGINT;
+    ALTER TABLE Paciente ADD COLUMN IF NOT EXISTS contrasena_actualizada_en TIMESTAMPTZ DEFAULT NOW();
+    ALTER TABLE Paciente ADD COLUMN IF NOT EXISTS correo_verificado BOOLEAN DEFAULT true;
+    ALTER TABLE Empleado ADD COLUMN IF NOT EXISTS mfa_secret_pendiente TEXT;
+    ALTER TABLE Empleado ADD COLUMN IF NOT EXISTS mfa_ultimo_paso BIGINT;
+    ALTER TABLE Empleado ADD COLUMN IF NOT EXISTS contrasena_actualizada_en TIMESTAMPTZ DEFAULT NOW();
+
+    INSERT INTO identidades (id_identidad, correo, contraseña, mfa_enabled, mfa_secret, mfa_secret_pendiente,
+                             mfa_ultimo_paso, contrasena_actualizada_en, correo_verificado)
+    SELECT DISTINCT ON (lower(u.correo)) gen_random_uuid()::text, lower(u.correo), u.contraseña,
+           COALESCE(u.mfa_enabled, false), u.mfa_secret, u.mfa_secret_pendiente, u.mfa_ultimo_paso,
+           COALESCE(u.contrasena_actualizada_en, NOW()), u.correo_verificado
+    FROM (
+        SELECT correo, contraseña, mfa_enabled, mfa_secret, mfa_secret_pendiente, mfa_ultimo_paso,
+               contrasena_actualizada_en, true AS correo_verificado, 0 AS prioridad
+        FROM Empleado WHERE contraseña IS NOT NULL
+        UNION ALL
+        SELECT correo, contraseña, mfa_enabled, mfa_secret, mfa_secret_pendiente, mfa_ultimo_paso,
+               contrasena_actualizada_en, COALESCE(correo_verificado, true), 1
+        FROM Paciente WHERE contraseña IS NOT NULL
+    ) u
+    WHERE NOT EXISTS (SELECT 1 FROM identidades i WHERE i.correo = lower(u.correo))
+    ORDER BY lower(u.correo), u.prioridad;
+
+    INSERT INTO identidad_roles (id_identidad, tipo_usuario, id_usuario)
+    SELECT i.id_identidad, 'empleado', e.id_empleado::text
+    FROM Empleado e JOIN identidades i ON i.correo = lower(e.correo)
+    ON CONFLICT DO NOTHING;
+
+    INSERT INTO identidad_roles (id_identidad, tipo_usuario, id_usuario)
+    SELECT i.id_identidad, 'paciente', p.id_paciente::text
+    FROM Paciente p JOIN identidades i ON i.correo = lower(p.correo)
+    ON CONFLICT DO NOTHING;
+
+    ALTER TABLE Paciente DROP COLUMN contraseña, DROP COLUMN mfa_enabled, DROP COLUMN mfa_secret,
+        DROP COLUMN mfa_secret_pendiente, DROP COLUMN mfa_ultimo_paso, DROP COLUMN contrasena_actualizada_en,
+        DROP COLUMN correo_verificado;
+    ALTER TABLE Empleado DROP COLUMN contraseña, DROP COLUMN mfa_enabled, DROP COLUMN mfa_secret,
+        DROP COLUMN mfa_secret_pendiente, DROP COLUMN mfa_ultimo_paso, DROP COLUMN contrasena_actualizada_en;
+END
+$$;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS webauthn_sesiones;
+DROP TABLE IF EXISTS webauthn_credenciales;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS cuentas_servicio;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS client_id;
+DROP TABLE IF EXISTS oauth_codigos;
+DROP TABLE IF EXISTS oauth_consentimientos;
+DROP TABLE IF EXISTS oauth_clientes;
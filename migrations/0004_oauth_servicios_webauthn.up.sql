@@ -0,0 +1,79 @@
+-- Proveedor OpenID Connect, cuentas de servicio con API keys y WebAuthn.
+
+CREATE TABLE IF NOT EXISTS oauth_clientes (
+    client_id     TEXT PRIMARY KEY,
+    nombre        TEXT NOT NULL,
+    secreto_hash  TEXT,
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    scopes        TEXT[] NOT NULL DEFAULT '{}',
+    publico       BOOLEAN NOT NULL DEFAULT false,
+    creado_en     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS oauth_consentimientos (
+    id_identidad TEXT NOT NULL REFERENCES identidades (id_identidad) ON DELETE CASCADE,
+    client_id    TEXT NOT NULL REFERENCES oauth_clientes (client_id) ON DELETE CASCADE,
+    scopes       TEXT[] NOT NULL DEFAULT '{}',
+    otorgado_en  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id_identidad, client_id)
+);
+
+CREATE TABLE IF NOT EXISTS oauth_codigos (
+    codigo_hash    TEXT PRIMARY KEY,
+    client_id      TEXT NOT NULL REFERENCES oauth_clientes (client_id) ON DELETE CASCADE,
+    id_identidad   TEXT NOT NULL REFERENCES identidades (id_identidad) ON DELETE CASCADE,
+    rol            TEXT NOT NULL,
+    redirect_uri   TEXT NOT NULL,
+    scope          TEXT NOT NULL DEFAULT '',
+    nonce          TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL,
+    auth_time      TIMESTAMPTZ NOT NULL,
+    expira_en      TIMESTAMPTZ NOT NULL,
+    usado_en       TIMESTAMPTZ
+);
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS client_id TEXT;
+
+CREATE TABLE IF NOT EXISTS cuentas_servicio (
+    id_cuenta_servicio UUID PRIMARY KEY,
+    nombre             TEXT NOT NULL,
+    descripcion        TEXT,
+    permisos           TEXT[] NOT NULL DEFAULT '{}',
+    activa             BOOLEAN NOT NULL DEFAULT true,
+    creado_en          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id_api_key         UUID PRIMARY KEY,
+    id_cuenta_servicio UUID NOT NULL REFERENCES cuentas_servicio (id_cuenta_servicio) ON DELETE CASCADE,
+    nombre             TEXT NOT NULL,
+    prefijo            TEXT NOT NULL UNIQUE,
+    hash               TEXT NOT NULL,
+    expira_en          TIMESTAMPTZ,
+    ultimo_uso_en      TIMESTAMPTZ,
+    revocada_en        TIMESTAMPTZ,
+    creado_en          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webauthn_credenciales (
+    id_credencial    BYTEA PRIMARY KEY,
+    id_identidad     TEXT NOT NULL REFERENCES identidades (id_identidad) ON DELETE CASCADE,
+    nombre           TEXT NOT NULL,
+    llave_publica    BYTEA NOT NULL,
+    attestation_type TEXT NOT NULL DEFAULT '',
+    aaguid           BYTEA,
+    sign_count       BIGINT NOT NULL DEFAULT 0,
+    transportes      TEXT[] NOT NULL DEFAULT '{}',
+    backup_eligible  BOOLEAN NOT NULL DEFAULT false,
+    backup_state     BOOLEAN NOT NULL DEFAULT false,
+    creado_en        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ultimo_uso_en    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webauthn_credenciales_identidad_idx ON webauthn_credenciales (id_identidad);
+
+CREATE TABLE IF NOT EXISTS webauthn_sesiones (
+    clave     TEXT PRIMARY KEY,
+    datos     BYTEA NOT NULL,
+    expira_en TIMESTAMPTZ NOT NULL
+);
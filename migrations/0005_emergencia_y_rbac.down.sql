@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS renovaciones_rol;
+DROP TABLE IF EXISTS catalogo_permisos;
+DROP TABLE IF EXISTS roles;
+ALTER TABLE permisos DROP COLUMN IF EXISTS permitido;
+ALTER TABLE permisos DROP COLUMN IF EXISTS ruta;
+ALTER TABLE permisos DROP COLUMN IF EXISTS metodo;
+DROP TABLE IF EXISTS accesos_emergencia;
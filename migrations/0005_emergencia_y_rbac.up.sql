@@ -0,0 +1,41 @@
+-- Accesos de emergencia, reglas por método y ruta en permisos, catálogo de
+-- roles y permisos y renovación forzada de tokens por rol.
+
+CREATE TABLE IF NOT EXISTS accesos_emergencia (
+    id_acceso           UUID PRIMARY KEY,
+    id_empleado         TEXT NOT NULL,
+    correo              TEXT NOT NULL,
+    rol                 TEXT NOT NULL,
+    id_paciente         INT NOT NULL REFERENCES Paciente (id_paciente) ON DELETE CASCADE,
+    justificacion       TEXT NOT NULL,
+    ip                  TEXT,
+    otorgado_en         TIMESTAMPTZ NOT NULL,
+    expira_en           TIMESTAMPTZ NOT NULL,
+    revocado_en         TIMESTAMPTZ,
+    revisado_por        TEXT,
+    revisado_en         TIMESTAMPTZ,
+    comentario_revision TEXT
+);
+
+CREATE INDEX IF NOT EXISTS accesos_emergencia_vigente_idx ON accesos_emergencia (id_empleado, id_paciente, expira_en);
+
+ALTER TABLE permisos ADD COLUMN IF NOT EXISTS metodo TEXT;
+ALTER TABLE permisos ADD COLUMN IF NOT EXISTS ruta TEXT;
+ALTER TABLE permisos ADD COLUMN IF NOT EXISTS permitido BOOLEAN NOT NULL DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS roles (
+    rol         TEXT PRIMARY KEY,
+    descripcion TEXT,
+    creado_en   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS catalogo_permisos (
+    permiso     TEXT PRIMARY KEY,
+    descripcion TEXT,
+    creado_en   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS renovaciones_rol (
+    rol           TEXT PRIMARY KEY,
+    renovar_desde TIMESTAMPTZ NOT NULL
+);
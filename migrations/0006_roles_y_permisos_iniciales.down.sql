@@ -0,0 +1,20 @@
+-- Quita las asignaciones sin reglas de método o ruta de los roles iniciales y
+-- su catálogo; los roles y permisos creados después se conservan.
+
+DELETE FROM permisos
+WHERE rol IN ('paciente', 'doctor', 'enfermera', 'administrador') AND metodo IS NULL AND ruta IS NULL;
+
+DELETE FROM catalogo_permisos
+WHERE permiso IN (
+    'solicitar_cita', 'ver_citas', 'actualizar_citas', 'eliminar_citas', 'ver_pacientes',
+    'actualizar_pacientes', 'eliminar_pacientes', 'ver_todos_los_pacientes', 'ver_empleados',
+    'administrar_empleados', 'administrar_consultorios', 'administrar_horarios', 'ver_recetas',
+    'crear_recetas', 'actualizar_recetas', 'eliminar_recetas', 'crear_expedientes', 'actualizar_expedientes',
+    'eliminar_expedientes', 'ver_antecedentes', 'crear_antecedentes', 'actualizar_antecedentes',
+    'eliminar_antecedentes', 'empleado', 'empleados', 'paciente', 'acceso_emergencia',
+    'revisar_accesos_emergencia', 'ver_logs', 'administrar_seguridad'
+) AND permiso NOT IN (SELECT permiso FROM permisos);
+
+DELETE FROM roles
+WHERE rol IN ('paciente', 'doctor', 'enfermera', 'administrador')
+  AND NOT EXISTS (SELECT 1 FROM permisos p WHERE p.rol = roles.rol);
@@ -0,0 +1,86 @@
+-- Roles, catálogo de permisos y asignaciones iniciales. Solo se agregan las
+-- filas que faltan: no se tocan los permisos ya configurados.
+
+INSERT INTO roles (rol, descripcion) VALUES
+    ('paciente', 'Pacientes registrados'),
+    ('doctor', 'Médicos'),
+    ('enfermera', 'Personal de enfermería'),
+    ('administrador', 'Administración del hospital y del sistema')
+ON CONFLICT (rol) DO NOTHING;
+
+INSERT INTO catalogo_permisos (permiso, descripcion) VALUES
+    ('solicitar_cita', 'Agendar consultas y consultar horarios, consultorios, recetas y expedientes propios'),
+    ('ver_citas', 'Listar todas las consultas'),
+    ('actualizar_citas', 'Modificar consultas'),
+    ('eliminar_citas', 'Eliminar consultas'),
+    ('ver_pacientes', 'Consultar pacientes'),
+    ('actualizar_pacientes', 'Modificar pacientes'),
+    ('eliminar_pacientes', 'Eliminar pacientes'),
+    ('ver_todos_los_pacientes', 'Acceder a pacientes de cualquier área'),
+    ('ver_empleados', 'Consultar empleados'),
+    ('administrar_empleados', 'Registrar, modificar y eliminar empleados'),
+    ('administrar_consultorios', 'Registrar, modificar y eliminar consultorios'),
+    ('administrar_horarios', 'Registrar, modificar y eliminar horarios'),
+    ('ver_recetas', 'Listar todas las recetas'),
+    ('crear_recetas', 'Emitir recetas'),
+    ('actualizar_recetas', 'Modificar recetas'),
+    ('eliminar_recetas', 'Eliminar recetas'),
+    ('crear_expedientes', 'Abrir expedientes'),
+    ('actualizar_expedientes', 'Modificar expedientes'),
+    ('eliminar_expedientes', 'Eliminar expedientes'),
+    ('ver_antecedentes', 'Consultar antecedentes'),
+    ('crear_antecedentes', 'Registrar antecedentes'),
+    ('actualizar_antecedentes', 'Modificar antecedentes'),
+    ('eliminar_antecedentes', 'Eliminar antecedentes'),
+    ('empleado', 'Historial clínico'),
+    ('empleados', 'Reportes del personal'),
+    ('paciente', 'Consentimiento y reportes del paciente'),
+    ('acceso_emergencia', 'Solicitar acceso de emergencia a un paciente'),
+    ('revisar_accesos_emergencia', 'Revisar y revocar accesos de emergencia'),
+    ('ver_logs', 'Consultar la bitácora de solicitudes'),
+    ('administrar_seguridad', 'Sesiones, bloqueos, roles, permisos, clientes OAuth y cuentas de servicio')
+ON CONFLICT (permiso) DO NOTHING;
+
+WITH asignaciones (rol, permiso) AS (VALUES
+    ('paciente', 'solicitar_cita'),
+    ('paciente', 'paciente'),
+    ('paciente', 'ver_pacientes'),
+    ('paciente', 'actualizar_pacientes'),
+
+    ('enfermera', 'solicitar_cita'),
+    ('enfermera', 'ver_citas'),
+    ('enfermera', 'ver_pacientes'),
+    ('enfermera', 'ver_recetas'),
+    ('enfermera', 'ver_antecedentes'),
+    ('enfermera', 'crear_antecedentes'),
+    ('enfermera', 'actualizar_antecedentes'),
+    ('enfermera', 'empleado'),
+    ('enfermera', 'empleados'),
+    ('enfermera', 'acceso_emergencia'),
+
+    ('doctor', 'solicitar_cita'),
+    ('doctor', 'ver_citas'),
+    ('doctor', 'actualizar_citas'),
+    ('doctor', 'ver_pacientes'),
+    ('doctor', 'actualizar_pacientes'),
+    ('doctor', 'ver_recetas'),
+    ('doctor', 'crear_recetas'),
+    ('doctor', 'actualizar_recetas'),
+    ('doctor', 'crear_expedientes'),
+    ('doctor', 'actualizar_expedientes'),
+    ('doctor', 'ver_antecedentes'),
+    ('doctor', 'crear_antecedentes'),
+    ('doctor', 'actualizar_antecedentes'),
+    ('doctor', 'empleado'),
+    ('doctor', 'empleados'),
+    ('doctor', 'acceso_emergencia')
+)
+INSERT INTO permisos (rol, permiso)
+SELECT a.rol, a.permiso FROM asignaciones a
+WHERE NOT EXISTS (SELECT 1 FROM permisos p WHERE p.rol = a.rol AND p.permiso = a.permiso);
+
+-- El administrador recibe todo el catálogo salvo los permisos propios del paciente
+INSERT INTO permisos (rol, permiso)
+SELECT 'administrador', c.permiso FROM catalogo_permisos c
+WHERE c.permiso NOT IN ('paciente', 'acceso_emergencia')
+  AND NOT EXISTS (SELECT 1 FROM permisos p WHERE p.rol = 'administrador' AND p.permiso = c.permiso);
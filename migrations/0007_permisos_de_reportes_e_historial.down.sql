@@ -0,0 +1,45 @@
+-- Vuelve a los permisos genéricos paciente, empleado y empleados. ver_reportes
+-- regresa como paciente en el rol paciente y como empleados en los demás.
+
+INSERT INTO catalogo_permisos (permiso, descripcion) VALUES
+    ('empleado', 'Historial clínico'),
+    ('empleados', 'Reportes del personal'),
+    ('paciente', 'Consentimiento y reportes del paciente')
+ON CONFLICT (permiso) DO NOTHING;
+
+WITH reemplazos (nuevo, anterior) AS (VALUES
+    ('ver_historial', 'empleado'),
+    ('crear_historial', 'empleado'),
+    ('actualizar_historial', 'empleado'),
+    ('eliminar_historial', 'empleado'),
+    ('registrar_consentimiento', 'paciente')
+)
+INSERT INTO permisos (rol, permiso, metodo, ruta, permitido)
+SELECT DISTINCT p.rol, r.anterior, p.metodo, p.ruta, p.permitido
+FROM permisos p JOIN reemplazos r ON r.nuevo = p.permiso
+WHERE NOT EXISTS (SELECT 1 FROM permisos q WHERE q.rol = p.rol AND q.permiso = r.anterior);
+
+INSERT INTO permisos (rol, permiso, metodo, ruta, permitido)
+SELECT DISTINCT p.rol, CASE WHEN p.rol = 'paciente' THEN 'paciente' ELSE 'empleados' END, p.metodo, p.ruta, p.permitido
+FROM permisos p
+WHERE p.permiso = 'ver_reportes'
+  AND NOT EXISTS (SELECT 1 FROM permisos q WHERE q.rol = p.rol
+      AND q.permiso = CASE WHEN p.rol = 'paciente' THEN 'paciente' ELSE 'empleados' END);
+
+UPDATE cuentas_servicio SET permisos = ARRAY(
+    SELECT DISTINCT n.permiso
+    FROM unnest(cuentas_servicio.permisos) AS a(permiso)
+    CROSS JOIN LATERAL unnest(CASE
+        WHEN a.permiso IN ('ver_historial', 'crear_historial', 'actualizar_historial', 'eliminar_historial')
+            THEN ARRAY['empleado']
+        WHEN a.permiso = 'ver_reportes' THEN ARRAY['empleados']
+        WHEN a.permiso = 'registrar_consentimiento' THEN ARRAY['paciente']
+        ELSE ARRAY[a.permiso] END) AS n(permiso)
+)
+WHERE permisos && ARRAY['ver_historial', 'crear_historial', 'actualizar_historial', 'eliminar_historial',
+    'ver_reportes', 'registrar_consentimiento'];
+
+DELETE FROM permisos WHERE permiso IN ('ver_reportes', 'ver_historial', 'crear_historial', 'actualizar_historial',
+    'eliminar_historial', 'registrar_consentimiento');
+DELETE FROM catalogo_permisos WHERE permiso IN ('ver_reportes', 'ver_historial', 'crear_historial',
+    'actualizar_historial', 'eliminar_historial', 'registrar_consentimiento');
@@ -0,0 +1,40 @@
+-- Reemplaza los permisos genéricos paciente, empleado y empleados por permisos
+-- del catálogo. Cada rol y cuenta de servicio recibe los nuevos con las mismas
+-- reglas de método y ruta que tenía el anterior.
+
+INSERT INTO catalogo_permisos (permiso, descripcion) VALUES
+    ('ver_reportes', 'Consultar reportes de consultas e ingresos'),
+    ('ver_historial', 'Consultar el historial clínico'),
+    ('crear_historial', 'Registrar entradas del historial clínico'),
+    ('actualizar_historial', 'Modificar el historial clínico'),
+    ('eliminar_historial', 'Eliminar entradas del historial clínico'),
+    ('registrar_consentimiento', 'Consultar el aviso de privacidad y registrar el consentimiento')
+ON CONFLICT (permiso) DO NOTHING;
+
+WITH reemplazos (anterior, nuevo) AS (VALUES
+    ('empleado', 'ver_historial'),
+    ('empleado', 'crear_historial'),
+    ('empleado', 'actualizar_historial'),
+    ('empleado', 'eliminar_historial'),
+    ('empleados', 'ver_reportes'),
+    ('paciente', 'ver_reportes'),
+    ('paciente', 'registrar_consentimiento')
+)
+INSERT INTO permisos (rol, permiso, metodo, ruta, permitido)
+SELECT DISTINCT p.rol, r.nuevo, p.metodo, p.ruta, p.permitido
+FROM permisos p JOIN reemplazos r ON r.anterior = p.permiso
+WHERE NOT EXISTS (SELECT 1 FROM permisos q WHERE q.rol = p.rol AND q.permiso = r.nuevo);
+
+UPDATE cuentas_servicio SET permisos = ARRAY(
+    SELECT DISTINCT n.permiso
+    FROM unnest(cuentas_servicio.permisos) AS a(permiso)
+    CROSS JOIN LATERAL unnest(CASE a.permiso
+        WHEN 'empleado' THEN ARRAY['ver_historial', 'crear_historial', 'actualizar_historial', 'eliminar_historial']
+        WHEN 'empleados' THEN ARRAY['ver_reportes']
+        WHEN 'paciente' THEN ARRAY['ver_reportes', 'registrar_consentimiento']
+        ELSE ARRAY[a.permiso] END) AS n(permiso)
+)
+WHERE permisos && ARRAY['empleado', 'empleados', 'paciente'];
+
+DELETE FROM permisos WHERE permiso IN ('empleado', 'empleados', 'paciente');
+DELETE FROM catalogo_permisos WHERE permiso IN ('empleado', 'empleados', 'paciente');
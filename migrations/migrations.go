@@ -0,0 +1,304 @@
+// Package migrations contiene el esquema de la base de datos como migraciones
+// versionadas embebidas en el binario. Cada versión tiene un archivo
+// NNNN_nombre.up.sql y su NNNN_nombre.down.sql; las versiones aplicadas se
+// registran en la tabla schema_migrations.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var archivos embed.FS
+
+// bloqueoMigraciones es la llave del advisory lock que evita que dos procesos
+// migren la misma base al mismo tiempo
+const bloqueoMigraciones = 7310224
+
+// ErrEsquemaAtrasado indica que la base no tiene todas las migraciones del binario
+var ErrEsquemaAtrasado = errors.New("el esquema de la base de datos está atrasado")
+
+// Migracion es una versión del esquema con sus scripts de subida y bajada
+type Migracion struct {
+	Version int
+	Nombre  string
+	subida  string
+	bajada  string
+}
+
+// EstadoMigracion indica si una versión está aplicada y desde cuándo
+type EstadoMigracion struct {
+	Version    int
+	Nombre     string
+	AplicadaEn *time.Time
+}
+
+// Todas devuelve las migraciones embebidas ordenadas por versión
+func Todas() ([]Migracion, error) {
+	nombres, err := fs.Glob(archivos, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	porVersion := map[int]*Migracion{}
+	for _, archivo := range nombres {
+		base, sentido, ok := strings.Cut(strings.TrimSuffix(archivo, ".sql"), ".")
+		if !ok || (sentido != "up" && sentido != "down") {
+			return nil, fmt.Errorf("migración %s: el nombre debe terminar en .up.sql o .down.sql", archivo)
+		}
+		numero, nombre, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(numero)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migración %s: el nombre debe empezar con la versión (0001_nombre)", archivo)
+		}
+
+		contenido, err := archivos.ReadFile(archivo)
+		if err != nil {
+			return nil, err
+		}
+
+		m := porVersion[version]
+		if m == nil {
+			m = &Migracion{Version: version, Nombre: nombre}
+			porVersion[version] = m
+		} else if m.Nombre != nombre {
+			return nil, fmt.Errorf("migración %04d: nombres distintos (%s y %s)", version, m.Nombre, nombre)
+		}
+		if sentido == "up" {
+			m.subida = string(contenido)
+		} else {
+			m.bajada = string(contenido)
+		}
+	}
+
+	lista := make([]Migracion, 0, len(porVersion))
+	for _, m := range porVersion {
+		if m.subida == "" || m.bajada == "" {
+			return nil, fmt.Errorf("migración %04d_%s: falta el archivo .up.sql o .down.sql", m.Version, m.Nombre)
+		}
+		lista = append(lista, *m)
+	}
+	sort.Slice(lista, func(i, j int) bool { return lista[i].Version < lista[j].Version })
+	return lista, nil
+}
+
+// Subir aplica todas las migraciones pendientes, cada una en su transacción
+func Subir(db *sql.DB) ([]Migracion, error) {
+	todas, err := Todas()
+	if err != nil {
+		return nil, err
+	}
+
+	var aplicadas []Migracion
+	err = conBloqueo(db, func(conn *sql.Conn) error {
+		hechas, err := versionesAplicadas(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range todas {
+			if _, ok := hechas[m.Version]; ok {
+				continue
+			}
+			if err := ejecutar(conn, m, m.subida,
+				`INSERT INTO schema_migrations (version, nombre) VALUES ($1, $2)`, m.Version, m.Nombre); err != nil {
+				return err
+			}
+			aplicadas = append(aplicadas, m)
+		}
+		return nil
+	})
+	return aplicadas, err
+}
+
+// Bajar revierte las últimas n migraciones aplicadas, de la más reciente a la más antigua
+func Bajar(db *sql.DB, n int) ([]Migracion, error) {
+	todas, err := Todas()
+	if err != nil {
+		return nil, err
+	}
+	porVersion := map[int]Migracion{}
+	for _, m := range todas {
+		porVersion[m.Version] = m
+	}
+
+	var revertidas []Migracion
+	err = conBloqueo(db, func(conn *sql.Conn) error {
+		hechas, err := versionesAplicadas(conn)
+		if err != nil {
+			return err
+		}
+		versiones := make([]int, 0, len(hechas))
+		for v := range hechas {
+			versiones = append(versiones, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versiones)))
+
+		for _, v := range versiones {
+			if len(revertidas) == n {
+				break
+			}
+			m, ok := porVersion[v]
+			if !ok {
+				return fmt.Errorf("la versión %04d está aplicada pero este binario no la conoce", v)
+			}
+			if err := ejecutar(conn, m, m.bajada,
+				`DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return err
+			}
+			revertidas = append(revertidas, m)
+		}
+		return nil
+	})
+	return revertidas, err
+}
+
+// Estado devuelve todas las migraciones del binario con su fecha de aplicación
+// (nil si está pendiente), más las versiones aplicadas que el binario no conoce
+func Estado(db *sql.DB) ([]EstadoMigracion, error) {
+	todas, err := Todas()
+	if err != nil {
+		return nil, err
+	}
+	// Una base sin schema_migrations tiene todas las migraciones pendientes
+	var existe bool
+	if err := db.QueryRow(`SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&existe); err != nil {
+		return nil, err
+	}
+	hechas := map[int]EstadoMigracion{}
+	if existe {
+		if err := leerAplicadas(db, hechas); err != nil {
+			return nil, err
+		}
+	}
+
+	estado := make([]EstadoMigracion, 0, len(todas))
+	for _, m := range todas {
+		e := EstadoMigracion{Version: m.Version, Nombre: m.Nombre}
+		if h, ok := hechas[m.Version]; ok {
+			e.AplicadaEn = h.AplicadaEn
+			delete(hechas, m.Version)
+		}
+		estado = append(estado, e)
+	}
+	for _, h := range hechas {
+		estado = append(estado, h)
+	}
+	sort.Slice(estado, func(i, j int) bool { return estado[i].Version < estado[j].Version })
+	return estado, nil
+}
+
+// VerificarEsquema devuelve ErrEsquemaAtrasado si falta aplicar alguna
+// migración del binario. Una base más nueva que el binario no es error.
+func VerificarEsquema(db *sql.DB) error {
+	estado, err := Estado(db)
+	if err != nil {
+		return err
+	}
+	var pendientes []string
+	for _, e := range estado {
+		if e.AplicadaEn == nil {
+			pendientes = append(pendientes, fmt.Sprintf("%04d_%s", e.Version, e.Nombre))
+		}
+	}
+	if len(pendientes) > 0 {
+		return fmt.Errorf("%w: faltan %d migraciones (%s)", ErrEsquemaAtrasado, len(pendientes), strings.Join(pendientes, ", "))
+	}
+	return nil
+}
+
+func leerAplicadas(db *sql.DB, hechas map[int]EstadoMigracion) error {
+	rows, err := db.Query(`SELECT version, nombre, aplicada_en FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e     EstadoMigracion
+			fecha time.Time
+		)
+		if err := rows.Scan(&e.Version, &e.Nombre, &fecha); err != nil {
+			return err
+		}
+		e.AplicadaEn = &fecha
+		hechas[e.Version] = e
+	}
+	return rows.Err()
+}
+
+func crearTabla(conn *sql.Conn) error {
+	_, err := conn.ExecContext(context.Background(), `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version     INT PRIMARY KEY,
+		nombre      TEXT NOT NULL,
+		aplicada_en TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	return err
+}
+
+// conBloqueo ejecuta fn en una conexión dedicada que tiene el advisory lock
+// de migraciones
+func conBloqueo(db *sql.DB, fn func(conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, bloqueoMigraciones); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, bloqueoMigraciones)
+
+	if err := crearTabla(conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func versionesAplicadas(conn *sql.Conn) (map[int]struct{}, error) {
+	rows, err := conn.QueryContext(context.Background(), `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hechas := map[int]struct{}{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		hechas[v] = struct{}{}
+	}
+	return hechas, rows.Err()
+}
+
+// ejecutar corre el script y el registro en schema_migrations en una sola transacción
+func ejecutar(conn *sql.Conn, m Migracion, script, registro string, args ...interface{}) error {
+	ctx := context.Background()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Sin parámetros el script va como consulta simple y puede tener varias sentencias
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migración %04d_%s: %w", m.Version, m.Nombre, err)
+	}
+	if _, err := tx.ExecContext(ctx, registro, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"database/sql"
+	"time"
+)
 
 type Consulta struct {
 	ID             int        `json:"id_consulta"`
@@ -15,3 +18,27 @@ type Consulta struct {
 	Costo          float64    `json:"costo"`
 	FechaHora      time.Time  `json:"fecha_hora"`
 }
+
+// ConsultaDetallada es una consulta con los datos del paciente, la receta, el
+// horario, el empleado y el consultorio (los listados de /consultas)
+type ConsultaDetallada struct {
+	IDConsulta     int             `json:"id_consulta"`
+	IDConsultorio  int             `json:"id_consultorio,omitempty"`
+	NombrePaciente string          `json:"nombre_paciente"`
+	AppPaterno     string          `json:"appaterno_paciente"`
+	AppMaterno     string          `json:"apmaterno_paciente"`
+	FechaReceta    sql.NullTime    `json:"fecha_receta"`
+	Medicamento    sql.NullString  `json:"medicamento"`
+	Dosis          sql.NullString  `json:"dosis"`
+	Turno          string          `json:"turno"`
+	EmpleadoNombre string          `json:"nombre_empleado"`
+	EmpleadoAppPat string          `json:"appaterno_empleado"`
+	EmpleadoAppMat string          `json:"apmaterno_empleado"`
+	AreaEmpleado   string          `json:"area_empleado"`
+	TipoConsul     string          `json:"tipo_consultorio"`
+	NombreConsul   string          `json:"nombre_consultorio"`
+	TipoConsulta   string          `json:"tipo"`
+	Diagnostico    sql.NullString  `json:"diagnostico"`
+	Costo          sql.NullFloat64 `json:"costo"`
+	FechaHora      *time.Time      `json:"fecha_hora"`
+}
@@ -9,4 +9,6 @@ type Empleado struct {
 	Area       string `json:"area"`
 	Correo     string `json:"correo"`
 	Contrasena string `json:"contrasena,omitempty"`
+	// VincularIdentidad agrega el rol a la cuenta existente del correo (requiere su contraseña)
+	VincularIdentidad bool `json:"vincular_identidad,omitempty"`
 }
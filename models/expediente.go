@@ -8,3 +8,12 @@ type Expediente struct {
     Seguro       string    `json:"seguro"`
     FechaCreacion time.Time `json:"fecha_creacion"`
 }
+
+// ExpedienteDetallado es un expediente con el nombre del paciente y sus antecedentes
+type ExpedienteDetallado struct {
+    Expediente
+    Nombre       string
+    Appaterno    string
+    Apmaterno    string
+    Antecedentes []Antecedente
+}
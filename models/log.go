@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Log es una solicitud registrada por el middleware Logger
+type Log struct {
+	Timestamp    time.Time
+	Method       string
+	Path         string
+	Status       int
+	ResponseTime int64 // milisegundos
+	IP           string
+	UserAgent    string
+	Level        string
+	System       interface{}
+	Body         interface{}
+}
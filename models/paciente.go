@@ -7,4 +7,6 @@ type Paciente struct {
 	Apmaterno  string `json:"apmaterno"`
 	Correo     string `json:"correo"`
 	Contrasena string `json:"contrasena,omitempty"` // omitida en respuestas
+	// VincularIdentidad agrega el rol a la cuenta existente del correo (requiere su contraseña)
+	VincularIdentidad bool `json:"vincular_identidad,omitempty"`
 }
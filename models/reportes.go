@@ -0,0 +1,53 @@
+package models
+
+// DetalleConsultaPaciente es una fila del reporte de consultas de un paciente
+type DetalleConsultaPaciente struct {
+	ID          int     `json:"id_consulta"`
+	Paciente    string  `json:"paciente"`
+	Empleado    string  `json:"empleado"`
+	Turno       string  `json:"turno"`
+	Consultorio string  `json:"consultorio"`
+	Tipo        string  `json:"tipo"`
+	Diagnostico string  `json:"diagnostico"`
+	Costo       float64 `json:"costo"`
+	FechaHora   string  `json:"fecha_hora"`
+}
+
+// DetalleSimpleConsulta es una fila del reporte simple de consultas
+type DetalleSimpleConsulta struct {
+	ID          int     `json:"id_consulta"`
+	Paciente    string  `json:"paciente"`
+	Empleado    string  `json:"empleado"`
+	Tipo        string  `json:"tipo"`
+	Diagnostico string  `json:"diagnostico"`
+	Costo       float64 `json:"costo"`
+	FechaHora   string  `json:"fecha_hora"`
+}
+
+// DetalleConsultaExpediente cruza un antecedente con una consulta del historial de un expediente
+type DetalleConsultaExpediente struct {
+	DiagnosticoAntecedente string `json:"diagnostico_antecedente"`
+	Descripcion            string `json:"descripcion"`
+	FechaAntecedente       string `json:"fecha_antecedente"`
+	TipoConsulta           string `json:"tipo_consulta"`
+	FechaConsulta          string `json:"fecha_consulta"`
+	DiagnosticoConsulta    string `json:"diagnostico_consulta"`
+}
+
+// ConsultasPorArea cuenta las consultas atendidas por empleados de un área
+type ConsultasPorArea struct {
+	Area  string `json:"area"`
+	Total int    `json:"total"`
+}
+
+// ConsultasPorTurno cuenta las consultas de un turno
+type ConsultasPorTurno struct {
+	Turno string `json:"turno"`
+	Total int    `json:"total"`
+}
+
+// IngresosPorConsultorio suma el costo de las consultas de un consultorio
+type IngresosPorConsultorio struct {
+	Consultorio string  `json:"consultorio"`
+	Total       float64 `json:"total"`
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Antecedentes administra la tabla Antecedentes
+type Antecedentes interface {
+	Crear(a *models.Antecedente) error
+	Listar() ([]models.Antecedente, error)
+	Obtener(id int) (models.Antecedente, error)
+	Actualizar(a models.Antecedente) error
+	Eliminar(id int) error
+}
+
+type antecedentesPG struct {
+	db *sql.DB
+}
+
+func (r *antecedentesPG) Crear(a *models.Antecedente) error {
+	return r.db.QueryRow(`INSERT INTO Antecedentes (id_expediente, diagnostico, descripcion, fecha)
+		VALUES ($1, $2, $3, $4) RETURNING id_antecedente`,
+		a.IDExpediente, a.Diagnostico, a.Descripcion, a.Fecha).Scan(&a.ID)
+}
+
+func (r *antecedentesPG) Listar() ([]models.Antecedente, error) {
+	rows, err := r.db.Query(`SELECT id_antecedente, id_expediente, diagnostico, descripcion, fecha FROM Antecedentes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var antecedentes []models.Antecedente
+	for rows.Next() {
+		var a models.Antecedente
+		if err := rows.Scan(&a.ID, &a.IDExpediente, &a.Diagnostico, &a.Descripcion, &a.Fecha); err == nil {
+			antecedentes = append(antecedentes, a)
+		}
+	}
+	return antecedentes, nil
+}
+
+func (r *antecedentesPG) Obtener(id int) (models.Antecedente, error) {
+	var a models.Antecedente
+	err := r.db.QueryRow(`SELECT id_antecedente, id_expediente, diagnostico, descripcion, fecha FROM Antecedentes WHERE id_antecedente=$1`, id).
+		Scan(&a.ID, &a.IDExpediente, &a.Diagnostico, &a.Descripcion, &a.Fecha)
+	return a, noEncontrado(err)
+}
+
+func (r *antecedentesPG) Actualizar(a models.Antecedente) error {
+	_, err := r.db.Exec(`UPDATE Antecedentes SET id_expediente=$1, diagnostico=$2, descripcion=$3, fecha=$4 WHERE id_antecedente=$5`,
+		a.IDExpediente, a.Diagnostico, a.Descripcion, a.Fecha, a.ID)
+	return err
+}
+
+func (r *antecedentesPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Antecedentes WHERE id_antecedente=$1", id)
+	return err
+}
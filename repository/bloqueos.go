@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"back-menchaca/utils"
+)
+
+func (r *seguridadPG) BloqueoLogin(correo string) (*utils.CuentaBloqueada, error) {
+	var (
+		cb    = utils.CuentaBloqueada{Correo: correo}
+		hasta sql.NullTime
+	)
+	err := r.db.QueryRow(`SELECT fallos, ultimo_fallo, bloqueado_hasta FROM bloqueos_login WHERE correo = $1`, correo).
+		Scan(&cb.Fallos, &cb.UltimoFallo, &hasta)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cb.BloqueadoHasta = tiempoONil(hasta)
+	return &cb, nil
+}
+
+func (r *seguridadPG) FallosLoginIP(ip string, desde time.Time) (int, time.Time, error) {
+	var (
+		fallos  int
+		primero sql.NullTime
+	)
+	err := r.db.QueryRow(`SELECT COUNT(*), MIN(creado_en) FROM intentos_login
+		WHERE ip = $1 AND NOT exitoso AND creado_en > $2`, ip, desde).Scan(&fallos, &primero)
+	return fallos, primero.Time, err
+}
+
+func (r *seguridadPG) RegistrarIntentoLogin(correo, ip string, exitoso bool) error {
+	_, err := r.db.Exec(`INSERT INTO intentos_login (correo, ip, exitoso) VALUES ($1, $2, $3)`, correo, ip, exitoso)
+	return err
+}
+
+func (r *seguridadPG) SumarFalloLogin(correo string) (int, error) {
+	var fallos int
+	err := r.db.QueryRow(`
+		INSERT INTO bloqueos_login (correo, fallos, ultimo_fallo) VALUES ($1, 1, NOW())
+		ON CONFLICT (correo) DO UPDATE SET
+			fallos = CASE WHEN bloqueos_login.bloqueado_hasta IS NOT NULL AND bloqueos_login.bloqueado_hasta < NOW()
+			              THEN 1 ELSE bloqueos_login.fallos + 1 END,
+			bloqueado_hasta = CASE WHEN bloqueos_login.bloqueado_hasta < NOW() THEN NULL ELSE bloqueos_login.bloqueado_hasta END,
+			ultimo_fallo = NOW()
+		RETURNING fallos`, correo).Scan(&fallos)
+	return fallos, err
+}
+
+func (r *seguridadPG) BloquearLogin(correo string, hasta time.Time) error {
+	_, err := r.db.Exec(`UPDATE bloqueos_login SET bloqueado_hasta = $1 WHERE correo = $2`, hasta, correo)
+	return err
+}
+
+func (r *seguridadPG) EliminarBloqueoLogin(correo string) (bool, error) {
+	return afectoAlguna(r.db.Exec(`DELETE FROM bloqueos_login WHERE correo = $1`, correo))
+}
+
+func (r *seguridadPG) BloqueosVigentes() ([]utils.CuentaBloqueada, error) {
+	rows, err := r.db.Query(`SELECT correo, fallos, ultimo_fallo, bloqueado_hasta FROM bloqueos_login
+		WHERE bloqueado_hasta > NOW() ORDER BY bloqueado_hasta DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cuentas := []utils.CuentaBloqueada{}
+	for rows.Next() {
+		var (
+			cb    utils.CuentaBloqueada
+			hasta sql.NullTime
+		)
+		if err := rows.Scan(&cb.Correo, &cb.Fallos, &cb.UltimoFallo, &hasta); err != nil {
+			return nil, err
+		}
+		cb.BloqueadoHasta = tiempoONil(hasta)
+		cuentas = append(cuentas, cb)
+	}
+	return cuentas, rows.Err()
+}
+
+func (r *seguridadPG) GuardarEventoSeguridad(e utils.EventoSeguridad) error {
+	detalle, err := json.Marshal(e.Detalle)
+	if err != nil {
+		detalle = []byte("{}")
+	}
+	_, err = r.db.Exec(`INSERT INTO eventos_seguridad (tipo, correo, ip, detalle) VALUES ($1, $2, $3, $4)`,
+		e.Tipo, e.Correo, e.IP, string(detalle))
+	return err
+}
+
+func (r *seguridadPG) EventosSeguridad(tipo, correo string, limite int) ([]utils.EventoSeguridad, error) {
+	rows, err := r.db.Query(`SELECT id, tipo, COALESCE(correo, ''), COALESCE(ip, ''), detalle, creado_en
+		FROM eventos_seguridad
+		WHERE ($1 = '' OR tipo = $1) AND ($2 = '' OR correo = $2)
+		ORDER BY creado_en DESC LIMIT $3`, tipo, correo, limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	eventos := []utils.EventoSeguridad{}
+	for rows.Next() {
+		var (
+			e       utils.EventoSeguridad
+			detalle []byte
+		)
+		if err := rows.Scan(&e.ID, &e.Tipo, &e.Correo, &e.IP, &detalle, &e.CreadoEn); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(detalle, &e.Detalle)
+		eventos = append(eventos, e)
+	}
+	return eventos, rows.Err()
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Consentimientos registra la aceptación del aviso de privacidad
+type Consentimientos interface {
+	Registrar(idPaciente int, fecha time.Time) error
+}
+
+type consentimientosPG struct {
+	db *sql.DB
+}
+
+func (r *consentimientosPG) Registrar(idPaciente int, fecha time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO Consentimientos (id_paciente, fecha_hora) VALUES ($1, $2)`, idPaciente, fecha)
+	return err
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	"back-menchaca/models"
+)
+
+// Consultas administra la tabla Consultas
+type Consultas interface {
+	Crear(c *models.Consulta) error
+	// Listar devuelve todas las consultas con sus datos relacionados
+	Listar() ([]models.ConsultaDetallada, error)
+	// ListarPorEmpleado devuelve las consultas de los horarios del empleado,
+	// con el id del consultorio
+	ListarPorEmpleado(idEmpleado int) ([]models.ConsultaDetallada, error)
+	ListarPorPaciente(idPaciente int) ([]models.Consulta, error)
+	Obtener(id int) (models.Consulta, error)
+	Existe(id int) (bool, error)
+	Actualizar(c models.Consulta) error
+	Eliminar(id int) error
+}
+
+type consultasPG struct {
+	db *sql.DB
+}
+
+const selectConsultaDetallada = `
+	SELECT
+		c.id_consulta,%s
+		p.nombre AS nombre_paciente, p.appaterno AS app_paterno_paciente, p.apmaterno AS ap_materno_paciente,
+		r.fecha AS fecha_receta, r.medicamento, r.dosis,
+		h.turno,
+		e.nombre AS nombre_empleado, e.appaterno AS app_paterno_empleado, e.apmaterno AS ap_materno_empleado, e.area AS area_empleado,
+		co.tipo AS tipo_consultorio, co.nombre AS nombre_consultorio,
+		c.tipo, c.diagnostico, c.costo, c.fecha_hora
+	FROM Consultas c
+	LEFT JOIN Paciente p ON c.id_paciente = p.id_paciente
+	LEFT JOIN Recetas r ON c.id_receta = r.id_receta
+	LEFT JOIN Horarios h ON c.id_horario = h.id_horario
+	LEFT JOIN Empleado e ON h.id_empleado = e.id_empleado
+	LEFT JOIN Consultorios co ON c.id_consultorio = co.id_consultorio
+`
+
+func (r *consultasPG) Crear(c *models.Consulta) error {
+	return r.db.QueryRow(`
+		INSERT INTO Consultas (id_paciente, tipo, id_receta, id_horario, id_consultorio, diagnostico, costo, fecha_hora)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id_consulta`,
+		c.IDPaciente, c.Tipo, c.IDReceta, c.IDHorario, c.IDConsultorio, c.Diagnostico, c.Costo, c.FechaHora,
+	).Scan(&c.ID)
+}
+
+func (r *consultasPG) Listar() ([]models.ConsultaDetallada, error) {
+	rows, err := r.db.Query(fmt.Sprintf(selectConsultaDetallada, ""))
+	if err != nil {
+		return nil, err
+	}
+	return escanearConsultasDetalladas(rows, false)
+}
+
+func (r *consultasPG) ListarPorEmpleado(idEmpleado int) ([]models.ConsultaDetallada, error) {
+	rows, err := r.db.Query(fmt.Sprintf(selectConsultaDetallada, " co.id_consultorio,")+"WHERE e.id_empleado = $1", idEmpleado)
+	if err != nil {
+		return nil, err
+	}
+	return escanearConsultasDetalladas(rows, true)
+}
+
+func (r *consultasPG) ListarPorPaciente(idPaciente int) ([]models.Consulta, error) {
+	rows, err := r.db.Query(`
+		SELECT id_consulta, id_paciente, tipo, id_receta, id_horario, id_consultorio, diagnostico, costo, fecha_hora
+		FROM Consultas
+		WHERE id_paciente = $1`, idPaciente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consultas []models.Consulta
+	for rows.Next() {
+		var c models.Consulta
+		var (
+			diagnostico sql.NullString
+			idReceta    sql.NullInt64
+			costo       sql.NullFloat64
+		)
+		if err := rows.Scan(&c.ID, &c.IDPaciente, &c.Tipo, &idReceta, &c.IDHorario, &c.IDConsultorio,
+			&diagnostico, &costo, &c.FechaHora); err != nil {
+			log.Println("Error al escanear fila:", err)
+			continue
+		}
+
+		// Los valores NULL quedan en su valor por defecto
+		c.Diagnostico = diagnostico.String
+		if idReceta.Valid {
+			val := int(idReceta.Int64)
+			c.IDReceta = &val
+		}
+		c.Costo = costo.Float64
+
+		consultas = append(consultas, c)
+	}
+	return consultas, rows.Err()
+}
+
+func (r *consultasPG) Obtener(id int) (models.Consulta, error) {
+	var c models.Consulta
+	err := r.db.QueryRow(
+		`SELECT id_consulta, id_paciente, tipo, id_receta, id_horario, id_consultorio, diagnostico, costo, fecha_hora
+		 FROM Consultas WHERE id_consulta = $1`, id,
+	).Scan(&c.ID, &c.IDPaciente, &c.Tipo, &c.IDReceta, &c.IDHorario, &c.IDConsultorio, &c.Diagnostico, &c.Costo, &c.FechaHora)
+	return c, noEncontrado(err)
+}
+
+func (r *consultasPG) Existe(id int) (bool, error) {
+	return existe(r.db, "SELECT EXISTS(SELECT 1 FROM Consultas WHERE id_consulta = $1)", id)
+}
+
+func (r *consultasPG) Actualizar(c models.Consulta) error {
+	_, err := r.db.Exec(`UPDATE Consultas SET id_paciente=$1, tipo=$2, id_receta=$3, id_horario=$4, id_consultorio=$5, diagnostico=$6, costo=$7, fecha_hora=$8 WHERE id_consulta=$9`,
+		c.IDPaciente, c.Tipo, c.IDReceta, c.IDHorario, c.IDConsultorio, c.Diagnostico, c.Costo, c.FechaHora, c.ID,
+	)
+	return err
+}
+
+func (r *consultasPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Consultas WHERE id_consulta=$1", id)
+	return err
+}
+
+// escanearConsultasDetalladas omite las filas que no se pueden leer (p. ej.
+// consultas sin horario o sin paciente)
+func escanearConsultasDetalladas(rows *sql.Rows, conConsultorio bool) ([]models.ConsultaDetallada, error) {
+	defer rows.Close()
+
+	var consultas []models.ConsultaDetallada
+	for rows.Next() {
+		var c models.ConsultaDetallada
+		destino := []interface{}{&c.IDConsulta}
+		if conConsultorio {
+			destino = append(destino, &c.IDConsultorio)
+		}
+		destino = append(destino,
+			&c.NombrePaciente, &c.AppPaterno, &c.AppMaterno,
+			&c.FechaReceta, &c.Medicamento, &c.Dosis,
+			&c.Turno,
+			&c.EmpleadoNombre, &c.EmpleadoAppPat, &c.EmpleadoAppMat, &c.AreaEmpleado,
+			&c.TipoConsul, &c.NombreConsul,
+			&c.TipoConsulta, &c.Diagnostico, &c.Costo, &c.FechaHora,
+		)
+		if err := rows.Scan(destino...); err != nil {
+			log.Printf("❌ Error en rows.Scan: %v", err)
+			continue
+		}
+		consultas = append(consultas, c)
+	}
+	return consultas, nil
+}
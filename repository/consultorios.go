@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Consultorios administra la tabla Consultorios
+type Consultorios interface {
+	Crear(c *models.Consultorio) error
+	Listar() ([]models.Consultorio, error)
+	Obtener(id int) (models.Consultorio, error)
+	Existe(id int) (bool, error)
+	Actualizar(c models.Consultorio) error
+	Eliminar(id int) error
+}
+
+type consultoriosPG struct {
+	db *sql.DB
+}
+
+func (r *consultoriosPG) Crear(c *models.Consultorio) error {
+	return r.db.QueryRow(`INSERT INTO Consultorios (nombre, tipo) VALUES ($1, $2) RETURNING id_consultorio`,
+		c.Nombre, c.Tipo).Scan(&c.ID)
+}
+
+func (r *consultoriosPG) Listar() ([]models.Consultorio, error) {
+	rows, err := r.db.Query("SELECT id_consultorio, nombre, tipo FROM Consultorios")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lista []models.Consultorio
+	for rows.Next() {
+		var c models.Consultorio
+		if err := rows.Scan(&c.ID, &c.Nombre, &c.Tipo); err == nil {
+			lista = append(lista, c)
+		}
+	}
+	return lista, nil
+}
+
+func (r *consultoriosPG) Obtener(id int) (models.Consultorio, error) {
+	var c models.Consultorio
+	err := r.db.QueryRow("SELECT id_consultorio, nombre, tipo FROM Consultorios WHERE id_consultorio = $1", id).
+		Scan(&c.ID, &c.Nombre, &c.Tipo)
+	return c, noEncontrado(err)
+}
+
+func (r *consultoriosPG) Existe(id int) (bool, error) {
+	return existe(r.db, "SELECT EXISTS(SELECT 1 FROM Consultorios WHERE id_consultorio=$1)", id)
+}
+
+func (r *consultoriosPG) Actualizar(c models.Consultorio) error {
+	_, err := r.db.Exec("UPDATE Consultorios SET nombre=$1, tipo=$2 WHERE id_consultorio=$3", c.Nombre, c.Tipo, c.ID)
+	return err
+}
+
+func (r *consultoriosPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Consultorios WHERE id_consultorio=$1", id)
+	return err
+}
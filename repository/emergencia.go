@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+
+	"back-menchaca/utils"
+)
+
+const selectAccesoEmergencia = `SELECT id_acceso, id_empleado, correo, rol, id_paciente, justificacion, COALESCE(ip, ''),
+	otorgado_en, expira_en, revocado_en, COALESCE(revisado_por, ''), revisado_en, COALESCE(comentario_revision, '')
+	FROM accesos_emergencia`
+
+func (r *seguridadPG) GuardarAccesoEmergencia(a *utils.AccesoEmergencia) error {
+	_, err := r.db.Exec(`INSERT INTO accesos_emergencia
+		(id_acceso, id_empleado, correo, rol, id_paciente, justificacion, ip, otorgado_en, expira_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		a.ID, a.IDEmpleado, a.Correo, a.Rol, a.IDPaciente, a.Justificacion, a.IP, a.OtorgadoEn, a.ExpiraEn)
+	return err
+}
+
+func (r *seguridadPG) BuscarAccesoEmergencia(id string) (*utils.AccesoEmergencia, error) {
+	return escanearAccesoEmergencia(r.db.QueryRow(selectAccesoEmergencia+` WHERE id_acceso = $1`, id))
+}
+
+func escanearAccesoEmergencia(fila escaner) (*utils.AccesoEmergencia, error) {
+	var (
+		a                  utils.AccesoEmergencia
+		revocado, revisado sql.NullTime
+	)
+	err := fila.Scan(&a.ID, &a.IDEmpleado, &a.Correo, &a.Rol, &a.IDPaciente, &a.Justificacion, &a.IP,
+		&a.OtorgadoEn, &a.ExpiraEn, &revocado, &a.RevisadoPor, &revisado, &a.ComentarioRevision)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrAccesoEmergenciaNoEncontrado
+	} else if err != nil {
+		return nil, err
+	}
+	a.RevocadoEn = tiempoONil(revocado)
+	a.RevisadoEn = tiempoONil(revisado)
+	return &a, nil
+}
+
+func (r *seguridadPG) AccesoEmergenciaVigente(idEmpleado string, idPaciente int) (string, error) {
+	var id string
+	err := r.db.QueryRow(`SELECT id_acceso FROM accesos_emergencia
+		WHERE id_empleado = $1 AND id_paciente = $2 AND revocado_en IS NULL AND expira_en > NOW()
+		ORDER BY expira_en DESC LIMIT 1`, idEmpleado, idPaciente).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return id, err
+}
+
+func (r *seguridadPG) AccesosEmergenciaVigentes(idEmpleado string, limite int) ([]utils.AccesoEmergencia, error) {
+	return r.listarAccesosEmergencia(`WHERE id_empleado = $1 AND revocado_en IS NULL AND expira_en > NOW()`, limite, idEmpleado)
+}
+
+func (r *seguridadPG) AccesosEmergenciaRevision(soloPendientes bool, limite int) ([]utils.AccesoEmergencia, error) {
+	return r.listarAccesosEmergencia(`WHERE (NOT $1::boolean OR revisado_en IS NULL)`, limite, soloPendientes)
+}
+
+func (r *seguridadPG) listarAccesosEmergencia(where string, limite int, args ...interface{}) ([]utils.AccesoEmergencia, error) {
+	rows, err := r.db.Query(selectAccesoEmergencia+" "+where+" ORDER BY otorgado_en DESC LIMIT "+strconv.Itoa(limite), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accesos := []utils.AccesoEmergencia{}
+	for rows.Next() {
+		a, err := escanearAccesoEmergencia(rows)
+		if err != nil {
+			return nil, err
+		}
+		accesos = append(accesos, *a)
+	}
+	return accesos, rows.Err()
+}
+
+func (r *seguridadPG) MarcarAccesoEmergenciaRevisado(id, revisor, comentario string, revocar bool) error {
+	_, err := r.db.Exec(`UPDATE accesos_emergencia
+		SET revisado_por = $2, revisado_en = NOW(), comentario_revision = $3,
+			revocado_en = CASE WHEN $4 AND revocado_en IS NULL AND expira_en > NOW() THEN NOW() ELSE revocado_en END
+		WHERE id_acceso = $1`, id, revisor, comentario, revocar)
+	return err
+}
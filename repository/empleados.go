@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Empleados administra la tabla Empleado
+type Empleados interface {
+	// Crear registra al empleado y le asocia la identidad en una sola
+	// transacción; devuelve el id de la identidad
+	Crear(e *models.Empleado, alta AltaIdentidad) (string, error)
+	Listar() ([]models.Empleado, error)
+	Obtener(id int) (models.Empleado, error)
+	Existe(id int) (bool, error)
+	// Actualizar modifica al empleado y, en la misma transacción, el correo de
+	// su identidad; devuelve el id de la identidad si su correo cambió
+	Actualizar(e models.Empleado) (string, error)
+	Eliminar(id int) error
+}
+
+type empleadosPG struct {
+	db *sql.DB
+}
+
+func (r *empleadosPG) Crear(e *models.Empleado, alta AltaIdentidad) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO Empleado (nombre, appaterno, apmaterno, tipo_empleado, area, correo)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id_empleado`,
+		e.Nombre, e.Appaterno, e.Apmaterno, e.Tipo, e.Area, e.Correo,
+	).Scan(&e.ID)
+	if err != nil {
+		return "", err
+	}
+
+	identidadID, err := registrarIdentidad(tx, alta, "empleado", e.ID)
+	if err != nil {
+		return "", err
+	}
+	return identidadID, tx.Commit()
+}
+
+func (r *empleadosPG) Listar() ([]models.Empleado, error) {
+	rows, err := r.db.Query("SELECT id_empleado, nombre, appaterno, apmaterno, tipo_empleado, area, correo FROM Empleado")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	empleados := []models.Empleado{}
+	for rows.Next() {
+		var e models.Empleado
+		if err := rows.Scan(&e.ID, &e.Nombre, &e.Appaterno, &e.Apmaterno, &e.Tipo, &e.Area, &e.Correo); err == nil {
+			empleados = append(empleados, e)
+		}
+	}
+	return empleados, nil
+}
+
+func (r *empleadosPG) Obtener(id int) (models.Empleado, error) {
+	var e models.Empleado
+	err := r.db.QueryRow(
+		"SELECT id_empleado, nombre, appaterno, apmaterno, tipo_empleado, area, correo FROM Empleado WHERE id_empleado = $1", id,
+	).Scan(&e.ID, &e.Nombre, &e.Appaterno, &e.Apmaterno, &e.Tipo, &e.Area, &e.Correo)
+	return e, noEncontrado(err)
+}
+
+func (r *empleadosPG) Existe(id int) (bool, error) {
+	return existe(r.db, "SELECT EXISTS(SELECT 1 FROM Empleado WHERE id_empleado=$1)", id)
+}
+
+func (r *empleadosPG) Actualizar(e models.Empleado) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE Empleado SET nombre=$1, appaterno=$2, apmaterno=$3, tipo_empleado=$4, area=$5, correo=$6
+		 WHERE id_empleado=$7`,
+		e.Nombre, e.Appaterno, e.Apmaterno, e.Tipo, e.Area, e.Correo, e.ID,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	identidadID, err := cambiarCorreoAcceso(tx, "empleado", e.ID, e.Correo)
+	if err != nil {
+		return "", err
+	}
+	return identidadID, tx.Commit()
+}
+
+func (r *empleadosPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Empleado WHERE id_empleado = $1", id)
+	return err
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"log"
+
+	"back-menchaca/models"
+)
+
+// Expedientes administra la tabla Expediente
+type Expedientes interface {
+	Crear(e *models.Expediente) error
+	// Listar y ObtenerDetalle incluyen el nombre del paciente y el diagnóstico
+	// y la descripción de los antecedentes del expediente
+	Listar() ([]models.ExpedienteDetallado, error)
+	ObtenerDetalle(id int) (models.ExpedienteDetallado, error)
+	Obtener(id int) (models.Expediente, error)
+	Existe(id int) (bool, error)
+	Actualizar(e models.Expediente) error
+	Eliminar(id int) error
+}
+
+type expedientesPG struct {
+	db *sql.DB
+}
+
+const selectExpedienteDetallado = `
+	SELECT e.id_expediente, e.id_paciente, e.seguro, e.fecha_creacion,
+	       p.nombre, p.appaterno, p.apmaterno
+	FROM Expediente e
+	LEFT JOIN Paciente p ON e.id_paciente = p.id_paciente
+`
+
+func (r *expedientesPG) Crear(e *models.Expediente) error {
+	return r.db.QueryRow(`INSERT INTO Expediente (id_paciente, seguro, fecha_creacion) VALUES ($1, $2, $3) RETURNING id_expediente`,
+		e.IDPaciente, e.Seguro, e.FechaCreacion).Scan(&e.ID)
+}
+
+func (r *expedientesPG) Listar() ([]models.ExpedienteDetallado, error) {
+	rows, err := r.db.Query(selectExpedienteDetallado)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expedientes []models.ExpedienteDetallado
+	for rows.Next() {
+		e, err := escanearExpediente(rows)
+		if err != nil {
+			log.Println("❌ Error al escanear expediente:", err)
+			continue
+		}
+		expedientes = append(expedientes, e)
+	}
+	rows.Close()
+
+	for i := range expedientes {
+		expedientes[i].Antecedentes, err = r.antecedentes(expedientes[i].ID)
+		if err != nil {
+			log.Println("⚠️ Error al consultar antecedentes:", err)
+		}
+	}
+	return expedientes, nil
+}
+
+func (r *expedientesPG) ObtenerDetalle(id int) (models.ExpedienteDetallado, error) {
+	e, err := escanearExpediente(r.db.QueryRow(selectExpedienteDetallado+"WHERE e.id_expediente = $1", id))
+	if err != nil {
+		return e, noEncontrado(err)
+	}
+
+	e.Antecedentes, err = r.antecedentes(id)
+	if err != nil {
+		log.Println("⚠️ Error al consultar antecedentes:", err)
+	}
+	return e, nil
+}
+
+func (r *expedientesPG) Obtener(id int) (models.Expediente, error) {
+	var e models.Expediente
+	err := r.db.QueryRow(`SELECT id_expediente, id_paciente, seguro, fecha_creacion FROM Expediente WHERE id_expediente=$1`, id).
+		Scan(&e.ID, &e.IDPaciente, &e.Seguro, &e.FechaCreacion)
+	return e, noEncontrado(err)
+}
+
+func (r *expedientesPG) Existe(id int) (bool, error) {
+	return existe(r.db, `SELECT EXISTS(SELECT 1 FROM Expediente WHERE id_expediente = $1)`, id)
+}
+
+func (r *expedientesPG) Actualizar(e models.Expediente) error {
+	_, err := r.db.Exec(`UPDATE Expediente SET id_paciente=$1, seguro=$2, fecha_creacion=$3 WHERE id_expediente=$4`,
+		e.IDPaciente, e.Seguro, e.FechaCreacion, e.ID)
+	return err
+}
+
+func (r *expedientesPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Expediente WHERE id_expediente=$1", id)
+	return err
+}
+
+func (r *expedientesPG) antecedentes(idExpediente int) ([]models.Antecedente, error) {
+	rows, err := r.db.Query(`SELECT diagnostico, descripcion FROM Antecedentes WHERE id_expediente = $1`, idExpediente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var antecedentes []models.Antecedente
+	for rows.Next() {
+		var diag, desc sql.NullString
+		if err := rows.Scan(&diag, &desc); err != nil {
+			log.Println("❌ Error al escanear antecedente:", err)
+			continue
+		}
+		antecedentes = append(antecedentes, models.Antecedente{
+			IDExpediente: idExpediente,
+			Diagnostico:  diag.String,
+			Descripcion:  desc.String,
+		})
+	}
+	return antecedentes, nil
+}
+
+type escaner interface {
+	Scan(dest ...interface{}) error
+}
+
+// escanearExpediente lee una fila de selectExpedienteDetallado; los NULL
+// quedan en su valor por defecto
+func escanearExpediente(fila escaner) (models.ExpedienteDetallado, error) {
+	var (
+		e                        models.ExpedienteDetallado
+		seguro, nombre, app, apm sql.NullString
+		fechaCreacion            sql.NullTime
+	)
+	if err := fila.Scan(&e.ID, &e.IDPaciente, &seguro, &fechaCreacion, &nombre, &app, &apm); err != nil {
+		return e, err
+	}
+	e.Seguro = seguro.String
+	e.FechaCreacion = fechaCreacion.Time
+	e.Nombre = nombre.String
+	e.Appaterno = app.String
+	e.Apmaterno = apm.String
+	return e, nil
+}
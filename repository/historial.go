@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Historial administra la tabla Historial_Clinico
+type Historial interface {
+	Crear(h *models.HistorialClinico) error
+	Listar() ([]models.HistorialClinico, error)
+	Obtener(id int) (models.HistorialClinico, error)
+	Actualizar(h models.HistorialClinico) error
+	Eliminar(id int) error
+}
+
+type historialPG struct {
+	db *sql.DB
+}
+
+func (r *historialPG) Crear(h *models.HistorialClinico) error {
+	return r.db.QueryRow(`INSERT INTO Historial_Clinico (id_expediente, id_consultas) VALUES ($1, $2) RETURNING id_historial`,
+		h.IDExpediente, h.IDConsulta).Scan(&h.ID)
+}
+
+func (r *historialPG) Listar() ([]models.HistorialClinico, error) {
+	rows, err := r.db.Query("SELECT id_historial, id_expediente, id_consultas FROM Historial_Clinico")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var historiales []models.HistorialClinico
+	for rows.Next() {
+		var h models.HistorialClinico
+		if err := rows.Scan(&h.ID, &h.IDExpediente, &h.IDConsulta); err == nil {
+			historiales = append(historiales, h)
+		}
+	}
+	return historiales, nil
+}
+
+func (r *historialPG) Obtener(id int) (models.HistorialClinico, error) {
+	var h models.HistorialClinico
+	err := r.db.QueryRow("SELECT id_historial, id_expediente, id_consultas FROM Historial_Clinico WHERE id_historial = $1", id).
+		Scan(&h.ID, &h.IDExpediente, &h.IDConsulta)
+	return h, noEncontrado(err)
+}
+
+func (r *historialPG) Actualizar(h models.HistorialClinico) error {
+	_, err := r.db.Exec(`UPDATE Historial_Clinico SET id_expediente=$1, id_consultas=$2 WHERE id_historial=$3`,
+		h.IDExpediente, h.IDConsulta, h.ID)
+	return err
+}
+
+func (r *historialPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Historial_Clinico WHERE id_historial = $1", id)
+	return err
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Horarios administra la tabla Horarios
+type Horarios interface {
+	Crear(h *models.Horario) error
+	Listar() ([]models.Horario, error)
+	Obtener(id int) (models.Horario, error)
+	Existe(id int) (bool, error)
+	Actualizar(h models.Horario) error
+	Eliminar(id int) error
+}
+
+type horariosPG struct {
+	db *sql.DB
+}
+
+func (r *horariosPG) Crear(h *models.Horario) error {
+	return r.db.QueryRow(`INSERT INTO Horarios (id_consultorio, turno, id_empleado) VALUES ($1, $2, $3) RETURNING id_horario`,
+		h.IDConsultorio, h.Turno, h.IDEmpleado).Scan(&h.ID)
+}
+
+func (r *horariosPG) Listar() ([]models.Horario, error) {
+	rows, err := r.db.Query("SELECT id_horario, id_consultorio, turno, id_empleado FROM Horarios")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lista []models.Horario
+	for rows.Next() {
+		var h models.Horario
+		if err := rows.Scan(&h.ID, &h.IDConsultorio, &h.Turno, &h.IDEmpleado); err == nil {
+			lista = append(lista, h)
+		}
+	}
+	return lista, nil
+}
+
+func (r *horariosPG) Obtener(id int) (models.Horario, error) {
+	var h models.Horario
+	err := r.db.QueryRow("SELECT id_horario, id_consultorio, turno, id_empleado FROM Horarios WHERE id_horario = $1", id).
+		Scan(&h.ID, &h.IDConsultorio, &h.Turno, &h.IDEmpleado)
+	return h, noEncontrado(err)
+}
+
+func (r *horariosPG) Existe(id int) (bool, error) {
+	return existe(r.db, "SELECT EXISTS(SELECT 1 FROM Horarios WHERE id_horario = $1)", id)
+}
+
+func (r *horariosPG) Actualizar(h models.Horario) error {
+	_, err := r.db.Exec("UPDATE Horarios SET id_consultorio=$1, turno=$2, id_empleado=$3 WHERE id_horario=$4",
+		h.IDConsultorio, h.Turno, h.IDEmpleado, h.ID)
+	return err
+}
+
+func (r *horariosPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Horarios WHERE id_horario=$1", id)
+	return err
+}
@@ -0,0 +1,329 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"back-menchaca/utils"
+
+	"github.com/google/uuid"
+)
+
+const selectIdentidad = `SELECT id_identidad, correo, contraseña, mfa_enabled, mfa_secret, mfa_secret_pendiente,
+	contrasena_actualizada_en, correo_verificado,
+	(SELECT COUNT(*) FROM webauthn_credenciales w WHERE w.id_identidad = identidades.id_identidad)
+	FROM identidades`
+
+// registrarIdentidad crea o reutiliza la identidad del alta y le agrega el rol
+func registrarIdentidad(tx *sql.Tx, alta AltaIdentidad, tipo string, id int) (string, error) {
+	identidadID := alta.IdentidadID
+	if identidadID == "" {
+		var existe bool
+		correo := strings.ToLower(alta.Correo)
+		if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM identidades WHERE correo = $1)`, correo).Scan(&existe); err != nil {
+			return "", err
+		}
+		if existe {
+			return "", utils.ErrCorreoRegistrado
+		}
+
+		identidadID = uuid.NewString()
+		_, err := tx.Exec(`INSERT INTO identidades (id_identidad, correo, contraseña, mfa_enabled, correo_verificado, contrasena_actualizada_en)
+			VALUES ($1, $2, $3, false, $4, NOW())`, identidadID, correo, alta.Hash, alta.Verificado)
+		if err != nil {
+			return "", err
+		}
+	} else if alta.Verificado {
+		if _, err := tx.Exec(`UPDATE identidades SET correo_verificado = true WHERE id_identidad = $1`, identidadID); err != nil {
+			return "", err
+		}
+	}
+
+	_, err := tx.Exec(`INSERT INTO identidad_roles (id_identidad, tipo_usuario, id_usuario) VALUES ($1, $2, $3)`,
+		identidadID, tipo, strconv.Itoa(id))
+	return identidadID, err
+}
+
+// cambiarCorreoAcceso lleva el correo de un paciente o empleado a su identidad,
+// que queda sin verificar. Devuelve el id de la identidad si el correo cambió.
+func cambiarCorreoAcceso(tx *sql.Tx, tipo string, id int, correo string) (string, error) {
+	var identidadID, actual string
+	err := tx.QueryRow(`SELECT i.id_identidad, i.correo FROM identidad_roles r
+		JOIN identidades i ON i.id_identidad = r.id_identidad
+		WHERE r.tipo_usuario = $1 AND r.id_usuario = $2`, tipo, strconv.Itoa(id)).Scan(&identidadID, &actual)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	correo = strings.ToLower(correo)
+	if correo == "" || correo == actual {
+		return "", nil
+	}
+
+	var existe bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM identidades WHERE correo = $1 AND id_identidad <> $2)`,
+		correo, identidadID).Scan(&existe); err != nil {
+		return "", err
+	}
+	if existe {
+		return "", utils.ErrCorreoRegistrado
+	}
+	_, err = tx.Exec(`UPDATE identidades SET correo = $1, correo_verificado = false WHERE id_identidad = $2`,
+		correo, identidadID)
+	return identidadID, err
+}
+
+func (r *seguridadPG) BuscarIdentidadPorCorreo(correo string) (*utils.Cuenta, error) {
+	return r.cargarCuenta(r.db.QueryRow(selectIdentidad+` WHERE correo = $1`, correo))
+}
+
+func (r *seguridadPG) ObtenerIdentidad(identidadID string) (*utils.Cuenta, error) {
+	return r.cargarCuenta(r.db.QueryRow(selectIdentidad+` WHERE id_identidad = $1`, identidadID))
+}
+
+func (r *seguridadPG) IdentidadDeUsuario(tipo, idUsuario string) (string, error) {
+	var identidadID string
+	err := r.db.QueryRow(`SELECT id_identidad FROM identidad_roles WHERE tipo_usuario = $1 AND id_usuario = $2`,
+		tipo, idUsuario).Scan(&identidadID)
+	if err == sql.ErrNoRows {
+		return "", utils.ErrCuentaNoEncontrada
+	}
+	return identidadID, err
+}
+
+func (r *seguridadPG) cargarCuenta(row *sql.Row) (*utils.Cuenta, error) {
+	var (
+		c         utils.Cuenta
+		mfa       sql.NullBool
+		secret    sql.NullString
+		pendiente sql.NullString
+		cambio    sql.NullTime
+		verif     sql.NullBool
+	)
+	err := row.Scan(&c.IdentidadID, &c.Correo, &c.Hash, &mfa, &secret, &pendiente, &cambio, &verif, &c.Passkeys)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrCuentaNoEncontrada
+	} else if err != nil {
+		return nil, err
+	}
+	c.MFAEnabled = mfa.Bool
+	c.MFASecret = secret.String
+	c.MFASecretPendiente = pendiente.String
+	c.ContrasenaActualizadaEn = cambio.Time
+	c.CorreoVerificado = verif.Bool
+
+	rows, err := r.db.Query(`
+		SELECT ir.tipo_usuario, ir.id_usuario, COALESCE(e.tipo_empleado, 'paciente')
+		FROM identidad_roles ir
+		LEFT JOIN Empleado e ON ir.tipo_usuario = 'empleado' AND e.id_empleado::text = ir.id_usuario
+		WHERE ir.id_identidad = $1
+		ORDER BY ir.tipo_usuario`, c.IdentidadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rc utils.RolCuenta
+		if err := rows.Scan(&rc.Tipo, &rc.ID, &rc.Rol); err != nil {
+			return nil, err
+		}
+		c.Roles = append(c.Roles, rc)
+	}
+	return &c, rows.Err()
+}
+
+func (r *seguridadPG) EliminarRolIdentidad(tipo, idUsuario string) error {
+	var identidadID string
+	err := r.db.QueryRow(`DELETE FROM identidad_roles WHERE tipo_usuario = $1 AND id_usuario = $2
+		RETURNING id_identidad`, tipo, idUsuario).Scan(&identidadID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(`DELETE FROM identidades WHERE id_identidad = $1
+		AND NOT EXISTS (SELECT 1 FROM identidad_roles WHERE id_identidad = $1)`, identidadID)
+	return err
+}
+
+func (r *seguridadPG) MarcarCorreoVerificado(identidadID, correo string) (bool, error) {
+	return afectoUna(r.db.Exec(`UPDATE identidades SET correo_verificado = true
+		WHERE id_identidad = $1 AND correo = $2`, identidadID, correo))
+}
+
+func (r *seguridadPG) GuardarMFAPendiente(identidadID, secret string) error {
+	_, err := r.db.Exec(`UPDATE identidades SET mfa_secret_pendiente = $1 WHERE id_identidad = $2`, secret, identidadID)
+	return err
+}
+
+func (r *seguridadPG) ConfirmarMFA(identidadID string, paso int64) error {
+	_, err := r.db.Exec(`UPDATE identidades
+		SET mfa_secret = mfa_secret_pendiente, mfa_secret_pendiente = NULL, mfa_enabled = true, mfa_ultimo_paso = $2
+		WHERE id_identidad = $1 AND mfa_secret_pendiente IS NOT NULL`, identidadID, paso)
+	return err
+}
+
+func (r *seguridadPG) ConsumirPasoTOTP(identidadID string, paso int64) (bool, error) {
+	return afectoUna(r.db.Exec(`UPDATE identidades SET mfa_ultimo_paso = $1
+		WHERE id_identidad = $2 AND (mfa_ultimo_paso IS NULL OR mfa_ultimo_paso < $1)`, paso, identidadID))
+}
+
+func (r *seguridadPG) DesactivarMFA(identidadID string) error {
+	_, err := r.db.Exec(`UPDATE identidades
+		SET mfa_secret = NULL, mfa_secret_pendiente = NULL, mfa_enabled = false, mfa_ultimo_paso = NULL
+		WHERE id_identidad = $1`, identidadID)
+	return err
+}
+
+func (r *seguridadPG) ReemplazarHash(identidadID, anterior, nuevo string) error {
+	_, err := r.db.Exec(`UPDATE identidades SET contraseña = $1 WHERE id_identidad = $2 AND contraseña = $3`,
+		nuevo, identidadID, anterior)
+	return err
+}
+
+func (r *seguridadPG) HistorialContrasenas(identidadID string, limite int) ([]string, error) {
+	rows, err := r.db.Query(`SELECT hash FROM historial_contrasenas
+		WHERE id_identidad = $1
+		ORDER BY creado_en DESC LIMIT $2`, identidadID, limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+func (r *seguridadPG) CambiarContrasena(identidadID, anterior, nuevo string, conservar int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO historial_contrasenas (id_identidad, hash) VALUES ($1, $2)`,
+		identidadID, anterior); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM historial_contrasenas WHERE id_identidad = $1 AND id NOT IN (
+		SELECT id FROM historial_contrasenas WHERE id_identidad = $1
+		ORDER BY creado_en DESC LIMIT $2)`, identidadID, conservar); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE identidades SET contraseña = $1, contrasena_actualizada_en = NOW() WHERE id_identidad = $2`,
+		nuevo, identidadID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *seguridadPG) GuardarTokenReset(identidadID, tokenHash string, expira time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE password_reset_tokens SET usado_en = NOW()
+		WHERE id_identidad = $1 AND usado_en IS NULL`, identidadID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO password_reset_tokens (token_hash, id_identidad, expira_en)
+		VALUES ($1, $2, $3)`, tokenHash, identidadID, expira); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *seguridadPG) ConsumirTokenReset(tokenHash string) (string, error) {
+	var identidadID string
+	err := r.db.QueryRow(`UPDATE password_reset_tokens SET usado_en = NOW()
+		WHERE token_hash = $1 AND usado_en IS NULL AND expira_en > NOW()
+		RETURNING id_identidad`, tokenHash).Scan(&identidadID)
+	if err == sql.ErrNoRows {
+		return "", utils.ErrResetInvalido
+	}
+	return identidadID, err
+}
+
+func (r *seguridadPG) ReemplazarCodigosRecuperacion(identidadID string, hashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_codigos_recuperacion WHERE id_identidad = $1`, identidadID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.Exec(`INSERT INTO mfa_codigos_recuperacion (id_identidad, codigo_hash)
+			VALUES ($1, $2)`, identidadID, hash); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *seguridadPG) CodigosRecuperacionPendientes(identidadID string) ([]utils.CodigoRecuperacion, error) {
+	rows, err := r.db.Query(`SELECT id, codigo_hash FROM mfa_codigos_recuperacion
+		WHERE id_identidad = $1 AND usado_en IS NULL`, identidadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codigos []utils.CodigoRecuperacion
+	for rows.Next() {
+		var c utils.CodigoRecuperacion
+		if err := rows.Scan(&c.ID, &c.Hash); err != nil {
+			return nil, err
+		}
+		codigos = append(codigos, c)
+	}
+	return codigos, rows.Err()
+}
+
+func (r *seguridadPG) MarcarCodigoRecuperacionUsado(id int64) (bool, error) {
+	// La condición usado_en IS NULL evita que dos requests usen el mismo código
+	return afectoUna(r.db.Exec(`UPDATE mfa_codigos_recuperacion SET usado_en = NOW()
+		WHERE id = $1 AND usado_en IS NULL`, id))
+}
+
+func (r *seguridadPG) ContarCodigosRecuperacion(identidadID string) (int, error) {
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM mfa_codigos_recuperacion
+		WHERE id_identidad = $1 AND usado_en IS NULL`, identidadID).Scan(&total)
+	return total, err
+}
+
+func (r *seguridadPG) EliminarCodigosRecuperacion(identidadID string) error {
+	_, err := r.db.Exec(`DELETE FROM mfa_codigos_recuperacion WHERE id_identidad = $1`, identidadID)
+	return err
+}
+
+func (r *seguridadPG) NombreUsuario(tipo, idUsuario string) (string, error) {
+	tabla, col := "Empleado", "id_empleado"
+	if tipo == "paciente" {
+		tabla, col = "Paciente", "id_paciente"
+	}
+	var nombre string
+	err := r.db.QueryRow(`SELECT TRIM(CONCAT_WS(' ', nombre, appaterno, apmaterno)) FROM `+tabla+
+		` WHERE `+col+`::text = $1`, idUsuario).Scan(&nombre)
+	return nombre, err
+}
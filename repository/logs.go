@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"back-menchaca/models"
+)
+
+// Logs guarda y consulta las solicitudes registradas por el middleware Logger
+type Logs interface {
+	Guardar(l models.Log) error
+	// Recientes devuelve los últimos registros, del más nuevo al más viejo
+	Recientes(limite int) ([]map[string]interface{}, error)
+}
+
+type logsPG struct {
+	db *sql.DB
+}
+
+func (r *logsPG) Guardar(l models.Log) error {
+	// Consulta SQL directa (sin prepared statement)
+	query := `
+		INSERT INTO logs (
+			timestamp, method, path, status, response_time, ip,
+			user_agent, level, system, body
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	maxRetries := 2
+	var lastError error
+
+	for i := 0; i < maxRetries; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		_, err := r.db.ExecContext(ctx, query,
+			l.Timestamp, l.Method, l.Path, l.Status, l.ResponseTime, l.IP,
+			l.UserAgent, l.Level, toJSON(l.System), toJSON(l.Body),
+		)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		lastError = err
+
+		// Manejar específicamente el error de prepared statement
+		if strings.Contains(err.Error(), "unnamed prepared statement does not exist") {
+			// Reintentar inmediatamente con nueva conexión
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		// Para otros errores, hacer backoff exponencial
+		time.Sleep(time.Duration(math.Pow(2, float64(i))) * time.Second)
+	}
+
+	return fmt.Errorf("after %d attempts: %v", maxRetries, lastError)
+}
+
+func (r *logsPG) Recientes(limite int) ([]map[string]interface{}, error) {
+	rows, err := r.db.Query(`SELECT * FROM logs ORDER BY timestamp DESC LIMIT $1`, limite)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]interface{}
+	for rows.Next() {
+		// Creamos un slice de interfaces para los valores
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{})
+		for i, col := range columns {
+			val := values[i]
+
+			// Convertir []byte a string si aplica
+			if b, ok := val.([]byte); ok {
+				var decoded interface{}
+				// Intentar decodificar JSON (para los campos JSONB como query, body, etc.)
+				if json.Unmarshal(b, &decoded) == nil {
+					row[col] = decoded
+				} else {
+					row[col] = string(b)
+				}
+			} else {
+				row[col] = val
+			}
+		}
+		results = append(results, row)
+	}
+	return results, nil
+}
+
+// toJSON convierte a JSON de forma segura
+func toJSON(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf(`{"json_error":"%v"}`, err.Error())
+	}
+	return string(data)
+}
@@ -0,0 +1,131 @@
+package memoria
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"back-menchaca/utils"
+)
+
+func (r *seguridad) BloqueoLogin(correo string) (*utils.CuentaBloqueada, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cb, ok := r.bloqueos[correo]
+	if !ok {
+		return nil, nil
+	}
+	return &cb, nil
+}
+
+func (r *seguridad) FallosLoginIP(ip string, desde time.Time) (int, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var (
+		fallos  int
+		primero time.Time
+	)
+	for _, in := range r.intentosLogin {
+		if in.ip != ip || in.exitoso || !in.creado.After(desde) {
+			continue
+		}
+		if fallos++; primero.IsZero() || in.creado.Before(primero) {
+			primero = in.creado
+		}
+	}
+	return fallos, primero, nil
+}
+
+func (r *seguridad) RegistrarIntentoLogin(correo, ip string, exitoso bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intentosLogin = append(r.intentosLogin, intentoLogin{correo: correo, ip: ip, exitoso: exitoso, creado: time.Now()})
+	return nil
+}
+
+func (r *seguridad) SumarFalloLogin(correo string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ahora := time.Now()
+	cb, ok := r.bloqueos[correo]
+	switch {
+	case !ok:
+		cb = utils.CuentaBloqueada{Correo: correo, Fallos: 1}
+	case cb.BloqueadoHasta != nil && cb.BloqueadoHasta.Before(ahora):
+		cb.Fallos = 1
+		cb.BloqueadoHasta = nil
+	default:
+		cb.Fallos++
+	}
+	cb.UltimoFallo = ahora
+	r.bloqueos[correo] = cb
+	return cb.Fallos, nil
+}
+
+func (r *seguridad) BloquearLogin(correo string, hasta time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cb, ok := r.bloqueos[correo]; ok {
+		cb.BloqueadoHasta = &hasta
+		r.bloqueos[correo] = cb
+	}
+	return nil
+}
+
+func (r *seguridad) EliminarBloqueoLogin(correo string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.bloqueos[correo]
+	delete(r.bloqueos, correo)
+	return ok, nil
+}
+
+func (r *seguridad) BloqueosVigentes() ([]utils.CuentaBloqueada, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ahora := time.Now()
+	cuentas := []utils.CuentaBloqueada{}
+	for _, cb := range r.bloqueos {
+		if cb.BloqueadoHasta != nil && cb.BloqueadoHasta.After(ahora) {
+			cuentas = append(cuentas, cb)
+		}
+	}
+	slices.SortFunc(cuentas, func(a, b utils.CuentaBloqueada) int { return b.BloqueadoHasta.Compare(*a.BloqueadoHasta) })
+	return cuentas, nil
+}
+
+func (r *seguridad) GuardarEventoSeguridad(e utils.EventoSeguridad) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	detalle, err := json.Marshal(e.Detalle)
+	if err != nil {
+		detalle = []byte("{}")
+	}
+	guardado := utils.EventoSeguridad{
+		ID:       int64(r.siguiente("eventos_seguridad")),
+		Tipo:     e.Tipo,
+		Correo:   e.Correo,
+		IP:       e.IP,
+		CreadoEn: time.Now(),
+	}
+	json.Unmarshal(detalle, &guardado.Detalle)
+	r.eventos = append(r.eventos, guardado)
+	return nil
+}
+
+func (r *seguridad) EventosSeguridad(tipo, correo string, limite int) ([]utils.EventoSeguridad, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	eventos := []utils.EventoSeguridad{}
+	for i := len(r.eventos) - 1; i >= 0 && len(eventos) < limite; i-- {
+		e := r.eventos[i]
+		if (tipo == "" || e.Tipo == tipo) && (correo == "" || e.Correo == correo) {
+			// El detalle se vuelve a decodificar para no compartir el mapa guardado
+			detalle, _ := json.Marshal(e.Detalle)
+			e.Detalle = nil
+			json.Unmarshal(detalle, &e.Detalle)
+			eventos = append(eventos, e)
+		}
+	}
+	return eventos, nil
+}
@@ -0,0 +1,648 @@
+package memoria
+
+import (
+	"time"
+
+	"back-menchaca/models"
+	"back-menchaca/repository"
+)
+
+type pacientes struct{ *datos }
+
+func (d *datos) correoPacienteLibre(correo string, excepto int) error {
+	for _, p := range d.pacientes {
+		if p.Correo == correo && p.ID != excepto {
+			return duplicado("Paciente", "correo", correo)
+		}
+	}
+	return nil
+}
+
+func (r *pacientes) Crear(p *models.Paciente, alta repository.AltaIdentidad) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.correoPacienteLibre(p.Correo, 0); err != nil {
+		return "", err
+	}
+	if err := r.validarAlta(alta); err != nil {
+		return "", err
+	}
+	p.ID = r.siguiente("Paciente")
+	r.pacientes[p.ID] = models.Paciente{ID: p.ID, Nombre: p.Nombre, Appaterno: p.Appaterno, Apmaterno: p.Apmaterno, Correo: p.Correo}
+	return r.registrarIdentidad(alta, "paciente", p.ID), nil
+}
+
+func (r *pacientes) Listar() ([]models.Paciente, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ordenados(r.pacientes), nil
+}
+
+func (r *pacientes) Obtener(id int) (models.Paciente, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pacientes[id]
+	if !ok {
+		return p, repository.ErrNoEncontrado
+	}
+	return p, nil
+}
+
+func (r *pacientes) Existe(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.pacientes[id]
+	return ok, nil
+}
+
+func (r *pacientes) Actualizar(p models.Paciente) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pacientes[p.ID]; !ok {
+		return "", nil
+	}
+	if err := r.correoPacienteLibre(p.Correo, p.ID); err != nil {
+		return "", err
+	}
+	i, err := r.correoAccesoLibre("paciente", p.ID, p.Correo)
+	if err != nil {
+		return "", err
+	}
+	r.pacientes[p.ID] = models.Paciente{ID: p.ID, Nombre: p.Nombre, Appaterno: p.Appaterno, Apmaterno: p.Apmaterno, Correo: p.Correo}
+	return cambiarCorreo(i, p.Correo), nil
+}
+
+func (r *pacientes) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.borrarPaciente(id)
+	return nil
+}
+
+// borrarPaciente borra en cascada sus expedientes, consultas, consentimientos
+// y accesos de emergencia
+func (d *datos) borrarPaciente(id int) {
+	if _, ok := d.pacientes[id]; !ok {
+		return
+	}
+	delete(d.pacientes, id)
+	for _, e := range d.expedientes {
+		if e.IDPaciente == id {
+			d.borrarExpediente(e.ID)
+		}
+	}
+	for _, c := range d.consultas {
+		if c.IDPaciente == id {
+			d.borrarConsulta(c.ID)
+		}
+	}
+	for _, c := range d.consentimientos {
+		if c.IDPaciente == id {
+			delete(d.consentimientos, c.ID)
+		}
+	}
+	for _, a := range d.accesosEmergencia {
+		if a.IDPaciente == id {
+			delete(d.accesosEmergencia, a.ID)
+		}
+	}
+}
+
+type empleados struct{ *datos }
+
+func (d *datos) correoEmpleadoLibre(correo string, excepto int) error {
+	for _, e := range d.empleados {
+		if e.Correo == correo && e.ID != excepto {
+			return duplicado("Empleado", "correo", correo)
+		}
+	}
+	return nil
+}
+
+func (r *empleados) Crear(e *models.Empleado, alta repository.AltaIdentidad) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.correoEmpleadoLibre(e.Correo, 0); err != nil {
+		return "", err
+	}
+	if err := r.validarAlta(alta); err != nil {
+		return "", err
+	}
+	e.ID = r.siguiente("Empleado")
+	r.empleados[e.ID] = models.Empleado{ID: e.ID, Nombre: e.Nombre, Appaterno: e.Appaterno, Apmaterno: e.Apmaterno,
+		Tipo: e.Tipo, Area: e.Area, Correo: e.Correo}
+	return r.registrarIdentidad(alta, "empleado", e.ID), nil
+}
+
+func (r *empleados) Listar() ([]models.Empleado, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lista := ordenados(r.empleados)
+	if lista == nil {
+		lista = []models.Empleado{}
+	}
+	return lista, nil
+}
+
+func (r *empleados) Obtener(id int) (models.Empleado, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.empleados[id]
+	if !ok {
+		return e, repository.ErrNoEncontrado
+	}
+	return e, nil
+}
+
+func (r *empleados) Existe(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.empleados[id]
+	return ok, nil
+}
+
+func (r *empleados) Actualizar(e models.Empleado) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.empleados[e.ID]; !ok {
+		return "", nil
+	}
+	if err := r.correoEmpleadoLibre(e.Correo, e.ID); err != nil {
+		return "", err
+	}
+	i, err := r.correoAccesoLibre("empleado", e.ID, e.Correo)
+	if err != nil {
+		return "", err
+	}
+	r.empleados[e.ID] = models.Empleado{ID: e.ID, Nombre: e.Nombre, Appaterno: e.Appaterno, Apmaterno: e.Apmaterno,
+		Tipo: e.Tipo, Area: e.Area, Correo: e.Correo}
+	return cambiarCorreo(i, e.Correo), nil
+}
+
+func (r *empleados) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, h := range r.horarios {
+		if h.IDEmpleado == id {
+			return referenciado("Empleado", id, "Horarios")
+		}
+	}
+	delete(r.empleados, id)
+	return nil
+}
+
+type consultorios struct{ *datos }
+
+func (r *consultorios) Crear(c *models.Consultorio) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c.ID = r.siguiente("Consultorios")
+	r.consultorios[c.ID] = *c
+	return nil
+}
+
+func (r *consultorios) Listar() ([]models.Consultorio, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ordenados(r.consultorios), nil
+}
+
+func (r *consultorios) Obtener(id int) (models.Consultorio, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.consultorios[id]
+	if !ok {
+		return c, repository.ErrNoEncontrado
+	}
+	return c, nil
+}
+
+func (r *consultorios) Existe(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.consultorios[id]
+	return ok, nil
+}
+
+func (r *consultorios) Actualizar(c models.Consultorio) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.consultorios[c.ID]; ok {
+		r.consultorios[c.ID] = c
+	}
+	return nil
+}
+
+func (r *consultorios) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, h := range r.horarios {
+		if h.IDConsultorio == id {
+			return referenciado("Consultorios", id, "Horarios")
+		}
+	}
+	for _, rec := range r.recetas {
+		if rec.IDConsultorio == id {
+			return referenciado("Consultorios", id, "Recetas")
+		}
+	}
+	for _, c := range r.consultas {
+		if c.IDConsultorio == id {
+			return referenciado("Consultorios", id, "Consultas")
+		}
+	}
+	delete(r.consultorios, id)
+	return nil
+}
+
+type horarios struct{ *datos }
+
+func (d *datos) validarHorario(h models.Horario) error {
+	if _, ok := d.consultorios[h.IDConsultorio]; !ok {
+		return sinReferencia("Horarios", "id_consultorio", h.IDConsultorio)
+	}
+	if _, ok := d.empleados[h.IDEmpleado]; !ok {
+		return sinReferencia("Horarios", "id_empleado", h.IDEmpleado)
+	}
+	return nil
+}
+
+func (r *horarios) Crear(h *models.Horario) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.validarHorario(*h); err != nil {
+		return err
+	}
+	h.ID = r.siguiente("Horarios")
+	r.horarios[h.ID] = *h
+	return nil
+}
+
+func (r *horarios) Listar() ([]models.Horario, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ordenados(r.horarios), nil
+}
+
+func (r *horarios) Obtener(id int) (models.Horario, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.horarios[id]
+	if !ok {
+		return h, repository.ErrNoEncontrado
+	}
+	return h, nil
+}
+
+func (r *horarios) Existe(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.horarios[id]
+	return ok, nil
+}
+
+func (r *horarios) Actualizar(h models.Horario) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.horarios[h.ID]; !ok {
+		return nil
+	}
+	if err := r.validarHorario(h); err != nil {
+		return err
+	}
+	r.horarios[h.ID] = h
+	return nil
+}
+
+func (r *horarios) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.consultas {
+		if c.IDHorario == id {
+			return referenciado("Horarios", id, "Consultas")
+		}
+	}
+	delete(r.horarios, id)
+	return nil
+}
+
+type recetas struct{ *datos }
+
+func (r *recetas) Crear(rec *models.Receta) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.consultorios[rec.IDConsultorio]; !ok {
+		return sinReferencia("Recetas", "id_consultorio", rec.IDConsultorio)
+	}
+	rec.ID = r.siguiente("Recetas")
+	fila := *rec
+	fila.Fecha = soloFecha(fila.Fecha)
+	r.recetas[rec.ID] = fila
+	return nil
+}
+
+func (r *recetas) CrearEnConsulta(rec *models.Receta, idConsulta int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.consultas[idConsulta]
+	if !ok {
+		return repository.ErrNoEncontrado
+	}
+	if _, ok := r.consultorios[rec.IDConsultorio]; !ok {
+		return sinReferencia("Recetas", "id_consultorio", rec.IDConsultorio)
+	}
+	rec.ID = r.siguiente("Recetas")
+	fila := *rec
+	fila.Fecha = soloFecha(fila.Fecha)
+	r.recetas[rec.ID] = fila
+	id := rec.ID
+	c.IDReceta = &id
+	r.consultas[c.ID] = c
+	return nil
+}
+
+func (r *recetas) Listar() ([]models.Receta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ordenados(r.recetas), nil
+}
+
+func (r *recetas) Obtener(id int) (models.Receta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.recetas[id]
+	if !ok {
+		return rec, repository.ErrNoEncontrado
+	}
+	return rec, nil
+}
+
+func (r *recetas) ObtenerDetalle(id int) (models.Receta, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, ok := r.recetas[id]
+	if !ok {
+		return models.Receta{}, "", repository.ErrNoEncontrado
+	}
+	return rec, r.consultorios[rec.IDConsultorio].Nombre, nil
+}
+
+func (r *recetas) Actualizar(rec models.Receta) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.recetas[rec.ID]; !ok {
+		return nil
+	}
+	if _, ok := r.consultorios[rec.IDConsultorio]; !ok {
+		return sinReferencia("Recetas", "id_consultorio", rec.IDConsultorio)
+	}
+	rec.Fecha = soloFecha(rec.Fecha)
+	r.recetas[rec.ID] = rec
+	return nil
+}
+
+// Eliminar deja sin receta las consultas que la tenían (ON DELETE SET NULL)
+func (r *recetas) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.recetas[id]; !ok {
+		return nil
+	}
+	delete(r.recetas, id)
+	for _, c := range r.consultas {
+		if c.IDReceta != nil && *c.IDReceta == id {
+			c.IDReceta = nil
+			r.consultas[c.ID] = c
+		}
+	}
+	return nil
+}
+
+type expedientes struct{ *datos }
+
+func (r *expedientes) Crear(e *models.Expediente) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pacientes[e.IDPaciente]; !ok {
+		return sinReferencia("Expediente", "id_paciente", e.IDPaciente)
+	}
+	e.ID = r.siguiente("Expediente")
+	fila := *e
+	fila.FechaCreacion = soloFecha(fila.FechaCreacion)
+	r.expedientes[e.ID] = fila
+	return nil
+}
+
+func (r *expedientes) detalle(e models.Expediente) models.ExpedienteDetallado {
+	p := r.pacientes[e.IDPaciente]
+	det := models.ExpedienteDetallado{Expediente: e, Nombre: p.Nombre, Appaterno: p.Appaterno, Apmaterno: p.Apmaterno}
+	for _, a := range ordenados(r.antecedentes) {
+		if a.IDExpediente == e.ID {
+			det.Antecedentes = append(det.Antecedentes, models.Antecedente{
+				IDExpediente: e.ID,
+				Diagnostico:  a.Diagnostico,
+				Descripcion:  a.Descripcion,
+			})
+		}
+	}
+	return det
+}
+
+func (r *expedientes) Listar() ([]models.ExpedienteDetallado, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lista []models.ExpedienteDetallado
+	for _, e := range ordenados(r.expedientes) {
+		lista = append(lista, r.detalle(e))
+	}
+	return lista, nil
+}
+
+func (r *expedientes) ObtenerDetalle(id int) (models.ExpedienteDetallado, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.expedientes[id]
+	if !ok {
+		return models.ExpedienteDetallado{}, repository.ErrNoEncontrado
+	}
+	return r.detalle(e), nil
+}
+
+func (r *expedientes) Obtener(id int) (models.Expediente, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.expedientes[id]
+	if !ok {
+		return e, repository.ErrNoEncontrado
+	}
+	return e, nil
+}
+
+func (r *expedientes) Existe(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.expedientes[id]
+	return ok, nil
+}
+
+func (r *expedientes) Actualizar(e models.Expediente) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.expedientes[e.ID]; !ok {
+		return nil
+	}
+	if _, ok := r.pacientes[e.IDPaciente]; !ok {
+		return sinReferencia("Expediente", "id_paciente", e.IDPaciente)
+	}
+	e.FechaCreacion = soloFecha(e.FechaCreacion)
+	r.expedientes[e.ID] = e
+	return nil
+}
+
+func (r *expedientes) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.borrarExpediente(id)
+	return nil
+}
+
+// borrarExpediente borra en cascada sus antecedentes y su historial clínico
+func (d *datos) borrarExpediente(id int) {
+	delete(d.expedientes, id)
+	for _, a := range d.antecedentes {
+		if a.IDExpediente == id {
+			delete(d.antecedentes, a.ID)
+		}
+	}
+	for _, h := range d.historial {
+		if h.IDExpediente == id {
+			delete(d.historial, h.ID)
+		}
+	}
+}
+
+type antecedentes struct{ *datos }
+
+func (r *antecedentes) Crear(a *models.Antecedente) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.expedientes[a.IDExpediente]; !ok {
+		return sinReferencia("Antecedentes", "id_expediente", a.IDExpediente)
+	}
+	a.ID = r.siguiente("Antecedentes")
+	fila := *a
+	fila.Fecha = soloFecha(fila.Fecha)
+	r.antecedentes[a.ID] = fila
+	return nil
+}
+
+func (r *antecedentes) Listar() ([]models.Antecedente, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ordenados(r.antecedentes), nil
+}
+
+func (r *antecedentes) Obtener(id int) (models.Antecedente, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.antecedentes[id]
+	if !ok {
+		return a, repository.ErrNoEncontrado
+	}
+	return a, nil
+}
+
+func (r *antecedentes) Actualizar(a models.Antecedente) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.antecedentes[a.ID]; !ok {
+		return nil
+	}
+	if _, ok := r.expedientes[a.IDExpediente]; !ok {
+		return sinReferencia("Antecedentes", "id_expediente", a.IDExpediente)
+	}
+	a.Fecha = soloFecha(a.Fecha)
+	r.antecedentes[a.ID] = a
+	return nil
+}
+
+func (r *antecedentes) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.antecedentes, id)
+	return nil
+}
+
+type historial struct{ *datos }
+
+func (d *datos) validarHistorial(h models.HistorialClinico) error {
+	if _, ok := d.expedientes[h.IDExpediente]; !ok {
+		return sinReferencia("Historial_Clinico", "id_expediente", h.IDExpediente)
+	}
+	if _, ok := d.consultas[h.IDConsulta]; !ok {
+		return sinReferencia("Historial_Clinico", "id_consultas", h.IDConsulta)
+	}
+	return nil
+}
+
+func (r *historial) Crear(h *models.HistorialClinico) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.validarHistorial(*h); err != nil {
+		return err
+	}
+	h.ID = r.siguiente("Historial_Clinico")
+	r.historial[h.ID] = *h
+	return nil
+}
+
+func (r *historial) Listar() ([]models.HistorialClinico, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ordenados(r.historial), nil
+}
+
+func (r *historial) Obtener(id int) (models.HistorialClinico, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.historial[id]
+	if !ok {
+		return h, repository.ErrNoEncontrado
+	}
+	return h, nil
+}
+
+func (r *historial) Actualizar(h models.HistorialClinico) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.historial[h.ID]; !ok {
+		return nil
+	}
+	if err := r.validarHistorial(h); err != nil {
+		return err
+	}
+	r.historial[h.ID] = h
+	return nil
+}
+
+func (r *historial) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.historial, id)
+	return nil
+}
+
+type consentimientos struct{ *datos }
+
+func (r *consentimientos) Registrar(idPaciente int, fecha time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.pacientes[idPaciente]; !ok {
+		return sinReferencia("Consentimientos", "id_paciente", idPaciente)
+	}
+	id := r.siguiente("Consentimientos")
+	r.consentimientos[id] = models.Consentimiento{ID: id, IDPaciente: idPaciente, FechaHora: fecha}
+	return nil
+}
@@ -0,0 +1,374 @@
+package memoria
+
+import (
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"math"
+	"slices"
+
+	"back-menchaca/models"
+	"back-menchaca/repository"
+)
+
+type consultas struct{ *datos }
+
+func (d *datos) validarConsulta(c models.Consulta) error {
+	if _, ok := d.pacientes[c.IDPaciente]; !ok {
+		return sinReferencia("Consultas", "id_paciente", c.IDPaciente)
+	}
+	if c.IDReceta != nil {
+		if _, ok := d.recetas[*c.IDReceta]; !ok {
+			return sinReferencia("Consultas", "id_receta", *c.IDReceta)
+		}
+	}
+	if _, ok := d.horarios[c.IDHorario]; !ok {
+		return sinReferencia("Consultas", "id_horario", c.IDHorario)
+	}
+	if _, ok := d.consultorios[c.IDConsultorio]; !ok {
+		return sinReferencia("Consultas", "id_consultorio", c.IDConsultorio)
+	}
+	return nil
+}
+
+// fila copia la consulta como la guarda la columna NUMERIC(10, 2) y sin
+// compartir el id de la receta con quien la envió
+func fila(c models.Consulta) models.Consulta {
+	c.Costo = math.Round(c.Costo*100) / 100
+	if c.IDReceta != nil {
+		id := *c.IDReceta
+		c.IDReceta = &id
+	}
+	return c
+}
+
+func (r *consultas) Crear(c *models.Consulta) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.validarConsulta(*c); err != nil {
+		return err
+	}
+	c.ID = r.siguiente("Consultas")
+	r.consultas[c.ID] = fila(*c)
+	return nil
+}
+
+// detallada arma la fila con los datos relacionados, como los LEFT JOIN de
+// Postgres
+func (d *datos) detallada(c models.Consulta, conConsultorio bool) models.ConsultaDetallada {
+	p := d.pacientes[c.IDPaciente]
+	h := d.horarios[c.IDHorario]
+	e := d.empleados[h.IDEmpleado]
+	co := d.consultorios[c.IDConsultorio]
+	fecha := c.FechaHora
+
+	det := models.ConsultaDetallada{
+		IDConsulta:     c.ID,
+		NombrePaciente: p.Nombre,
+		AppPaterno:     p.Appaterno,
+		AppMaterno:     p.Apmaterno,
+		Turno:          h.Turno,
+		EmpleadoNombre: e.Nombre,
+		EmpleadoAppPat: e.Appaterno,
+		EmpleadoAppMat: e.Apmaterno,
+		AreaEmpleado:   e.Area,
+		TipoConsul:     co.Tipo,
+		NombreConsul:   co.Nombre,
+		TipoConsulta:   c.Tipo,
+		Diagnostico:    sql.NullString{String: c.Diagnostico, Valid: true},
+		Costo:          sql.NullFloat64{Float64: c.Costo, Valid: true},
+		FechaHora:      &fecha,
+	}
+	if conConsultorio {
+		det.IDConsultorio = c.IDConsultorio
+	}
+	if c.IDReceta != nil {
+		if rec, ok := d.recetas[*c.IDReceta]; ok {
+			det.FechaReceta = sql.NullTime{Time: rec.Fecha, Valid: true}
+			det.Medicamento = sql.NullString{String: rec.Medicamento, Valid: true}
+			det.Dosis = sql.NullString{String: rec.Dosis, Valid: true}
+		}
+	}
+	return det
+}
+
+func (r *consultas) Listar() ([]models.ConsultaDetallada, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lista []models.ConsultaDetallada
+	for _, c := range ordenados(r.consultas) {
+		lista = append(lista, r.detallada(c, false))
+	}
+	return lista, nil
+}
+
+func (r *consultas) ListarPorEmpleado(idEmpleado int) ([]models.ConsultaDetallada, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lista []models.ConsultaDetallada
+	for _, c := range ordenados(r.consultas) {
+		if r.horarios[c.IDHorario].IDEmpleado == idEmpleado {
+			lista = append(lista, r.detallada(c, true))
+		}
+	}
+	return lista, nil
+}
+
+func (r *consultas) ListarPorPaciente(idPaciente int) ([]models.Consulta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lista []models.Consulta
+	for _, c := range ordenados(r.consultas) {
+		if c.IDPaciente == idPaciente {
+			lista = append(lista, fila(c))
+		}
+	}
+	return lista, nil
+}
+
+func (r *consultas) Obtener(id int) (models.Consulta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.consultas[id]
+	if !ok {
+		return c, repository.ErrNoEncontrado
+	}
+	return fila(c), nil
+}
+
+func (r *consultas) Existe(id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.consultas[id]
+	return ok, nil
+}
+
+func (r *consultas) Actualizar(c models.Consulta) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.consultas[c.ID]; !ok {
+		return nil
+	}
+	if err := r.validarConsulta(c); err != nil {
+		return err
+	}
+	r.consultas[c.ID] = fila(c)
+	return nil
+}
+
+func (r *consultas) Eliminar(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.borrarConsulta(id)
+	return nil
+}
+
+// borrarConsulta borra en cascada sus entradas del historial clínico
+func (d *datos) borrarConsulta(id int) {
+	delete(d.consultas, id)
+	for _, h := range d.historial {
+		if h.IDConsulta == id {
+			delete(d.historial, h.ID)
+		}
+	}
+}
+
+type reportes struct{ *datos }
+
+// Los reportes omiten, como los de Postgres, las filas a las que les falta
+// alguna relación del JOIN
+
+func (r *reportes) ConsultasPorPaciente(idPaciente int) ([]models.DetalleConsultaPaciente, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pacientes[idPaciente]
+	if !ok {
+		return nil, nil
+	}
+	var filas []models.Consulta
+	for _, c := range ordenados(r.consultas) {
+		if c.IDPaciente == idPaciente {
+			filas = append(filas, c)
+		}
+	}
+	slices.SortStableFunc(filas, func(a, b models.Consulta) int { return b.FechaHora.Compare(a.FechaHora) })
+
+	var resultados []models.DetalleConsultaPaciente
+	for _, c := range filas {
+		h, ok := r.horarios[c.IDHorario]
+		if !ok {
+			continue
+		}
+		e, ok := r.empleados[h.IDEmpleado]
+		if !ok {
+			continue
+		}
+		co, ok := r.consultorios[c.IDConsultorio]
+		if !ok {
+			continue
+		}
+		resultados = append(resultados, models.DetalleConsultaPaciente{
+			ID: c.ID, Paciente: p.Nombre, Empleado: e.Nombre, Turno: h.Turno, Consultorio: co.Nombre,
+			Tipo: c.Tipo, Diagnostico: c.Diagnostico, Costo: c.Costo, FechaHora: texto(c.FechaHora),
+		})
+	}
+	return resultados, nil
+}
+
+func (r *reportes) DetallesConsultaExpediente(idExpediente int) ([]models.DetalleConsultaExpediente, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var resultados []models.DetalleConsultaExpediente
+	for _, h := range ordenados(r.historial) {
+		if h.IDExpediente != idExpediente {
+			continue
+		}
+		c, ok := r.consultas[h.IDConsulta]
+		if !ok {
+			continue
+		}
+		for _, a := range ordenados(r.antecedentes) {
+			if a.IDExpediente != idExpediente {
+				continue
+			}
+			resultados = append(resultados, models.DetalleConsultaExpediente{
+				DiagnosticoAntecedente: a.Diagnostico,
+				Descripcion:            a.Descripcion,
+				FechaAntecedente:       texto(a.Fecha),
+				TipoConsulta:           c.Tipo,
+				FechaConsulta:          texto(c.FechaHora),
+				DiagnosticoConsulta:    c.Diagnostico,
+			})
+		}
+	}
+	return resultados, nil
+}
+
+func (r *reportes) ConsultasPorArea() ([]models.ConsultasPorArea, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totales := map[string]int{}
+	for _, c := range r.consultas {
+		if h, ok := r.horarios[c.IDHorario]; ok {
+			if e, ok := r.empleados[h.IDEmpleado]; ok {
+				totales[e.Area]++
+			}
+		}
+	}
+	var data []models.ConsultasPorArea
+	for _, area := range slices.Sorted(maps.Keys(totales)) {
+		data = append(data, models.ConsultasPorArea{Area: area, Total: totales[area]})
+	}
+	return data, nil
+}
+
+func (r *reportes) ConsultasPorTurno() ([]models.ConsultasPorTurno, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totales := map[string]int{}
+	for _, c := range r.consultas {
+		if h, ok := r.horarios[c.IDHorario]; ok {
+			totales[h.Turno]++
+		}
+	}
+	var data []models.ConsultasPorTurno
+	for _, turno := range slices.Sorted(maps.Keys(totales)) {
+		data = append(data, models.ConsultasPorTurno{Turno: turno, Total: totales[turno]})
+	}
+	return data, nil
+}
+
+func (r *reportes) IngresosPorConsultorio() ([]models.IngresosPorConsultorio, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totales := map[string]float64{}
+	for _, c := range r.consultas {
+		if co, ok := r.consultorios[c.IDConsultorio]; ok {
+			totales[co.Nombre] += c.Costo
+		}
+	}
+	var data []models.IngresosPorConsultorio
+	for _, nombre := range slices.Sorted(maps.Keys(totales)) {
+		data = append(data, models.IngresosPorConsultorio{Consultorio: nombre, Total: math.Round(totales[nombre]*100) / 100})
+	}
+	return data, nil
+}
+
+func (r *reportes) DetalleSimpleConsultas() ([]models.DetalleSimpleConsulta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var detalles []models.DetalleSimpleConsulta
+	for _, c := range ordenados(r.consultas) {
+		e, ok := r.empleados[r.horarios[c.IDHorario].IDEmpleado]
+		if !ok {
+			continue
+		}
+		detalles = append(detalles, models.DetalleSimpleConsulta{
+			ID: c.ID, Paciente: r.pacientes[c.IDPaciente].Nombre, Empleado: e.Nombre,
+			Tipo: c.Tipo, Diagnostico: c.Diagnostico, Costo: c.Costo, FechaHora: texto(c.FechaHora),
+		})
+	}
+	return detalles, nil
+}
+
+type logs struct{ *datos }
+
+// maxLogs limita los registros que se conservan en memoria
+const maxLogs = 1000
+
+func (r *logs) Guardar(l models.Log) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logs = append(r.logs, map[string]interface{}{
+		"id":            int64(r.siguiente("logs")),
+		"timestamp":     l.Timestamp,
+		"method":        l.Method,
+		"path":          l.Path,
+		"status":        int64(l.Status),
+		"response_time": l.ResponseTime,
+		"ip":            l.IP,
+		"user_agent":    l.UserAgent,
+		"level":         l.Level,
+		"request_id":    nil,
+		"system":        comoJSON(l.System),
+		"body":          comoJSON(l.Body),
+	})
+	if len(r.logs) > maxLogs {
+		r.logs = slices.Delete(r.logs, 0, len(r.logs)-maxLogs)
+	}
+	return nil
+}
+
+func (r *logs) Recientes(limite int) ([]map[string]interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var results []map[string]interface{}
+	for i := len(r.logs) - 1; i >= 0 && len(results) < limite; i-- {
+		row := make(map[string]interface{}, len(r.logs[i]))
+		for k, v := range r.logs[i] {
+			row[k] = v
+		}
+		results = append(results, row)
+	}
+	return results, nil
+}
+
+// comoJSON devuelve el valor como queda al guardarlo en una columna JSONB y
+// volver a leerlo
+func comoJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return map[string]interface{}{"json_error": err.Error()}
+	}
+	var decoded interface{}
+	json.Unmarshal(data, &decoded)
+	return decoded
+}
@@ -0,0 +1,201 @@
+package memoria
+
+import (
+	"time"
+
+	"back-menchaca/models"
+	"back-menchaca/repository"
+	"back-menchaca/utils"
+)
+
+// contrasenaDemo es la contraseña de todas las cuentas de ejemplo
+const contrasenaDemo = "Menchaca#2025"
+
+// Roles, catálogo de permisos y asignaciones: los que quedan después de las
+// migraciones 0006_roles_y_permisos_iniciales y 0007_permisos_de_reportes_e_historial
+var (
+	rolesIniciales = [][2]string{
+		{"paciente", "Pacientes registrados"},
+		{"doctor", "Médicos"},
+		{"enfermera", "Personal de enfermería"},
+		{"administrador", "Administración del hospital y del sistema"},
+	}
+
+	catalogoInicial = [][2]string{
+		{"solicitar_cita", "Agendar consultas y consultar horarios, consultorios, recetas y expedientes propios"},
+		{"ver_citas", "Listar todas las consultas"},
+		{"actualizar_citas", "Modificar consultas"},
+		{"eliminar_citas", "Eliminar consultas"},
+		{"ver_pacientes", "Consultar pacientes"},
+		{"actualizar_pacientes", "Modificar pacientes"},
+		{"eliminar_pacientes", "Eliminar pacientes"},
+		{"ver_todos_los_pacientes", "Acceder a pacientes de cualquier área"},
+		{"ver_empleados", "Consultar empleados"},
+		{"administrar_empleados", "Registrar, modificar y eliminar empleados"},
+		{"administrar_consultorios", "Registrar, modificar y eliminar consultorios"},
+		{"administrar_horarios", "Registrar, modificar y eliminar horarios"},
+		{"ver_recetas", "Listar todas las recetas"},
+		{"crear_recetas", "Emitir recetas"},
+		{"actualizar_recetas", "Modificar recetas"},
+		{"eliminar_recetas", "Eliminar recetas"},
+		{"crear_expedientes", "Abrir expedientes"},
+		{"actualizar_expedientes", "Modificar expedientes"},
+		{"eliminar_expedientes", "Eliminar expedientes"},
+		{"ver_antecedentes", "Consultar antecedentes"},
+		{"crear_antecedentes", "Registrar antecedentes"},
+		{"actualizar_antecedentes", "Modificar antecedentes"},
+		{"eliminar_antecedentes", "Eliminar antecedentes"},
+		{"ver_reportes", "Consultar reportes de consultas e ingresos"},
+		{"ver_historial", "Consultar el historial clínico"},
+		{"crear_historial", "Registrar entradas del historial clínico"},
+		{"actualizar_historial", "Modificar el historial clínico"},
+		{"eliminar_historial", "Eliminar entradas del historial clínico"},
+		{"registrar_consentimiento", "Consultar el aviso de privacidad y registrar el consentimiento"},
+		{"acceso_emergencia", "Solicitar acceso de emergencia a un paciente"},
+		{"revisar_accesos_emergencia", "Revisar y revocar accesos de emergencia"},
+		{"ver_logs", "Consultar la bitácora de solicitudes"},
+		{"administrar_seguridad", "Sesiones, bloqueos, roles, permisos, clientes OAuth y cuentas de servicio"},
+	}
+
+	asignacionesIniciales = map[string][]string{
+		"paciente": {"solicitar_cita", "ver_reportes", "registrar_consentimiento", "ver_pacientes",
+			"actualizar_pacientes"},
+		"enfermera": {"solicitar_cita", "ver_citas", "ver_pacientes", "ver_recetas", "ver_antecedentes",
+			"crear_antecedentes", "actualizar_antecedentes", "ver_historial", "crear_historial",
+			"actualizar_historial", "eliminar_historial", "ver_reportes", "acceso_emergencia"},
+		"doctor": {"solicitar_cita", "ver_citas", "actualizar_citas", "ver_pacientes", "actualizar_pacientes",
+			"ver_recetas", "crear_recetas", "actualizar_recetas", "crear_expedientes", "actualizar_expedientes",
+			"ver_antecedentes", "crear_antecedentes", "actualizar_antecedentes", "ver_historial", "crear_historial",
+			"actualizar_historial", "eliminar_historial", "ver_reportes", "acceso_emergencia"},
+	}
+)
+
+// sembrar carga los datos de ejemplo a través de los repositorios, para que
+// pasen por las mismas reglas que los datos de la API
+func sembrar(d *datos, repos *repository.Repositorios) error {
+	ahora := time.Now()
+	for _, rol := range rolesIniciales {
+		d.roles[rol[0]] = rolRegistrado{descripcion: rol[1], creado: ahora}
+	}
+	for _, p := range catalogoInicial {
+		d.catalogo[p[0]] = permisoRegistrado{descripcion: p[1], creado: ahora}
+	}
+	for _, rol := range []string{"paciente", "enfermera", "doctor"} {
+		for _, permiso := range asignacionesIniciales[rol] {
+			d.asignaciones = append(d.asignaciones, utils.AsignacionPermiso{
+				Rol: rol, Permiso: permiso, ReglaPermiso: utils.ReglaPermiso{Permitido: true},
+			})
+		}
+	}
+	// El administrador recibe todo el catálogo salvo los permisos propios del paciente
+	for _, p := range catalogoInicial {
+		if p[0] != "registrar_consentimiento" && p[0] != "acceso_emergencia" {
+			d.asignaciones = append(d.asignaciones, utils.AsignacionPermiso{
+				Rol: "administrador", Permiso: p[0], ReglaPermiso: utils.ReglaPermiso{Permitido: true},
+			})
+		}
+	}
+
+	hash, err := utils.HashPassword(contrasenaDemo)
+	if err != nil {
+		return err
+	}
+	alta := func(correo string) repository.AltaIdentidad {
+		return repository.AltaIdentidad{Correo: correo, Hash: hash, Verificado: true}
+	}
+
+	consultorios := []models.Consultorio{
+		{Nombre: "Consultorio 1", Tipo: "Medicina general"},
+		{Nombre: "Consultorio 2", Tipo: "Pediatría"},
+		{Nombre: "Urgencias", Tipo: "Urgencias"},
+	}
+	for i := range consultorios {
+		if err := repos.Consultorios.Crear(&consultorios[i]); err != nil {
+			return err
+		}
+	}
+
+	empleados := []models.Empleado{
+		{Nombre: "Sofía", Appaterno: "Menchaca", Apmaterno: "Ruiz", Tipo: "administrador", Area: "Dirección",
+			Correo: "admin@menchaca.demo"},
+		{Nombre: "Laura", Appaterno: "García", Apmaterno: "Torres", Tipo: "doctor", Area: "Medicina general",
+			Correo: "laura.garcia@menchaca.demo"},
+		{Nombre: "Carlos", Appaterno: "Ramírez", Apmaterno: "Luna", Tipo: "doctor", Area: "Pediatría",
+			Correo: "carlos.ramirez@menchaca.demo"},
+		{Nombre: "Ana", Appaterno: "López", Apmaterno: "Díaz", Tipo: "enfermera", Area: "Medicina general",
+			Correo: "ana.lopez@menchaca.demo"},
+	}
+	for i := range empleados {
+		if _, err := repos.Empleados.Crear(&empleados[i], alta(empleados[i].Correo)); err != nil {
+			return err
+		}
+	}
+
+	pacientes := []models.Paciente{
+		{Nombre: "Juan", Appaterno: "Pérez", Apmaterno: "Gómez", Correo: "juan.perez@menchaca.demo"},
+		{Nombre: "María", Appaterno: "Hernández", Apmaterno: "Castro", Correo: "maria.hernandez@menchaca.demo"},
+		{Nombre: "Pedro", Appaterno: "Sánchez", Apmaterno: "Vega", Correo: "pedro.sanchez@menchaca.demo"},
+	}
+	for i := range pacientes {
+		if _, err := repos.Pacientes.Crear(&pacientes[i], alta(pacientes[i].Correo)); err != nil {
+			return err
+		}
+	}
+
+	horarios := []models.Horario{
+		{IDConsultorio: consultorios[0].ID, Turno: "matutino", IDEmpleado: empleados[1].ID},
+		{IDConsultorio: consultorios[1].ID, Turno: "vespertino", IDEmpleado: empleados[2].ID},
+		{IDConsultorio: consultorios[0].ID, Turno: "matutino", IDEmpleado: empleados[3].ID},
+	}
+	for i := range horarios {
+		if err := repos.Horarios.Crear(&horarios[i]); err != nil {
+			return err
+		}
+	}
+
+	receta := models.Receta{Fecha: ahora.AddDate(0, 0, -3), Medicamento: "Paracetamol", Dosis: "500 mg cada 8 horas",
+		IDConsultorio: consultorios[0].ID}
+	if err := repos.Recetas.Crear(&receta); err != nil {
+		return err
+	}
+
+	consultas := []models.Consulta{
+		{IDPaciente: pacientes[0].ID, Tipo: "general", IDReceta: &receta.ID, IDHorario: horarios[0].ID,
+			IDConsultorio: consultorios[0].ID, Diagnostico: "Infección respiratoria", Costo: 350,
+			FechaHora: ahora.AddDate(0, 0, -3).Truncate(time.Hour)},
+		{IDPaciente: pacientes[1].ID, Tipo: "pediatria", IDHorario: horarios[1].ID,
+			IDConsultorio: consultorios[1].ID, Diagnostico: "Control de rutina", Costo: 300,
+			FechaHora: ahora.AddDate(0, 0, -2).Truncate(time.Hour)},
+		{IDPaciente: pacientes[2].ID, Tipo: "general", IDHorario: horarios[2].ID,
+			IDConsultorio: consultorios[0].ID, Diagnostico: "Hipertensión", Costo: 400,
+			FechaHora: ahora.AddDate(0, 0, -1).Truncate(time.Hour)},
+	}
+	for i := range consultas {
+		if err := repos.Consultas.Crear(&consultas[i]); err != nil {
+			return err
+		}
+	}
+
+	expedientes := []models.Expediente{
+		{IDPaciente: pacientes[0].ID, Seguro: "IMSS", FechaCreacion: ahora.AddDate(-1, 0, 0)},
+		{IDPaciente: pacientes[1].ID, Seguro: "ISSSTE", FechaCreacion: ahora.AddDate(0, -6, 0)},
+	}
+	for i := range expedientes {
+		if err := repos.Expedientes.Crear(&expedientes[i]); err != nil {
+			return err
+		}
+	}
+
+	antecedente := models.Antecedente{IDExpediente: expedientes[0].ID, Diagnostico: "Asma",
+		Descripcion: "Asma leve desde la infancia", Fecha: ahora.AddDate(-10, 0, 0)}
+	if err := repos.Antecedentes.Crear(&antecedente); err != nil {
+		return err
+	}
+
+	historial := models.HistorialClinico{IDExpediente: expedientes[0].ID, IDConsulta: consultas[0].ID}
+	if err := repos.Historial.Crear(&historial); err != nil {
+		return err
+	}
+
+	return repos.Consentimientos.Registrar(pacientes[0].ID, ahora.AddDate(-1, 0, 0))
+}
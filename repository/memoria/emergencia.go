@@ -0,0 +1,102 @@
+package memoria
+
+import (
+	"slices"
+	"time"
+
+	"back-menchaca/utils"
+)
+
+func (r *seguridad) GuardarAccesoEmergencia(a *utils.AccesoEmergencia) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.accesosEmergencia[a.ID]; ok {
+		return duplicado("accesos_emergencia", "id_acceso", a.ID)
+	}
+	if _, ok := r.pacientes[a.IDPaciente]; !ok {
+		return sinReferencia("accesos_emergencia", "id_paciente", a.IDPaciente)
+	}
+	r.accesosEmergencia[a.ID] = utils.AccesoEmergencia{
+		ID: a.ID, IDEmpleado: a.IDEmpleado, Correo: a.Correo, Rol: a.Rol, IDPaciente: a.IDPaciente,
+		Justificacion: a.Justificacion, IP: a.IP, OtorgadoEn: a.OtorgadoEn, ExpiraEn: a.ExpiraEn,
+	}
+	return nil
+}
+
+func (r *seguridad) BuscarAccesoEmergencia(id string) (*utils.AccesoEmergencia, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.accesosEmergencia[id]
+	if !ok {
+		return nil, utils.ErrAccesoEmergenciaNoEncontrado
+	}
+	return &a, nil
+}
+
+func vigente(a utils.AccesoEmergencia, ahora time.Time) bool {
+	return a.RevocadoEn == nil && a.ExpiraEn.After(ahora)
+}
+
+func (r *seguridad) AccesoEmergenciaVigente(idEmpleado string, idPaciente int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ahora := time.Now()
+	var elegido *utils.AccesoEmergencia
+	for _, a := range r.accesosEmergencia {
+		if a.IDEmpleado == idEmpleado && a.IDPaciente == idPaciente && vigente(a, ahora) &&
+			(elegido == nil || a.ExpiraEn.After(elegido.ExpiraEn)) {
+			elegido = &a
+		}
+	}
+	if elegido == nil {
+		return "", nil
+	}
+	return elegido.ID, nil
+}
+
+func (r *seguridad) AccesosEmergenciaVigentes(idEmpleado string, limite int) ([]utils.AccesoEmergencia, error) {
+	ahora := time.Now()
+	return r.listarAccesosEmergencia(limite, func(a utils.AccesoEmergencia) bool {
+		return a.IDEmpleado == idEmpleado && vigente(a, ahora)
+	})
+}
+
+func (r *seguridad) AccesosEmergenciaRevision(soloPendientes bool, limite int) ([]utils.AccesoEmergencia, error) {
+	return r.listarAccesosEmergencia(limite, func(a utils.AccesoEmergencia) bool {
+		return !soloPendientes || a.RevisadoEn == nil
+	})
+}
+
+func (r *seguridad) listarAccesosEmergencia(limite int, filtro func(utils.AccesoEmergencia) bool) ([]utils.AccesoEmergencia, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	accesos := []utils.AccesoEmergencia{}
+	for _, a := range r.accesosEmergencia {
+		if filtro(a) {
+			accesos = append(accesos, a)
+		}
+	}
+	slices.SortFunc(accesos, func(a, b utils.AccesoEmergencia) int { return b.OtorgadoEn.Compare(a.OtorgadoEn) })
+	if len(accesos) > limite {
+		accesos = accesos[:limite]
+	}
+	return accesos, nil
+}
+
+func (r *seguridad) MarcarAccesoEmergenciaRevisado(id, revisor, comentario string, revocar bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.accesosEmergencia[id]
+	if !ok {
+		return nil
+	}
+	ahora := time.Now()
+	a.RevisadoPor = revisor
+	a.RevisadoEn = tiempo(ahora)
+	a.ComentarioRevision = comentario
+	if revocar && vigente(a, ahora) {
+		a.RevocadoEn = tiempo(ahora)
+	}
+	r.accesosEmergencia[id] = a
+	return nil
+}
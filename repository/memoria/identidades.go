@@ -0,0 +1,422 @@
+package memoria
+
+import (
+	"database/sql"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"back-menchaca/repository"
+	"back-menchaca/utils"
+
+	"github.com/google/uuid"
+)
+
+// validarAlta comprueba, antes de escribir nada, que registrarIdentidad va a
+// poder completar el alta
+func (d *datos) validarAlta(alta repository.AltaIdentidad) error {
+	if alta.IdentidadID == "" {
+		if d.identidadPorCorreo(strings.ToLower(alta.Correo)) != nil {
+			return utils.ErrCorreoRegistrado
+		}
+		return nil
+	}
+	if _, ok := d.identidades[alta.IdentidadID]; !ok {
+		return sinReferencia("identidad_roles", "id_identidad", alta.IdentidadID)
+	}
+	return nil
+}
+
+// registrarIdentidad crea o reutiliza la identidad del alta y le agrega el
+// rol; el alta ya pasó por validarAlta
+func (d *datos) registrarIdentidad(alta repository.AltaIdentidad, tipo string, id int) string {
+	identidadID := alta.IdentidadID
+	if identidadID == "" {
+		identidadID = uuid.NewString()
+		d.identidades[identidadID] = &identidad{
+			id:           identidadID,
+			correo:       strings.ToLower(alta.Correo),
+			hash:         alta.Hash,
+			verificado:   alta.Verificado,
+			contrasenaEn: time.Now(),
+		}
+	} else if alta.Verificado {
+		d.identidades[identidadID].verificado = true
+	}
+	d.identidadRoles[claveUsuario{tipo, strconv.Itoa(id)}] = identidadID
+	return identidadID
+}
+
+// correoAccesoLibre comprueba que el correo nuevo de un paciente o empleado se
+// pueda llevar a su identidad; devuelve la identidad si el correo cambia
+func (d *datos) correoAccesoLibre(tipo string, id int, correo string) (*identidad, error) {
+	i, ok := d.identidades[d.identidadRoles[claveUsuario{tipo, strconv.Itoa(id)}]]
+	correo = strings.ToLower(correo)
+	if !ok || correo == "" || correo == i.correo {
+		return nil, nil
+	}
+	if otra := d.identidadPorCorreo(correo); otra != nil && otra != i {
+		return nil, utils.ErrCorreoRegistrado
+	}
+	return i, nil
+}
+
+// cambiarCorreo deja el correo nuevo sin verificar en la identidad que devolvió
+// correoAccesoLibre y devuelve su id ("" si no había cambio)
+func cambiarCorreo(i *identidad, correo string) string {
+	if i == nil {
+		return ""
+	}
+	i.correo = strings.ToLower(correo)
+	i.verificado = false
+	return i.id
+}
+
+func (d *datos) identidadPorCorreo(correo string) *identidad {
+	for _, i := range d.identidades {
+		if i.correo == correo {
+			return i
+		}
+	}
+	return nil
+}
+
+// cuenta arma la cuenta con sus roles y el número de autenticadores WebAuthn
+func (d *datos) cuenta(i *identidad) *utils.Cuenta {
+	c := &utils.Cuenta{
+		IdentidadID:             i.id,
+		Correo:                  i.correo,
+		Hash:                    i.hash,
+		MFAEnabled:              i.mfaEnabled,
+		MFASecret:               i.mfaSecret,
+		MFASecretPendiente:      i.mfaPendiente,
+		ContrasenaActualizadaEn: i.contrasenaEn,
+		CorreoVerificado:        i.verificado,
+	}
+	for _, cred := range d.credencialesWA {
+		if cred.identidadID == i.id {
+			c.Passkeys++
+		}
+	}
+	for clave, identidadID := range d.identidadRoles {
+		if identidadID != i.id {
+			continue
+		}
+		rol := "paciente"
+		if clave.tipo == "empleado" {
+			if id, err := strconv.Atoi(clave.id); err == nil {
+				if e, ok := d.empleados[id]; ok {
+					rol = e.Tipo
+				}
+			}
+		}
+		c.Roles = append(c.Roles, utils.RolCuenta{Tipo: clave.tipo, ID: clave.id, Rol: rol})
+	}
+	slices.SortFunc(c.Roles, func(a, b utils.RolCuenta) int {
+		if a.Tipo != b.Tipo {
+			return strings.Compare(a.Tipo, b.Tipo)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return c
+}
+
+// borrarIdentidad borra en cascada lo que cuelga de la identidad
+func (d *datos) borrarIdentidad(identidadID string) {
+	delete(d.identidades, identidadID)
+	for clave, id := range d.identidadRoles {
+		if id == identidadID {
+			delete(d.identidadRoles, clave)
+		}
+	}
+	d.historialHashes = slices.DeleteFunc(d.historialHashes, func(h hashAnterior) bool { return h.identidadID == identidadID })
+	for hash, t := range d.tokensReset {
+		if t.identidadID == identidadID {
+			delete(d.tokensReset, hash)
+		}
+	}
+	for id, c := range d.codigosRecup {
+		if c.identidadID == identidadID {
+			delete(d.codigosRecup, id)
+		}
+	}
+	for id, c := range d.credencialesWA {
+		if c.identidadID == identidadID {
+			delete(d.credencialesWA, id)
+		}
+	}
+	for clave := range d.consentimientosOA {
+		if clave.identidadID == identidadID {
+			delete(d.consentimientosOA, clave)
+		}
+	}
+	for hash, c := range d.codigosOAuth {
+		if c.codigo.IdentidadID == identidadID {
+			delete(d.codigosOAuth, hash)
+		}
+	}
+}
+
+func (r *seguridad) BuscarIdentidadPorCorreo(correo string) (*utils.Cuenta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.identidadPorCorreo(correo)
+	if i == nil {
+		return nil, utils.ErrCuentaNoEncontrada
+	}
+	return r.cuenta(i), nil
+}
+
+func (r *seguridad) ObtenerIdentidad(identidadID string) (*utils.Cuenta, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.identidades[identidadID]
+	if !ok {
+		return nil, utils.ErrCuentaNoEncontrada
+	}
+	return r.cuenta(i), nil
+}
+
+func (r *seguridad) IdentidadDeUsuario(tipo, idUsuario string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identidadID, ok := r.identidadRoles[claveUsuario{tipo, idUsuario}]
+	if !ok {
+		return "", utils.ErrCuentaNoEncontrada
+	}
+	return identidadID, nil
+}
+
+func (r *seguridad) EliminarRolIdentidad(tipo, idUsuario string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clave := claveUsuario{tipo, idUsuario}
+	identidadID, ok := r.identidadRoles[clave]
+	if !ok {
+		return nil
+	}
+	delete(r.identidadRoles, clave)
+	for _, id := range r.identidadRoles {
+		if id == identidadID {
+			return nil
+		}
+	}
+	r.borrarIdentidad(identidadID)
+	return nil
+}
+
+func (r *seguridad) MarcarCorreoVerificado(identidadID, correo string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.identidades[identidadID]
+	if !ok || i.correo != correo {
+		return false, nil
+	}
+	i.verificado = true
+	return true, nil
+}
+
+func (r *seguridad) GuardarMFAPendiente(identidadID, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.identidades[identidadID]; ok {
+		i.mfaPendiente = secret
+	}
+	return nil
+}
+
+func (r *seguridad) ConfirmarMFA(identidadID string, paso int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.identidades[identidadID]; ok && i.mfaPendiente != "" {
+		i.mfaSecret, i.mfaPendiente = i.mfaPendiente, ""
+		i.mfaEnabled = true
+		i.ultimoPaso = &paso
+	}
+	return nil
+}
+
+func (r *seguridad) ConsumirPasoTOTP(identidadID string, paso int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.identidades[identidadID]
+	if !ok || (i.ultimoPaso != nil && *i.ultimoPaso >= paso) {
+		return false, nil
+	}
+	i.ultimoPaso = &paso
+	return true, nil
+}
+
+func (r *seguridad) DesactivarMFA(identidadID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.identidades[identidadID]; ok {
+		i.mfaSecret, i.mfaPendiente = "", ""
+		i.mfaEnabled = false
+		i.ultimoPaso = nil
+	}
+	return nil
+}
+
+func (r *seguridad) ReemplazarHash(identidadID, anterior, nuevo string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if i, ok := r.identidades[identidadID]; ok && i.hash == anterior {
+		i.hash = nuevo
+	}
+	return nil
+}
+
+func (r *seguridad) HistorialContrasenas(identidadID string, limite int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hashes []string
+	for i := len(r.historialHashes) - 1; i >= 0 && len(hashes) < limite; i-- {
+		if r.historialHashes[i].identidadID == identidadID {
+			hashes = append(hashes, r.historialHashes[i].hash)
+		}
+	}
+	return hashes, nil
+}
+
+func (r *seguridad) CambiarContrasena(identidadID, anterior, nuevo string, conservar int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, ok := r.identidades[identidadID]
+	if !ok {
+		return sinReferencia("historial_contrasenas", "id_identidad", identidadID)
+	}
+
+	r.historialHashes = append(r.historialHashes, hashAnterior{identidadID: identidadID, hash: anterior})
+	conservados := 0
+	for j := len(r.historialHashes) - 1; j >= 0; j-- {
+		if r.historialHashes[j].identidadID != identidadID {
+			continue
+		}
+		if conservados++; conservados > conservar {
+			r.historialHashes = slices.Delete(r.historialHashes, j, j+1)
+		}
+	}
+
+	i.hash = nuevo
+	i.contrasenaEn = time.Now()
+	return nil
+}
+
+func (r *seguridad) GuardarTokenReset(identidadID, tokenHash string, expira time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.identidades[identidadID]; !ok {
+		return sinReferencia("password_reset_tokens", "id_identidad", identidadID)
+	}
+	if _, ok := r.tokensReset[tokenHash]; ok {
+		return duplicado("password_reset_tokens", "token_hash", tokenHash)
+	}
+	for _, t := range r.tokensReset {
+		if t.identidadID == identidadID {
+			t.usado = true
+		}
+	}
+	r.tokensReset[tokenHash] = &tokenReset{identidadID: identidadID, expira: expira}
+	return nil
+}
+
+func (r *seguridad) ConsumirTokenReset(tokenHash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokensReset[tokenHash]
+	if !ok || t.usado || !t.expira.After(time.Now()) {
+		return "", utils.ErrResetInvalido
+	}
+	t.usado = true
+	return t.identidadID, nil
+}
+
+func (r *seguridad) ReemplazarCodigosRecuperacion(identidadID string, hashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.identidades[identidadID]; !ok {
+		return sinReferencia("mfa_codigos_recuperacion", "id_identidad", identidadID)
+	}
+	for id, c := range r.codigosRecup {
+		if c.identidadID == identidadID {
+			delete(r.codigosRecup, id)
+		}
+	}
+	for _, hash := range hashes {
+		id := int64(r.siguiente("mfa_codigos_recuperacion"))
+		r.codigosRecup[id] = &codigoRecuperacion{id: id, identidadID: identidadID, hash: hash}
+	}
+	return nil
+}
+
+func (r *seguridad) CodigosRecuperacionPendientes(identidadID string) ([]utils.CodigoRecuperacion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var codigos []utils.CodigoRecuperacion
+	for _, c := range ordenados(r.codigosRecup) {
+		if c.identidadID == identidadID && !c.usado {
+			codigos = append(codigos, utils.CodigoRecuperacion{ID: c.id, Hash: c.hash})
+		}
+	}
+	return codigos, nil
+}
+
+func (r *seguridad) MarcarCodigoRecuperacionUsado(id int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.codigosRecup[id]
+	if !ok || c.usado {
+		return false, nil
+	}
+	c.usado = true
+	return true, nil
+}
+
+func (r *seguridad) ContarCodigosRecuperacion(identidadID string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0
+	for _, c := range r.codigosRecup {
+		if c.identidadID == identidadID && !c.usado {
+			total++
+		}
+	}
+	return total, nil
+}
+
+func (r *seguridad) EliminarCodigosRecuperacion(identidadID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, c := range r.codigosRecup {
+		if c.identidadID == identidadID {
+			delete(r.codigosRecup, id)
+		}
+	}
+	return nil
+}
+
+func (r *seguridad) NombreUsuario(tipo, idUsuario string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, err := strconv.Atoi(idUsuario)
+	if err != nil {
+		return "", sql.ErrNoRows
+	}
+	var partes []string
+	if tipo == "paciente" {
+		p, ok := r.pacientes[id]
+		if !ok {
+			return "", sql.ErrNoRows
+		}
+		partes = []string{p.Nombre, p.Appaterno, p.Apmaterno}
+	} else {
+		e, ok := r.empleados[id]
+		if !ok {
+			return "", sql.ErrNoRows
+		}
+		partes = []string{e.Nombre, e.Appaterno, e.Apmaterno}
+	}
+	return strings.TrimSpace(strings.Join(partes, " ")), nil
+}
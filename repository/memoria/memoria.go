@@ -0,0 +1,184 @@
+// Package memoria implementa los repositorios y el almacenamiento de seguridad
+// en la memoria del proceso, para demos, desarrollo del front end y pruebas de
+// handlers (serve --store=memory). Arranca con datos de ejemplo y aplica las
+// mismas reglas que el esquema de Postgres: llaves primarias, columnas UNIQUE y
+// llaves foráneas con sus ON DELETE. Los datos se pierden al detener el proceso.
+package memoria
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"back-menchaca/models"
+	"back-menchaca/repository"
+	"back-menchaca/utils"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	// ErrDuplicado equivale a una violación de llave primaria o de UNIQUE
+	ErrDuplicado = errors.New("valor duplicado")
+	// ErrReferencia equivale a una violación de llave foránea
+	ErrReferencia = errors.New("viola una llave foránea")
+)
+
+func duplicado(tabla, columna string, valor interface{}) error {
+	return fmt.Errorf("%w: %s.%s = %v", ErrDuplicado, tabla, columna, valor)
+}
+
+func sinReferencia(tabla, columna string, valor interface{}) error {
+	return fmt.Errorf("%w: %s.%s = %v no existe", ErrReferencia, tabla, columna, valor)
+}
+
+func referenciado(tabla string, id int, desde string) error {
+	return fmt.Errorf("%w: %s %d sigue referenciado desde %s", ErrReferencia, tabla, id, desde)
+}
+
+// datos son las tablas. Todos los repositorios comparten la instancia y su
+// mutex; los métodos toman el candado una sola vez y usan funciones internas
+// que suponen que ya está tomado.
+type datos struct {
+	mu sync.Mutex
+
+	pacientes       map[int]models.Paciente
+	empleados       map[int]models.Empleado
+	consultorios    map[int]models.Consultorio
+	horarios        map[int]models.Horario
+	recetas         map[int]models.Receta
+	consultas       map[int]models.Consulta
+	expedientes     map[int]models.Expediente
+	antecedentes    map[int]models.Antecedente
+	historial       map[int]models.HistorialClinico
+	consentimientos map[int]models.Consentimiento
+	logs            []map[string]interface{}
+
+	identidades       map[string]*identidad
+	identidadRoles    map[claveUsuario]string
+	historialHashes   []hashAnterior
+	tokensReset       map[string]*tokenReset
+	codigosRecup      map[int64]*codigoRecuperacion
+	refreshTokens     map[string]*refreshToken
+	jtiRevocados      map[string]time.Time
+	revocaciones      map[claveUsuario]time.Time
+	renovaciones      map[string]time.Time
+	intentosMFA       map[string]int
+	intentosLogin     []intentoLogin
+	bloqueos          map[string]utils.CuentaBloqueada
+	eventos           []utils.EventoSeguridad
+	clientesOAuth     map[string]utils.ClienteOAuth
+	consentimientosOA map[claveConsentimiento][]string
+	codigosOAuth      map[string]*codigoOAuth
+	cuentasServicio   map[string]utils.CuentaServicio
+	apiKeys           map[string]*utils.APIKeyGuardada
+	credencialesWA    map[string]*credencialWebAuthn
+	sesionesWA        map[string]sesionWebAuthn
+	accesosEmergencia map[string]utils.AccesoEmergencia
+	roles             map[string]rolRegistrado
+	catalogo          map[string]permisoRegistrado
+	asignaciones      []utils.AsignacionPermiso
+
+	secuencias map[string]int
+}
+
+// siguiente devuelve el siguiente valor de la secuencia (como SERIAL)
+func (d *datos) siguiente(tabla string) int {
+	d.secuencias[tabla]++
+	return d.secuencias[tabla]
+}
+
+// Nuevo crea los repositorios en memoria con los datos de ejemplo
+func Nuevo() (*repository.Repositorios, error) {
+	d := &datos{
+		pacientes:       map[int]models.Paciente{},
+		empleados:       map[int]models.Empleado{},
+		consultorios:    map[int]models.Consultorio{},
+		horarios:        map[int]models.Horario{},
+		recetas:         map[int]models.Receta{},
+		consultas:       map[int]models.Consulta{},
+		expedientes:     map[int]models.Expediente{},
+		antecedentes:    map[int]models.Antecedente{},
+		historial:       map[int]models.HistorialClinico{},
+		consentimientos: map[int]models.Consentimiento{},
+
+		identidades:       map[string]*identidad{},
+		identidadRoles:    map[claveUsuario]string{},
+		tokensReset:       map[string]*tokenReset{},
+		codigosRecup:      map[int64]*codigoRecuperacion{},
+		refreshTokens:     map[string]*refreshToken{},
+		jtiRevocados:      map[string]time.Time{},
+		revocaciones:      map[claveUsuario]time.Time{},
+		renovaciones:      map[string]time.Time{},
+		intentosMFA:       map[string]int{},
+		bloqueos:          map[string]utils.CuentaBloqueada{},
+		clientesOAuth:     map[string]utils.ClienteOAuth{},
+		consentimientosOA: map[claveConsentimiento][]string{},
+		codigosOAuth:      map[string]*codigoOAuth{},
+		cuentasServicio:   map[string]utils.CuentaServicio{},
+		apiKeys:           map[string]*utils.APIKeyGuardada{},
+		credencialesWA:    map[string]*credencialWebAuthn{},
+		sesionesWA:        map[string]sesionWebAuthn{},
+		accesosEmergencia: map[string]utils.AccesoEmergencia{},
+		roles:             map[string]rolRegistrado{},
+		catalogo:          map[string]permisoRegistrado{},
+
+		secuencias: map[string]int{},
+	}
+
+	repos := &repository.Repositorios{
+		Pacientes:       &pacientes{d},
+		Empleados:       &empleados{d},
+		Consultorios:    &consultorios{d},
+		Horarios:        &horarios{d},
+		Consultas:       &consultas{d},
+		Recetas:         &recetas{d},
+		Expedientes:     &expedientes{d},
+		Antecedentes:    &antecedentes{d},
+		Historial:       &historial{d},
+		Consentimientos: &consentimientos{d},
+		Reportes:        &reportes{d},
+		Logs:            &logs{d},
+		Seguridad:       &seguridad{d},
+	}
+	if err := sembrar(d, repos); err != nil {
+		return nil, fmt.Errorf("cargando los datos de ejemplo: %w", err)
+	}
+	return repos, nil
+}
+
+// ordenados devuelve las filas de la tabla ordenadas por id (nil si no hay,
+// como los listados de Postgres)
+func ordenados[K int | int64 | string, V any](tabla map[K]V) []V {
+	var filas []V
+	for _, k := range slices.Sorted(maps.Keys(tabla)) {
+		filas = append(filas, tabla[k])
+	}
+	return filas
+}
+
+// soloFecha trunca al día, como las columnas DATE
+func soloFecha(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// texto formatea una fecha como la devuelve database/sql al leerla en un string
+func texto(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+type credencialWebAuthn struct {
+	identidadID string
+	nombre      string
+	cred        webauthn.Credential
+	creado      time.Time
+	ultimoUso   *time.Time
+}
+
+type sesionWebAuthn struct {
+	datos  []byte
+	expira time.Time
+}
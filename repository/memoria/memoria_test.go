@@ -0,0 +1,217 @@
+package memoria
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"back-menchaca/models"
+	"back-menchaca/repository"
+)
+
+// Las pruebas siguen las restricciones de migrations/0001_esquema_base.up.sql
+// sobre los datos de ejemplo: 3 pacientes, 4 empleados, 3 consultorios, 3
+// horarios, una receta en la consulta 1, 3 consultas, los expedientes 1
+// (paciente 1, con un antecedente y una entrada de historial) y 2 (paciente 2).
+
+func nuevo(t *testing.T) (*repository.Repositorios, *datos) {
+	t.Helper()
+	repos, err := Nuevo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repos, repos.Pacientes.(*pacientes).datos
+}
+
+func TestCorreosDuplicados(t *testing.T) {
+	repos, d := nuevo(t)
+
+	casos := map[string]func() error{
+		"paciente nuevo con correo de paciente": func() error {
+			_, err := repos.Pacientes.Crear(&models.Paciente{Nombre: "Otro", Correo: "juan.perez@menchaca.demo"},
+				repository.AltaIdentidad{Correo: "otro@menchaca.demo"})
+			return err
+		},
+		"empleado nuevo con correo de empleado": func() error {
+			_, err := repos.Empleados.Crear(&models.Empleado{Nombre: "Otro", Tipo: "doctor", Correo: "admin@menchaca.demo"},
+				repository.AltaIdentidad{Correo: "otro@menchaca.demo"})
+			return err
+		},
+		"paciente que toma el correo de otro": func() error {
+			p, _ := repos.Pacientes.Obtener(2)
+			p.Correo = "juan.perez@menchaca.demo"
+			_, err := repos.Pacientes.Actualizar(p)
+			return err
+		},
+		"empleado que toma el correo de otro": func() error {
+			e, _ := repos.Empleados.Obtener(2)
+			e.Correo = "admin@menchaca.demo"
+			_, err := repos.Empleados.Actualizar(e)
+			return err
+		},
+	}
+	for nombre, fn := range casos {
+		t.Run(nombre, func(t *testing.T) {
+			if err := fn(); !errors.Is(err, ErrDuplicado) {
+				t.Errorf("err = %v, se esperaba ErrDuplicado", err)
+			}
+		})
+	}
+
+	if len(d.pacientes) != 3 || len(d.empleados) != 4 {
+		t.Errorf("los rechazos dejaron filas: %d pacientes, %d empleados", len(d.pacientes), len(d.empleados))
+	}
+	if p, _ := repos.Pacientes.Obtener(2); p.Correo != "maria.hernandez@menchaca.demo" {
+		t.Errorf("la actualización rechazada cambió el correo a %q", p.Correo)
+	}
+}
+
+func TestLlavesForaneasAlInsertar(t *testing.T) {
+	repos, _ := nuevo(t)
+	receta := 99
+
+	casos := map[string]func() error{
+		"horario sin consultorio": func() error {
+			return repos.Horarios.Crear(&models.Horario{IDConsultorio: 99, IDEmpleado: 1, Turno: "matutino"})
+		},
+		"horario sin empleado": func() error {
+			return repos.Horarios.Crear(&models.Horario{IDConsultorio: 1, IDEmpleado: 99, Turno: "matutino"})
+		},
+		"receta sin consultorio": func() error {
+			return repos.Recetas.Crear(&models.Receta{Medicamento: "Ibuprofeno", Dosis: "400 mg", IDConsultorio: 99})
+		},
+		"consulta sin paciente": func() error {
+			return repos.Consultas.Crear(&models.Consulta{IDPaciente: 99, IDHorario: 1, IDConsultorio: 1})
+		},
+		"consulta sin receta": func() error {
+			return repos.Consultas.Crear(&models.Consulta{IDPaciente: 1, IDReceta: &receta, IDHorario: 1, IDConsultorio: 1})
+		},
+		"consulta sin horario": func() error {
+			return repos.Consultas.Crear(&models.Consulta{IDPaciente: 1, IDHorario: 99, IDConsultorio: 1})
+		},
+		"expediente sin paciente": func() error {
+			return repos.Expedientes.Crear(&models.Expediente{IDPaciente: 99})
+		},
+		"antecedente sin expediente": func() error {
+			return repos.Antecedentes.Crear(&models.Antecedente{IDExpediente: 99, Diagnostico: "Asma"})
+		},
+		"historial sin consulta": func() error {
+			return repos.Historial.Crear(&models.HistorialClinico{IDExpediente: 1, IDConsulta: 99})
+		},
+		"consentimiento sin paciente": func() error {
+			return repos.Consentimientos.Registrar(99, time.Now())
+		},
+		"receta actualizada a un consultorio inexistente": func() error {
+			r, _ := repos.Recetas.Obtener(1)
+			r.IDConsultorio = 99
+			return repos.Recetas.Actualizar(r)
+		},
+	}
+	for nombre, fn := range casos {
+		t.Run(nombre, func(t *testing.T) {
+			if err := fn(); !errors.Is(err, ErrReferencia) {
+				t.Errorf("err = %v, se esperaba ErrReferencia", err)
+			}
+		})
+	}
+}
+
+// Las llaves foráneas sin ON DELETE impiden borrar un registro referenciado
+func TestBorrarReferenciado(t *testing.T) {
+	repos, d := nuevo(t)
+
+	casos := map[string]func() error{
+		"empleado con horarios":                         func() error { return repos.Empleados.Eliminar(2) },
+		"consultorio con horarios, recetas y consultas": func() error { return repos.Consultorios.Eliminar(1) },
+		"horario con consultas":                         func() error { return repos.Horarios.Eliminar(1) },
+	}
+	for nombre, fn := range casos {
+		t.Run(nombre, func(t *testing.T) {
+			if err := fn(); !errors.Is(err, ErrReferencia) {
+				t.Errorf("err = %v, se esperaba ErrReferencia", err)
+			}
+		})
+	}
+
+	if _, ok := d.empleados[2]; !ok {
+		t.Error("se borró el empleado referenciado")
+	}
+	if _, ok := d.consultorios[1]; !ok {
+		t.Error("se borró el consultorio referenciado")
+	}
+	if _, ok := d.horarios[1]; !ok {
+		t.Error("se borró el horario referenciado")
+	}
+}
+
+func TestBorrarPacienteEnCascada(t *testing.T) {
+	repos, d := nuevo(t)
+
+	if err := repos.Pacientes.Eliminar(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.expedientes[1]; ok {
+		t.Error("quedó el expediente del paciente")
+	}
+	if len(d.antecedentes) != 0 || len(d.historial) != 0 {
+		t.Errorf("quedaron %d antecedentes y %d entradas de historial del expediente", len(d.antecedentes), len(d.historial))
+	}
+	for _, c := range d.consultas {
+		if c.IDPaciente == 1 {
+			t.Errorf("quedó la consulta %d del paciente", c.ID)
+		}
+	}
+	if len(d.consentimientos) != 0 {
+		t.Errorf("quedaron %d consentimientos del paciente", len(d.consentimientos))
+	}
+
+	// Los demás pacientes no se tocan
+	if len(d.pacientes) != 2 || len(d.expedientes) != 1 || len(d.consultas) != 2 {
+		t.Errorf("la cascada borró de más: %d pacientes, %d expedientes, %d consultas",
+			len(d.pacientes), len(d.expedientes), len(d.consultas))
+	}
+}
+
+func TestBorrarConsultaYExpedienteEnCascada(t *testing.T) {
+	t.Run("consulta", func(t *testing.T) {
+		repos, d := nuevo(t)
+		if err := repos.Consultas.Eliminar(1); err != nil {
+			t.Fatal(err)
+		}
+		if len(d.historial) != 0 {
+			t.Errorf("quedaron %d entradas de historial de la consulta", len(d.historial))
+		}
+		if _, ok := d.expedientes[1]; !ok {
+			t.Error("se borró el expediente de la entrada de historial")
+		}
+	})
+
+	t.Run("expediente", func(t *testing.T) {
+		repos, d := nuevo(t)
+		if err := repos.Expedientes.Eliminar(1); err != nil {
+			t.Fatal(err)
+		}
+		if len(d.antecedentes) != 0 || len(d.historial) != 0 {
+			t.Errorf("quedaron %d antecedentes y %d entradas de historial", len(d.antecedentes), len(d.historial))
+		}
+		if _, ok := d.consultas[1]; !ok {
+			t.Error("se borró la consulta de la entrada de historial")
+		}
+	})
+}
+
+// Borrar una receta deja sin receta sus consultas (ON DELETE SET NULL)
+func TestBorrarRecetaDejaConsultasSinReceta(t *testing.T) {
+	repos, d := nuevo(t)
+
+	if err := repos.Recetas.Eliminar(1); err != nil {
+		t.Fatal(err)
+	}
+	c, ok := d.consultas[1]
+	if !ok {
+		t.Fatal("se borró la consulta de la receta")
+	}
+	if c.IDReceta != nil {
+		t.Errorf("la consulta conserva la receta %d", *c.IDReceta)
+	}
+}
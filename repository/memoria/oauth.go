@@ -0,0 +1,123 @@
+package memoria
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"back-menchaca/utils"
+)
+
+func (r *seguridad) ObtenerClienteOAuth(clientID string) (*utils.ClienteOAuth, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cl, ok := r.clientesOAuth[clientID]
+	if !ok {
+		return nil, utils.ErrClienteOAuthInvalido
+	}
+	cl.RedirectURIs = copia(cl.RedirectURIs)
+	cl.Scopes = copia(cl.Scopes)
+	return &cl, nil
+}
+
+func (r *seguridad) GuardarClienteOAuth(cl *utils.ClienteOAuth) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clientesOAuth[cl.ClientID]; ok {
+		return duplicado("oauth_clientes", "client_id", cl.ClientID)
+	}
+	guardado := *cl
+	guardado.SecretoHash = strings.TrimSpace(guardado.SecretoHash)
+	guardado.RedirectURIs = copia(cl.RedirectURIs)
+	guardado.Scopes = copia(cl.Scopes)
+	r.clientesOAuth[cl.ClientID] = guardado
+	return nil
+}
+
+func (r *seguridad) ClientesOAuth() ([]utils.ClienteOAuth, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clientes := []utils.ClienteOAuth{}
+	for _, cl := range r.clientesOAuth {
+		cl.SecretoHash = ""
+		cl.RedirectURIs = copia(cl.RedirectURIs)
+		cl.Scopes = copia(cl.Scopes)
+		clientes = append(clientes, cl)
+	}
+	slices.SortFunc(clientes, func(a, b utils.ClienteOAuth) int { return strings.Compare(a.Nombre, b.Nombre) })
+	return clientes, nil
+}
+
+func (r *seguridad) BorrarClienteOAuth(clientID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.clientesOAuth[clientID]; !ok {
+		return false, nil
+	}
+	delete(r.clientesOAuth, clientID)
+	for clave := range r.consentimientosOA {
+		if clave.clientID == clientID {
+			delete(r.consentimientosOA, clave)
+		}
+	}
+	for hash, c := range r.codigosOAuth {
+		if c.codigo.ClientID == clientID {
+			delete(r.codigosOAuth, hash)
+		}
+	}
+	return true, nil
+}
+
+func (r *seguridad) ConsentimientoOAuth(identidadID, clientID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	scopes, ok := r.consentimientosOA[claveConsentimiento{identidadID, clientID}]
+	if !ok {
+		return nil, nil
+	}
+	if scopes == nil {
+		return []string{}, nil
+	}
+	return copia(scopes), nil
+}
+
+func (r *seguridad) GuardarConsentimientoOAuth(identidadID, clientID string, scopes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.identidades[identidadID]; !ok {
+		return sinReferencia("oauth_consentimientos", "id_identidad", identidadID)
+	}
+	if _, ok := r.clientesOAuth[clientID]; !ok {
+		return sinReferencia("oauth_consentimientos", "client_id", clientID)
+	}
+	r.consentimientosOA[claveConsentimiento{identidadID, clientID}] = copia(scopes)
+	return nil
+}
+
+func (r *seguridad) GuardarCodigoAutorizacion(codigoHash string, ca utils.CodigoAutorizacion, expira time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.codigosOAuth[codigoHash]; ok {
+		return duplicado("oauth_codigos", "codigo_hash", codigoHash)
+	}
+	if _, ok := r.clientesOAuth[ca.ClientID]; !ok {
+		return sinReferencia("oauth_codigos", "client_id", ca.ClientID)
+	}
+	if _, ok := r.identidades[ca.IdentidadID]; !ok {
+		return sinReferencia("oauth_codigos", "id_identidad", ca.IdentidadID)
+	}
+	r.codigosOAuth[codigoHash] = &codigoOAuth{codigo: ca, expira: expira}
+	return nil
+}
+
+func (r *seguridad) ConsumirCodigoAutorizacion(codigoHash string) (*utils.CodigoAutorizacion, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.codigosOAuth[codigoHash]
+	if !ok || c.usado || !c.expira.After(time.Now()) {
+		return nil, utils.ErrCodigoInvalido
+	}
+	c.usado = true
+	ca := c.codigo
+	return &ca, nil
+}
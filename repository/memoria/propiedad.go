@@ -0,0 +1,163 @@
+package memoria
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+func (r *seguridad) EmpleadoAtiendePaciente(idEmpleado, idPaciente int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	yo, ok := r.empleados[idEmpleado]
+	if !ok {
+		return false, nil
+	}
+	for _, c := range r.consultas {
+		if c.IDPaciente != idPaciente {
+			continue
+		}
+		h, ok := r.horarios[c.IDHorario]
+		if !ok {
+			continue
+		}
+		if e, ok := r.empleados[h.IDEmpleado]; ok && (e.ID == yo.ID || e.Area == yo.Area) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *seguridad) PacienteDeConsulta(idConsulta int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.consultas[idConsulta]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return c.IDPaciente, nil
+}
+
+func (r *seguridad) PacienteDeReceta(idReceta int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range ordenados(r.consultas) {
+		if c.IDReceta != nil && *c.IDReceta == idReceta {
+			return c.IDPaciente, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+func (r *seguridad) PacienteDeExpediente(idExpediente int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.expedientes[idExpediente]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return e.IDPaciente, nil
+}
+
+func (r *seguridad) PacienteDeAntecedente(idAntecedente int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.antecedentes[idAntecedente]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	e, ok := r.expedientes[a.IDExpediente]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return e.IDPaciente, nil
+}
+
+func (r *seguridad) PacienteDeHistorial(idHistorial int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.historial[idHistorial]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	e, ok := r.expedientes[h.IDExpediente]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return e.IDPaciente, nil
+}
+
+// ExisteRegistro busca en las columnas enteras de las tablas clínicas; los
+// nombres no distinguen mayúsculas, como los identificadores sin comillas de
+// Postgres
+func (r *seguridad) ExisteRegistro(tabla, columna string, id int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	filas, ok := r.columnasEnteras(strings.ToLower(tabla))
+	if !ok {
+		return false, fmt.Errorf("la tabla %q no existe", tabla)
+	}
+	columna = strings.ToLower(columna)
+	for _, fila := range filas {
+		if valor, ok := fila[columna]; ok && valor == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// columnasEnteras devuelve las columnas enteras no nulas de cada fila de la
+// tabla (una columna que no aparece nunca coincide)
+func (d *datos) columnasEnteras(tabla string) ([]map[string]int, bool) {
+	var filas []map[string]int
+	switch tabla {
+	case "paciente":
+		for id := range d.pacientes {
+			filas = append(filas, map[string]int{"id_paciente": id})
+		}
+	case "empleado":
+		for id := range d.empleados {
+			filas = append(filas, map[string]int{"id_empleado": id})
+		}
+	case "consultorios":
+		for id := range d.consultorios {
+			filas = append(filas, map[string]int{"id_consultorio": id})
+		}
+	case "horarios":
+		for _, h := range d.horarios {
+			filas = append(filas, map[string]int{"id_horario": h.ID, "id_consultorio": h.IDConsultorio, "id_empleado": h.IDEmpleado})
+		}
+	case "recetas":
+		for _, rec := range d.recetas {
+			filas = append(filas, map[string]int{"id_receta": rec.ID, "id_consultorio": rec.IDConsultorio})
+		}
+	case "consultas":
+		for _, c := range d.consultas {
+			fila := map[string]int{"id_consulta": c.ID, "id_paciente": c.IDPaciente, "id_horario": c.IDHorario,
+				"id_consultorio": c.IDConsultorio}
+			if c.IDReceta != nil {
+				fila["id_receta"] = *c.IDReceta
+			}
+			filas = append(filas, fila)
+		}
+	case "expediente":
+		for _, e := range d.expedientes {
+			filas = append(filas, map[string]int{"id_expediente": e.ID, "id_paciente": e.IDPaciente})
+		}
+	case "antecedentes":
+		for _, a := range d.antecedentes {
+			filas = append(filas, map[string]int{"id_antecedente": a.ID, "id_expediente": a.IDExpediente})
+		}
+	case "historial_clinico":
+		for _, h := range d.historial {
+			filas = append(filas, map[string]int{"id_historial": h.ID, "id_expediente": h.IDExpediente, "id_consultas": h.IDConsulta})
+		}
+	case "consentimientos":
+		for _, c := range d.consentimientos {
+			filas = append(filas, map[string]int{"id": c.ID, "id_paciente": c.IDPaciente})
+		}
+	default:
+		return nil, false
+	}
+	return filas, true
+}
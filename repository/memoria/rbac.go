@@ -0,0 +1,174 @@
+package memoria
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"back-menchaca/utils"
+)
+
+func (d *datos) permisoConocido(permiso string) bool {
+	if _, ok := d.catalogo[permiso]; ok {
+		return true
+	}
+	return slices.ContainsFunc(d.asignaciones, func(a utils.AsignacionPermiso) bool { return a.Permiso == permiso })
+}
+
+func (r *seguridad) AsignacionesPermisos() ([]utils.AsignacionPermiso, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.asignaciones), nil
+}
+
+func (r *seguridad) RolesRegistrados() ([]utils.Rol, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nombres := map[string]bool{}
+	for rol := range r.roles {
+		nombres[rol] = true
+	}
+	for _, a := range r.asignaciones {
+		nombres[a.Rol] = true
+	}
+
+	roles := []utils.Rol{}
+	for _, nombre := range slices.Sorted(maps.Keys(nombres)) {
+		rol := utils.Rol{Rol: nombre}
+		if reg, ok := r.roles[nombre]; ok {
+			rol.Descripcion = reg.descripcion
+			rol.CreadoEn = tiempo(reg.creado)
+		}
+		if nombre == "paciente" {
+			for clave := range r.identidadRoles {
+				if clave.tipo == "paciente" {
+					rol.Usuarios++
+				}
+			}
+		} else {
+			for _, e := range r.empleados {
+				if e.Tipo == nombre {
+					rol.Usuarios++
+				}
+			}
+		}
+		roles = append(roles, rol)
+	}
+	return roles, nil
+}
+
+func (r *seguridad) RolRegistrado(rol string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.roles[rol]
+	return ok, nil
+}
+
+func (r *seguridad) GuardarRol(rol, descripcion string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.roles[rol]; ok {
+		return duplicado("roles", "rol", rol)
+	}
+	r.roles[rol] = rolRegistrado{descripcion: descripcion, creado: time.Now()}
+	return nil
+}
+
+func (r *seguridad) RolAsignado(rol string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.empleados {
+		if e.Tipo == rol {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *seguridad) BorrarRol(rol string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asignaciones = slices.DeleteFunc(r.asignaciones, func(a utils.AsignacionPermiso) bool { return a.Rol == rol })
+	delete(r.roles, rol)
+	return nil
+}
+
+func (r *seguridad) PermisosRegistrados() ([]utils.PermisoCatalogo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nombres := map[string]bool{}
+	for permiso := range r.catalogo {
+		nombres[permiso] = true
+	}
+	for _, a := range r.asignaciones {
+		nombres[a.Permiso] = true
+	}
+
+	permisos := []utils.PermisoCatalogo{}
+	for _, nombre := range slices.Sorted(maps.Keys(nombres)) {
+		permisos = append(permisos, utils.PermisoCatalogo{Permiso: nombre, Descripcion: r.catalogo[nombre].descripcion})
+	}
+	return permisos, nil
+}
+
+func (r *seguridad) PermisoRegistrado(permiso string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.permisoConocido(permiso), nil
+}
+
+func (r *seguridad) GuardarPermiso(permiso, descripcion string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.catalogo[permiso]; ok {
+		return duplicado("catalogo_permisos", "permiso", permiso)
+	}
+	r.catalogo[permiso] = permisoRegistrado{descripcion: descripcion, creado: time.Now()}
+	return nil
+}
+
+func (r *seguridad) BorrarPermiso(permiso string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asignaciones = slices.DeleteFunc(r.asignaciones, func(a utils.AsignacionPermiso) bool { return a.Permiso == permiso })
+	delete(r.catalogo, permiso)
+	for id, s := range r.cuentasServicio {
+		if slices.Contains(s.Permisos, permiso) {
+			s.Permisos = slices.DeleteFunc(copia(s.Permisos), func(p string) bool { return p == permiso })
+			r.cuentasServicio[id] = s
+		}
+	}
+	return nil
+}
+
+func (r *seguridad) ReemplazarPermisoRol(rol, permiso string, reglas []utils.ReglaPermiso) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asignaciones = slices.DeleteFunc(r.asignaciones, func(a utils.AsignacionPermiso) bool {
+		return a.Rol == rol && a.Permiso == permiso
+	})
+	for _, regla := range reglas {
+		r.asignaciones = append(r.asignaciones, utils.AsignacionPermiso{
+			Rol:     rol,
+			Permiso: permiso,
+			ReglaPermiso: utils.ReglaPermiso{
+				Metodo:    strings.TrimSpace(strings.ToUpper(regla.Metodo)),
+				Ruta:      strings.TrimSpace(regla.Ruta),
+				Permitido: regla.Permitido,
+			},
+		})
+	}
+	return nil
+}
+
+func (r *seguridad) BorrarPermisoRol(rol, permiso string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asignaciones = slices.DeleteFunc(r.asignaciones, func(a utils.AsignacionPermiso) bool {
+		return a.Rol == rol && a.Permiso == permiso
+	})
+	return nil
+}
@@ -0,0 +1,96 @@
+package memoria
+
+import (
+	"time"
+
+	"back-menchaca/utils"
+)
+
+// seguridad implementa utils.Almacen sobre las mismas tablas que los
+// repositorios clínicos. Sus métodos están repartidos por módulo en los
+// archivos de este paquete, como en repository.
+type seguridad struct{ *datos }
+
+type identidad struct {
+	id           string
+	correo       string
+	hash         string
+	mfaEnabled   bool
+	mfaSecret    string
+	mfaPendiente string
+	ultimoPaso   *int64
+	contrasenaEn time.Time
+	verificado   bool
+}
+
+// claveUsuario es la llave de identidad_roles y revocaciones_usuario
+type claveUsuario struct {
+	tipo string
+	id   string
+}
+
+type hashAnterior struct {
+	identidadID string
+	hash        string
+}
+
+type tokenReset struct {
+	identidadID string
+	expira      time.Time
+	usado       bool
+}
+
+type codigoRecuperacion struct {
+	id          int64
+	identidadID string
+	hash        string
+	usado       bool
+}
+
+type refreshToken struct {
+	sesion   utils.RefreshSession
+	tipo     string
+	expira   time.Time
+	usado    bool
+	revocado bool
+}
+
+type intentoLogin struct {
+	correo  string
+	ip      string
+	exitoso bool
+	creado  time.Time
+}
+
+type claveConsentimiento struct {
+	identidadID string
+	clientID    string
+}
+
+type codigoOAuth struct {
+	codigo utils.CodigoAutorizacion
+	expira time.Time
+	usado  bool
+}
+
+type rolRegistrado struct {
+	descripcion string
+	creado      time.Time
+}
+
+type permisoRegistrado struct {
+	descripcion string
+	creado      time.Time
+}
+
+// copia evita que quien recibe un slice guardado lo modifique
+func copia(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+func tiempo(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,159 @@
+package memoria
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"back-menchaca/utils"
+)
+
+func (r *seguridad) ContarPermisosConocidos(permisos []string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, p := range slices.Compact(slices.Sorted(slices.Values(permisos))) {
+		if r.permisoConocido(p) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *seguridad) GuardarCuentaServicio(s *utils.CuentaServicio) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.cuentasServicio[s.ID]; ok {
+		return duplicado("cuentas_servicio", "id_cuenta_servicio", s.ID)
+	}
+	guardada := *s
+	guardada.Permisos = copia(s.Permisos)
+	r.cuentasServicio[s.ID] = guardada
+	return nil
+}
+
+func cuentaServicio(s utils.CuentaServicio) *utils.CuentaServicio {
+	s.Permisos = copia(s.Permisos)
+	if s.Permisos == nil {
+		s.Permisos = []string{}
+	}
+	return &s
+}
+
+func (r *seguridad) BuscarCuentaServicio(id string) (*utils.CuentaServicio, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.cuentasServicio[id]
+	if !ok {
+		return nil, utils.ErrCuentaServicioNoEncontrada
+	}
+	return cuentaServicio(s), nil
+}
+
+func (r *seguridad) CuentasServicio() ([]utils.CuentaServicio, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cuentas := []utils.CuentaServicio{}
+	for _, s := range r.cuentasServicio {
+		cuentas = append(cuentas, *cuentaServicio(s))
+	}
+	slices.SortFunc(cuentas, func(a, b utils.CuentaServicio) int { return strings.Compare(a.Nombre, b.Nombre) })
+	return cuentas, nil
+}
+
+func (r *seguridad) CambiarPermisosServicio(id string, permisos []string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.cuentasServicio[id]
+	if !ok {
+		return false, nil
+	}
+	s.Permisos = copia(permisos)
+	r.cuentasServicio[id] = s
+	return true, nil
+}
+
+func (r *seguridad) DesactivarServicio(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.cuentasServicio[id]
+	if !ok {
+		return false, nil
+	}
+	s.Activa = false
+	r.cuentasServicio[id] = s
+	for _, k := range r.apiKeys {
+		if k.IDCuenta == id && k.RevocadaEn == nil {
+			k.RevocadaEn = tiempo(time.Now())
+		}
+	}
+	return true, nil
+}
+
+func (r *seguridad) GuardarAPIKey(k *utils.APIKeyGuardada) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.apiKeys[k.ID]; ok {
+		return duplicado("api_keys", "id_api_key", k.ID)
+	}
+	if _, ok := r.cuentasServicio[k.IDCuenta]; !ok {
+		return sinReferencia("api_keys", "id_cuenta_servicio", k.IDCuenta)
+	}
+	for _, otra := range r.apiKeys {
+		if otra.Prefijo == k.Prefijo {
+			return duplicado("api_keys", "prefijo", k.Prefijo)
+		}
+	}
+	guardada := *k
+	r.apiKeys[k.ID] = &guardada
+	return nil
+}
+
+func (r *seguridad) APIKeys(idCuenta string) ([]utils.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	llaves := []utils.APIKey{}
+	for _, k := range r.apiKeys {
+		if k.IDCuenta == idCuenta {
+			llaves = append(llaves, k.APIKey)
+		}
+	}
+	slices.SortFunc(llaves, func(a, b utils.APIKey) int { return b.CreadoEn.Compare(a.CreadoEn) })
+	return llaves, nil
+}
+
+func (r *seguridad) RevocarLlave(idCuenta, idLlave string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.apiKeys[idLlave]
+	if !ok || k.IDCuenta != idCuenta || k.RevocadaEn != nil {
+		return false, nil
+	}
+	k.RevocadaEn = tiempo(time.Now())
+	return true, nil
+}
+
+func (r *seguridad) BuscarAPIKey(prefijo string) (*utils.APIKeyGuardada, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.apiKeys {
+		if k.Prefijo == prefijo {
+			return &utils.APIKeyGuardada{
+				APIKey:   utils.APIKey{ID: k.ID, Prefijo: prefijo, ExpiraEn: k.ExpiraEn, RevocadaEn: k.RevocadaEn},
+				IDCuenta: k.IDCuenta,
+				Hash:     k.Hash,
+			}, nil
+		}
+	}
+	return nil, utils.ErrAPIKeyInvalida
+}
+
+func (r *seguridad) RegistrarUsoLlave(idLlave string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ahora := time.Now()
+	if k, ok := r.apiKeys[idLlave]; ok && (k.UltimoUsoEn == nil || k.UltimoUsoEn.Before(ahora.Add(-time.Minute))) {
+		k.UltimoUsoEn = &ahora
+	}
+	return nil
+}
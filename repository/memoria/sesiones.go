@@ -0,0 +1,141 @@
+package memoria
+
+import (
+	"time"
+
+	"back-menchaca/utils"
+)
+
+func (d *datos) insertarRefreshToken(tokenHash string, s utils.RefreshSession, expira time.Time) error {
+	if _, ok := d.refreshTokens[tokenHash]; ok {
+		return duplicado("refresh_tokens", "token_hash", tokenHash)
+	}
+	d.refreshTokens[tokenHash] = &refreshToken{sesion: s, tipo: utils.TipoUsuario(s.Rol), expira: expira}
+	return nil
+}
+
+func (d *datos) revocarFamilia(familiaID string) {
+	for _, t := range d.refreshTokens {
+		if t.sesion.FamiliaID == familiaID {
+			t.revocado = true
+		}
+	}
+}
+
+func (r *seguridad) GuardarRefreshToken(tokenHash string, s utils.RefreshSession, expira time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.insertarRefreshToken(tokenHash, s, expira)
+}
+
+func (r *seguridad) RotarRefreshToken(tokenHash, clientID, nuevoHash string, expira time.Time) (*utils.RefreshSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.refreshTokens[tokenHash]
+	if !ok {
+		return nil, utils.ErrRefreshInvalido
+	}
+	s := t.sesion
+	if t.revocado || time.Now().After(t.expira) || s.ClientID != clientID {
+		return nil, utils.ErrRefreshInvalido
+	}
+
+	if t.usado {
+		r.revocarFamilia(s.FamiliaID)
+		return &s, utils.ErrRefreshReutilizado
+	}
+
+	if err := r.insertarRefreshToken(nuevoHash, s, expira); err != nil {
+		return nil, err
+	}
+	t.usado = true
+	return &s, nil
+}
+
+func (r *seguridad) RevocarFamilia(familiaID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revocarFamilia(familiaID)
+	return nil
+}
+
+func (r *seguridad) RevocarFamiliaDeToken(tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.refreshTokens[tokenHash]; ok {
+		r.revocarFamilia(t.sesion.FamiliaID)
+	}
+	return nil
+}
+
+func (r *seguridad) RevocarJTI(jti string, expira time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.jtiRevocados[jti]; !ok {
+		r.jtiRevocados[jti] = expira
+	}
+	return nil
+}
+
+func (r *seguridad) ConsumirJTI(jti string, expira time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.jtiRevocados[jti]; ok {
+		return false, nil
+	}
+	r.jtiRevocados[jti] = expira
+	return true, nil
+}
+
+func (r *seguridad) LiberarJTI(jti string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.jtiRevocados, jti)
+	return nil
+}
+
+func (r *seguridad) JTIRevocado(jti string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.jtiRevocados[jti]
+	return ok, nil
+}
+
+func (r *seguridad) RevocarSesionesUsuario(tipo, id string, desde time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revocaciones[claveUsuario{tipo, id}] = desde
+	for _, t := range r.refreshTokens {
+		if t.tipo == tipo && t.sesion.ID == id {
+			t.revocado = true
+		}
+	}
+	return nil
+}
+
+func (r *seguridad) SesionesRevocadasDesde(tipo, id string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.revocaciones[claveUsuario{tipo, id}], nil
+}
+
+func (r *seguridad) GuardarRenovacionRol(rol string, desde time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.renovaciones[rol] = desde
+	return nil
+}
+
+func (r *seguridad) RenovacionRol(rol string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.renovaciones[rol], nil
+}
+
+func (r *seguridad) SumarFalloMFA(jti string, expira time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.intentosMFA[jti]++
+	return r.intentosMFA[jti], nil
+}
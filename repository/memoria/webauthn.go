@@ -0,0 +1,135 @@
+package memoria
+
+import (
+	"encoding/base64"
+	"slices"
+	"time"
+
+	"back-menchaca/utils"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// credencialesDe devuelve las credenciales de la identidad en el orden en que
+// se registraron
+func (d *datos) credencialesDe(identidadID string) []*credencialWebAuthn {
+	var lista []*credencialWebAuthn
+	for _, c := range d.credencialesWA {
+		if c.identidadID == identidadID {
+			lista = append(lista, c)
+		}
+	}
+	slices.SortFunc(lista, func(a, b *credencialWebAuthn) int { return a.creado.Compare(b.creado) })
+	return lista
+}
+
+func (r *seguridad) CredencialesWebAuthn(identidadID string) ([]webauthn.Credential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var credenciales []webauthn.Credential
+	for _, c := range r.credencialesDe(identidadID) {
+		cred := c.cred
+		cred.ID = slices.Clone(cred.ID)
+		cred.PublicKey = slices.Clone(cred.PublicKey)
+		cred.Authenticator.AAGUID = slices.Clone(cred.Authenticator.AAGUID)
+		cred.Transport = slices.Clone(cred.Transport)
+		credenciales = append(credenciales, cred)
+	}
+	return credenciales, nil
+}
+
+func (r *seguridad) GuardarCredencialWebAuthn(identidadID, nombre string, cred *webauthn.Credential, creado time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.credencialesWA[string(cred.ID)]; ok {
+		return duplicado("webauthn_credenciales", "id_credencial", base64.RawURLEncoding.EncodeToString(cred.ID))
+	}
+	if _, ok := r.identidades[identidadID]; !ok {
+		return sinReferencia("webauthn_credenciales", "id_identidad", identidadID)
+	}
+
+	// Se guardan las mismas columnas que en webauthn_credenciales
+	guardada := webauthn.Credential{
+		ID:              slices.Clone(cred.ID),
+		PublicKey:       slices.Clone(cred.PublicKey),
+		AttestationType: cred.AttestationType,
+		Transport:       []protocol.AuthenticatorTransport{},
+	}
+	guardada.Authenticator.AAGUID = slices.Clone(cred.Authenticator.AAGUID)
+	guardada.Authenticator.SignCount = cred.Authenticator.SignCount
+	guardada.Flags.BackupEligible = cred.Flags.BackupEligible
+	guardada.Flags.BackupState = cred.Flags.BackupState
+	guardada.Transport = append(guardada.Transport, cred.Transport...)
+
+	r.credencialesWA[string(cred.ID)] = &credencialWebAuthn{
+		identidadID: identidadID,
+		nombre:      nombre,
+		cred:        guardada,
+		creado:      creado,
+	}
+	return nil
+}
+
+func (r *seguridad) ActualizarCredencialWebAuthn(identidadID string, cred *webauthn.Credential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.credencialesWA[string(cred.ID)]
+	if !ok || c.identidadID != identidadID {
+		return nil
+	}
+	c.cred.Authenticator.SignCount = cred.Authenticator.SignCount
+	c.cred.Flags.BackupState = cred.Flags.BackupState
+	c.ultimoUso = tiempo(time.Now())
+	return nil
+}
+
+func (r *seguridad) AutenticadoresWebAuthn(identidadID string) ([]utils.CredencialWebAuthn, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credenciales := []utils.CredencialWebAuthn{}
+	for _, c := range r.credencialesDe(identidadID) {
+		transportes := []string{}
+		for _, t := range c.cred.Transport {
+			transportes = append(transportes, string(t))
+		}
+		credenciales = append(credenciales, utils.CredencialWebAuthn{
+			ID:           base64.RawURLEncoding.EncodeToString(c.cred.ID),
+			Nombre:       c.nombre,
+			Transportes:  transportes,
+			Sincronizada: c.cred.Flags.BackupState,
+			CreadoEn:     c.creado,
+			UltimoUsoEn:  c.ultimoUso,
+		})
+	}
+	return credenciales, nil
+}
+
+func (r *seguridad) BorrarCredencialWebAuthn(identidadID string, id []byte) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.credencialesWA[string(id)]
+	if !ok || c.identidadID != identidadID {
+		return false, nil
+	}
+	delete(r.credencialesWA, string(id))
+	return true, nil
+}
+
+func (r *seguridad) GuardarSesionWebAuthn(clave string, datos []byte, expira time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sesionesWA[clave] = sesionWebAuthn{datos: slices.Clone(datos), expira: expira}
+	return nil
+}
+
+func (r *seguridad) ConsumirSesionWebAuthn(clave string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sesionesWA[clave]
+	if !ok || !s.expira.After(time.Now()) {
+		return nil, utils.ErrWebAuthnSinSesion
+	}
+	delete(r.sesionesWA, clave)
+	return s.datos, nil
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"back-menchaca/utils"
+
+	"github.com/lib/pq"
+)
+
+func (r *seguridadPG) ObtenerClienteOAuth(clientID string) (*utils.ClienteOAuth, error) {
+	var (
+		cl     utils.ClienteOAuth
+		hash   sql.NullString
+		uris   pq.StringArray
+		scopes pq.StringArray
+	)
+	err := r.db.QueryRow(`SELECT client_id, nombre, secreto_hash, redirect_uris, scopes, publico, creado_en
+		FROM oauth_clientes WHERE client_id = $1`, clientID).
+		Scan(&cl.ClientID, &cl.Nombre, &hash, &uris, &scopes, &cl.Publico, &cl.CreadoEn)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrClienteOAuthInvalido
+	} else if err != nil {
+		return nil, err
+	}
+	cl.SecretoHash = hash.String
+	cl.RedirectURIs = uris
+	cl.Scopes = scopes
+	return &cl, nil
+}
+
+func (r *seguridadPG) GuardarClienteOAuth(cl *utils.ClienteOAuth) error {
+	_, err := r.db.Exec(`INSERT INTO oauth_clientes (client_id, nombre, secreto_hash, redirect_uris, scopes, publico, creado_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		cl.ClientID, cl.Nombre, nuloSiVacio(cl.SecretoHash), pq.Array(cl.RedirectURIs), pq.Array(cl.Scopes), cl.Publico, cl.CreadoEn)
+	return err
+}
+
+func (r *seguridadPG) ClientesOAuth() ([]utils.ClienteOAuth, error) {
+	rows, err := r.db.Query(`SELECT client_id, nombre, redirect_uris, scopes, publico, creado_en
+		FROM oauth_clientes ORDER BY nombre`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clientes := []utils.ClienteOAuth{}
+	for rows.Next() {
+		var (
+			cl     utils.ClienteOAuth
+			uris   pq.StringArray
+			scopes pq.StringArray
+		)
+		if err := rows.Scan(&cl.ClientID, &cl.Nombre, &uris, &scopes, &cl.Publico, &cl.CreadoEn); err != nil {
+			return nil, err
+		}
+		cl.RedirectURIs = uris
+		cl.Scopes = scopes
+		clientes = append(clientes, cl)
+	}
+	return clientes, rows.Err()
+}
+
+func (r *seguridadPG) BorrarClienteOAuth(clientID string) (bool, error) {
+	if _, err := r.db.Exec(`DELETE FROM oauth_consentimientos WHERE client_id = $1`, clientID); err != nil {
+		return false, err
+	}
+	return afectoAlguna(r.db.Exec(`DELETE FROM oauth_clientes WHERE client_id = $1`, clientID))
+}
+
+func (r *seguridadPG) ConsentimientoOAuth(identidadID, clientID string) ([]string, error) {
+	var otorgados pq.StringArray
+	err := r.db.QueryRow(`SELECT scopes FROM oauth_consentimientos WHERE id_identidad = $1 AND client_id = $2`,
+		identidadID, clientID).Scan(&otorgados)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return otorgados, err
+}
+
+func (r *seguridadPG) GuardarConsentimientoOAuth(identidadID, clientID string, scopes []string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO oauth_consentimientos (id_identidad, client_id, scopes, otorgado_en) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (id_identidad, client_id) DO UPDATE SET scopes = EXCLUDED.scopes, otorgado_en = NOW()`,
+		identidadID, clientID, pq.Array(scopes))
+	return err
+}
+
+func (r *seguridadPG) GuardarCodigoAutorizacion(codigoHash string, ca utils.CodigoAutorizacion, expira time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO oauth_codigos
+		(codigo_hash, client_id, id_identidad, rol, redirect_uri, scope, nonce, code_challenge, auth_time, expira_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		codigoHash, ca.ClientID, ca.IdentidadID, ca.Rol, ca.RedirectURI, ca.Scope, ca.Nonce, ca.Challenge,
+		ca.AuthTime, expira)
+	return err
+}
+
+func (r *seguridadPG) ConsumirCodigoAutorizacion(codigoHash string) (*utils.CodigoAutorizacion, error) {
+	var ca utils.CodigoAutorizacion
+	err := r.db.QueryRow(`UPDATE oauth_codigos SET usado_en = NOW()
+		WHERE codigo_hash = $1 AND usado_en IS NULL AND expira_en > NOW()
+		RETURNING client_id, id_identidad, rol, redirect_uri, scope, nonce, code_challenge, auth_time`, codigoHash).
+		Scan(&ca.ClientID, &ca.IdentidadID, &ca.Rol, &ca.RedirectURI, &ca.Scope, &ca.Nonce, &ca.Challenge, &ca.AuthTime)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrCodigoInvalido
+	} else if err != nil {
+		return nil, err
+	}
+	return &ca, nil
+}
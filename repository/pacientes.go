@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Pacientes administra la tabla Paciente
+type Pacientes interface {
+	// Crear registra al paciente y le asocia la identidad en una sola
+	// transacción; devuelve el id de la identidad
+	Crear(p *models.Paciente, alta AltaIdentidad) (string, error)
+	Listar() ([]models.Paciente, error)
+	Obtener(id int) (models.Paciente, error)
+	Existe(id int) (bool, error)
+	// Actualizar modifica al paciente y, en la misma transacción, el correo de
+	// su identidad; devuelve el id de la identidad si su correo cambió
+	Actualizar(p models.Paciente) (string, error)
+	Eliminar(id int) error
+}
+
+type pacientesPG struct {
+	db *sql.DB
+}
+
+func (r *pacientesPG) Crear(p *models.Paciente, alta AltaIdentidad) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Las credenciales viven en la identidad
+	err = tx.QueryRow(`INSERT INTO Paciente (nombre, appaterno, apmaterno, correo)
+		VALUES ($1, $2, $3, $4) RETURNING id_paciente`,
+		p.Nombre, p.Appaterno, p.Apmaterno, p.Correo,
+	).Scan(&p.ID)
+	if err != nil {
+		return "", err
+	}
+
+	identidadID, err := registrarIdentidad(tx, alta, "paciente", p.ID)
+	if err != nil {
+		return "", err
+	}
+	return identidadID, tx.Commit()
+}
+
+func (r *pacientesPG) Listar() ([]models.Paciente, error) {
+	rows, err := r.db.Query("SELECT id_paciente, nombre, appaterno, apmaterno, correo FROM Paciente")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pacientes []models.Paciente
+	for rows.Next() {
+		var p models.Paciente
+		if err := rows.Scan(&p.ID, &p.Nombre, &p.Appaterno, &p.Apmaterno, &p.Correo); err != nil {
+			continue
+		}
+		pacientes = append(pacientes, p)
+	}
+	return pacientes, nil
+}
+
+func (r *pacientesPG) Obtener(id int) (models.Paciente, error) {
+	var p models.Paciente
+	err := r.db.QueryRow("SELECT id_paciente, nombre, appaterno, apmaterno, correo FROM Paciente WHERE id_paciente = $1", id).
+		Scan(&p.ID, &p.Nombre, &p.Appaterno, &p.Apmaterno, &p.Correo)
+	return p, noEncontrado(err)
+}
+
+func (r *pacientesPG) Existe(id int) (bool, error) {
+	return existe(r.db, "SELECT EXISTS(SELECT 1 FROM Paciente WHERE id_paciente = $1)", id)
+}
+
+func (r *pacientesPG) Actualizar(p models.Paciente) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE Paciente SET nombre=$1, appaterno=$2, apmaterno=$3, correo=$4 WHERE id_paciente=$5`,
+		p.Nombre, p.Appaterno, p.Apmaterno, p.Correo, p.ID)
+	if err != nil {
+		return "", err
+	}
+
+	identidadID, err := cambiarCorreoAcceso(tx, "paciente", p.ID, p.Correo)
+	if err != nil {
+		return "", err
+	}
+	return identidadID, tx.Commit()
+}
+
+func (r *pacientesPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Paciente WHERE id_paciente = $1", id)
+	return err
+}
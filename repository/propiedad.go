@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+func (r *seguridadPG) EmpleadoAtiendePaciente(idEmpleado, idPaciente int) (bool, error) {
+	var atiende bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM Consultas c
+			JOIN Horarios h ON c.id_horario = h.id_horario
+			JOIN Empleado e ON h.id_empleado = e.id_empleado
+			JOIN Empleado yo ON yo.id_empleado = $1
+			WHERE c.id_paciente = $2 AND (e.id_empleado = yo.id_empleado OR e.area = yo.area))`,
+		idEmpleado, idPaciente).Scan(&atiende)
+	return atiende, err
+}
+
+func (r *seguridadPG) PacienteDeConsulta(idConsulta int) (int, error) {
+	var idPaciente int
+	err := r.db.QueryRow(`SELECT id_paciente FROM Consultas WHERE id_consulta = $1`, idConsulta).Scan(&idPaciente)
+	return idPaciente, err
+}
+
+func (r *seguridadPG) PacienteDeReceta(idReceta int) (int, error) {
+	var idPaciente sql.NullInt64
+	err := r.db.QueryRow(`SELECT id_paciente FROM Consultas WHERE id_receta = $1 LIMIT 1`, idReceta).Scan(&idPaciente)
+	if err == nil && !idPaciente.Valid {
+		err = sql.ErrNoRows
+	}
+	return int(idPaciente.Int64), err
+}
+
+func (r *seguridadPG) PacienteDeExpediente(idExpediente int) (int, error) {
+	var idPaciente int
+	err := r.db.QueryRow(`SELECT id_paciente FROM Expediente WHERE id_expediente = $1`, idExpediente).Scan(&idPaciente)
+	return idPaciente, err
+}
+
+func (r *seguridadPG) PacienteDeAntecedente(idAntecedente int) (int, error) {
+	var idPaciente int
+	err := r.db.QueryRow(`SELECT e.id_paciente FROM Antecedentes a
+		JOIN Expediente e ON e.id_expediente = a.id_expediente WHERE a.id_antecedente = $1`, idAntecedente).Scan(&idPaciente)
+	return idPaciente, err
+}
+
+func (r *seguridadPG) PacienteDeHistorial(idHistorial int) (int, error) {
+	var idPaciente int
+	err := r.db.QueryRow(`SELECT e.id_paciente FROM Historial_Clinico h
+		JOIN Expediente e ON e.id_expediente = h.id_expediente WHERE h.id_historial = $1`, idHistorial).Scan(&idPaciente)
+	return idPaciente, err
+}
+
+func (r *seguridadPG) ExisteRegistro(tabla, columna string, id int) (bool, error) {
+	return existe(r.db, "SELECT EXISTS(SELECT 1 FROM "+tabla+" WHERE "+columna+" = $1)", id)
+}
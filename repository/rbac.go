@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+
+	"back-menchaca/utils"
+)
+
+func (r *seguridadPG) AsignacionesPermisos() ([]utils.AsignacionPermiso, error) {
+	rows, err := r.db.Query(`SELECT rol, permiso, COALESCE(metodo, ''), COALESCE(ruta, ''), COALESCE(permitido, true)
+		FROM permisos`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var asignaciones []utils.AsignacionPermiso
+	for rows.Next() {
+		var a utils.AsignacionPermiso
+		if err := rows.Scan(&a.Rol, &a.Permiso, &a.Metodo, &a.Ruta, &a.Permitido); err != nil {
+			return nil, err
+		}
+		asignaciones = append(asignaciones, a)
+	}
+	return asignaciones, rows.Err()
+}
+
+func (r *seguridadPG) RolesRegistrados() ([]utils.Rol, error) {
+	rows, err := r.db.Query(`
+		SELECT r.rol, COALESCE(ro.descripcion, ''), ro.creado_en,
+			CASE WHEN r.rol = 'paciente' THEN (SELECT COUNT(*) FROM identidad_roles WHERE tipo_usuario = 'paciente')
+				ELSE (SELECT COUNT(*) FROM Empleado e WHERE e.tipo_empleado = r.rol) END
+		FROM (SELECT rol FROM roles UNION SELECT rol FROM permisos) r
+		LEFT JOIN roles ro ON ro.rol = r.rol
+		ORDER BY r.rol`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []utils.Rol{}
+	for rows.Next() {
+		var rol utils.Rol
+		var creado sql.NullTime
+		if err := rows.Scan(&rol.Rol, &rol.Descripcion, &creado, &rol.Usuarios); err != nil {
+			return nil, err
+		}
+		rol.CreadoEn = tiempoONil(creado)
+		roles = append(roles, rol)
+	}
+	return roles, rows.Err()
+}
+
+func (r *seguridadPG) RolRegistrado(rol string) (bool, error) {
+	var existe bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM roles WHERE rol = $1)`, rol).Scan(&existe)
+	return existe, err
+}
+
+func (r *seguridadPG) GuardarRol(rol, descripcion string) error {
+	_, err := r.db.Exec(`INSERT INTO roles (rol, descripcion, creado_en) VALUES ($1, $2, NOW())`, rol, descripcion)
+	return err
+}
+
+func (r *seguridadPG) RolAsignado(rol string) (bool, error) {
+	var enUso bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM Empleado WHERE tipo_empleado = $1)`, rol).Scan(&enUso)
+	return enUso, err
+}
+
+func (r *seguridadPG) BorrarRol(rol string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM permisos WHERE rol = $1`, rol); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM roles WHERE rol = $1`, rol); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *seguridadPG) PermisosRegistrados() ([]utils.PermisoCatalogo, error) {
+	rows, err := r.db.Query(`
+		SELECT p.permiso, COALESCE(c.descripcion, '')
+		FROM (SELECT permiso FROM catalogo_permisos UNION SELECT permiso FROM permisos) p
+		LEFT JOIN catalogo_permisos c ON c.permiso = p.permiso
+		ORDER BY p.permiso`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permisos := []utils.PermisoCatalogo{}
+	for rows.Next() {
+		var p utils.PermisoCatalogo
+		if err := rows.Scan(&p.Permiso, &p.Descripcion); err != nil {
+			return nil, err
+		}
+		permisos = append(permisos, p)
+	}
+	return permisos, rows.Err()
+}
+
+func (r *seguridadPG) PermisoRegistrado(permiso string) (bool, error) {
+	var existe bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM catalogo_permisos WHERE permiso = $1)
+		OR EXISTS(SELECT 1 FROM permisos WHERE permiso = $1)`, permiso).Scan(&existe)
+	return existe, err
+}
+
+func (r *seguridadPG) GuardarPermiso(permiso, descripcion string) error {
+	_, err := r.db.Exec(`INSERT INTO catalogo_permisos (permiso, descripcion, creado_en) VALUES ($1, $2, NOW())`,
+		permiso, descripcion)
+	return err
+}
+
+func (r *seguridadPG) BorrarPermiso(permiso string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM permisos WHERE permiso = $1`, permiso); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM catalogo_permisos WHERE permiso = $1`, permiso); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE cuentas_servicio SET permisos = array_remove(permisos, $1) WHERE $1 = ANY(permisos)`,
+		permiso); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *seguridadPG) ReemplazarPermisoRol(rol, permiso string, reglas []utils.ReglaPermiso) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM permisos WHERE rol = $1 AND permiso = $2`, rol, permiso); err != nil {
+		return err
+	}
+	for _, regla := range reglas {
+		_, err := tx.Exec(`INSERT INTO permisos (rol, permiso, metodo, ruta, permitido) VALUES ($1, $2, $3, $4, $5)`,
+			rol, permiso, nuloSiVacio(strings.ToUpper(regla.Metodo)), nuloSiVacio(regla.Ruta), regla.Permitido)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *seguridadPG) BorrarPermisoRol(rol, permiso string) error {
+	_, err := r.db.Exec(`DELETE FROM permisos WHERE rol = $1 AND permiso = $2`, rol, permiso)
+	return err
+}
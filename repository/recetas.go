@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Recetas administra la tabla Recetas
+type Recetas interface {
+	Crear(r *models.Receta) error
+	// CrearEnConsulta crea la receta y la asigna a la consulta en una sola
+	// transacción; devuelve ErrNoEncontrado si la consulta no existe
+	CrearEnConsulta(r *models.Receta, idConsulta int) error
+	Listar() ([]models.Receta, error)
+	Obtener(id int) (models.Receta, error)
+	// ObtenerDetalle devuelve la receta con el nombre de su consultorio
+	ObtenerDetalle(id int) (models.Receta, string, error)
+	Actualizar(r models.Receta) error
+	Eliminar(id int) error
+}
+
+type recetasPG struct {
+	db *sql.DB
+}
+
+func (r *recetasPG) Crear(rec *models.Receta) error {
+	return r.db.QueryRow(`INSERT INTO Recetas (fecha, medicamento, dosis, id_consultorio)
+		VALUES ($1, $2, $3, $4) RETURNING id_receta`,
+		rec.Fecha, rec.Medicamento, rec.Dosis, rec.IDConsultorio).Scan(&rec.ID)
+}
+
+func (r *recetasPG) CrearEnConsulta(rec *models.Receta, idConsulta int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO Recetas (fecha, medicamento, dosis, id_consultorio)
+		VALUES ($1, $2, $3, $4) RETURNING id_receta`,
+		rec.Fecha, rec.Medicamento, rec.Dosis, rec.IDConsultorio).Scan(&rec.ID)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`UPDATE Consultas SET id_receta=$1 WHERE id_consulta=$2`, rec.ID, idConsulta)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrNoEncontrado
+	}
+	return tx.Commit()
+}
+
+func (r *recetasPG) Listar() ([]models.Receta, error) {
+	rows, err := r.db.Query("SELECT id_receta, fecha, medicamento, dosis, id_consultorio FROM Recetas")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recetas []models.Receta
+	for rows.Next() {
+		var rec models.Receta
+		if err := rows.Scan(&rec.ID, &rec.Fecha, &rec.Medicamento, &rec.Dosis, &rec.IDConsultorio); err == nil {
+			recetas = append(recetas, rec)
+		}
+	}
+	return recetas, nil
+}
+
+func (r *recetasPG) Obtener(id int) (models.Receta, error) {
+	var rec models.Receta
+	err := r.db.QueryRow("SELECT id_receta, fecha, medicamento, dosis, id_consultorio FROM Recetas WHERE id_receta=$1", id).
+		Scan(&rec.ID, &rec.Fecha, &rec.Medicamento, &rec.Dosis, &rec.IDConsultorio)
+	return rec, noEncontrado(err)
+}
+
+func (r *recetasPG) ObtenerDetalle(id int) (models.Receta, string, error) {
+	var (
+		rec         models.Receta
+		consultorio string
+	)
+	err := r.db.QueryRow(
+		`SELECT r.id_receta, r.fecha, r.medicamento, r.dosis, r.id_consultorio, c.nombre
+		FROM Recetas r
+		INNER JOIN Consultorios c ON r.id_consultorio = c.id_consultorio
+		WHERE r.id_receta = $1`, id,
+	).Scan(&rec.ID, &rec.Fecha, &rec.Medicamento, &rec.Dosis, &rec.IDConsultorio, &consultorio)
+	return rec, consultorio, noEncontrado(err)
+}
+
+func (r *recetasPG) Actualizar(rec models.Receta) error {
+	_, err := r.db.Exec(`UPDATE Recetas SET fecha=$1, medicamento=$2, dosis=$3, id_consultorio=$4 WHERE id_receta=$5`,
+		rec.Fecha, rec.Medicamento, rec.Dosis, rec.IDConsultorio, rec.ID)
+	return err
+}
+
+func (r *recetasPG) Eliminar(id int) error {
+	_, err := r.db.Exec("DELETE FROM Recetas WHERE id_receta=$1", id)
+	return err
+}
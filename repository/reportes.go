@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/models"
+)
+
+// Reportes son las consultas agregadas de /reportes. Las filas con datos
+// incompletos (p. ej. sin diagnóstico) se omiten.
+type Reportes interface {
+	ConsultasPorPaciente(idPaciente int) ([]models.DetalleConsultaPaciente, error)
+	DetallesConsultaExpediente(idExpediente int) ([]models.DetalleConsultaExpediente, error)
+	ConsultasPorArea() ([]models.ConsultasPorArea, error)
+	ConsultasPorTurno() ([]models.ConsultasPorTurno, error)
+	IngresosPorConsultorio() ([]models.IngresosPorConsultorio, error)
+	DetalleSimpleConsultas() ([]models.DetalleSimpleConsulta, error)
+}
+
+type reportesPG struct {
+	db *sql.DB
+}
+
+func (r *reportesPG) ConsultasPorPaciente(idPaciente int) ([]models.DetalleConsultaPaciente, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			c.id_consulta,
+			p.nombre AS paciente,
+			em.nombre AS empleado,
+			h.turno,
+			co.nombre AS consultorio,
+			c.tipo,
+			c.diagnostico,
+			c.costo,
+			c.fecha_hora
+		FROM Consultas c
+		JOIN Paciente p ON c.id_paciente = p.id_paciente
+		JOIN Horarios h ON c.id_horario = h.id_horario
+		JOIN Empleado em ON h.id_empleado = em.id_empleado
+		JOIN Consultorios co ON c.id_consultorio = co.id_consultorio
+		WHERE p.id_paciente = $1
+		ORDER BY c.fecha_hora DESC
+	`, idPaciente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resultados []models.DetalleConsultaPaciente
+	for rows.Next() {
+		var d models.DetalleConsultaPaciente
+		if err := rows.Scan(&d.ID, &d.Paciente, &d.Empleado, &d.Turno, &d.Consultorio, &d.Tipo, &d.Diagnostico, &d.Costo, &d.FechaHora); err == nil {
+			resultados = append(resultados, d)
+		}
+	}
+	return resultados, nil
+}
+
+func (r *reportesPG) DetallesConsultaExpediente(idExpediente int) ([]models.DetalleConsultaExpediente, error) {
+	rows, err := r.db.Query(`
+		SELECT a.diagnostico, a.descripcion, a.fecha, c.tipo, c.fecha_hora, c.diagnostico
+		FROM Historial_Clinico h
+		JOIN Consultas c ON h.id_consultas = c.id_consulta
+		JOIN Antecedentes a ON a.id_expediente = h.id_expediente
+		WHERE h.id_expediente = $1
+	`, idExpediente)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var resultados []models.DetalleConsultaExpediente
+	for rows.Next() {
+		var d models.DetalleConsultaExpediente
+		if err := rows.Scan(&d.DiagnosticoAntecedente, &d.Descripcion, &d.FechaAntecedente,
+			&d.TipoConsulta, &d.FechaConsulta, &d.DiagnosticoConsulta); err == nil {
+			resultados = append(resultados, d)
+		}
+	}
+	return resultados, nil
+}
+
+func (r *reportesPG) ConsultasPorArea() ([]models.ConsultasPorArea, error) {
+	rows, err := r.db.Query(`SELECT e.area, COUNT(*) FROM Consultas c JOIN Horarios h ON c.id_horario = h.id_horario JOIN Empleado e ON h.id_empleado = e.id_empleado GROUP BY e.area`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []models.ConsultasPorArea
+	for rows.Next() {
+		var d models.ConsultasPorArea
+		if err := rows.Scan(&d.Area, &d.Total); err == nil {
+			data = append(data, d)
+		}
+	}
+	return data, nil
+}
+
+func (r *reportesPG) ConsultasPorTurno() ([]models.ConsultasPorTurno, error) {
+	rows, err := r.db.Query(`SELECT h.turno, COUNT(*) FROM Consultas c JOIN Horarios h ON c.id_horario = h.id_horario GROUP BY h.turno`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []models.ConsultasPorTurno
+	for rows.Next() {
+		var d models.ConsultasPorTurno
+		if err := rows.Scan(&d.Turno, &d.Total); err == nil {
+			data = append(data, d)
+		}
+	}
+	return data, nil
+}
+
+func (r *reportesPG) IngresosPorConsultorio() ([]models.IngresosPorConsultorio, error) {
+	rows, err := r.db.Query(`SELECT cons.nombre, SUM(c.costo) FROM Consultas c JOIN Consultorios cons ON c.id_consultorio = cons.id_consultorio GROUP BY cons.nombre`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var data []models.IngresosPorConsultorio
+	for rows.Next() {
+		var d models.IngresosPorConsultorio
+		if err := rows.Scan(&d.Consultorio, &d.Total); err == nil {
+			data = append(data, d)
+		}
+	}
+	return data, nil
+}
+
+func (r *reportesPG) DetalleSimpleConsultas() ([]models.DetalleSimpleConsulta, error) {
+	rows, err := r.db.Query(`
+		SELECT
+			c.id_consulta,
+			p.nombre AS paciente,
+			em.nombre AS empleado,
+			c.tipo,
+			c.diagnostico,
+			c.costo,
+			c.fecha_hora
+		FROM Consultas c
+		LEFT JOIN Paciente p ON c.id_paciente = p.id_paciente
+		LEFT JOIN Horarios h ON c.id_horario = h.id_horario
+		LEFT JOIN Empleado em ON h.id_empleado = em.id_empleado
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var detalles []models.DetalleSimpleConsulta
+	for rows.Next() {
+		var d models.DetalleSimpleConsulta
+		if err := rows.Scan(&d.ID, &d.Paciente, &d.Empleado, &d.Tipo, &d.Diagnostico, &d.Costo, &d.FechaHora); err == nil {
+			detalles = append(detalles, d)
+		}
+	}
+	return detalles, nil
+}
@@ -0,0 +1,76 @@
+// Package repository define el acceso a datos de cada agregado clínico como
+// una interfaz, para que los handlers no dependan de SQL ni de config.DB. La
+// implementación para Postgres se construye con NuevoPostgres; la de memoria
+// (serve --store=memory) está en repository/memoria.
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"back-menchaca/utils"
+)
+
+// ErrNoEncontrado indica que el registro buscado no existe
+var ErrNoEncontrado = errors.New("registro no encontrado")
+
+// Repositorios agrupa los repositorios que se inyectan en los handlers
+type Repositorios struct {
+	Pacientes       Pacientes
+	Empleados       Empleados
+	Consultorios    Consultorios
+	Horarios        Horarios
+	Consultas       Consultas
+	Recetas         Recetas
+	Expedientes     Expedientes
+	Antecedentes    Antecedentes
+	Historial       Historial
+	Consentimientos Consentimientos
+	Reportes        Reportes
+	Logs            Logs
+	// Seguridad es el almacenamiento de los módulos de seguridad de utils; se
+	// instala con utils.UsarAlmacen
+	Seguridad utils.Almacen
+}
+
+// NuevoPostgres crea los repositorios sobre el pool de conexiones
+func NuevoPostgres(db *sql.DB) *Repositorios {
+	return &Repositorios{
+		Pacientes:       &pacientesPG{db: db},
+		Empleados:       &empleadosPG{db: db},
+		Consultorios:    &consultoriosPG{db: db},
+		Horarios:        &horariosPG{db: db},
+		Consultas:       &consultasPG{db: db},
+		Recetas:         &recetasPG{db: db},
+		Expedientes:     &expedientesPG{db: db},
+		Antecedentes:    &antecedentesPG{db: db},
+		Historial:       &historialPG{db: db},
+		Consentimientos: &consentimientosPG{db: db},
+		Reportes:        &reportesPG{db: db},
+		Logs:            &logsPG{db: db},
+		Seguridad:       &seguridadPG{db: db},
+	}
+}
+
+// AltaIdentidad describe la identidad con la que se registra un paciente o
+// empleado: una nueva si IdentidadID está vacío, o una existente a la que se
+// agrega el rol. Verificado marca el correo como verificado en ambos casos.
+type AltaIdentidad struct {
+	IdentidadID string
+	Correo      string
+	Hash        string
+	Verificado  bool
+}
+
+func noEncontrado(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNoEncontrado
+	}
+	return err
+}
+
+func existe(db *sql.DB, query string, id int) (bool, error) {
+	var ok bool
+	err := db.QueryRow(query, id).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// seguridadPG implementa utils.Almacen, el almacenamiento de los módulos de
+// seguridad (identidades, sesiones, bloqueos, OAuth, cuentas de servicio,
+// WebAuthn, accesos de emergencia, roles y permisos). Sus métodos están
+// repartidos por módulo en los archivos de este paquete.
+type seguridadPG struct {
+	db *sql.DB
+}
+
+// afectoUna indica si la sentencia modificó exactamente una fila
+func afectoUna(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// afectoAlguna indica si la sentencia modificó alguna fila
+func afectoAlguna(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func tiempoONil(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nuloSiVacio(s string) interface{} {
+	if s = strings.TrimSpace(s); s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"database/sql"
+
+	"back-menchaca/utils"
+
+	"github.com/lib/pq"
+)
+
+func (r *seguridadPG) ContarPermisosConocidos(permisos []string) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(DISTINCT permiso)
+		FROM (SELECT permiso FROM permisos UNION SELECT permiso FROM catalogo_permisos) p
+		WHERE permiso = ANY($1)`,
+		pq.Array(permisos)).Scan(&n)
+	return n, err
+}
+
+func (r *seguridadPG) GuardarCuentaServicio(s *utils.CuentaServicio) error {
+	_, err := r.db.Exec(`INSERT INTO cuentas_servicio (id_cuenta_servicio, nombre, descripcion, permisos, activa, creado_en)
+		VALUES ($1, $2, $3, $4, $5, $6)`, s.ID, s.Nombre, s.Descripcion, pq.Array(s.Permisos), s.Activa, s.CreadoEn)
+	return err
+}
+
+func (r *seguridadPG) BuscarCuentaServicio(id string) (*utils.CuentaServicio, error) {
+	return escanearCuentaServicio(r.db.QueryRow(`SELECT id_cuenta_servicio, nombre, descripcion, permisos, activa, creado_en
+		FROM cuentas_servicio WHERE id_cuenta_servicio = $1`, id))
+}
+
+func escanearCuentaServicio(fila escaner) (*utils.CuentaServicio, error) {
+	var (
+		s        utils.CuentaServicio
+		desc     sql.NullString
+		permisos pq.StringArray
+	)
+	err := fila.Scan(&s.ID, &s.Nombre, &desc, &permisos, &s.Activa, &s.CreadoEn)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrCuentaServicioNoEncontrada
+	} else if err != nil {
+		return nil, err
+	}
+	s.Descripcion = desc.String
+	s.Permisos = permisos
+	if s.Permisos == nil {
+		s.Permisos = []string{}
+	}
+	return &s, nil
+}
+
+func (r *seguridadPG) CuentasServicio() ([]utils.CuentaServicio, error) {
+	rows, err := r.db.Query(`SELECT id_cuenta_servicio, nombre, descripcion, permisos, activa, creado_en
+		FROM cuentas_servicio ORDER BY nombre`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cuentas := []utils.CuentaServicio{}
+	for rows.Next() {
+		s, err := escanearCuentaServicio(rows)
+		if err != nil {
+			return nil, err
+		}
+		cuentas = append(cuentas, *s)
+	}
+	return cuentas, rows.Err()
+}
+
+func (r *seguridadPG) CambiarPermisosServicio(id string, permisos []string) (bool, error) {
+	return afectoAlguna(r.db.Exec(`UPDATE cuentas_servicio SET permisos = $1 WHERE id_cuenta_servicio = $2`,
+		pq.Array(permisos), id))
+}
+
+func (r *seguridadPG) DesactivarServicio(id string) (bool, error) {
+	ok, err := afectoAlguna(r.db.Exec(`UPDATE cuentas_servicio SET activa = false WHERE id_cuenta_servicio = $1`, id))
+	if err != nil || !ok {
+		return ok, err
+	}
+	_, err = r.db.Exec(`UPDATE api_keys SET revocada_en = NOW()
+		WHERE id_cuenta_servicio = $1 AND revocada_en IS NULL`, id)
+	return true, err
+}
+
+func (r *seguridadPG) GuardarAPIKey(k *utils.APIKeyGuardada) error {
+	_, err := r.db.Exec(`INSERT INTO api_keys (id_api_key, id_cuenta_servicio, nombre, prefijo, hash, expira_en, creado_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, k.ID, k.IDCuenta, k.Nombre, k.Prefijo, k.Hash, k.ExpiraEn, k.CreadoEn)
+	return err
+}
+
+func (r *seguridadPG) APIKeys(idCuenta string) ([]utils.APIKey, error) {
+	rows, err := r.db.Query(`SELECT id_api_key, nombre, prefijo, expira_en, ultimo_uso_en, revocada_en, creado_en
+		FROM api_keys WHERE id_cuenta_servicio = $1 ORDER BY creado_en DESC`, idCuenta)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	llaves := []utils.APIKey{}
+	for rows.Next() {
+		var (
+			k                     utils.APIKey
+			expira, uso, revocada sql.NullTime
+		)
+		if err := rows.Scan(&k.ID, &k.Nombre, &k.Prefijo, &expira, &uso, &revocada, &k.CreadoEn); err != nil {
+			return nil, err
+		}
+		k.ExpiraEn = tiempoONil(expira)
+		k.UltimoUsoEn = tiempoONil(uso)
+		k.RevocadaEn = tiempoONil(revocada)
+		llaves = append(llaves, k)
+	}
+	return llaves, rows.Err()
+}
+
+func (r *seguridadPG) RevocarLlave(idCuenta, idLlave string) (bool, error) {
+	return afectoAlguna(r.db.Exec(`UPDATE api_keys SET revocada_en = NOW()
+		WHERE id_api_key = $1 AND id_cuenta_servicio = $2 AND revocada_en IS NULL`, idLlave, idCuenta))
+}
+
+func (r *seguridadPG) BuscarAPIKey(prefijo string) (*utils.APIKeyGuardada, error) {
+	var (
+		k                utils.APIKeyGuardada
+		expira, revocada sql.NullTime
+	)
+	err := r.db.QueryRow(`SELECT id_api_key, id_cuenta_servicio, hash, expira_en, revocada_en
+		FROM api_keys WHERE prefijo = $1`, prefijo).Scan(&k.ID, &k.IDCuenta, &k.Hash, &expira, &revocada)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrAPIKeyInvalida
+	} else if err != nil {
+		return nil, err
+	}
+	k.Prefijo = prefijo
+	k.ExpiraEn = tiempoONil(expira)
+	k.RevocadaEn = tiempoONil(revocada)
+	return &k, nil
+}
+
+func (r *seguridadPG) RegistrarUsoLlave(idLlave string) error {
+	_, err := r.db.Exec(`UPDATE api_keys SET ultimo_uso_en = NOW()
+		WHERE id_api_key = $1 AND (ultimo_uso_en IS NULL OR ultimo_uso_en < NOW() - INTERVAL '1 minute')`, idLlave)
+	return err
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"back-menchaca/utils"
+)
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertarRefreshToken(db execer, tokenHash string, s utils.RefreshSession, expira time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO refresh_tokens (token_hash, familia, id_identidad, id_usuario, tipo_usuario, correo, rol, client_id, expira_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9)`,
+		tokenHash, s.FamiliaID, s.IdentidadID, s.ID, utils.TipoUsuario(s.Rol), s.Email, s.Rol, s.ClientID, expira)
+	return err
+}
+
+func (r *seguridadPG) GuardarRefreshToken(tokenHash string, s utils.RefreshSession, expira time.Time) error {
+	return insertarRefreshToken(r.db, tokenHash, s, expira)
+}
+
+func (r *seguridadPG) RotarRefreshToken(tokenHash, clientID, nuevoHash string, expira time.Time) (*utils.RefreshSession, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var (
+		s        utils.RefreshSession
+		vence    time.Time
+		usado    sql.NullTime
+		revocado sql.NullTime
+	)
+	err = tx.QueryRow(`
+		SELECT id_identidad, id_usuario, correo, rol, familia, COALESCE(client_id, ''), expira_en, usado_en, revocado_en
+		FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, tokenHash).
+		Scan(&s.IdentidadID, &s.ID, &s.Email, &s.Rol, &s.FamiliaID, &s.ClientID, &vence, &usado, &revocado)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrRefreshInvalido
+	} else if err != nil {
+		return nil, err
+	}
+
+	if revocado.Valid || time.Now().After(vence) || s.ClientID != clientID {
+		return nil, utils.ErrRefreshInvalido
+	}
+
+	if usado.Valid {
+		if _, err := tx.Exec(`UPDATE refresh_tokens SET revocado_en = NOW()
+			WHERE familia = $1 AND revocado_en IS NULL`, s.FamiliaID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		return &s, utils.ErrRefreshReutilizado
+	}
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET usado_en = NOW() WHERE token_hash = $1`, tokenHash); err != nil {
+		return nil, err
+	}
+	if err := insertarRefreshToken(tx, nuevoHash, s, expira); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *seguridadPG) RevocarFamilia(familiaID string) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revocado_en = NOW()
+		WHERE familia = $1 AND revocado_en IS NULL`, familiaID)
+	return err
+}
+
+func (r *seguridadPG) RevocarFamiliaDeToken(tokenHash string) error {
+	_, err := r.db.Exec(`UPDATE refresh_tokens SET revocado_en = NOW()
+		WHERE revocado_en IS NULL AND familia = (SELECT familia FROM refresh_tokens WHERE token_hash = $1)`,
+		tokenHash)
+	return err
+}
+
+func (r *seguridadPG) RevocarJTI(jti string, expira time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO tokens_revocados (jti, expira_en) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`, jti, expira)
+	return err
+}
+
+func (r *seguridadPG) ConsumirJTI(jti string, expira time.Time) (bool, error) {
+	return afectoUna(r.db.Exec(`
+		INSERT INTO tokens_revocados (jti, expira_en) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING`, jti, expira))
+}
+
+func (r *seguridadPG) LiberarJTI(jti string) error {
+	_, err := r.db.Exec(`DELETE FROM tokens_revocados WHERE jti = $1`, jti)
+	return err
+}
+
+func (r *seguridadPG) JTIRevocado(jti string) (bool, error) {
+	var existe bool
+	err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM tokens_revocados WHERE jti = $1)`, jti).Scan(&existe)
+	return existe, err
+}
+
+func (r *seguridadPG) RevocarSesionesUsuario(tipo, id string, desde time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO revocaciones_usuario (tipo_usuario, id_usuario, revocado_desde) VALUES ($1, $2, $3)
+		ON CONFLICT (tipo_usuario, id_usuario) DO UPDATE SET revocado_desde = EXCLUDED.revocado_desde`,
+		tipo, id, desde)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_tokens SET revocado_en = NOW()
+		WHERE tipo_usuario = $1 AND id_usuario = $2 AND revocado_en IS NULL`, tipo, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *seguridadPG) SesionesRevocadasDesde(tipo, id string) (time.Time, error) {
+	var desde sql.NullTime
+	err := r.db.QueryRow(`SELECT revocado_desde FROM revocaciones_usuario
+		WHERE tipo_usuario = $1 AND id_usuario = $2`, tipo, id).Scan(&desde)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return desde.Time, err
+}
+
+func (r *seguridadPG) GuardarRenovacionRol(rol string, desde time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO renovaciones_rol (rol, renovar_desde) VALUES ($1, $2)
+		ON CONFLICT (rol) DO UPDATE SET renovar_desde = EXCLUDED.renovar_desde`, rol, desde)
+	return err
+}
+
+func (r *seguridadPG) RenovacionRol(rol string) (time.Time, error) {
+	var desde sql.NullTime
+	err := r.db.QueryRow(`SELECT renovar_desde FROM renovaciones_rol WHERE rol = $1`, rol).Scan(&desde)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return desde.Time, err
+}
+
+func (r *seguridadPG) SumarFalloMFA(jti string, expira time.Time) (int, error) {
+	var fallidos int
+	err := r.db.QueryRow(`
+		INSERT INTO mfa_intentos (jti, fallidos, expira_en) VALUES ($1, 1, $2)
+		ON CONFLICT (jti) DO UPDATE SET fallidos = mfa_intentos.fallidos + 1
+		RETURNING fallidos`, jti, expira).Scan(&fallidos)
+	return fallidos, err
+}
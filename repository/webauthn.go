@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"time"
+
+	"back-menchaca/utils"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/lib/pq"
+)
+
+func (r *seguridadPG) CredencialesWebAuthn(identidadID string) ([]webauthn.Credential, error) {
+	rows, err := r.db.Query(`SELECT id_credencial, llave_publica, attestation_type, aaguid, sign_count,
+		transportes, backup_eligible, backup_state
+		FROM webauthn_credenciales WHERE id_identidad = $1`, identidadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var credenciales []webauthn.Credential
+	for rows.Next() {
+		var (
+			cred        webauthn.Credential
+			transportes pq.StringArray
+			contador    int64
+		)
+		if err := rows.Scan(&cred.ID, &cred.PublicKey, &cred.AttestationType, &cred.Authenticator.AAGUID, &contador,
+			&transportes, &cred.Flags.BackupEligible, &cred.Flags.BackupState); err != nil {
+			return nil, err
+		}
+		cred.Authenticator.SignCount = uint32(contador)
+		for _, t := range transportes {
+			cred.Transport = append(cred.Transport, protocol.AuthenticatorTransport(t))
+		}
+		credenciales = append(credenciales, cred)
+	}
+	return credenciales, rows.Err()
+}
+
+func (r *seguridadPG) GuardarCredencialWebAuthn(identidadID, nombre string, cred *webauthn.Credential, creado time.Time) error {
+	transportes := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transportes = append(transportes, string(t))
+	}
+	_, err := r.db.Exec(`INSERT INTO webauthn_credenciales (id_credencial, id_identidad, nombre, llave_publica,
+		attestation_type, aaguid, sign_count, transportes, backup_eligible, backup_state, creado_en)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		cred.ID, identidadID, nombre, cred.PublicKey, cred.AttestationType, cred.Authenticator.AAGUID,
+		int64(cred.Authenticator.SignCount), pq.Array(transportes), cred.Flags.BackupEligible, cred.Flags.BackupState, creado)
+	return err
+}
+
+func (r *seguridadPG) ActualizarCredencialWebAuthn(identidadID string, cred *webauthn.Credential) error {
+	_, err := r.db.Exec(`UPDATE webauthn_credenciales SET sign_count = $1, backup_state = $2, ultimo_uso_en = NOW()
+		WHERE id_credencial = $3 AND id_identidad = $4`,
+		int64(cred.Authenticator.SignCount), cred.Flags.BackupState, cred.ID, identidadID)
+	return err
+}
+
+func (r *seguridadPG) AutenticadoresWebAuthn(identidadID string) ([]utils.CredencialWebAuthn, error) {
+	rows, err := r.db.Query(`SELECT id_credencial, nombre, transportes, backup_state, creado_en, ultimo_uso_en
+		FROM webauthn_credenciales WHERE id_identidad = $1 ORDER BY creado_en`, identidadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credenciales := []utils.CredencialWebAuthn{}
+	for rows.Next() {
+		var (
+			cred        utils.CredencialWebAuthn
+			id          []byte
+			transportes pq.StringArray
+			uso         sql.NullTime
+		)
+		if err := rows.Scan(&id, &cred.Nombre, &transportes, &cred.Sincronizada, &cred.CreadoEn, &uso); err != nil {
+			return nil, err
+		}
+		cred.ID = base64.RawURLEncoding.EncodeToString(id)
+		cred.Transportes = transportes
+		cred.UltimoUsoEn = tiempoONil(uso)
+		credenciales = append(credenciales, cred)
+	}
+	return credenciales, rows.Err()
+}
+
+func (r *seguridadPG) BorrarCredencialWebAuthn(identidadID string, id []byte) (bool, error) {
+	return afectoAlguna(r.db.Exec(`DELETE FROM webauthn_credenciales WHERE id_credencial = $1 AND id_identidad = $2`,
+		id, identidadID))
+}
+
+func (r *seguridadPG) GuardarSesionWebAuthn(clave string, datos []byte, expira time.Time) error {
+	_, err := r.db.Exec(`INSERT INTO webauthn_sesiones (clave, datos, expira_en) VALUES ($1, $2, $3)
+		ON CONFLICT (clave) DO UPDATE SET datos = EXCLUDED.datos, expira_en = EXCLUDED.expira_en`,
+		clave, datos, expira)
+	return err
+}
+
+func (r *seguridadPG) ConsumirSesionWebAuthn(clave string) ([]byte, error) {
+	var datos []byte
+	err := r.db.QueryRow(`DELETE FROM webauthn_sesiones WHERE clave = $1 AND expira_en > NOW() RETURNING datos`,
+		clave).Scan(&datos)
+	if err == sql.ErrNoRows {
+		return nil, utils.ErrWebAuthnSinSesion
+	}
+	return datos, err
+}
@@ -6,13 +6,13 @@ import (
 	"back-menchaca/middleware"
 )
 
-func AntecedentesRoutes(app fiber.Router) {
+func AntecedentesRoutes(app fiber.Router, h *handlers.AntecedenteHandler) {
 	ants := app.Group("/antecedentes")
-    ants.Post("/",middleware.JWTProtected("crear_antecedentes"), handlers.CrearAntecedente)
-    ants.Get("/get", handlers.ObtenerAntecedentes)
-    ants.Post("/getant", handlers.ObtenerAntecedentePorID)
-    ants.Put("/update", handlers.ActualizarAntecedente)
-    ants.Delete("/delete", handlers.EliminarAntecedente)
+    ants.Post("/",middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelExpediente, "crear_antecedentes"), h.CrearAntecedente)
+    ants.Get("/get", middleware.JWTProtected("ver_antecedentes"), middleware.SinPacientes(), h.ObtenerAntecedentes)
+    ants.Post("/getant", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelAntecedente, "ver_antecedentes"), h.ObtenerAntecedentePorID)
+    ants.Put("/update", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelAntecedente, "actualizar_antecedentes"), h.ActualizarAntecedente)
+    ants.Delete("/delete", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelAntecedente, "eliminar_antecedentes"), h.EliminarAntecedente)
 }
 
 
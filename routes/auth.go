@@ -3,7 +3,7 @@ package routes
 import (
 	"github.com/gofiber/fiber/v2"
 	"back-menchaca/handlers"
-	//"back-menchaca/middleware"
+	"back-menchaca/middleware"
 )
 
 func SetupAuthRoutes(app fiber.Router) {
@@ -11,8 +11,33 @@ func SetupAuthRoutes(app fiber.Router) {
 	auth.Post("/login", handlers.Login)
 	auth.Post("/refresh", handlers.RefreshToken)
 	auth.Post("/verify-mfa", handlers.VerifyMFA)
-	
-	// Nueva ruta para activar MFA
-	//auth.Post("/mfa/activate", middleware.JWTProtected(), handlers.ActivateMFA)
-	
+	auth.Post("/logout", middleware.JWTProtected(), handlers.Logout)
+	auth.Post("/logout-all", middleware.JWTProtected(), handlers.LogoutAll)
+	auth.Post("/step-up", middleware.JWTProtected(), handlers.StepUp)
+	auth.Post("/step-up/webauthn/begin", middleware.JWTProtected(), handlers.IniciarStepUpWebAuthn)
+	auth.Post("/usuarios/revocar-sesiones", middleware.JWTProtected("administrar_seguridad"), handlers.RevocarSesionesUsuario)
+
+	// Recuperación de contraseña
+	auth.Post("/password/forgot", handlers.ForgotPassword)
+	auth.Post("/password/reset", handlers.ResetPassword)
+	auth.Post("/password/change", middleware.JWTOCambioContrasena(), handlers.ChangePassword)
+
+	// Verificación de correo de pacientes
+	auth.Get("/email/verify", handlers.VerificarCorreo)
+	auth.Post("/email/verify", handlers.VerificarCorreo)
+	auth.Post("/email/resend", handlers.ReenviarVerificacion)
+
+	// Ciclo de vida de MFA
+	auth.Post("/mfa/enroll", middleware.JWTOEnrolamientoMFA(), handlers.EnrollMFA)
+	auth.Post("/mfa/confirm", middleware.JWTOEnrolamientoMFA(), handlers.ConfirmMFA)
+	auth.Post("/mfa/disable", middleware.JWTProtected(), handlers.DisableMFA)
+	auth.Get("/mfa/recovery-codes", middleware.JWTProtected(), handlers.ContarCodigosRecuperacion)
+	auth.Post("/mfa/recovery-codes/regenerate", middleware.JWTProtected(), middleware.RequiereStepUp(0), handlers.RegenerarCodigosRecuperacion)
+
+	// WebAuthn (llaves de seguridad y passkeys) como alternativa al TOTP
+	auth.Post("/mfa/webauthn/register/begin", middleware.JWTOEnrolamientoMFA(), handlers.IniciarRegistroWebAuthn)
+	auth.Post("/mfa/webauthn/register/finish", middleware.JWTOEnrolamientoMFA(), handlers.FinalizarRegistroWebAuthn)
+	auth.Post("/mfa/webauthn/login/begin", handlers.IniciarLoginWebAuthn)
+	auth.Get("/mfa/webauthn/credenciales", middleware.JWTProtected(), handlers.ListarCredencialesWebAuthn)
+	auth.Delete("/mfa/webauthn/credenciales/:id", middleware.JWTProtected(), handlers.EliminarCredencialWebAuthn)
 }
\ No newline at end of file
@@ -7,9 +7,9 @@ import (
 
 )
 
-func AvisoRoutes(app fiber.Router) {
-	aviso := app.Group("/consentimiento", middleware.JWTProtected("paciente"))
+func AvisoRoutes(app fiber.Router, h *handlers.ConsentimientoHandler) {
+	aviso := app.Group("/consentimiento", middleware.JWTProtected("registrar_consentimiento"))
 
-	aviso.Get("/aviso-privacidad", handlers.ObtenerAvisoPrivacidad)
-	aviso.Post("/consentimiento", handlers.RegistrarConsentimiento)
+	aviso.Get("/aviso-privacidad", h.ObtenerAvisoPrivacidad)
+	aviso.Post("/consentimiento", h.RegistrarConsentimiento)
 }
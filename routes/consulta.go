@@ -7,15 +7,15 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func ConsultasRoutes(app fiber.Router) {
+func ConsultasRoutes(app fiber.Router, h *handlers.ConsultaHandler) {
 	consultas := app.Group("/consultas")
 
-	consultas.Post("/",middleware.JWTProtected("solicitar_cita"), handlers.AgendarConsulta)
-	consultas.Get("/",middleware.JWTProtected("ver_citas"), handlers.ObtenerConsultas)
-	consultas.Post("/getConsl",middleware.JWTProtected("solicitar_cita"), handlers.ObtenerConsultaPorID)
-	consultas.Put("/update", handlers.ActualizarConsulta)
-	consultas.Delete("/delete", handlers.EliminarConsulta)
-	consultas.Post("/paciente/", middleware.JWTProtected("solicitar_cita"), handlers.ObtenerConsultasPaciente)
-	consultas.Post("/doctor/", middleware.JWTProtected("solicitar_cita"), handlers.ObtenerConsultasPorEmpleado)
+	consultas.Post("/",middleware.JWTProtected("solicitar_cita"), h.AgendarConsulta)
+	consultas.Get("/",middleware.JWTProtected("ver_citas"), middleware.SinPacientes(), h.ObtenerConsultas)
+	consultas.Post("/getConsl", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDeLaConsulta, "solicitar_cita"), h.ObtenerConsultaPorID)
+	consultas.Put("/update", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDeLaConsulta, "actualizar_citas"), h.ActualizarConsulta)
+	consultas.Delete("/delete", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDeLaConsulta, "eliminar_citas"), h.EliminarConsulta)
+	consultas.Post("/paciente/", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelCuerpo, "solicitar_cita"), h.ObtenerConsultasPaciente)
+	consultas.Post("/doctor/", middleware.JWTProtected("ver_citas"), middleware.SinPacientes(), h.ObtenerConsultasPorEmpleado)
 
 }
@@ -6,12 +6,12 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupConsultorioRoutes(app fiber.Router) {
+func SetupConsultorioRoutes(app fiber.Router, h *handlers.ConsultorioHandler) {
 	consultorio := app.Group("/consultorios")
 
-	consultorio.Post("/", handlers.CrearConsultorio)
-	consultorio.Get("/get",middleware.JWTProtected("solicitar_cita"), handlers.ObtenerConsultorios)
-	consultorio.Post("/getconsultorio", handlers.ObtenerConsultorioPorID)
-	consultorio.Put("/update", handlers.ActualizarConsultorio)
-	consultorio.Delete("/delete", handlers.EliminarConsultorio)
+	consultorio.Post("/", middleware.JWTProtected("administrar_consultorios"), h.CrearConsultorio)
+	consultorio.Get("/get",middleware.JWTProtected("solicitar_cita"), h.ObtenerConsultorios)
+	consultorio.Post("/getconsultorio", middleware.JWTProtected("solicitar_cita"), h.ObtenerConsultorioPorID)
+	consultorio.Put("/update", middleware.JWTProtected("administrar_consultorios"), h.ActualizarConsultorio)
+	consultorio.Delete("/delete", middleware.JWTProtected("administrar_consultorios"), h.EliminarConsultorio)
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"back-menchaca/handlers"
+	"back-menchaca/middleware"
+)
+
+func SetupEmergenciaRoutes(app fiber.Router) {
+	emg := app.Group("/emergencia")
+	emg.Post("/acceso", middleware.JWTProtected("acceso_emergencia"), handlers.SolicitarAccesoEmergencia)
+	emg.Get("/acceso", middleware.JWTProtected("acceso_emergencia"), handlers.ListarMisAccesosEmergencia)
+
+	// Cola de revisión para supervisores
+	emg.Get("/revision", middleware.JWTProtected("revisar_accesos_emergencia"), handlers.ListarRevisionEmergencia)
+	emg.Post("/revision/:id", middleware.JWTProtected("revisar_accesos_emergencia"), handlers.RevisarAccesoEmergencia)
+}
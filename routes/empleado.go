@@ -6,12 +6,12 @@ import (
 	"back-menchaca/middleware"
 )
 
-func SetupEmpleadoRoutes(app fiber.Router) {
+func SetupEmpleadoRoutes(app fiber.Router, h *handlers.EmpleadoHandler) {
 	empleado := app.Group("/empleados")
 
-	empleado.Post("/",middleware.JWTProtected("crear_antecedentes"), handlers.CrearEmpleado)
-	empleado.Get("/get", handlers.ObtenerEmpleados)
-	empleado.Post("/getempleado", handlers.ObtenerEmpleadoPorID)
-	empleado.Put("/update", handlers.ActualizarEmpleado)
-	empleado.Delete("/delete", handlers.EliminarEmpleado)
+	empleado.Post("/",middleware.JWTProtected("administrar_empleados"), h.CrearEmpleado)
+	empleado.Get("/get", middleware.JWTProtected("ver_empleados"), h.ObtenerEmpleados)
+	empleado.Post("/getempleado", middleware.JWTProtected("ver_empleados"), h.ObtenerEmpleadoPorID)
+	empleado.Put("/update", middleware.JWTProtected("administrar_empleados"), h.ActualizarEmpleado)
+	empleado.Delete("/delete", middleware.JWTProtected("administrar_empleados"), h.EliminarEmpleado)
 }
@@ -7,12 +7,12 @@ import (
 )
 
 
-func ExpedienteRoutes(app fiber.Router) {
+func ExpedienteRoutes(app fiber.Router, h *handlers.ExpedienteHandler) {
 	expediente := app.Group("/expediente")
 
-    expediente.Post("/", handlers.CrearExpediente)
-    expediente.Get("/get",middleware.JWTProtected("solicitar_cita"), handlers.ObtenerExpedientes)
-    expediente.Post("/getExp",middleware.JWTProtected("solicitar_cita"), handlers.ObtenerExpedientePorID)
-    expediente.Put("/update", handlers.ActualizarExpediente)
-    expediente.Delete("/delete", handlers.EliminarExpediente)
+    expediente.Post("/", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelCuerpo, "crear_expedientes"), h.CrearExpediente)
+    expediente.Get("/get",middleware.JWTProtected("solicitar_cita"), middleware.SinPacientes(), h.ObtenerExpedientes)
+    expediente.Post("/getExp",middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelExpediente, "solicitar_cita"), h.ObtenerExpedientePorID)
+    expediente.Put("/update", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelExpediente, "actualizar_expedientes"), h.ActualizarExpediente)
+    expediente.Delete("/delete", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelExpediente, "eliminar_expedientes"), middleware.RequiereStepUp(0), h.EliminarExpediente)
 }
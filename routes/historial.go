@@ -6,14 +6,14 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func HistorialRoutes(app fiber.Router) {
-	historial := app.Group("/historial", middleware.JWTProtected("empleado"))
+func HistorialRoutes(app fiber.Router, h *handlers.HistorialHandler) {
+	historial := app.Group("/historial")
 
-	historial.Post("/create", handlers.CrearHistorialClinico)
-	historial.Get("/get", handlers.ObtenerHistorialesClinicos)
-	historial.Post("/historialget", handlers.ObtenerHistorialClinicoPorID)
-	historial.Put("/update", handlers.ActualizarHistorialClinico)
-	historial.Delete("/delete", handlers.EliminarHistorialClinico)
+	historial.Post("/create", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelExpediente, "crear_historial"), h.CrearHistorialClinico)
+	historial.Get("/get", middleware.JWTProtected("ver_historial"), middleware.SinPacientes(), h.ObtenerHistorialesClinicos)
+	historial.Post("/historialget", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelHistorial, "ver_historial"), h.ObtenerHistorialClinicoPorID)
+	historial.Put("/update", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelHistorial, "actualizar_historial"), h.ActualizarHistorialClinico)
+	historial.Delete("/delete", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelHistorial, "eliminar_historial"), h.EliminarHistorialClinico)
 }
 
 
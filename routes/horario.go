@@ -6,12 +6,12 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupHorarioRoutes(app fiber.Router) {
+func SetupHorarioRoutes(app fiber.Router, h *handlers.HorarioHandler) {
 	horario := app.Group("/horarios")
 	
-	horario.Post("/create", handlers.CrearHorario)
-	horario.Get("/get",middleware.JWTProtected("solicitar_cita"), handlers.ObtenerHorarios)
-	horario.Post("/gethorario", handlers.ObtenerHorarioPorID)
-	horario.Put("/update", handlers.ActualizarHorario)
-	horario.Delete("/delete", handlers.EliminarHorario)
+	horario.Post("/create", middleware.JWTProtected("administrar_horarios"), h.CrearHorario)
+	horario.Get("/get",middleware.JWTProtected("solicitar_cita"), h.ObtenerHorarios)
+	horario.Post("/gethorario", middleware.JWTProtected("solicitar_cita"), h.ObtenerHorarioPorID)
+	horario.Put("/update", middleware.JWTProtected("administrar_horarios"), h.ActualizarHorario)
+	horario.Delete("/delete", middleware.JWTProtected("administrar_horarios"), h.EliminarHorario)
 }
@@ -3,9 +3,10 @@ package routes
 import (
 	"github.com/gofiber/fiber/v2"
 	"back-menchaca/handlers"
+	"back-menchaca/middleware"
 )
 
-func SetupLogRoutes(app fiber.Router) {
-	logs := app.Group("/logs")
-	logs.Get("/", handlers.GetLogs)
+func SetupLogRoutes(app fiber.Router, h *handlers.LogHandler) {
+	logs := app.Group("/logs", middleware.JWTProtected("ver_logs"))
+	logs.Get("/", h.GetLogs)
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"back-menchaca/handlers"
+	"back-menchaca/middleware"
+)
+
+func SetupOAuthRoutes(app fiber.Router) {
+	oauth := app.Group("/oauth")
+	oauth.Get("/authorize", handlers.OAuthAuthorize)
+	oauth.Post("/authorize", middleware.JWTProtected(), handlers.OAuthAuthorizeSesion)
+	oauth.Post("/consent", middleware.JWTProtected(), handlers.OAuthConsent)
+	oauth.Post("/token", handlers.OAuthToken)
+	oauth.Get("/userinfo", middleware.JWTUserInfo(), handlers.OAuthUserInfo)
+	oauth.Post("/userinfo", middleware.JWTUserInfo(), handlers.OAuthUserInfo)
+}
@@ -5,32 +5,15 @@ import (
 	"back-menchaca/handlers"
 	"back-menchaca/middleware"
 )
-/*
-func SetupPacienteRoutes(app fiber.Router) {
-	app.Post("/pacientes", handlers.CrearPaciente)
 
-	//paciente := app.Group("/pacientes", middleware.JWTProtected())
-	
-	paciente := app.Group("/pacientes", middleware.AutorizarPorPermiso())
+func SetupPacienteRoutes(app fiber.Router, h *handlers.PacienteHandler) {
+	app.Post("/pacientes", h.CrearPaciente) // Registro libre (sin protección)
 
-	paciente.Get("/get", handlers.ObtenerPacientes)
-	paciente.Post("/getpaciente", handlers.ObtenerPacientePorID)
-	paciente.Put("/update", handlers.ActualizarPaciente)
-	paciente.Delete("/delete", handlers.EliminarPaciente)
-}
-*/
-
-func SetupPacienteRoutes(app fiber.Router) {
-	app.Post("/pacientes", handlers.CrearPaciente) // Registro libre (sin protección)
+	// Cada ruta declara el permiso que exige; la política decide qué roles lo tienen
+	paciente := app.Group("/pacientes")
 
-	// Agrupamos todas las rutas protegidas
-	paciente := app.Group("/pacientes",
-		middleware.JWTProtected(),           // 1️⃣ Verifica JWT y extrae el rol
-		middleware.AutorizarPorPermiso(),    // 2️⃣ Verifica en BD si ese rol tiene permiso para acceder
-	)
-
-	paciente.Get("/get", handlers.ObtenerPacientes)             // paciente puede
-	paciente.Post("/getpaciente", handlers.ObtenerPacientePorID) // paciente puede
-	paciente.Put("/update", handlers.ActualizarPaciente)         // paciente puede
-	paciente.Delete("/delete", handlers.EliminarPaciente)        // solo empleado puede
-}
\ No newline at end of file
+	paciente.Get("/get", middleware.JWTProtected("ver_pacientes"), middleware.SinPacientes(), h.ObtenerPacientes)
+	paciente.Post("/getpaciente", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelCuerpo, "ver_pacientes"), h.ObtenerPacientePorID)
+	paciente.Put("/update", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDelCuerpo, "actualizar_pacientes"), h.ActualizarPaciente)
+	paciente.Delete("/delete", middleware.JWTProtected("eliminar_pacientes"), middleware.RequiereStepUp(0), h.EliminarPaciente) // con segundo factor reciente
+}
@@ -6,14 +6,14 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func SetupRecetasRoutes(app fiber.Router) {
+func SetupRecetasRoutes(app fiber.Router, h *handlers.RecetaHandler) {
 	rec := app.Group("/recetas")
 
-	rec.Post("/",middleware.JWTProtected("crear_recetas"), handlers.CrearReceta)
-	rec.Get("/get",middleware.JWTProtected("ver_recetas"), handlers.ObtenerRecetas)
+	rec.Post("/",middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDeLaConsulta, "crear_recetas"), h.CrearReceta)
+	rec.Get("/get",middleware.JWTProtected("ver_recetas"), middleware.SinPacientes(), h.ObtenerRecetas)
 
 	
-	rec.Post("/recetaget", middleware.JWTProtected("solicitar_cita"), handlers.ObtenerRecetaPorID)
-	rec.Put("/update", handlers.ActualizarReceta)
-	rec.Delete("/delete", handlers.EliminarReceta)
+	rec.Post("/recetaget", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDeLaReceta, "solicitar_cita"), h.ObtenerRecetaPorID)
+	rec.Put("/update", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDeLaReceta, "actualizar_recetas"), middleware.RequiereStepUp(0), h.ActualizarReceta)
+	rec.Delete("/delete", middleware.JWTProtected(), middleware.AccesoPaciente(middleware.PacienteDeLaReceta, "eliminar_recetas"), middleware.RequiereStepUp(0), h.EliminarReceta)
 }
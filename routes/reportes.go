@@ -6,13 +6,13 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func ReportesRoutes(app fiber.Router) {
-	rep := app.Group("/reportes", middleware.JWTProtected("paciente", "empleados"))
+func ReportesRoutes(app fiber.Router, h *handlers.ReporteHandler) {
+	rep := app.Group("/reportes")
 
-	rep.Post("/consultas-por-paciente-detalle", handlers.ReporteDetalleConsultasPorPaciente)
-	rep.Post("/detalles-consulta-expediente", handlers.ReporteDetallesConsultaExpediente)
-	rep.Get("/consultas-por-area", handlers.ReporteConsultasPorArea)
-	rep.Get("/consultas-por-turno", handlers.ReporteConsultasPorTurno)
-	rep.Get("/ingresos-por-consultorio", handlers.ReporteIngresosPorConsultorio)
-	rep.Get("/consultas-detalle-simple", handlers.ObtenerDetalleSimpleConsultas)
+	rep.Post("/consultas-por-paciente-detalle", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelCuerpo, "ver_reportes"), h.ReporteDetalleConsultasPorPaciente)
+	rep.Post("/detalles-consulta-expediente", middleware.JWTProtected(), middleware.LecturaPaciente(middleware.PacienteDelExpediente, "ver_reportes"), h.ReporteDetallesConsultaExpediente)
+	rep.Get("/consultas-por-area", middleware.JWTProtected("ver_reportes"), middleware.SinPacientes(), h.ReporteConsultasPorArea)
+	rep.Get("/consultas-por-turno", middleware.JWTProtected("ver_reportes"), middleware.SinPacientes(), h.ReporteConsultasPorTurno)
+	rep.Get("/ingresos-por-consultorio", middleware.JWTProtected("ver_reportes"), middleware.SinPacientes(), h.ReporteIngresosPorConsultorio)
+	rep.Get("/consultas-detalle-simple", middleware.JWTProtected("ver_reportes"), middleware.SinPacientes(), h.ObtenerDetalleSimpleConsultas)
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"back-menchaca/handlers"
+)
+
+// SetupSaludRoutes registra las sondas de vida y disponibilidad. Se registran
+// antes del logger y del limitador para no llenar la tabla logs ni consumir cuota.
+func SetupSaludRoutes(app fiber.Router) {
+	app.Get("/healthz", handlers.Healthz)
+	app.Get("/readyz", handlers.Readyz)
+}
@@ -0,0 +1,40 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"back-menchaca/handlers"
+	"back-menchaca/middleware"
+)
+
+func SetupSeguridadRoutes(app fiber.Router) {
+	seguridad := app.Group("/seguridad", middleware.JWTProtected("administrar_seguridad"))
+	seguridad.Get("/bloqueos", handlers.ListarBloqueos)
+	seguridad.Post("/bloqueos/desbloquear", handlers.DesbloquearCuenta)
+	seguridad.Get("/eventos", handlers.ListarEventosSeguridad)
+
+	// Clientes del proveedor OpenID Connect
+	seguridad.Get("/oauth/clientes", handlers.ListarClientesOAuth)
+	seguridad.Post("/oauth/clientes", handlers.CrearClienteOAuth)
+	seguridad.Delete("/oauth/clientes/:clientID", handlers.EliminarClienteOAuth)
+
+	// Cuentas de servicio y API keys para clientes máquina
+	seguridad.Get("/servicios", handlers.ListarCuentasServicio)
+	seguridad.Post("/servicios", handlers.CrearCuentaServicio)
+	seguridad.Put("/servicios/:id/permisos", handlers.ActualizarPermisosServicio)
+	seguridad.Delete("/servicios/:id", handlers.DesactivarCuentaServicio)
+	seguridad.Get("/servicios/:id/llaves", handlers.ListarAPIKeys)
+	seguridad.Post("/servicios/:id/llaves", handlers.CrearAPIKey)
+	seguridad.Delete("/servicios/:id/llaves/:idLlave", handlers.RevocarAPIKey)
+
+	// Roles y permisos del motor de permisos
+	seguridad.Get("/roles", handlers.ListarRoles)
+	seguridad.Post("/roles", handlers.CrearRol)
+	seguridad.Delete("/roles/:rol", handlers.EliminarRol)
+	seguridad.Put("/roles/:rol/permisos/:permiso", handlers.AsignarPermisoRol)
+	seguridad.Delete("/roles/:rol/permisos/:permiso", handlers.QuitarPermisoRol)
+	seguridad.Post("/roles/:rol/renovar-tokens", handlers.ForzarRenovacionRol)
+	seguridad.Get("/permisos", handlers.ListarPermisos)
+	seguridad.Post("/permisos", handlers.CrearPermiso)
+	seguridad.Delete("/permisos/:permiso", handlers.EliminarPermiso)
+	seguridad.Get("/usuarios/:tipo/:id/permisos", handlers.PermisosEfectivosUsuario)
+}
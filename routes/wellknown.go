@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"back-menchaca/handlers"
+	"github.com/gofiber/fiber/v2"
+)
+
+func SetupWellKnownRoutes(app fiber.Router) {
+	wk := app.Group("/.well-known")
+	wk.Get("/jwks.json", handlers.JWKS)
+	wk.Get("/openid-configuration", handlers.OpenIDConfiguration)
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Almacenamiento de los módulos de seguridad. Las funciones de este paquete
+// aplican las reglas (hashes, cachés, expiraciones, políticas) y delegan la
+// lectura y escritura en el Almacen configurado al arrancar con UsarAlmacen:
+// Postgres (repository.NuevoPostgres) o la memoria del proceso
+// (repository/memoria, con serve --store=memory).
+//
+// Los métodos devuelven los errores de este paquete cuando el registro buscado
+// no existe (ErrCuentaNoEncontrada, ErrRefreshInvalido, ...), igual que las
+// funciones que los usan.
+
+// Almacen reúne todo lo que guardan los módulos de seguridad
+type Almacen interface {
+	AlmacenIdentidades
+	AlmacenSesiones
+	AlmacenBloqueos
+	AlmacenOAuth
+	AlmacenServicios
+	AlmacenWebAuthn
+	AlmacenEmergencia
+	AlmacenRBAC
+	AlmacenPropiedad
+}
+
+var almacen Almacen
+
+// UsarAlmacen configura el almacenamiento de los módulos de seguridad
+func UsarAlmacen(a Almacen) {
+	almacen = a
+}
+
+// AlmacenIdentidades guarda las identidades, sus roles y sus credenciales
+// (contraseña, historial, tokens de restablecimiento, TOTP y códigos de recuperación)
+type AlmacenIdentidades interface {
+	// BuscarIdentidadPorCorreo y ObtenerIdentidad cargan la identidad con sus
+	// roles y el número de autenticadores WebAuthn, sin rol activo
+	BuscarIdentidadPorCorreo(correo string) (*Cuenta, error)
+	ObtenerIdentidad(identidadID string) (*Cuenta, error)
+	IdentidadDeUsuario(tipo, idUsuario string) (string, error)
+	// EliminarRolIdentidad quita el rol y borra la identidad si ya no le quedan roles
+	EliminarRolIdentidad(tipo, idUsuario string) error
+	MarcarCorreoVerificado(identidadID, correo string) (bool, error)
+
+	GuardarMFAPendiente(identidadID, secret string) error
+	ConfirmarMFA(identidadID string, paso int64) error
+	// ConsumirPasoTOTP guarda el paso como el último usado si es posterior al anterior
+	ConsumirPasoTOTP(identidadID string, paso int64) (bool, error)
+	DesactivarMFA(identidadID string) error
+
+	// ReemplazarHash cambia el hash de la contraseña solo si sigue siendo anterior
+	ReemplazarHash(identidadID, anterior, nuevo string) error
+	// HistorialContrasenas devuelve los hashes anteriores, del más nuevo al más viejo
+	HistorialContrasenas(identidadID string, limite int) ([]string, error)
+	// CambiarContrasena guarda el hash nuevo y pasa el anterior al historial,
+	// del que solo se conservan los últimos conservar
+	CambiarContrasena(identidadID, anterior, nuevo string, conservar int) error
+	// GuardarTokenReset invalida los tokens pendientes de la identidad y guarda el nuevo
+	GuardarTokenReset(identidadID, tokenHash string, expira time.Time) error
+	// ConsumirTokenReset marca el token como usado y devuelve su identidad
+	ConsumirTokenReset(tokenHash string) (string, error)
+
+	ReemplazarCodigosRecuperacion(identidadID string, hashes []string) error
+	CodigosRecuperacionPendientes(identidadID string) ([]CodigoRecuperacion, error)
+	// MarcarCodigoRecuperacionUsado devuelve false si otro request ya lo usó
+	MarcarCodigoRecuperacionUsado(id int64) (bool, error)
+	ContarCodigosRecuperacion(identidadID string) (int, error)
+	EliminarCodigosRecuperacion(identidadID string) error
+
+	// NombreUsuario devuelve el nombre completo del paciente o empleado
+	NombreUsuario(tipo, idUsuario string) (string, error)
+}
+
+// CodigoRecuperacion es un código de recuperación sin usar
+type CodigoRecuperacion struct {
+	ID   int64
+	Hash string
+}
+
+// AlmacenSesiones guarda los refresh tokens, las revocaciones de access
+// tokens y los intentos fallidos de MFA por token temporal
+type AlmacenSesiones interface {
+	GuardarRefreshToken(tokenHash string, s RefreshSession, expira time.Time) error
+	// RotarRefreshToken consume el token y guarda el siguiente de su familia en
+	// una sola operación. Devuelve ErrRefreshInvalido si no existe, expiró, fue
+	// revocado o es de otro cliente, y ErrRefreshReutilizado (con la sesión)
+	// después de revocar la familia si ya se había usado.
+	RotarRefreshToken(tokenHash, clientID, nuevoHash string, expira time.Time) (*RefreshSession, error)
+	RevocarFamilia(familiaID string) error
+	RevocarFamiliaDeToken(tokenHash string) error
+
+	RevocarJTI(jti string, expira time.Time) error
+	// ConsumirJTI revoca el jti solo si no estaba revocado; indica si lo revocó
+	ConsumirJTI(jti string, expira time.Time) (bool, error)
+	LiberarJTI(jti string) error
+	JTIRevocado(jti string) (bool, error)
+	// RevocarSesionesUsuario registra el cierre de sesiones y revoca los refresh tokens del usuario
+	RevocarSesionesUsuario(tipo, id string, desde time.Time) error
+	// SesionesRevocadasDesde devuelve el último cierre de sesiones (cero si no hay)
+	SesionesRevocadasDesde(tipo, id string) (time.Time, error)
+	GuardarRenovacionRol(rol string, desde time.Time) error
+	// RenovacionRol devuelve desde cuándo deben renovarse los tokens del rol (cero si nunca)
+	RenovacionRol(rol string) (time.Time, error)
+
+	// SumarFalloMFA suma un intento fallido al token temporal y devuelve el total
+	SumarFalloMFA(jti string, expira time.Time) (int, error)
+}
+
+// AlmacenBloqueos guarda los intentos de login, los bloqueos por correo y los
+// eventos de seguridad
+type AlmacenBloqueos interface {
+	// BloqueoLogin devuelve el contador de fallos del correo (nil si no tiene)
+	BloqueoLogin(correo string) (*CuentaBloqueada, error)
+	// FallosLoginIP cuenta los fallos de la IP desde la fecha y devuelve el más antiguo
+	FallosLoginIP(ip string, desde time.Time) (int, time.Time, error)
+	RegistrarIntentoLogin(correo, ip string, exitoso bool) error
+	// SumarFalloLogin suma un fallo al correo y devuelve el total; si el
+	// bloqueo anterior ya venció el contador vuelve a empezar
+	SumarFalloLogin(correo string) (int, error)
+	BloquearLogin(correo string, hasta time.Time) error
+	EliminarBloqueoLogin(correo string) (bool, error)
+	BloqueosVigentes() ([]CuentaBloqueada, error)
+
+	GuardarEventoSeguridad(e EventoSeguridad) error
+	// EventosSeguridad filtra por tipo y correo si no son vacíos
+	EventosSeguridad(tipo, correo string, limite int) ([]EventoSeguridad, error)
+}
+
+// AlmacenOAuth guarda los clientes OAuth, los consentimientos y los códigos de autorización
+type AlmacenOAuth interface {
+	ObtenerClienteOAuth(clientID string) (*ClienteOAuth, error)
+	GuardarClienteOAuth(cl *ClienteOAuth) error
+	ClientesOAuth() ([]ClienteOAuth, error)
+	// BorrarClienteOAuth también borra sus consentimientos y códigos
+	BorrarClienteOAuth(clientID string) (bool, error)
+	// ConsentimientoOAuth devuelve los scopes autorizados (nil si no hay)
+	ConsentimientoOAuth(identidadID, clientID string) ([]string, error)
+	GuardarConsentimientoOAuth(identidadID, clientID string, scopes []string) error
+	GuardarCodigoAutorizacion(codigoHash string, ca CodigoAutorizacion, expira time.Time) error
+	// ConsumirCodigoAutorizacion marca el código como usado si sigue vigente
+	ConsumirCodigoAutorizacion(codigoHash string) (*CodigoAutorizacion, error)
+}
+
+// AlmacenServicios guarda las cuentas de servicio y sus API keys
+type AlmacenServicios interface {
+	// ContarPermisosConocidos cuenta cuántos de los permisos (sin repetir)
+	// están en el catálogo o asignados a algún rol
+	ContarPermisosConocidos(permisos []string) (int, error)
+	GuardarCuentaServicio(s *CuentaServicio) error
+	BuscarCuentaServicio(id string) (*CuentaServicio, error)
+	CuentasServicio() ([]CuentaServicio, error)
+	CambiarPermisosServicio(id string, permisos []string) (bool, error)
+	// DesactivarServicio desactiva la cuenta y revoca sus llaves
+	DesactivarServicio(id string) (bool, error)
+
+	GuardarAPIKey(k *APIKeyGuardada) error
+	APIKeys(idCuenta string) ([]APIKey, error)
+	RevocarLlave(idCuenta, idLlave string) (bool, error)
+	BuscarAPIKey(prefijo string) (*APIKeyGuardada, error)
+	// RegistrarUsoLlave actualiza el último uso si pasó más de un minuto
+	RegistrarUsoLlave(idLlave string) error
+}
+
+// APIKeyGuardada es una llave con su cuenta y el hash del secreto
+type APIKeyGuardada struct {
+	APIKey
+	IDCuenta string
+	Hash     string
+}
+
+// AlmacenWebAuthn guarda los autenticadores y los datos de las ceremonias pendientes
+type AlmacenWebAuthn interface {
+	CredencialesWebAuthn(identidadID string) ([]webauthn.Credential, error)
+	GuardarCredencialWebAuthn(identidadID, nombre string, cred *webauthn.Credential, creado time.Time) error
+	// ActualizarCredencialWebAuthn guarda el contador y el respaldo tras un uso
+	ActualizarCredencialWebAuthn(identidadID string, cred *webauthn.Credential) error
+	AutenticadoresWebAuthn(identidadID string) ([]CredencialWebAuthn, error)
+	BorrarCredencialWebAuthn(identidadID string, id []byte) (bool, error)
+	GuardarSesionWebAuthn(clave string, datos []byte, expira time.Time) error
+	// ConsumirSesionWebAuthn devuelve y borra los datos si no han expirado
+	ConsumirSesionWebAuthn(clave string) ([]byte, error)
+}
+
+// AlmacenEmergencia guarda los accesos de emergencia
+type AlmacenEmergencia interface {
+	GuardarAccesoEmergencia(a *AccesoEmergencia) error
+	BuscarAccesoEmergencia(id string) (*AccesoEmergencia, error)
+	// AccesoEmergenciaVigente devuelve el id del acceso vigente que vence al
+	// último (vacío si no hay)
+	AccesoEmergenciaVigente(idEmpleado string, idPaciente int) (string, error)
+	// AccesosEmergencia devuelven los más recientes primero
+	AccesosEmergenciaVigentes(idEmpleado string, limite int) ([]AccesoEmergencia, error)
+	AccesosEmergenciaRevision(soloPendientes bool, limite int) ([]AccesoEmergencia, error)
+	// MarcarAccesoEmergenciaRevisado registra la revisión; con revocar también
+	// termina el acceso si sigue vigente
+	MarcarAccesoEmergenciaRevisado(id, revisor, comentario string, revocar bool) error
+}
+
+// AlmacenRBAC guarda los roles, el catálogo de permisos y sus asignaciones
+type AlmacenRBAC interface {
+	AsignacionesPermisos() ([]AsignacionPermiso, error)
+	// RolesRegistrados incluye los roles que solo aparecen en las asignaciones
+	// y cuántos usuarios tiene cada uno; sin permisos
+	RolesRegistrados() ([]Rol, error)
+	RolRegistrado(rol string) (bool, error)
+	GuardarRol(rol, descripcion string) error
+	// RolAsignado indica si algún empleado tiene el rol
+	RolAsignado(rol string) (bool, error)
+	// BorrarRol también borra sus asignaciones
+	BorrarRol(rol string) error
+	// PermisosRegistrados incluye los permisos que solo aparecen en las
+	// asignaciones; solo con nombre y descripción
+	PermisosRegistrados() ([]PermisoCatalogo, error)
+	PermisoRegistrado(permiso string) (bool, error)
+	GuardarPermiso(permiso, descripcion string) error
+	// BorrarPermiso lo quita del catálogo, de los roles y de las cuentas de servicio
+	BorrarPermiso(permiso string) error
+	// ReemplazarPermisoRol reemplaza las reglas del permiso en el rol
+	ReemplazarPermisoRol(rol, permiso string, reglas []ReglaPermiso) error
+	BorrarPermisoRol(rol, permiso string) error
+}
+
+// AsignacionPermiso es una fila de la tabla permisos: el permiso del rol,
+// limitado a una ruta si la regla la tiene
+type AsignacionPermiso struct {
+	Rol     string
+	Permiso string
+	ReglaPermiso
+}
+
+// AlmacenPropiedad responde a qué paciente pertenece cada registro clínico
+type AlmacenPropiedad interface {
+	// EmpleadoAtiendePaciente indica si el paciente tiene consultas con el
+	// empleado o con otro empleado de su área
+	EmpleadoAtiendePaciente(idEmpleado, idPaciente int) (bool, error)
+	PacienteDeConsulta(idConsulta int) (int, error)
+	PacienteDeReceta(idReceta int) (int, error)
+	PacienteDeExpediente(idExpediente int) (int, error)
+	PacienteDeAntecedente(idAntecedente int) (int, error)
+	PacienteDeHistorial(idHistorial int) (int, error)
+	ExisteRegistro(tabla, columna string, id int) (bool, error)
+}
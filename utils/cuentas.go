@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Identidades: cada correo corresponde a una sola identidad, que guarda las
+// credenciales (contraseña, MFA) y tiene uno o más roles en identidad_roles.
+// Un rol apunta a un registro de Paciente o de Empleado; el rol de un empleado
+// es su tipo_empleado. Así una misma persona puede ser doctor y paciente.
+
+var (
+	ErrCuentaNoEncontrada = errors.New("cuenta no encontrada")
+	ErrCorreoRegistrado   = errors.New("el correo ya está registrado")
+)
+
+// RolCuenta es uno de los roles de una identidad
+type RolCuenta struct {
+	Tipo string // "paciente" o "empleado"
+	ID   string // id_paciente o id_empleado
+	Rol  string
+}
+
+// Cuenta reúne los datos de autenticación de una identidad. ID y Rol
+// corresponden al rol activo (vacíos hasta que se selecciona uno).
+type Cuenta struct {
+	IdentidadID        string
+	Correo             string
+	Hash               string
+	MFAEnabled         bool
+	MFASecret          string
+	MFASecretPendiente string
+	// ContrasenaActualizadaEn es cero si no se conoce la fecha del último cambio
+	ContrasenaActualizadaEn time.Time
+	CorreoVerificado        bool
+	Passkeys                int // autenticadores WebAuthn registrados
+	Roles                   []RolCuenta
+
+	ID  string
+	Rol string
+}
+
+// SeleccionarRol activa uno de los roles de la cuenta. Con rol vacío solo
+// funciona si la cuenta tiene un único rol.
+func (c *Cuenta) SeleccionarRol(rol string) bool {
+	if rol == "" && len(c.Roles) == 1 {
+		rol = c.Roles[0].Rol
+	}
+	for _, r := range c.Roles {
+		if r.Rol == rol {
+			c.ID, c.Rol = r.ID, r.Rol
+			return true
+		}
+	}
+	return false
+}
+
+// TieneMFA indica si la cuenta tiene algún segundo factor (TOTP o WebAuthn)
+func (c *Cuenta) TieneMFA() bool {
+	return c.MFAEnabled || c.Passkeys > 0
+}
+
+// MetodosMFA devuelve los segundos factores con los que puede completar el login
+func (c *Cuenta) MetodosMFA() []string {
+	var metodos []string
+	if c.MFAEnabled {
+		metodos = append(metodos, "totp")
+	}
+	if c.Passkeys > 0 {
+		metodos = append(metodos, "webauthn")
+	}
+	return metodos
+}
+
+// NombresRoles devuelve los roles de la cuenta
+func (c *Cuenta) NombresRoles() []string {
+	roles := make([]string, 0, len(c.Roles))
+	for _, r := range c.Roles {
+		roles = append(roles, r.Rol)
+	}
+	return roles
+}
+
+// EsPersonal indica si la identidad tiene algún rol de empleado
+func (c *Cuenta) EsPersonal() bool {
+	for _, r := range c.Roles {
+		if r.Tipo == "empleado" {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal devuelve el principal del rol activo
+func (c *Cuenta) Principal() Principal {
+	return Principal{
+		IdentidadID: c.IdentidadID,
+		Correo:      c.Correo,
+		Tipo:        TipoUsuario(c.Rol),
+		ID:          c.ID,
+		Rol:         c.Rol,
+		Roles:       c.NombresRoles(),
+	}
+}
+
+// BuscarCuentaPorCorreo carga la identidad del correo con todos sus roles
+func BuscarCuentaPorCorreo(correo string) (*Cuenta, error) {
+	return almacen.BuscarIdentidadPorCorreo(strings.ToLower(correo))
+}
+
+// ObtenerCuenta carga la identidad y activa el rol indicado (si no es vacío)
+func ObtenerCuenta(identidadID, rol string) (*Cuenta, error) {
+	c, err := almacen.ObtenerIdentidad(identidadID)
+	if err != nil {
+		return nil, err
+	}
+	if rol != "" && !c.SeleccionarRol(rol) {
+		return nil, ErrCuentaNoEncontrada
+	}
+	return c, nil
+}
+
+// ObtenerCuentaDeUsuario carga la identidad a la que pertenece un paciente o empleado
+func ObtenerCuentaDeUsuario(tipo, idUsuario string) (*Cuenta, error) {
+	identidadID, err := almacen.IdentidadDeUsuario(tipo, idUsuario)
+	if err != nil {
+		return nil, err
+	}
+	return ObtenerCuenta(identidadID, "")
+}
+
+// EliminarRolIdentidad quita el rol de un paciente o empleado eliminado y borra
+// la identidad si ya no le quedan roles
+func EliminarRolIdentidad(tipo, idUsuario string) error {
+	return almacen.EliminarRolIdentidad(tipo, idUsuario)
+}
+
+// GuardarMFAPendiente guarda un secreto TOTP que aún no ha sido confirmado
+func GuardarMFAPendiente(c *Cuenta, secret string) error {
+	return almacen.GuardarMFAPendiente(c.IdentidadID, secret)
+}
+
+// ConfirmarMFA activa el secreto pendiente como secreto TOTP de la cuenta.
+// paso es el paso de tiempo del código de confirmación, que ya no podrá reutilizarse.
+func ConfirmarMFA(c *Cuenta, paso int64) error {
+	return almacen.ConfirmarMFA(c.IdentidadID, paso)
+}
+
+// VerificarTOTP valida un código contra el secreto activo de la cuenta y lo
+// consume: un código (paso de tiempo) ya aceptado no vuelve a ser válido
+func VerificarTOTP(c *Cuenta, codigo string) (bool, error) {
+	paso, ok := ValidarTOTPPaso(codigo, c.MFASecret, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return almacen.ConsumirPasoTOTP(c.IdentidadID, paso)
+}
+
+// DesactivarMFA elimina el secreto TOTP de la cuenta
+func DesactivarMFA(c *Cuenta) error {
+	return almacen.DesactivarMFA(c.IdentidadID)
+}
+
+// MarcarCorreoVerificado verifica el correo de la identidad si aún es el mismo del enlace
+func MarcarCorreoVerificado(identidadID, correo string) (bool, error) {
+	return almacen.MarcarCorreoVerificado(identidadID, correo)
+}
+
+// RehashContrasena vuelve a guardar la contraseña con el algoritmo y parámetros
+// actuales. No cuenta como cambio de contraseña.
+func RehashContrasena(c *Cuenta, contrasena string) error {
+	hash, err := HashPassword(contrasena)
+	if err != nil {
+		return err
+	}
+
+	if err := almacen.ReemplazarHash(c.IdentidadID, c.Hash, hash); err != nil {
+		return err
+	}
+	c.Hash = hash
+	return nil
+}
+
+// RevocarSesionesCuenta cierra las sesiones de todos los roles de la identidad
+func RevocarSesionesCuenta(c *Cuenta) error {
+	for _, r := range c.Roles {
+		if err := RevokeAllSessions(r.ID, r.Rol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"back-menchaca/config"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Acceso de emergencia ("break-the-glass"): un empleado con el permiso
+// acceso_emergencia puede leer los registros de un paciente fuera de su área,
+// con los permisos de su rol, por tiempo limitado y con una justificación
+// obligatoria. Cada acceso queda en accesos_emergencia, se notifica al oficial
+// de cumplimiento y queda pendiente hasta que un supervisor lo revisa.
+
+var (
+	ErrAccesoEmergenciaNoEncontrado = errors.New("acceso de emergencia no encontrado")
+	ErrAccesoEmergenciaPropio       = errors.New("no puedes revisar tu propio acceso de emergencia")
+)
+
+// DuracionAccesoEmergencia es la vigencia de cada acceso (EMERGENCIA_DURACION_MINUTOS, 60 por defecto)
+func DuracionAccesoEmergencia() time.Duration {
+	return time.Duration(config.Actual().Seguridad.EmergenciaMinutos) * time.Minute
+}
+
+// AccesoEmergencia es un acceso otorgado a un empleado sobre un paciente
+type AccesoEmergencia struct {
+	ID                 string     `json:"id"`
+	IDEmpleado         string     `json:"id_empleado"`
+	Correo             string     `json:"correo"`
+	Rol                string     `json:"rol"`
+	IDPaciente         int        `json:"id_paciente"`
+	Justificacion      string     `json:"justificacion"`
+	IP                 string     `json:"ip"`
+	OtorgadoEn         time.Time  `json:"otorgado_en"`
+	ExpiraEn           time.Time  `json:"expira_en"`
+	RevocadoEn         *time.Time `json:"revocado_en,omitempty"`
+	RevisadoPor        string     `json:"revisado_por,omitempty"`
+	RevisadoEn         *time.Time `json:"revisado_en,omitempty"`
+	ComentarioRevision string     `json:"comentario_revision,omitempty"`
+}
+
+// OtorgarAccesoEmergencia registra un acceso nuevo del empleado al paciente
+func OtorgarAccesoEmergencia(p Principal, idPaciente int, justificacion, ip string) (*AccesoEmergencia, error) {
+	ahora := time.Now()
+	a := &AccesoEmergencia{
+		ID:            uuid.NewString(),
+		IDEmpleado:    p.ID,
+		Correo:        p.Correo,
+		Rol:           p.Rol,
+		IDPaciente:    idPaciente,
+		Justificacion: justificacion,
+		IP:            ip,
+		OtorgadoEn:    ahora,
+		ExpiraEn:      ahora.Add(DuracionAccesoEmergencia()),
+	}
+	if err := almacen.GuardarAccesoEmergencia(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// AccesoEmergenciaVigente devuelve el id del acceso vigente del empleado al paciente (vacío si no hay)
+func AccesoEmergenciaVigente(idEmpleado string, idPaciente int) (string, error) {
+	return almacen.AccesoEmergenciaVigente(idEmpleado, idPaciente)
+}
+
+// ListarAccesosEmergenciaEmpleado devuelve los accesos vigentes del empleado
+func ListarAccesosEmergenciaEmpleado(idEmpleado string) ([]AccesoEmergencia, error) {
+	return almacen.AccesosEmergenciaVigentes(idEmpleado, 100)
+}
+
+// ListarAccesosEmergenciaRevision devuelve la cola de revisión (solo pendientes o todos)
+func ListarAccesosEmergenciaRevision(soloPendientes bool, limite int) ([]AccesoEmergencia, error) {
+	return almacen.AccesosEmergenciaRevision(soloPendientes, limite)
+}
+
+// RevisarAccesoEmergencia marca el acceso como revisado por el supervisor. Con
+// revocar también termina el acceso si aún estaba vigente.
+func RevisarAccesoEmergencia(id string, supervisor Principal, comentario string, revocar bool) (*AccesoEmergencia, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrAccesoEmergenciaNoEncontrado
+	}
+	a, err := almacen.BuscarAccesoEmergencia(id)
+	if err != nil {
+		return nil, err
+	}
+	if a.IDEmpleado == supervisor.ID && supervisor.Tipo == "empleado" {
+		return nil, ErrAccesoEmergenciaPropio
+	}
+
+	if err := almacen.MarcarAccesoEmergenciaRevisado(id, supervisor.Correo, comentario, revocar); err != nil {
+		return nil, err
+	}
+	return almacen.BuscarAccesoEmergencia(id)
+}
+
+// PacienteDeExpediente devuelve el paciente dueño del expediente
+func PacienteDeExpediente(idExpediente int) (int, error) {
+	return almacen.PacienteDeExpediente(idExpediente)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// RegistrarEventoSeguridad guarda un evento para que seguridad pueda revisarlo
+// (bloqueos, reutilización de tokens, etc.). Los errores solo se registran en el log.
+func RegistrarEventoSeguridad(tipo, correo, ip string, detalle map[string]interface{}) {
+	err := almacen.GuardarEventoSeguridad(EventoSeguridad{Tipo: tipo, Correo: correo, IP: ip, Detalle: detalle, CreadoEn: time.Now()})
+	if err != nil {
+		log.Printf("⚠️ Error registrando evento de seguridad %s: %v", tipo, err)
+	}
+}
+
+// EventoSeguridad es un registro de la tabla eventos_seguridad
+type EventoSeguridad struct {
+	ID       int64                  `json:"id"`
+	Tipo     string                 `json:"tipo"`
+	Correo   string                 `json:"correo"`
+	IP       string                 `json:"ip"`
+	Detalle  map[string]interface{} `json:"detalle"`
+	CreadoEn time.Time              `json:"creado_en"`
+}
+
+// ListarEventosSeguridad devuelve los eventos más recientes, opcionalmente filtrados por tipo y correo
+func ListarEventosSeguridad(tipo, correo string, limite int) ([]EventoSeguridad, error) {
+	return almacen.EventosSeguridad(tipo, strings.ToLower(correo), limite)
+}
@@ -1,13 +1,123 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"back-menchaca/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
-	func HashPassword(password string) (string, error) {
-		bytes, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hash de contraseñas. El algoritmo de un hash guardado se reconoce por su
+// prefijo ($argon2id$ o $2a$/$2b$/$2y$ de bcrypt), así conviven hashes viejos y
+// nuevos. Los hashes nuevos usan PASSWORD_HASH_ALGO (argon2id por defecto) y
+// VerifyPassword indica cuándo conviene volver a calcular uno desactualizado.
+
+const bcryptCost = 12
+
+type argon2Params struct {
+	memoria     uint32 // KiB
+	iteraciones uint32
+	paralelismo uint8
+}
+
+func argon2Config() argon2Params {
+	s := config.Actual().Seguridad
+	return argon2Params{
+		memoria:     uint32(s.Argon2MemoriaKB),
+		iteraciones: uint32(s.Argon2Iteraciones),
+		paralelismo: uint8(s.Argon2Paralelismo),
+	}
+}
+
+func algoritmoHash() string {
+	if config.Actual().Seguridad.PasswordHashAlgo == "bcrypt" {
+		return "bcrypt"
+	}
+	return "argon2id"
+}
+
+// HashPassword calcula el hash con el algoritmo configurado
+func HashPassword(password string) (string, error) {
+	if algoritmoHash() == "bcrypt" {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 		return string(bytes), err
 	}
+	return hashArgon2id(password, argon2Config())
+}
+
+// VerifyPassword compara la contraseña con el hash guardado. needsRehash es true
+// cuando la contraseña es correcta pero el hash usa otro algoritmo o parámetros.
+func VerifyPassword(password, hash string) (ok bool, needsRehash bool) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, err := verificarArgon2id(password, hash)
+		if err != nil {
+			return false, false
+		}
+		return true, algoritmoHash() != "argon2id" || params != argon2Config()
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return false, false
+		}
+		cost, _ := bcrypt.Cost([]byte(hash))
+		return true, algoritmoHash() != "bcrypt" || cost != bcryptCost
+	default:
+		return false, false
+	}
+}
 
+// CheckPasswordHash indica si la contraseña corresponde al hash
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	ok, _ := VerifyPassword(password, hash)
+	return ok
+}
+
+func hashArgon2id(password string, p argon2Params) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.iteraciones, p.memoria, p.paralelismo, 32)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.memoria, p.iteraciones, p.paralelismo,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verificarArgon2id valida un hash en formato PHC y devuelve los parámetros con que se generó
+func verificarArgon2id(password, hash string) (argon2Params, error) {
+	partes := strings.Split(hash, "$")
+	if len(partes) != 6 {
+		return argon2Params{}, errors.New("hash argon2id inválido")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(partes[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2Params{}, errors.New("versión de argon2 no soportada")
+	}
+
+	var p argon2Params
+	if _, err := fmt.Sscanf(partes[3], "m=%d,t=%d,p=%d", &p.memoria, &p.iteraciones, &p.paralelismo); err != nil {
+		return argon2Params{}, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(partes[4])
+	if err != nil {
+		return argon2Params{}, err
+	}
+	esperado, err := base64.RawStdEncoding.DecodeString(partes[5])
+	if err != nil {
+		return argon2Params{}, err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.iteraciones, p.memoria, p.paralelismo, uint32(len(esperado)))
+	if subtle.ConstantTimeCompare(key, esperado) != 1 {
+		return argon2Params{}, errors.New("contraseña incorrecta")
+	}
+	return p, nil
 }
@@ -1,73 +1,95 @@
 package utils
 
 import (
-	"os"
 	"time"
-	"back-menchaca/config"
-	"log"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-func GetPermisosPorRol(rol string) ([]string, error) {
-	rows, err := config.DB.Query(`
-		SELECT permiso FROM permisos WHERE rol = $1
-	`, rol)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// AccessTokenTTL es la vigencia de los access tokens de usuario
+const AccessTokenTTL = 60 * time.Minute
 
-	var permisos []string
-	for rows.Next() {
-		var permiso string
-		if err := rows.Scan(&permiso); err != nil {
-			log.Println("Error scan permisos:", err)
-			continue
-		}
-		permisos = append(permisos, permiso)
-	}
+// GetPermisosPorRol devuelve los permisos del rol según la política cargada
+func GetPermisosPorRol(rol string) ([]string, error) {
+	return PoliticaActual().PermisosDeRol(rol), nil
+}
 
-	return permisos, nil
+// GenerateJWT genera el access token del principal con los permisos de su rol activo
+func GenerateJWT(p Principal) (string, error) {
+    return generarAccessToken(p, nil)
 }
-func GenerateJWT(id string, email string, rol string) (string, error) {
-    permisos, err := GetPermisosPorRol(rol)
+
+// generarAccessToken agrega a los claims del access token los claims extra indicados
+func generarAccessToken(p Principal, extra jwt.MapClaims) (string, error) {
+    permisos, err := GetPermisosPorRol(p.Rol)
     if err != nil {
         return "", err
     }
 
-    claims := jwt.MapClaims{
-        "id": id,
-        "email": email,
-        "rol": rol,
-        "permisos": permisos,
-        "exp": time.Now().Add(60 * time.Minute).Unix(),
+    ahora := time.Now()
+    // Un token emitido justo después de cerrar todas las sesiones no debe quedar revocado
+    if desde := revocadoDesdeLocal(p.ID, p.Rol); ahora.Before(desde) {
+        ahora = desde
+    }
+    claims := p.claims()
+    claims["typ"] = "access"
+    claims["jti"] = uuid.NewString()
+    claims["permisos"] = permisos
+    claims["iat"] = ahora.Unix()
+    claims["exp"] = ahora.Add(AccessTokenTTL).Unix()
+    for k, v := range extra {
+        claims[k] = v
     }
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+    return signToken(claims)
 }
 
+// TipoUsuario indica en qué tabla vive la cuenta: "paciente", "empleado" o
+// "servicio" (cuentas de servicio)
+func TipoUsuario(rol string) string {
+	switch rol {
+	case "paciente", RolServicio:
+		return rol
+	}
+	return "empleado"
+}
 
+// tokenDeUso genera un token de vida corta que solo sirve para un paso del login
+func tokenDeUso(p Principal, tipo string, duracion time.Duration) (string, error) {
+	claims := p.claims()
+	claims["typ"] = tipo
+	claims["jti"] = uuid.NewString()
+	claims["iat"] = time.Now().Unix()
+	claims["exp"] = time.Now().Add(duracion).Unix()
+	return signToken(claims)
+}
 
+// GenerateTempToken genera un token temporal para verificación MFA
+func GenerateTempToken(p Principal) (string, error) {
+	return tokenDeUso(p, "mfa", 5*time.Minute)
+}
 
-func GenerateRefreshToken(id string, email, rol string) (string, error) {
-    claims := jwt.MapClaims{
-        "id": id,
-		"email": email,
-		"rol":   rol,
-		"exp":   time.Now().Add(7 * 24 * time.Hour).Unix(), 
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("REFRESH_SECRET")))
+// GenerateEnrollToken genera un token que solo sirve para configurar MFA
+// (usuarios cuyo rol exige MFA y aún no lo han activado)
+func GenerateEnrollToken(p Principal) (string, error) {
+	return tokenDeUso(p, "mfa_enroll", 10*time.Minute)
 }
-// GenerateTempToken genera un token temporal para verificación MFA
-func GenerateTempToken(email, rol string) (string, error) {
+
+// GeneratePasswordChangeToken genera un token que solo sirve para cambiar una
+// contraseña expirada por la política de rotación
+func GeneratePasswordChangeToken(p Principal) (string, error) {
+	return tokenDeUso(p, "pwd_change", 10*time.Minute)
+}
+
+// GenerateEmailVerifyToken genera el token del enlace de verificación de correo.
+// Incluye el correo para que el enlace deje de servir si la cuenta lo cambia.
+func GenerateEmailVerifyToken(identidadID, email string) (string, error) {
 	claims := jwt.MapClaims{
+		"typ":   "email_verify",
+		"jti":   uuid.NewString(),
+		"idn":   identidadID,
 		"email": email,
-		"rol":   rol,
-		"exp":   time.Now().Add(5 * time.Minute).Unix(), // 
+		"exp":   time.Now().Add(24 * time.Hour).Unix(),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("TEMP_SECRET")))
+	return signToken(claims)
 }
-
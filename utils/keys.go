@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"back-menchaca/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Llaves de firma de los JWT. Se cargan desde el directorio JWT_KEYS_DIR, un
+// archivo PEM por llave cuyo nombre (sin extensión) es el kid. Las llaves
+// privadas firman y verifican; las públicas solo verifican, lo que permite
+// retirar una llave vieja sin invalidar los tokens que ya emitió.
+
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer // nil si solo se tiene la llave pública
+	public  crypto.PublicKey
+}
+
+var (
+	keysMu     sync.RWMutex
+	signingKey *jwtKey
+	verifyKeys = map[string]*jwtKey{}
+)
+
+// LoadJWTKeys carga las llaves de JWT_KEYS_DIR y selecciona la llave activa
+// (JWT_ACTIVE_KID o, si no se indica, la última llave privada en orden alfabético).
+func LoadJWTKeys() error {
+	dir := config.Actual().JWT.KeysDir
+	if dir == "" {
+		log.Println("⚠️ JWT_KEYS_DIR no configurado, se usará una llave Ed25519 temporal (los tokens no sobreviven a un reinicio)")
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return err
+		}
+		k := &jwtKey{kid: "temporal", method: jwt.SigningMethodEdDSA, private: priv, public: priv.Public()}
+		keysMu.Lock()
+		signingKey = k
+		verifyKeys = map[string]*jwtKey{k.kid: k}
+		keysMu.Unlock()
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	keys := map[string]*jwtKey{}
+	var ultimaPrivada *jwtKey
+	for _, f := range files {
+		k, err := parseKeyFile(f)
+		if err != nil {
+			return fmt.Errorf("llave %s: %w", f, err)
+		}
+		keys[k.kid] = k
+		if k.private != nil {
+			ultimaPrivada = k
+		}
+	}
+
+	activa := ultimaPrivada
+	if kid := config.Actual().JWT.ActiveKID; kid != "" {
+		activa = keys[kid]
+		if activa == nil || activa.private == nil {
+			return fmt.Errorf("la llave activa %q no existe o no es privada", kid)
+		}
+	}
+	if activa == nil {
+		return errors.New("no hay llaves privadas en " + dir)
+	}
+
+	keysMu.Lock()
+	signingKey = activa
+	verifyKeys = keys
+	keysMu.Unlock()
+
+	log.Printf("🔑 %d llaves JWT cargadas, llave activa: %s", len(keys), activa.kid)
+	return nil
+}
+
+func parseKeyFile(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("archivo PEM inválido")
+	}
+
+	k := &jwtKey{kid: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("tipo de bloque PEM no soportado: %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, key, key.Public()
+	case *rsa.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodRS256, key, key.Public()
+	case ed25519.PublicKey:
+		k.method, k.public = jwt.SigningMethodEdDSA, key
+	case *rsa.PublicKey:
+		k.method, k.public = jwt.SigningMethodRS256, key
+	default:
+		return nil, errors.New("solo se soportan llaves Ed25519 y RSA")
+	}
+	return k, nil
+}
+
+// signToken firma los claims con la llave activa e incluye su kid en el encabezado
+func signToken(claims jwt.MapClaims) (string, error) {
+	keysMu.RLock()
+	k := signingKey
+	keysMu.RUnlock()
+	if k == nil {
+		return "", errors.New("no hay llave de firma cargada")
+	}
+
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	return token.SignedString(k.private)
+}
+
+// ParseToken valida la firma (eligiendo la llave por kid), la expiración y el tipo del token
+func ParseToken(tokenStr string, tipo string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		keysMu.RLock()
+		k := verifyKeys[kid]
+		keysMu.RUnlock()
+		if k == nil {
+			return nil, fmt.Errorf("kid desconocido: %q", kid)
+		}
+		if t.Method.Alg() != k.method.Alg() {
+			return nil, fmt.Errorf("método de firma inesperado: %v", t.Header["alg"])
+		}
+		return k.public, nil
+	}, jwt.WithValidMethods([]string{"EdDSA", "RS256"}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("claims inválidos")
+	}
+	if typ, _ := claims["typ"].(string); typ != tipo {
+		return nil, fmt.Errorf("tipo de token inesperado: %q", typ)
+	}
+	return claims, nil
+}
+
+// JWKS devuelve las llaves públicas de verificación en formato JSON Web Key Set
+func JWKS() map[string]interface{} {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	kids := make([]string, 0, len(verifyKeys))
+	for kid := range verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := []map[string]string{}
+	for _, kid := range kids {
+		k := verifyKeys[kid]
+		jwk := map[string]string{"kid": k.kid, "use": "sig", "alg": k.method.Alg()}
+		switch pub := k.public.(type) {
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		keys = append(keys, jwk)
+	}
+	return map[string]interface{}{"keys": keys}
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"back-menchaca/config"
+	"math"
+	"strings"
+	"time"
+)
+
+// Protección contra fuerza bruta en el login. Los fallos se cuentan por correo
+// (con espera progresiva y bloqueo temporal al llegar a LOGIN_MAX_FALLOS) y por
+// IP (LOGIN_MAX_FALLOS_IP dentro de la ventana de bloqueo).
+
+const fallosAntesDeEspera = 3
+
+// CuentaBloqueada describe un correo con intentos fallidos recientes
+type CuentaBloqueada struct {
+	Correo         string     `json:"correo"`
+	Fallos         int        `json:"fallos"`
+	UltimoFallo    time.Time  `json:"ultimo_fallo"`
+	BloqueadoHasta *time.Time `json:"bloqueado_hasta"`
+}
+
+func duracionBloqueo() time.Duration {
+	return time.Duration(config.Actual().Seguridad.LoginBloqueoMinutos) * time.Minute
+}
+
+// esperaProgresiva devuelve cuánto debe esperar un correo tras n fallos consecutivos
+func esperaProgresiva(fallos int) time.Duration {
+	if fallos < fallosAntesDeEspera {
+		return 0
+	}
+	segundos := math.Pow(2, float64(fallos-fallosAntesDeEspera))
+	return time.Duration(math.Min(segundos, 60)) * time.Second
+}
+
+// VerificarIntentoLogin indica si se permite intentar iniciar sesión. Si no se
+// permite devuelve hasta cuándo esperar y si la causa es un bloqueo de la cuenta.
+func VerificarIntentoLogin(correo, ip string) (time.Time, bool, error) {
+	correo = strings.ToLower(correo)
+	ahora := time.Now()
+
+	bloqueo, err := almacen.BloqueoLogin(correo)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	if bloqueo != nil {
+		if bloqueo.BloqueadoHasta != nil && ahora.Before(*bloqueo.BloqueadoHasta) {
+			return *bloqueo.BloqueadoHasta, true, nil
+		}
+		if siguiente := bloqueo.UltimoFallo.Add(esperaProgresiva(bloqueo.Fallos)); ahora.Before(siguiente) {
+			return siguiente, false, nil
+		}
+	}
+
+	fallosIP, primero, err := almacen.FallosLoginIP(ip, ahora.Add(-duracionBloqueo()))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if fallosIP >= config.Actual().Seguridad.LoginMaxFallosIP && !primero.IsZero() {
+		return primero.Add(duracionBloqueo()), false, nil
+	}
+
+	return time.Time{}, false, nil
+}
+
+// RegistrarFalloLogin cuenta un intento fallido y bloquea la cuenta al llegar al límite.
+// Si el intento provocó el bloqueo devuelve hasta cuándo dura.
+func RegistrarFalloLogin(correo, ip string) (time.Time, error) {
+	correo = strings.ToLower(correo)
+
+	if err := almacen.RegistrarIntentoLogin(correo, ip, false); err != nil {
+		return time.Time{}, err
+	}
+
+	fallos, err := almacen.SumarFalloLogin(correo)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if fallos < config.Actual().Seguridad.LoginMaxFallos {
+		return time.Time{}, nil
+	}
+
+	hasta := time.Now().Add(duracionBloqueo())
+	if err := almacen.BloquearLogin(correo, hasta); err != nil {
+		return time.Time{}, err
+	}
+	RegistrarEventoSeguridad("cuenta_bloqueada", correo, ip, map[string]interface{}{
+		"fallos":          fallos,
+		"bloqueado_hasta": hasta,
+	})
+	return hasta, nil
+}
+
+// RegistrarLoginExitoso guarda el intento y reinicia el contador del correo
+func RegistrarLoginExitoso(correo, ip string) error {
+	correo = strings.ToLower(correo)
+	if err := almacen.RegistrarIntentoLogin(correo, ip, true); err != nil {
+		return err
+	}
+	_, err := almacen.EliminarBloqueoLogin(correo)
+	return err
+}
+
+// ListarCuentasBloqueadas devuelve las cuentas bloqueadas actualmente
+func ListarCuentasBloqueadas() ([]CuentaBloqueada, error) {
+	return almacen.BloqueosVigentes()
+}
+
+// DesbloquearCuenta elimina el bloqueo y el contador de fallos de un correo
+func DesbloquearCuenta(correo string) (bool, error) {
+	return almacen.EliminarBloqueoLogin(strings.ToLower(correo))
+}
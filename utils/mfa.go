@@ -1,10 +1,16 @@
 package utils
 
 import (
+	"back-menchaca/config"
+	"crypto/subtle"
+	"time"
+
 	"github.com/pquerna/otp"
 	"github.com/pquerna/otp/totp"
 )
 
+const totpPeriodo = 30
+
 // GenerateMFASecret genera un nuevo secreto TOTP para un usuario
 func GenerateMFASecret(email string) (*otp.Key, error) {
 	return totp.Generate(totp.GenerateOpts{
@@ -19,3 +25,64 @@ func ValidateTOTP(token string, secret string) bool {
 	return totp.Validate(token, secret)
 }
 
+// ValidarTOTPPaso valida un código TOTP (con tolerancia de un periodo) y devuelve
+// el paso de tiempo al que corresponde, para poder rechazar su reutilización
+func ValidarTOTPPaso(token string, secret string, t time.Time) (int64, bool) {
+	if len(token) != 6 || secret == "" {
+		return 0, false
+	}
+	actual := t.Unix() / totpPeriodo
+	for _, desfase := range []int64{0, -1, 1} {
+		paso := actual + desfase
+		codigo, err := totp.GenerateCodeCustom(secret, time.Unix(paso*totpPeriodo, 0), totp.ValidateOpts{
+			Period:    totpPeriodo,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(codigo), []byte(token)) == 1 {
+			return paso, true
+		}
+	}
+	return 0, false
+}
+
+// MaxIntentosMFA es el número de códigos fallidos permitidos por token temporal (MFA_MAX_INTENTOS, 5 por defecto)
+func MaxIntentosMFA() int {
+	return config.Actual().Seguridad.MFAMaxIntentos
+}
+
+// RegistrarFalloMFA suma un intento fallido al token temporal y devuelve el total
+func RegistrarFalloMFA(jti string, expira time.Time) (int, error) {
+	return almacen.SumarFalloMFA(jti, expira)
+}
+
+// MFAObligatorio indica si la política exige MFA para el rol.
+// Se configura con MFA_REQUIRED_ROLES (lista separada por comas);
+// por defecto es obligatorio para doctores y administradores.
+func MFAObligatorio(rol string) bool {
+	for _, r := range config.Actual().Seguridad.MFARolesObligatorios {
+		if r == rol {
+			return true
+		}
+	}
+	return false
+}
+
+// MFAObligatorioCuenta indica si algún rol de la identidad exige MFA
+func MFAObligatorioCuenta(c *Cuenta) bool {
+	for _, r := range c.Roles {
+		if MFAObligatorio(r.Rol) {
+			return true
+		}
+	}
+	return false
+}
+
+// VentanaStepUp es la antigüedad máxima del segundo factor para operaciones
+// sensibles (STEP_UP_MINUTOS, 5 por defecto)
+func VentanaStepUp() time.Duration {
+	return time.Duration(config.Actual().Seguridad.StepUpMinutos) * time.Minute
+}
@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"back-menchaca/config"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Proveedor OAuth 2.0 / OpenID Connect. Los clientes registrados viven en
+// oauth_clientes; el flujo soportado es authorization code con PKCE (S256).
+// Los códigos de autorización son opacos, de un solo uso y se guardan con hash.
+
+const CodigoAutorizacionTTL = 2 * time.Minute
+
+var (
+	ErrClienteOAuthInvalido = errors.New("cliente OAuth inválido")
+	ErrCodigoInvalido       = errors.New("código de autorización inválido o expirado")
+)
+
+// ScopesSoportados son los scopes que entiende el proveedor
+var ScopesSoportados = []string{"openid", "profile", "email", "roles"}
+
+// ClienteOAuth es una aplicación registrada que puede pedir tokens
+type ClienteOAuth struct {
+	ClientID     string    `json:"client_id"`
+	Nombre       string    `json:"nombre"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Publico      bool      `json:"publico"` // sin secreto (SPA o app nativa), solo con PKCE
+	CreadoEn     time.Time `json:"creado_en"`
+	SecretoHash  string    `json:"-"` // vacío para clientes públicos
+}
+
+// CodigoAutorizacion es la información guardada con un código emitido
+type CodigoAutorizacion struct {
+	ClientID    string
+	IdentidadID string
+	Rol         string
+	RedirectURI string
+	Scope       string
+	Nonce       string
+	Challenge   string
+	AuthTime    time.Time
+}
+
+// IssuerOIDC es el identificador del proveedor (OIDC_ISSUER)
+func IssuerOIDC() string {
+	return strings.TrimSuffix(config.Actual().OIDC.Issuer, "/")
+}
+
+// BuscarClienteOAuth carga un cliente registrado
+func BuscarClienteOAuth(clientID string) (*ClienteOAuth, error) {
+	return almacen.ObtenerClienteOAuth(clientID)
+}
+
+// RedirectURIValida exige coincidencia exacta con una de las URIs registradas
+func (cl *ClienteOAuth) RedirectURIValida(uri string) bool {
+	for _, u := range cl.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// VerificarSecreto valida el secreto de un cliente confidencial. Los clientes
+// públicos no tienen secreto y solo se autentican con PKCE.
+func (cl *ClienteOAuth) VerificarSecreto(secreto string) bool {
+	if cl.Publico {
+		return secreto == ""
+	}
+	return secreto != "" && subtle.ConstantTimeCompare([]byte(HashToken(secreto)), []byte(cl.SecretoHash)) == 1
+}
+
+// FiltrarScopes devuelve los scopes pedidos que el cliente tiene permitidos
+func (cl *ClienteOAuth) FiltrarScopes(scope string) []string {
+	permitidos := map[string]bool{}
+	for _, s := range cl.Scopes {
+		permitidos[s] = true
+	}
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if permitidos[s] {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// CrearClienteOAuth registra un cliente. Devuelve el secreto en claro (vacío
+// para clientes públicos); es la única vez que se puede mostrar.
+func CrearClienteOAuth(nombre string, redirectURIs, scopes []string, publico bool) (*ClienteOAuth, string, error) {
+	cl := &ClienteOAuth{
+		ClientID:     uuid.NewString(),
+		Nombre:       nombre,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		Publico:      publico,
+		CreadoEn:     time.Now(),
+	}
+
+	var secreto string
+	if !publico {
+		var err error
+		if secreto, err = GenerarTokenOpaco(); err != nil {
+			return nil, "", err
+		}
+		cl.SecretoHash = HashToken(secreto)
+	}
+
+	if err := almacen.GuardarClienteOAuth(cl); err != nil {
+		return nil, "", err
+	}
+	return cl, secreto, nil
+}
+
+// ListarClientesOAuth devuelve los clientes registrados (sin secretos)
+func ListarClientesOAuth() ([]ClienteOAuth, error) {
+	return almacen.ClientesOAuth()
+}
+
+// EliminarClienteOAuth elimina un cliente y los consentimientos que tenía
+func EliminarClienteOAuth(clientID string) (bool, error) {
+	return almacen.BorrarClienteOAuth(clientID)
+}
+
+// ScopesConsentidos devuelve los scopes que la identidad autorizó al cliente
+func ScopesConsentidos(identidadID, clientID string) ([]string, error) {
+	return almacen.ConsentimientoOAuth(identidadID, clientID)
+}
+
+// TieneConsentimiento indica si la identidad ya autorizó todos los scopes para el cliente
+func TieneConsentimiento(identidadID, clientID string, scopes []string) (bool, error) {
+	otorgados, err := ScopesConsentidos(identidadID, clientID)
+	if err != nil {
+		return false, err
+	}
+
+	set := map[string]bool{}
+	for _, s := range otorgados {
+		set[s] = true
+	}
+	for _, s := range scopes {
+		if !set[s] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// GuardarConsentimiento registra los scopes que la identidad autorizó al cliente
+func GuardarConsentimiento(identidadID, clientID string, scopes []string) error {
+	return almacen.GuardarConsentimientoOAuth(identidadID, clientID, scopes)
+}
+
+// CrearCodigoAutorizacion emite un código de un solo uso para el cliente
+func CrearCodigoAutorizacion(ca CodigoAutorizacion) (string, error) {
+	codigo, err := GenerarTokenOpaco()
+	if err != nil {
+		return "", err
+	}
+
+	if err := almacen.GuardarCodigoAutorizacion(HashToken(codigo), ca, time.Now().Add(CodigoAutorizacionTTL)); err != nil {
+		return "", err
+	}
+	return codigo, nil
+}
+
+// ConsumirCodigoAutorizacion marca el código como usado y devuelve su información
+func ConsumirCodigoAutorizacion(codigo string) (*CodigoAutorizacion, error) {
+	return almacen.ConsumirCodigoAutorizacion(HashToken(codigo))
+}
+
+// VerificarPKCE compara el code_verifier con el code_challenge S256 guardado
+func VerificarPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	calculado := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(calculado), []byte(challenge)) == 1
+}
+
+// TipoTokenOAuth es el typ de los access tokens emitidos a clientes OAuth. Solo
+// sirven en /oauth/userinfo: las rutas de /api exigen typ access.
+const TipoTokenOAuth = "oauth_access"
+
+// GenerateOAuthAccessToken genera un access token emitido a un cliente OAuth
+func GenerateOAuthAccessToken(p Principal, clientID, scope string) (string, error) {
+	return generarAccessToken(p, jwt.MapClaims{
+		"typ":       TipoTokenOAuth,
+		"iss":       IssuerOIDC(),
+		"client_id": clientID,
+		"scope":     scope,
+	})
+}
+
+// GenerateIDToken genera el id_token de OpenID Connect para el cliente
+func GenerateIDToken(c *Cuenta, ca *CodigoAutorizacion) (string, error) {
+	ahora := time.Now()
+	claims := jwt.MapClaims{
+		"typ":       "id",
+		"iss":       IssuerOIDC(),
+		"sub":       c.IdentidadID,
+		"aud":       ca.ClientID,
+		"iat":       ahora.Unix(),
+		"exp":       ahora.Add(60 * time.Minute).Unix(),
+		"auth_time": ca.AuthTime.Unix(),
+	}
+	if ca.Nonce != "" {
+		claims["nonce"] = ca.Nonce
+	}
+	for k, v := range ClaimsUserInfo(c, strings.Fields(ca.Scope)) {
+		claims[k] = v
+	}
+	return signToken(claims)
+}
+
+// ClaimsUserInfo devuelve los claims de la identidad que cubren los scopes
+func ClaimsUserInfo(c *Cuenta, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{"sub": c.IdentidadID}
+	for _, s := range scopes {
+		switch s {
+		case "email":
+			claims["email"] = c.Correo
+			claims["email_verified"] = c.CorreoVerificado || c.EsPersonal()
+		case "roles":
+			claims["rol"] = c.Rol
+			claims["roles"] = c.NombresRoles()
+		case "profile":
+			if nombre, err := nombreDeRol(c.Rol, c.ID); err == nil {
+				claims["name"] = nombre
+			}
+		}
+	}
+	return claims
+}
+
+// nombreDeRol obtiene el nombre completo del paciente o empleado del rol activo
+func nombreDeRol(rol, id string) (string, error) {
+	return almacen.NombreUsuario(TipoUsuario(rol), id)
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"back-menchaca/config"
+	"errors"
+	"time"
+)
+
+// Historial de contraseñas y política de rotación. Se conservan los hashes de
+// las últimas PASSWORD_HISTORY contraseñas (incluida la actual) y el personal
+// debe cambiar la suya cada PASSWORD_MAX_DIAS_EMPLEADO días.
+
+var ErrContrasenaReutilizada = errors.New("la contraseña ya fue usada recientemente")
+
+func tamanoHistorial() int {
+	return config.Actual().Seguridad.PasswordHistory
+}
+
+// ContrasenaEnHistorial indica si la contraseña coincide con la actual o con alguna de las anteriores
+func ContrasenaEnHistorial(c *Cuenta, contrasena string) (bool, error) {
+	if CheckPasswordHash(contrasena, c.Hash) {
+		return true, nil
+	}
+
+	anteriores, err := almacen.HistorialContrasenas(c.IdentidadID, tamanoHistorial()-1)
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range anteriores {
+		if CheckPasswordHash(contrasena, hash) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ActualizarContrasena valida la contraseña contra el historial y la guarda.
+// La contraseña anterior pasa al historial y se descartan las más viejas.
+func ActualizarContrasena(c *Cuenta, contrasena string) error {
+	usada, err := ContrasenaEnHistorial(c, contrasena)
+	if err != nil {
+		return err
+	}
+	if usada {
+		return ErrContrasenaReutilizada
+	}
+
+	hash, err := HashPassword(contrasena)
+	if err != nil {
+		return err
+	}
+
+	if err := almacen.CambiarContrasena(c.IdentidadID, c.Hash, hash, tamanoHistorial()-1); err != nil {
+		return err
+	}
+	c.Hash = hash
+	c.ContrasenaActualizadaEn = time.Now()
+	return nil
+}
+
+// ContrasenaExpirada indica si la cuenta debe cambiar su contraseña por la política de rotación.
+// Solo aplica a identidades con algún rol de personal; si no se conoce la fecha
+// del último cambio no se exige.
+func ContrasenaExpirada(c *Cuenta) bool {
+	if !c.EsPersonal() || c.ContrasenaActualizadaEn.IsZero() {
+		return false
+	}
+	maxDias := config.Actual().Seguridad.PasswordMaxDiasEmpleado
+	return time.Since(c.ContrasenaActualizadaEn) > time.Duration(maxDias)*24*time.Hour
+}
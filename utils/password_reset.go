@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// Tokens de restablecimiento de contraseña: opacos, de un solo uso y de vida
+// corta. Como los refresh tokens, en la BD solo se guarda su hash.
+
+const ResetTokenTTL = 30 * time.Minute
+
+var ErrResetInvalido = errors.New("token de restablecimiento inválido o expirado")
+
+// GenerarTokenReset emite un token de restablecimiento e invalida los anteriores de la cuenta
+func GenerarTokenReset(c *Cuenta) (string, error) {
+	token, err := GenerarTokenOpaco()
+	if err != nil {
+		return "", err
+	}
+
+	if err := almacen.GuardarTokenReset(c.IdentidadID, HashToken(token), time.Now().Add(ResetTokenTTL)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumirTokenReset marca el token como usado y devuelve la cuenta a la que pertenece
+func ConsumirTokenReset(token string) (*Cuenta, error) {
+	identidadID, err := almacen.ConsumirTokenReset(HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	return ObtenerCuenta(identidadID, "")
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"back-menchaca/config"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Motor de autorización. La política se arma con la tabla permisos: cada fila
+// asigna un permiso a un rol y, si tiene metodo y ruta, limita ese permiso del
+// rol a las solicitudes que coinciden (permitido = false niega explícitamente;
+// sin ruta niega cualquiera). Las reglas de un rol no afectan a los demás roles
+// con el mismo permiso. Las rutas declaran en routes/ el permiso que requieren
+// con JWTProtected(...).
+//
+// La política vive en memoria y se recarga cada POLITICA_RECARGA_SEGUNDOS o al
+// llamar RecargarPolitica, así que los cambios no requieren reiniciar.
+//
+// Patrones de ruta: segmentos literales (sin distinguir mayúsculas), ":param"
+// para un segmento y "*" (o "%" por compatibilidad con los patrones LIKE
+// anteriores) al final para cualquier resto. Método vacío o "*" aplica a todos.
+
+// clavePermiso identifica las reglas de ruta de un permiso en un rol
+type clavePermiso struct {
+	rol     string
+	permiso string
+}
+
+type reglaRuta struct {
+	metodo    string
+	ruta      string
+	segmentos []string
+	permitido bool
+}
+
+// Politica es una versión inmutable de la política cargada
+type Politica struct {
+	roles     map[string]map[string]bool // rol -> permisos
+	reglas    map[clavePermiso][]reglaRuta
+	CargadaEn time.Time
+}
+
+var (
+	politicaActual     atomic.Pointer[Politica]
+	politicaRecargaMu  sync.Mutex
+	permisosDeclarados sync.Map
+)
+
+// PoliticaActual devuelve la última política cargada (vacía si aún no se carga)
+func PoliticaActual() *Politica {
+	if p := politicaActual.Load(); p != nil {
+		return p
+	}
+	return &Politica{roles: map[string]map[string]bool{}, reglas: map[clavePermiso][]reglaRuta{}}
+}
+
+// RecargarPolitica vuelve a leer las asignaciones de permisos
+func RecargarPolitica() error {
+	politicaRecargaMu.Lock()
+	defer politicaRecargaMu.Unlock()
+
+	asignaciones, err := almacen.AsignacionesPermisos()
+	if err != nil {
+		return err
+	}
+
+	p := &Politica{
+		roles:     map[string]map[string]bool{},
+		reglas:    map[clavePermiso][]reglaRuta{},
+		CargadaEn: time.Now(),
+	}
+	for _, a := range asignaciones {
+		if p.roles[a.Rol] == nil {
+			p.roles[a.Rol] = map[string]bool{}
+		}
+		// Una fila que solo niega no da el permiso al rol
+		if a.Permitido {
+			p.roles[a.Rol][a.Permiso] = true
+		}
+		if a.Ruta != "" || !a.Permitido {
+			patron := strings.ReplaceAll(a.Ruta, "%", "*")
+			if patron == "" {
+				patron = "*"
+			}
+			clave := clavePermiso{a.Rol, a.Permiso}
+			p.reglas[clave] = append(p.reglas[clave], reglaRuta{
+				metodo:    strings.ToUpper(strings.TrimSpace(a.Metodo)),
+				ruta:      a.Ruta,
+				segmentos: segmentosRuta(patron),
+				permitido: a.Permitido,
+			})
+		}
+	}
+
+	politicaActual.Store(p)
+	return nil
+}
+
+// IniciarPolitica carga la política y la recarga periódicamente
+func IniciarPolitica() error {
+	if err := RecargarPolitica(); err != nil {
+		return err
+	}
+	advertirPermisosSinRol()
+
+	intervalo := time.Duration(config.Actual().Seguridad.PoliticaRecargaSegundos) * time.Second
+	go func() {
+		for range time.Tick(intervalo) {
+			if err := RecargarPolitica(); err != nil {
+				log.Println("⚠️ Error recargando la política de permisos:", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// DeclararPermisos registra los permisos que exige alguna ruta, para avisar
+// al arrancar si alguno no está asignado a ningún rol
+func DeclararPermisos(permisos ...string) {
+	for _, p := range permisos {
+		permisosDeclarados.Store(p, true)
+	}
+}
+
+func advertirPermisosSinRol() {
+	pol := PoliticaActual()
+	var faltantes []string
+	permisosDeclarados.Range(func(k, _ interface{}) bool {
+		permiso := k.(string)
+		if len(pol.RolesConPermiso(permiso)) == 0 {
+			faltantes = append(faltantes, permiso)
+		}
+		return true
+	})
+	if len(faltantes) > 0 {
+		sort.Strings(faltantes)
+		log.Printf("⚠️ Permisos usados por rutas que ningún rol tiene: %s", strings.Join(faltantes, ", "))
+	}
+}
+
+// PermisosDeRol devuelve los permisos del rol según la política
+func (p *Politica) PermisosDeRol(rol string) []string {
+	permisos := make([]string, 0, len(p.roles[rol]))
+	for permiso := range p.roles[rol] {
+		permisos = append(permisos, permiso)
+	}
+	sort.Strings(permisos)
+	return permisos
+}
+
+// TienePermiso indica si el rol tiene el permiso, sin importar sus reglas de ruta
+func (p *Politica) TienePermiso(rol, permiso string) bool {
+	return p.roles[rol][permiso]
+}
+
+// Asignado indica si la tabla permisos tiene alguna fila del permiso para el
+// rol, aunque solo lo niegue
+func (p *Politica) Asignado(rol, permiso string) bool {
+	return p.roles[rol][permiso] || len(p.reglas[clavePermiso{rol, permiso}]) > 0
+}
+
+// RolesConPermiso devuelve los roles que tienen el permiso
+func (p *Politica) RolesConPermiso(permiso string) []string {
+	var roles []string
+	for rol, permisos := range p.roles {
+		if permisos[permiso] {
+			roles = append(roles, rol)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// RolesConReglas devuelve los roles que tienen reglas de ruta para el permiso
+func (p *Politica) RolesConReglas(permiso string) []string {
+	var roles []string
+	for clave := range p.reglas {
+		if clave.permiso == permiso {
+			roles = append(roles, clave.rol)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// ReglaPermiso es una regla de ruta de un permiso tal como está en la tabla
+type ReglaPermiso struct {
+	Metodo    string `json:"metodo,omitempty"`
+	Ruta      string `json:"ruta"`
+	Permitido bool   `json:"permitido"`
+}
+
+// ReglasDePermiso devuelve las reglas de ruta del permiso en el rol (vacío si
+// aplica a cualquier ruta)
+func (p *Politica) ReglasDePermiso(rol, permiso string) []ReglaPermiso {
+	clave := clavePermiso{rol, permiso}
+	reglas := make([]ReglaPermiso, 0, len(p.reglas[clave]))
+	for _, r := range p.reglas[clave] {
+		reglas = append(reglas, ReglaPermiso{Metodo: r.metodo, Ruta: r.ruta, Permitido: r.permitido})
+	}
+	return reglas
+}
+
+// PermisoDeclarado indica si alguna ruta exige el permiso
+func PermisoDeclarado(permiso string) bool {
+	_, ok := permisosDeclarados.Load(permiso)
+	return ok
+}
+
+// Autoriza indica si el sujeto puede hacer la solicitud (metodo, ruta) con
+// alguno de los permisos requeridos. Los permisos de un usuario son los de su
+// rol en la política vigente; las cuentas de servicio traen los suyos.
+func (p *Politica) Autoriza(rol string, permisosServicio map[string]bool, requeridos []string, metodo, ruta string) bool {
+	if len(requeridos) == 0 {
+		return true
+	}
+	permisos := p.roles[rol]
+	if rol == RolServicio {
+		permisos = permisosServicio
+	}
+
+	for _, req := range requeridos {
+		if permisos[req] && p.rutaPermitida(rol, req, metodo, ruta) {
+			return true
+		}
+	}
+	return false
+}
+
+// rutaPermitida aplica las reglas de ruta del permiso en el rol. Un permiso sin
+// reglas vale en cualquier ruta que lo declare; con reglas, alguna debe
+// coincidir y ninguna regla que coincida puede negarlo.
+func (p *Politica) rutaPermitida(rol, permiso, metodo, ruta string) bool {
+	reglas := p.reglas[clavePermiso{rol, permiso}]
+	if len(reglas) == 0 {
+		return true
+	}
+
+	segmentos := segmentosRuta(ruta)
+	permitido := false
+	for _, r := range reglas {
+		if r.metodo != "" && r.metodo != "*" && r.metodo != metodo {
+			continue
+		}
+		if !coincideRuta(r.segmentos, segmentos) {
+			continue
+		}
+		if !r.permitido {
+			return false
+		}
+		permitido = true
+	}
+	return permitido
+}
+
+func segmentosRuta(ruta string) []string {
+	ruta = strings.Trim(strings.TrimSpace(ruta), "/")
+	if ruta == "" {
+		return nil
+	}
+	return strings.Split(ruta, "/")
+}
+
+func coincideRuta(patron, ruta []string) bool {
+	for i, seg := range patron {
+		if seg == "*" && i == len(patron)-1 {
+			return true
+		}
+		if i >= len(ruta) {
+			return false
+		}
+		switch {
+		case seg == "*", strings.HasPrefix(seg, ":"):
+			continue
+		case strings.HasSuffix(seg, "*") && i == len(patron)-1:
+			return strings.HasPrefix(strings.ToLower(ruta[i]), strings.ToLower(strings.TrimSuffix(seg, "*")))
+		case !strings.EqualFold(seg, ruta[i]):
+			return false
+		}
+	}
+	return len(patron) == len(ruta)
+}
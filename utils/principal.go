@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Principal identifica a quién pertenece una sesión: la identidad (correo y
+// credenciales) y el rol con el que entró. Tipo e ID apuntan al registro de
+// Paciente o Empleado de ese rol.
+type Principal struct {
+	IdentidadID string
+	Correo      string
+	Tipo        string // "paciente" o "empleado"
+	ID          string // id_paciente o id_empleado
+	Rol         string // rol activo
+	Roles       []string
+
+	// AuthTime y AMR indican cuándo y con qué métodos se autenticó el usuario
+	// (claims auth_time y amr). Solo se incluyen en tokens emitidos al autenticarse.
+	AuthTime time.Time
+	AMR      []string
+}
+
+// Valores de amr (RFC 8176)
+const (
+	AMRContrasena = "pwd"
+	AMRCodigo     = "otp" // TOTP o código de recuperación
+	AMRLlave      = "hwk" // llave de seguridad WebAuthn
+	AMRMultiple   = "mfa"
+)
+
+// TieneSegundoFactor indica si la autenticación incluyó un segundo factor
+func (p Principal) TieneSegundoFactor() bool {
+	for _, m := range p.AMR {
+		if m == AMRMultiple {
+			return true
+		}
+	}
+	return false
+}
+
+// claims devuelve los claims comunes a todos los tokens del principal
+func (p Principal) claims() jwt.MapClaims {
+	claims := jwt.MapClaims{
+		"idn":   p.IdentidadID,
+		"tipo":  p.Tipo,
+		"id":    p.ID,
+		"email": p.Correo,
+		"rol":   p.Rol,
+		"roles": p.Roles,
+	}
+	if !p.AuthTime.IsZero() {
+		claims["auth_time"] = p.AuthTime.Unix()
+	}
+	if len(p.AMR) > 0 {
+		claims["amr"] = p.AMR
+	}
+	return claims
+}
+
+// PrincipalDesdeClaims reconstruye el principal guardado en un token. Un claim
+// ausente o de otro tipo queda vacío; quien necesite la identidad debe
+// rechazar el token si IdentidadID está vacío.
+func PrincipalDesdeClaims(claims jwt.MapClaims) Principal {
+	var p Principal
+	p.IdentidadID, _ = claims["idn"].(string)
+	p.ID, _ = claims["id"].(string)
+	p.Tipo, _ = claims["tipo"].(string)
+	p.Correo, _ = claims["email"].(string)
+	p.Rol, _ = claims["rol"].(string)
+	p.Roles = listaDeClaim(claims["roles"])
+	p.AMR = listaDeClaim(claims["amr"])
+	if authTime, ok := claims["auth_time"].(float64); ok {
+		p.AuthTime = time.Unix(int64(authTime), 0)
+	}
+	return p
+}
+
+func listaDeClaim(v interface{}) []string {
+	var lista []string
+	if valores, ok := v.([]interface{}); ok {
+		for _, r := range valores {
+			if s, ok := r.(string); ok {
+				lista = append(lista, s)
+			}
+		}
+	}
+	return lista
+}
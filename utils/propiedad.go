@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strconv"
+)
+
+// Propiedad de los registros de pacientes. Un paciente solo ve sus propios
+// registros; un empleado ve los de los pacientes que atiende o que tienen
+// consultas en su área, salvo que su rol tenga PermisoTodosLosPacientes.
+
+// PermisoTodosLosPacientes quita el límite por área a un rol de empleado
+const PermisoTodosLosPacientes = "ver_todos_los_pacientes"
+
+// EmpleadoAtiendePaciente indica si el paciente tiene alguna consulta con el
+// empleado o con otro empleado de su misma área
+func EmpleadoAtiendePaciente(idEmpleado string, idPaciente int) (bool, error) {
+	id, err := strconv.Atoi(idEmpleado)
+	if err != nil {
+		return false, nil
+	}
+	return almacen.EmpleadoAtiendePaciente(id, idPaciente)
+}
+
+// PacienteDeConsulta devuelve el paciente de la consulta
+func PacienteDeConsulta(idConsulta int) (int, error) {
+	return almacen.PacienteDeConsulta(idConsulta)
+}
+
+// PacienteDeReceta devuelve el paciente de la consulta en la que se emitió la receta
+func PacienteDeReceta(idReceta int) (int, error) {
+	return almacen.PacienteDeReceta(idReceta)
+}
+
+// PacienteDeAntecedente devuelve el paciente del expediente del antecedente
+func PacienteDeAntecedente(idAntecedente int) (int, error) {
+	return almacen.PacienteDeAntecedente(idAntecedente)
+}
+
+// PacienteDeHistorial devuelve el paciente del expediente de la entrada del historial
+func PacienteDeHistorial(idHistorial int) (int, error) {
+	return almacen.PacienteDeHistorial(idHistorial)
+}
@@ -0,0 +1,295 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Administración de roles y permisos. Los roles de empleado son los valores de
+// Empleado.tipo_empleado y se registran en la tabla roles; los permisos se
+// registran en catalogo_permisos y se asignan a los roles en la tabla permisos
+// (la que lee el motor de permisos). Cada cambio recarga la política.
+
+var (
+	ErrNombreInvalido       = errors.New("el nombre solo admite minúsculas, números y guion bajo")
+	ErrRolExiste            = errors.New("el rol ya existe")
+	ErrRolNoEncontrado      = errors.New("rol no encontrado")
+	ErrRolReservado         = errors.New("el rol está reservado")
+	ErrRolEnUso             = errors.New("hay usuarios con este rol")
+	ErrPermisoExiste        = errors.New("el permiso ya existe")
+	ErrPermisoNoExiste      = errors.New("permiso no encontrado")
+	ErrPermisoEnUso         = errors.New("el permiso lo exige alguna ruta")
+	ErrUltimoAdministrador  = errors.New("ningún otro rol tiene administrar_seguridad")
+	ErrTipoEmpleadoInvalido = errors.New("tipo de empleado inválido: debe ser un rol registrado")
+)
+
+// rolesReservados no se crean ni se eliminan: paciente es el rol de los
+// pacientes y los permisos de servicio se asignan por cuenta
+var rolesReservados = map[string]bool{"paciente": true, RolServicio: true}
+
+var nombreRBAC = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// Rol es un rol con sus permisos vigentes
+type Rol struct {
+	Rol         string     `json:"rol"`
+	Descripcion string     `json:"descripcion,omitempty"`
+	Permisos    []string   `json:"permisos"`
+	Usuarios    int        `json:"usuarios"`
+	CreadoEn    *time.Time `json:"creado_en,omitempty"`
+}
+
+// PermisoCatalogo es un permiso con los roles que lo tienen
+type PermisoCatalogo struct {
+	Permiso     string                    `json:"permiso"`
+	Descripcion string                    `json:"descripcion,omitempty"`
+	Roles       []string                  `json:"roles"`
+	Reglas      map[string][]ReglaPermiso `json:"reglas"` // rol -> reglas de ruta
+	EnUso       bool                      `json:"en_uso"`
+}
+
+// PermisosEfectivos son los permisos de un rol de un usuario
+type PermisosEfectivos struct {
+	Tipo     string                    `json:"tipo"`
+	ID       string                    `json:"id"`
+	Rol      string                    `json:"rol"`
+	Permisos map[string][]ReglaPermiso `json:"permisos"`
+}
+
+// ListarRoles devuelve los roles registrados y los que aparecen en la tabla
+// permisos, con cuántos usuarios tienen cada uno
+func ListarRoles() ([]Rol, error) {
+	roles, err := almacen.RolesRegistrados()
+	if err != nil {
+		return nil, err
+	}
+
+	politica := PoliticaActual()
+	for i := range roles {
+		roles[i].Permisos = politica.PermisosDeRol(roles[i].Rol)
+	}
+	return roles, nil
+}
+
+// CrearRol registra un rol sin permisos
+func CrearRol(rol, descripcion string) error {
+	if !nombreRBAC.MatchString(rol) {
+		return ErrNombreInvalido
+	}
+	if rolesReservados[rol] {
+		return ErrRolReservado
+	}
+	existe, err := rolExiste(rol)
+	if err != nil {
+		return err
+	}
+	if existe {
+		return ErrRolExiste
+	}
+	return almacen.GuardarRol(rol, descripcion)
+}
+
+// EliminarRol borra el rol y sus permisos si ningún empleado lo tiene
+func EliminarRol(rol string) error {
+	if rolesReservados[rol] {
+		return ErrRolReservado
+	}
+	existe, err := rolExiste(rol)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return ErrRolNoEncontrado
+	}
+	enUso, err := almacen.RolAsignado(rol)
+	if err != nil {
+		return err
+	}
+	if enUso {
+		return ErrRolEnUso
+	}
+	if err := verificarOtroAdministrador(rol); err != nil {
+		return err
+	}
+
+	if err := almacen.BorrarRol(rol); err != nil {
+		return err
+	}
+	return RecargarPolitica()
+}
+
+// ListarPermisos devuelve el catálogo de permisos con los roles que tiene cada uno
+func ListarPermisos() ([]PermisoCatalogo, error) {
+	permisos, err := almacen.PermisosRegistrados()
+	if err != nil {
+		return nil, err
+	}
+
+	politica := PoliticaActual()
+	for i := range permisos {
+		p := &permisos[i]
+		p.Roles = politica.RolesConPermiso(p.Permiso)
+		if p.Roles == nil {
+			p.Roles = []string{}
+		}
+		p.Reglas = map[string][]ReglaPermiso{}
+		for _, rol := range politica.RolesConReglas(p.Permiso) {
+			p.Reglas[rol] = politica.ReglasDePermiso(rol, p.Permiso)
+		}
+		p.EnUso = PermisoDeclarado(p.Permiso)
+	}
+	return permisos, nil
+}
+
+// CrearPermiso registra un permiso en el catálogo
+func CrearPermiso(permiso, descripcion string) error {
+	if !nombreRBAC.MatchString(permiso) {
+		return ErrNombreInvalido
+	}
+	existe, err := permisoExiste(permiso)
+	if err != nil {
+		return err
+	}
+	if existe {
+		return ErrPermisoExiste
+	}
+	return almacen.GuardarPermiso(permiso, descripcion)
+}
+
+// EliminarPermiso borra el permiso del catálogo y de todos los roles y cuentas
+// de servicio. Devuelve los roles que lo tenían.
+func EliminarPermiso(permiso string) ([]string, error) {
+	existe, err := permisoExiste(permiso)
+	if err != nil {
+		return nil, err
+	}
+	if !existe {
+		return nil, ErrPermisoNoExiste
+	}
+	if PermisoDeclarado(permiso) || permisosReservados[permiso] {
+		return nil, ErrPermisoEnUso
+	}
+	roles := PoliticaActual().RolesConPermiso(permiso)
+
+	if err := almacen.BorrarPermiso(permiso); err != nil {
+		return nil, err
+	}
+	invalidarCacheAPIKeys()
+	return roles, RecargarPolitica()
+}
+
+// AsignarPermisoRol da el permiso al rol. Sin reglas aplica a cualquier ruta
+// que lo exija; con reglas reemplaza las que tuviera.
+func AsignarPermisoRol(rol, permiso string, reglas []ReglaPermiso) error {
+	if rolesReservados[rol] && rol != "paciente" {
+		return ErrRolReservado
+	}
+	existe, err := rolExiste(rol)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return ErrRolNoEncontrado
+	}
+	if existe, err = permisoExiste(permiso); err != nil {
+		return err
+	} else if !existe {
+		return ErrPermisoNoExiste
+	}
+
+	if len(reglas) == 0 {
+		reglas = []ReglaPermiso{{Permitido: true}}
+	}
+	if err := almacen.ReemplazarPermisoRol(rol, permiso, reglas); err != nil {
+		return err
+	}
+	return RecargarPolitica()
+}
+
+// QuitarPermisoRol retira el permiso del rol
+func QuitarPermisoRol(rol, permiso string) error {
+	if !PoliticaActual().Asignado(rol, permiso) {
+		return ErrPermisoNoExiste
+	}
+	if permiso == "administrar_seguridad" {
+		if err := verificarOtroAdministrador(rol); err != nil {
+			return err
+		}
+	}
+	if err := almacen.BorrarPermisoRol(rol, permiso); err != nil {
+		return err
+	}
+	return RecargarPolitica()
+}
+
+// PermisosEfectivosUsuario devuelve los permisos que la política vigente da
+// a cada rol de un paciente, empleado o cuenta de servicio
+func PermisosEfectivosUsuario(tipo, id string) ([]PermisosEfectivos, error) {
+	politica := PoliticaActual()
+	if tipo == RolServicio {
+		cuenta, err := ObtenerCuentaServicio(id)
+		if err != nil {
+			return nil, err
+		}
+		ef := PermisosEfectivos{Tipo: tipo, ID: id, Rol: RolServicio, Permisos: map[string][]ReglaPermiso{}}
+		for _, p := range cuenta.Permisos {
+			ef.Permisos[p] = []ReglaPermiso{}
+		}
+		return []PermisosEfectivos{ef}, nil
+	}
+
+	cuenta, err := ObtenerCuentaDeUsuario(tipo, id)
+	if err != nil {
+		return nil, err
+	}
+	efectivos := []PermisosEfectivos{}
+	for _, r := range cuenta.Roles {
+		ef := PermisosEfectivos{Tipo: r.Tipo, ID: r.ID, Rol: r.Rol, Permisos: map[string][]ReglaPermiso{}}
+		for _, p := range politica.PermisosDeRol(r.Rol) {
+			ef.Permisos[p] = politica.ReglasDePermiso(r.Rol, p)
+		}
+		efectivos = append(efectivos, ef)
+	}
+	return efectivos, nil
+}
+
+// ValidarTipoEmpleado exige que el tipo de empleado sea un rol registrado del
+// personal, incluidos los creados en /api/seguridad/roles
+func ValidarTipoEmpleado(tipo string) error {
+	if rolesReservados[tipo] {
+		return ErrTipoEmpleadoInvalido
+	}
+	existe, err := rolExiste(tipo)
+	if err != nil {
+		return err
+	}
+	if !existe {
+		return ErrTipoEmpleadoInvalido
+	}
+	return nil
+}
+
+func rolExiste(rol string) (bool, error) {
+	if _, ok := PoliticaActual().roles[rol]; ok || rol == "paciente" {
+		return true, nil
+	}
+	return almacen.RolRegistrado(rol)
+}
+
+func permisoExiste(permiso string) (bool, error) {
+	return almacen.PermisoRegistrado(permiso)
+}
+
+// verificarOtroAdministrador evita dejar el sistema sin ningún rol que pueda
+// administrar la seguridad
+func verificarOtroAdministrador(rol string) error {
+	for _, r := range PoliticaActual().RolesConPermiso("administrar_seguridad") {
+		if r != rol {
+			return nil
+		}
+	}
+	if PoliticaActual().roles[rol]["administrar_seguridad"] {
+		return ErrUltimoAdministrador
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// Códigos de recuperación de MFA: se generan al confirmar MFA, se guardan con
+// el mismo hash que las contraseñas y cada uno se puede usar una sola vez en
+// lugar de un código TOTP.
+
+const (
+	totalCodigosRecuperacion = 10
+	alfabetoRecuperacion     = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+func generarCodigoRecuperacion() (string, error) {
+	b := make([]byte, 10)
+	max := big.NewInt(int64(len(alfabetoRecuperacion)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = alfabetoRecuperacion[n.Int64()]
+	}
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// normalizarCodigoRecuperacion permite escribir el código con mayúsculas o espacios
+func normalizarCodigoRecuperacion(codigo string) string {
+	codigo = strings.ToLower(strings.TrimSpace(codigo))
+	codigo = strings.ReplaceAll(codigo, " ", "")
+	if len(codigo) == 10 && !strings.Contains(codigo, "-") {
+		codigo = codigo[:5] + "-" + codigo[5:]
+	}
+	return codigo
+}
+
+// GenerarCodigosRecuperacion reemplaza los códigos de la cuenta por un juego nuevo
+// y los devuelve en claro (es la única vez que se pueden mostrar)
+func GenerarCodigosRecuperacion(c *Cuenta) ([]string, error) {
+	codigos := make([]string, 0, totalCodigosRecuperacion)
+	hashes := make([]string, 0, totalCodigosRecuperacion)
+	for i := 0; i < totalCodigosRecuperacion; i++ {
+		codigo, err := generarCodigoRecuperacion()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := HashPassword(codigo)
+		if err != nil {
+			return nil, err
+		}
+		codigos = append(codigos, codigo)
+		hashes = append(hashes, hash)
+	}
+
+	if err := almacen.ReemplazarCodigosRecuperacion(c.IdentidadID, hashes); err != nil {
+		return nil, err
+	}
+	return codigos, nil
+}
+
+// UsarCodigoRecuperacion valida un código y lo marca como usado
+func UsarCodigoRecuperacion(c *Cuenta, codigo string) (bool, error) {
+	codigo = normalizarCodigoRecuperacion(codigo)
+
+	pendientes, err := almacen.CodigosRecuperacionPendientes(c.IdentidadID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range pendientes {
+		if CheckPasswordHash(codigo, p.Hash) {
+			// Si otro request usó el mismo código al mismo tiempo, solo uno gana
+			return almacen.MarcarCodigoRecuperacionUsado(p.ID)
+		}
+	}
+	return false, nil
+}
+
+// ContarCodigosRecuperacion devuelve cuántos códigos sin usar le quedan a la cuenta
+func ContarCodigosRecuperacion(c *Cuenta) (int, error) {
+	return almacen.ContarCodigosRecuperacion(c.IdentidadID)
+}
+
+// EliminarCodigosRecuperacion borra todos los códigos de la cuenta (al desactivar MFA)
+func EliminarCodigosRecuperacion(c *Cuenta) error {
+	return almacen.EliminarCodigosRecuperacion(c.IdentidadID)
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Los refresh tokens son valores opacos: en la BD solo se guarda su hash y
+// cada uno pertenece a una familia que nace en el login. Cada uso en
+// /auth/refresh consume el token y emite el siguiente de la misma familia.
+
+const RefreshTokenTTL = 7 * 24 * time.Hour
+
+var (
+	ErrRefreshInvalido    = errors.New("refresh token inválido o expirado")
+	ErrRefreshReutilizado = errors.New("refresh token reutilizado")
+)
+
+// RefreshSession es la información asociada a un refresh token válido
+type RefreshSession struct {
+	IdentidadID string
+	ID          string
+	Email       string
+	Rol         string
+	FamiliaID   string
+	ClientID    string // cliente OAuth al que se emitió (vacío para el front end propio)
+}
+
+// HashToken obtiene el hash SHA-256 (hex) con el que se guardan los tokens opacos
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerarTokenOpaco genera un valor aleatorio apto para usarse como token
+func GenerarTokenOpaco() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GenerateRefreshToken emite el primer refresh token de una nueva familia (login)
+func GenerateRefreshToken(p Principal) (string, error) {
+	return nuevoRefreshToken(RefreshSession{IdentidadID: p.IdentidadID, ID: p.ID, Email: p.Correo, Rol: p.Rol, FamiliaID: uuid.NewString()})
+}
+
+// GenerateRefreshTokenOAuth emite el primer refresh token de una familia ligada a un cliente OAuth
+func GenerateRefreshTokenOAuth(p Principal, clientID string) (string, error) {
+	return nuevoRefreshToken(RefreshSession{IdentidadID: p.IdentidadID, ID: p.ID, Email: p.Correo, Rol: p.Rol, FamiliaID: uuid.NewString(), ClientID: clientID})
+}
+
+func nuevoRefreshToken(s RefreshSession) (string, error) {
+	token, err := GenerarTokenOpaco()
+	if err != nil {
+		return "", err
+	}
+	if err := almacen.GuardarRefreshToken(HashToken(token), s, time.Now().Add(RefreshTokenTTL)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken consume un refresh token y emite el siguiente de su familia.
+// clientID es el cliente OAuth que lo presenta (vacío para el front end propio);
+// un token emitido a otro cliente se considera inválido. Si el token ya había
+// sido rotado se revoca la familia completa y se devuelve ErrRefreshReutilizado.
+func RotateRefreshToken(token, clientID string) (*RefreshSession, string, error) {
+	nuevo, err := GenerarTokenOpaco()
+	if err != nil {
+		return nil, "", err
+	}
+
+	s, err := almacen.RotarRefreshToken(HashToken(token), clientID, HashToken(nuevo), time.Now().Add(RefreshTokenTTL))
+	if err != nil {
+		return s, "", err
+	}
+	return s, nuevo, nil
+}
+
+// RevokeRefreshFamily revoca todos los refresh tokens de una familia
+func RevokeRefreshFamily(familiaID string) error {
+	return almacen.RevocarFamilia(familiaID)
+}
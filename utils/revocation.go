@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Revocación de access tokens. Un token puede quedar invalidado de dos formas:
+// por su jti (logout) o porque el usuario cerró todas sus sesiones después de
+// la fecha de emisión (iat) del token. Además, al cambiar los permisos de un
+// rol se puede pedir que los access tokens de ese rol emitidos antes se
+// renueven: dejan de servir pero los refresh tokens siguen vigentes, así que el
+// cliente obtiene un token nuevo con los permisos actuales. Las consultas se cachean en memoria
+// para no ir a la BD en cada request.
+
+const (
+	revocationCacheTTL = 30 * time.Second
+	revocationCacheMax = 10000
+)
+
+type jtiCacheEntry struct {
+	revocado bool
+	hasta    time.Time
+}
+
+type usuarioCacheEntry struct {
+	desde time.Time // cero si el usuario nunca ha cerrado todas sus sesiones
+	hasta time.Time
+}
+
+var (
+	revocationMu    sync.Mutex
+	jtiCache        = map[string]jtiCacheEntry{}
+	usuarioRevCache = map[string]usuarioCacheEntry{}
+	rolRenovCache   = map[string]usuarioCacheEntry{}
+)
+
+func claveUsuario(id, rol string) string {
+	return TipoUsuario(rol) + ":" + id
+}
+
+// RevokeAccessToken agrega el jti del token a la lista de revocados hasta que expire
+func RevokeAccessToken(jti string, expira time.Time) error {
+	if err := almacen.RevocarJTI(jti, expira); err != nil {
+		return err
+	}
+
+	revocationMu.Lock()
+	jtiCache[jti] = jtiCacheEntry{revocado: true, hasta: expira}
+	revocationMu.Unlock()
+	return nil
+}
+
+// ConsumirTokenDeUso marca como usado el jti de un token de un solo uso. Es
+// atómico: de dos solicitudes simultáneas con el mismo token solo una obtiene
+// true. Si la operación no se completa, LiberarTokenDeUso permite reintentar.
+func ConsumirTokenDeUso(jti string, expira time.Time) (bool, error) {
+	consumido, err := almacen.ConsumirJTI(jti, expira)
+	if err != nil || !consumido {
+		return false, err
+	}
+	revocationMu.Lock()
+	jtiCache[jti] = jtiCacheEntry{revocado: true, hasta: expira}
+	revocationMu.Unlock()
+	return true, nil
+}
+
+// LiberarTokenDeUso deshace ConsumirTokenDeUso
+func LiberarTokenDeUso(jti string) error {
+	if err := almacen.LiberarJTI(jti); err != nil {
+		return err
+	}
+	revocationMu.Lock()
+	delete(jtiCache, jti)
+	revocationMu.Unlock()
+	return nil
+}
+
+// RevokeAllSessions cierra todas las sesiones del usuario: invalida los access
+// tokens emitidos hasta ahora y revoca todos sus refresh tokens
+func RevokeAllSessions(id, rol string) error {
+	// Se redondea al siguiente segundo porque iat tiene resolución de segundos
+	desde := time.Now().Truncate(time.Second).Add(time.Second)
+
+	if err := almacen.RevocarSesionesUsuario(TipoUsuario(rol), id, desde); err != nil {
+		return err
+	}
+
+	revocationMu.Lock()
+	usuarioRevCache[claveUsuario(id, rol)] = usuarioCacheEntry{desde: desde, hasta: time.Now().Add(revocationCacheTTL)}
+	revocationMu.Unlock()
+	return nil
+}
+
+// RevokeRefreshToken revoca la familia a la que pertenece un refresh token
+func RevokeRefreshToken(token string) error {
+	return almacen.RevocarFamiliaDeToken(HashToken(token))
+}
+
+// ForzarRenovacionRol invalida los access tokens del rol emitidos hasta ahora
+// sin cerrar las sesiones: el cliente debe usar su refresh token
+func ForzarRenovacionRol(rol string) error {
+	desde := time.Now().Truncate(time.Second).Add(time.Second)
+	if err := almacen.GuardarRenovacionRol(rol, desde); err != nil {
+		return err
+	}
+
+	revocationMu.Lock()
+	rolRenovCache[rol] = usuarioCacheEntry{desde: desde, hasta: time.Now().Add(revocationCacheTTL)}
+	revocationMu.Unlock()
+	return nil
+}
+
+// revocadoDesdeLocal devuelve el último cierre de sesiones (o renovación
+// forzada del rol) conocido por esta instancia para el usuario (cero si no hay)
+func revocadoDesdeLocal(id, rol string) time.Time {
+	revocationMu.Lock()
+	defer revocationMu.Unlock()
+	desde := usuarioRevCache[claveUsuario(id, rol)].desde
+	if renovar := rolRenovCache[rol].desde; renovar.After(desde) {
+		desde = renovar
+	}
+	return desde
+}
+
+// renovacionRol devuelve desde cuándo deben renovarse los tokens del rol (cero si nunca)
+func renovacionRol(rol string) (time.Time, error) {
+	ahora := time.Now()
+	revocationMu.Lock()
+	entry, ok := rolRenovCache[rol]
+	revocationMu.Unlock()
+	if ok && ahora.Before(entry.hasta) {
+		return entry.desde, nil
+	}
+
+	desde, err := almacen.RenovacionRol(rol)
+	if err != nil {
+		return time.Time{}, err
+	}
+	entry = usuarioCacheEntry{desde: desde, hasta: ahora.Add(revocationCacheTTL)}
+	revocationMu.Lock()
+	rolRenovCache[rol] = entry
+	revocationMu.Unlock()
+	return entry.desde, nil
+}
+
+// IsAccessTokenRevoked indica si un access token fue revocado por jti, por
+// cierre de sesiones o por una renovación forzada de su rol
+func IsAccessTokenRevoked(jti, id, rol string, emitido time.Time) (bool, error) {
+	ahora := time.Now()
+	clave := claveUsuario(id, rol)
+
+	revocationMu.Lock()
+	if len(jtiCache)+len(usuarioRevCache) > revocationCacheMax {
+		limpiarCacheRevocacion()
+	}
+	jtiEntry, jtiOK := jtiCache[jti]
+	usrEntry, usrOK := usuarioRevCache[clave]
+	revocationMu.Unlock()
+
+	if jtiOK && jtiEntry.revocado {
+		return true, nil
+	}
+
+	if !jtiOK || ahora.After(jtiEntry.hasta) {
+		revocado, err := IsJTIRevoked(jti)
+		if err != nil || revocado {
+			return revocado, err
+		}
+	}
+
+	if !usrOK || ahora.After(usrEntry.hasta) {
+		desde, err := almacen.SesionesRevocadasDesde(TipoUsuario(rol), id)
+		if err != nil {
+			return false, err
+		}
+		usrEntry = usuarioCacheEntry{desde: desde, hasta: ahora.Add(revocationCacheTTL)}
+		revocationMu.Lock()
+		usuarioRevCache[clave] = usrEntry
+		revocationMu.Unlock()
+	}
+
+	if !usrEntry.desde.IsZero() && emitido.Before(usrEntry.desde) {
+		return true, nil
+	}
+
+	renovar, err := renovacionRol(rol)
+	if err != nil {
+		return false, err
+	}
+	return !renovar.IsZero() && emitido.Before(renovar), nil
+}
+
+// IsJTIRevoked consulta (con caché) si un jti está en la lista de revocados.
+// Sirve para cualquier token con jti, no solo access tokens.
+func IsJTIRevoked(jti string) (bool, error) {
+	revocationMu.Lock()
+	entry, ok := jtiCache[jti]
+	revocationMu.Unlock()
+	if ok && (entry.revocado || time.Now().Before(entry.hasta)) {
+		return entry.revocado, nil
+	}
+
+	existe, err := almacen.JTIRevocado(jti)
+	if err != nil {
+		return false, err
+	}
+	revocationMu.Lock()
+	jtiCache[jti] = jtiCacheEntry{revocado: existe, hasta: time.Now().Add(revocationCacheTTL)}
+	revocationMu.Unlock()
+	return existe, nil
+}
+
+// limpiarCacheRevocacion elimina entradas vencidas del caché (requiere revocationMu)
+func limpiarCacheRevocacion() {
+	ahora := time.Now()
+	for k, v := range jtiCache {
+		if ahora.After(v.hasta) {
+			delete(jtiCache, k)
+		}
+	}
+	for k, v := range usuarioRevCache {
+		if ahora.After(v.hasta) {
+			delete(usuarioRevCache, k)
+		}
+	}
+}
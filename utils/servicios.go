@@ -0,0 +1,343 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Cuentas de servicio para clientes máquina (analizadores del laboratorio,
+// facturación). Una cuenta de servicio tiene un subconjunto de los permisos del
+// catálogo y se autentica con API keys: por el encabezado X-API-Key o
+// cambiándolas por un access token con el grant client_credentials.
+//
+// Formato de la llave: bm_<prefijo>_<secreto>. El prefijo se guarda en claro
+// para buscarla y mostrarla en los listados; del secreto solo se guarda el hash.
+
+const (
+	RolServicio      = "servicio"
+	prefijoAPIKey    = "bm_"
+	ServicioTokenTTL = 15 * time.Minute
+)
+
+var (
+	ErrCuentaServicioNoEncontrada = errors.New("cuenta de servicio no encontrada")
+	ErrAPIKeyInvalida             = errors.New("API key inválida, revocada o expirada")
+	ErrPermisoDesconocido         = errors.New("permiso no existe en el catálogo")
+	ErrPermisoReservado           = errors.New("permiso reservado para usuarios")
+)
+
+// permisosReservados no se pueden asignar a cuentas de servicio: una llave
+// filtrada no debe poder crear más llaves ni desbloquear cuentas
+var permisosReservados = map[string]bool{"administrar_seguridad": true}
+
+// CuentaServicio es una identidad no humana con permisos propios
+type CuentaServicio struct {
+	ID          string    `json:"id"`
+	Nombre      string    `json:"nombre"`
+	Descripcion string    `json:"descripcion,omitempty"`
+	Permisos    []string  `json:"permisos"`
+	Activa      bool      `json:"activa"`
+	CreadoEn    time.Time `json:"creado_en"`
+}
+
+// APIKey es la información visible de una llave (nunca el secreto)
+type APIKey struct {
+	ID          string     `json:"id"`
+	Nombre      string     `json:"nombre"`
+	Prefijo     string     `json:"prefijo"`
+	ExpiraEn    *time.Time `json:"expira_en,omitempty"`
+	UltimoUsoEn *time.Time `json:"ultimo_uso_en,omitempty"`
+	RevocadaEn  *time.Time `json:"revocada_en,omitempty"`
+	CreadoEn    time.Time  `json:"creado_en"`
+}
+
+// Principal devuelve el principal con el que la cuenta aparece en tokens y bitácoras
+func (s *CuentaServicio) Principal() Principal {
+	return Principal{
+		Tipo:   RolServicio,
+		ID:     s.ID,
+		Correo: "servicio:" + s.Nombre,
+		Rol:    RolServicio,
+		Roles:  []string{RolServicio},
+	}
+}
+
+// ValidarPermisosServicio verifica que todos los permisos existan en el catálogo
+// y que ninguno esté reservado
+func ValidarPermisosServicio(permisos []string) error {
+	for _, p := range permisos {
+		if permisosReservados[p] {
+			return ErrPermisoReservado
+		}
+	}
+	n, err := almacen.ContarPermisosConocidos(permisos)
+	if err != nil {
+		return err
+	}
+	unicos := map[string]bool{}
+	for _, p := range permisos {
+		unicos[p] = true
+	}
+	if n != len(unicos) {
+		return ErrPermisoDesconocido
+	}
+	return nil
+}
+
+// CrearCuentaServicio registra una cuenta de servicio
+func CrearCuentaServicio(nombre, descripcion string, permisos []string) (*CuentaServicio, error) {
+	if err := ValidarPermisosServicio(permisos); err != nil {
+		return nil, err
+	}
+	s := &CuentaServicio{
+		ID:          uuid.NewString(),
+		Nombre:      nombre,
+		Descripcion: descripcion,
+		Permisos:    permisos,
+		Activa:      true,
+		CreadoEn:    time.Now(),
+	}
+	if err := almacen.GuardarCuentaServicio(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ObtenerCuentaServicio carga una cuenta de servicio (activa o no)
+func ObtenerCuentaServicio(id string) (*CuentaServicio, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrCuentaServicioNoEncontrada
+	}
+	return almacen.BuscarCuentaServicio(id)
+}
+
+// ListarCuentasServicio devuelve todas las cuentas de servicio
+func ListarCuentasServicio() ([]CuentaServicio, error) {
+	return almacen.CuentasServicio()
+}
+
+// ActualizarPermisosServicio reemplaza los permisos de la cuenta y revoca los
+// access tokens emitidos con los permisos anteriores
+func ActualizarPermisosServicio(id string, permisos []string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrCuentaServicioNoEncontrada
+	}
+	if err := ValidarPermisosServicio(permisos); err != nil {
+		return err
+	}
+	ok, err := almacen.CambiarPermisosServicio(id, permisos)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCuentaServicioNoEncontrada
+	}
+	invalidarCacheAPIKeys()
+	return RevokeAllSessions(id, RolServicio)
+}
+
+// DesactivarCuentaServicio desactiva la cuenta, revoca sus llaves y sus tokens
+func DesactivarCuentaServicio(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrCuentaServicioNoEncontrada
+	}
+	ok, err := almacen.DesactivarServicio(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCuentaServicioNoEncontrada
+	}
+	invalidarCacheAPIKeys()
+	return RevokeAllSessions(id, RolServicio)
+}
+
+// CrearAPIKey emite una llave para la cuenta. Devuelve la llave en claro; es la
+// única vez que se puede mostrar.
+func CrearAPIKey(idCuenta, nombre string, expira *time.Time) (*APIKey, string, error) {
+	secreto, err := GenerarTokenOpaco()
+	if err != nil {
+		return nil, "", err
+	}
+	prefijo := strings.ReplaceAll(uuid.NewString()[:8], "-", "")
+
+	k := &APIKeyGuardada{
+		APIKey: APIKey{
+			ID:       uuid.NewString(),
+			Nombre:   nombre,
+			Prefijo:  prefijo,
+			ExpiraEn: expira,
+			CreadoEn: time.Now(),
+		},
+		IDCuenta: idCuenta,
+		Hash:     HashToken(secreto),
+	}
+	if err := almacen.GuardarAPIKey(k); err != nil {
+		return nil, "", err
+	}
+	return &k.APIKey, prefijoAPIKey + prefijo + "_" + secreto, nil
+}
+
+// ListarAPIKeys devuelve las llaves de una cuenta de servicio
+func ListarAPIKeys(idCuenta string) ([]APIKey, error) {
+	if _, err := uuid.Parse(idCuenta); err != nil {
+		return []APIKey{}, nil
+	}
+	return almacen.APIKeys(idCuenta)
+}
+
+// RevocarAPIKey revoca una llave de la cuenta
+func RevocarAPIKey(idCuenta, idLlave string) (bool, error) {
+	if _, err := uuid.Parse(idCuenta); err != nil {
+		return false, nil
+	}
+	if _, err := uuid.Parse(idLlave); err != nil {
+		return false, nil
+	}
+	ok, err := almacen.RevocarLlave(idCuenta, idLlave)
+	invalidarCacheAPIKeys()
+	return ok, err
+}
+
+// AutenticarAPIKey valida una llave y devuelve su cuenta de servicio y el id de la llave
+func AutenticarAPIKey(llave string) (*CuentaServicio, string, error) {
+	resto, ok := strings.CutPrefix(llave, prefijoAPIKey)
+	if !ok {
+		return nil, "", ErrAPIKeyInvalida
+	}
+	prefijo, secreto, ok := strings.Cut(resto, "_")
+	if !ok || prefijo == "" || secreto == "" {
+		return nil, "", ErrAPIKeyInvalida
+	}
+
+	if e, ok := buscarCacheAPIKey(llave); ok {
+		return e.cuenta, e.idLlave, nil
+	}
+
+	k, err := almacen.BuscarAPIKey(prefijo)
+	if err != nil {
+		return nil, "", err
+	}
+	if subtle.ConstantTimeCompare([]byte(HashToken(secreto)), []byte(k.Hash)) != 1 ||
+		k.RevocadaEn != nil || (k.ExpiraEn != nil && time.Now().After(*k.ExpiraEn)) {
+		return nil, "", ErrAPIKeyInvalida
+	}
+
+	cuenta, err := ObtenerCuentaServicio(k.IDCuenta)
+	if err == ErrCuentaServicioNoEncontrada || (err == nil && !cuenta.Activa) {
+		return nil, "", ErrAPIKeyInvalida
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	guardarCacheAPIKey(llave, apiKeyCacheEntry{cuenta: cuenta, idLlave: k.ID, expira: k.ExpiraEn})
+	registrarUsoAPIKey(k.ID)
+	return cuenta, k.ID, nil
+}
+
+// registrarUsoAPIKey actualiza ultimo_uso_en como máximo una vez por minuto por llave
+func registrarUsoAPIKey(idLlave string) {
+	go func() {
+		if err := almacen.RegistrarUsoLlave(idLlave); err != nil {
+			log.Println("Error registrando uso de API key:", err)
+		}
+	}()
+}
+
+// GenerateServiceToken genera el access token de una cuenta de servicio
+// (grant client_credentials). permisos debe ser un subconjunto de los de la cuenta.
+func GenerateServiceToken(s *CuentaServicio, permisos []string) (string, error) {
+	ahora := time.Now()
+	if desde := revocadoDesdeLocal(s.ID, RolServicio); ahora.Before(desde) {
+		ahora = desde
+	}
+	claims := s.Principal().claims()
+	claims["typ"] = "access"
+	claims["jti"] = uuid.NewString()
+	claims["permisos"] = permisos
+	claims["client_id"] = s.ID
+	claims["iss"] = IssuerOIDC()
+	claims["iat"] = ahora.Unix()
+	claims["exp"] = ahora.Add(ServicioTokenTTL).Unix()
+	return signToken(claims)
+}
+
+// PermisosTokenServicio devuelve los permisos efectivos de un access token de
+// cuenta de servicio: los del claim permisos que la cuenta todavía tiene. Una
+// cuenta desactivada o eliminada no tiene ninguno.
+func PermisosTokenServicio(claims jwt.MapClaims) (map[string]bool, error) {
+	permisos := map[string]bool{}
+	idCuenta, _ := claims["client_id"].(string)
+	cuenta, err := ObtenerCuentaServicio(idCuenta)
+	if err == ErrCuentaServicioNoEncontrada {
+		return permisos, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if !cuenta.Activa {
+		return permisos, nil
+	}
+
+	vigentes := map[string]bool{}
+	for _, p := range cuenta.Permisos {
+		vigentes[p] = true
+	}
+	for _, p := range listaDeClaim(claims["permisos"]) {
+		if vigentes[p] {
+			permisos[p] = true
+		}
+	}
+	return permisos, nil
+}
+
+// Las llaves validadas se cachean unos segundos para no consultar la BD en cada
+// request. Revocar en esta instancia limpia la caché; en otras instancias la
+// revocación tarda como máximo apiKeyCacheTTL en surtir efecto.
+
+const apiKeyCacheTTL = 30 * time.Second
+
+type apiKeyCacheEntry struct {
+	cuenta  *CuentaServicio
+	idLlave string
+	expira  *time.Time
+	hasta   time.Time
+}
+
+var (
+	apiKeyCacheMu sync.Mutex
+	apiKeyCache   = map[string]apiKeyCacheEntry{}
+)
+
+func buscarCacheAPIKey(llave string) (apiKeyCacheEntry, bool) {
+	apiKeyCacheMu.Lock()
+	defer apiKeyCacheMu.Unlock()
+	e, ok := apiKeyCache[HashToken(llave)]
+	ahora := time.Now()
+	if !ok || ahora.After(e.hasta) || (e.expira != nil && ahora.After(*e.expira)) {
+		return e, false
+	}
+	return e, true
+}
+
+func guardarCacheAPIKey(llave string, e apiKeyCacheEntry) {
+	apiKeyCacheMu.Lock()
+	defer apiKeyCacheMu.Unlock()
+	if len(apiKeyCache) > revocationCacheMax {
+		apiKeyCache = map[string]apiKeyCacheEntry{}
+	}
+	e.hasta = time.Now().Add(apiKeyCacheTTL)
+	apiKeyCache[HashToken(llave)] = e
+}
+
+func invalidarCacheAPIKeys() {
+	apiKeyCacheMu.Lock()
+	apiKeyCache = map[string]apiKeyCacheEntry{}
+	apiKeyCacheMu.Unlock()
+}
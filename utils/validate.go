@@ -9,7 +9,6 @@ import (
 	"time"
 	"fmt"
 	"back-menchaca/models"
-	"back-menchaca/config"
 )
 func SanitizarInput(s string) string {
 	//quita espacios y caracteres HTML peligrosos
@@ -106,12 +105,10 @@ func ValidarEmpleado(nombre, appaterno, tipo, area, correo, contrasena string) e
 	if strings.TrimSpace(appaterno) == "" || !ValidarTextoLetras(appaterno) {
 		return errors.New("apellido paterno inválido")
 	}
-	if strings.TrimSpace(tipo) == "" || !ValidarTextoLetras(tipo) {
-		return errors.New("tipo de empleado inválido")
+	// Que el tipo sea un rol registrado lo revisa ValidarTipoEmpleado
+	if strings.TrimSpace(tipo) == "" {
+		return ErrTipoEmpleadoInvalido
 	}
-	if tipo != "doctor" && tipo != "enfermera" && tipo != "administrador" {
-	return errors.New("tipo de empleado inválido. Solo se permite 'doctor', 'enfermera' o 'administrador'")
-}
 
 	if strings.TrimSpace(area) == "" || !ValidarTextoLetras(area) {
 		return errors.New("area inválida")
@@ -167,16 +164,13 @@ func ValidarConsulta(c models.Consulta) error {
 
 
 func ExisteID(tabla string, columna string, id int) bool {
-	var existe bool
-	query := "SELECT EXISTS(SELECT 1 FROM " + tabla + " WHERE " + columna + " = $1)"
-	err := config.DB.QueryRow(query, id).Scan(&existe)
+	existe, err := almacen.ExisteRegistro(tabla, columna, id)
 	return err == nil && existe
 }
 
 func ExisteIDHisotial(tabla string, campo string, id int) bool {
-	var count int
-	err := config.DB.QueryRow("SELECT COUNT(*) FROM "+tabla+" WHERE "+campo+" = $1", id).Scan(&count)
-	return err == nil && count > 0
+	existe, err := almacen.ExisteRegistro(tabla, campo, id)
+	return err == nil && existe
 }
 
 
@@ -187,12 +181,16 @@ func ValidarReceta(medicamento, dosis string, idConsultorio int) error {
 	if strings.TrimSpace(dosis) == "" {
 		return errors.New("La dosis es obligatoria")
 	}
-	if idConsultorio <= 0 || !ExisteID("Consultorios", "id_consultorio", idConsultorio) {
-		return errors.New("El ID de consultorio no es válido")
+	if idConsultorio <= 0 {
+		return ErrConsultorioInvalido
 	}
 	return nil
 }
 
+// ErrConsultorioInvalido se devuelve cuando la receta no tiene un consultorio
+// válido; quien la registra comprueba además que el consultorio exista
+var ErrConsultorioInvalido = errors.New("El ID de consultorio no es válido")
+
 
 func ValidarSeguro(seguro string) error {
     seguro = strings.TrimSpace(seguro)
@@ -204,9 +202,7 @@ func ValidarSeguro(seguro string) error {
 
 
 func ExisteIDExped(id int) bool {
-	var existe bool
-	query := `SELECT EXISTS(SELECT 1 FROM Expediente WHERE id_expediente = $1)`
-	err := config.DB.QueryRow(query, id).Scan(&existe)
+	existe, err := almacen.ExisteRegistro("Expediente", "id_expediente", id)
 	return err == nil && existe
 }
 
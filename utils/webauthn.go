@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"back-menchaca/config"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// WebAuthn (llaves de seguridad y passkeys) como segundo factor alternativo al
+// TOTP. No se pide attestation ni se consulta el servicio de metadatos de FIDO,
+// así que todo funciona sin salida a internet contra el RP ID local
+// (WEBAUTHN_RP_ID). Los datos de cada ceremonia se guardan en webauthn_sesiones
+// hasta que el navegador responde.
+
+const webauthnSesionTTL = 5 * time.Minute
+
+var (
+	ErrWebAuthnSinSesion = errors.New("no hay una ceremonia WebAuthn pendiente o expiró")
+	ErrWebAuthnClonada   = errors.New("el contador de la llave indica que pudo ser clonada")
+)
+
+var (
+	webAuthnOnce sync.Once
+	webAuthnRP   *webauthn.WebAuthn
+	webAuthnErr  error
+)
+
+// relyingParty construye la configuración del relying party a partir de
+// WEBAUTHN_RP_ID, WEBAUTHN_RP_NAME y WEBAUTHN_ORIGINS (separados por comas)
+func relyingParty() (*webauthn.WebAuthn, error) {
+	webAuthnOnce.Do(func() {
+		cfg := config.Actual().WebAuthn
+		webAuthnRP, webAuthnErr = webauthn.New(&webauthn.Config{
+			RPID:                  cfg.RPID,
+			RPDisplayName:         cfg.RPNombre,
+			RPOrigins:             cfg.Origenes,
+			AttestationPreference: protocol.PreferNoAttestation,
+			AuthenticatorSelection: protocol.AuthenticatorSelection{
+				ResidentKey:      protocol.ResidentKeyRequirementDiscouraged,
+				UserVerification: protocol.VerificationPreferred,
+			},
+		})
+	})
+	return webAuthnRP, webAuthnErr
+}
+
+// CredencialWebAuthn es la información visible de un autenticador registrado
+type CredencialWebAuthn struct {
+	ID           string     `json:"id"` // id de la credencial en base64url
+	Nombre       string     `json:"nombre"`
+	Transportes  []string   `json:"transportes,omitempty"`
+	Sincronizada bool       `json:"sincronizada"` // passkey respaldada en la nube del usuario
+	CreadoEn     time.Time  `json:"creado_en"`
+	UltimoUsoEn  *time.Time `json:"ultimo_uso_en,omitempty"`
+}
+
+// usuarioWebAuthn adapta una cuenta a la interfaz webauthn.User
+type usuarioWebAuthn struct {
+	cuenta       *Cuenta
+	credenciales []webauthn.Credential
+}
+
+func (u *usuarioWebAuthn) WebAuthnID() []byte {
+	id, err := uuid.Parse(u.cuenta.IdentidadID)
+	if err != nil {
+		return []byte(u.cuenta.IdentidadID)
+	}
+	return id[:]
+}
+
+func (u *usuarioWebAuthn) WebAuthnName() string        { return u.cuenta.Correo }
+func (u *usuarioWebAuthn) WebAuthnDisplayName() string { return u.cuenta.Correo }
+func (u *usuarioWebAuthn) WebAuthnCredentials() []webauthn.Credential {
+	return u.credenciales
+}
+
+func cargarUsuarioWebAuthn(c *Cuenta) (*usuarioWebAuthn, error) {
+	credenciales, err := almacen.CredencialesWebAuthn(c.IdentidadID)
+	if err != nil {
+		return nil, err
+	}
+	return &usuarioWebAuthn{cuenta: c, credenciales: credenciales}, nil
+}
+
+func guardarSesionWebAuthn(clave string, s *webauthn.SessionData) error {
+	datos, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return almacen.GuardarSesionWebAuthn(clave, datos, time.Now().Add(webauthnSesionTTL))
+}
+
+// consumirSesionWebAuthn obtiene y borra los datos de la ceremonia: cada
+// challenge solo se puede responder una vez
+func consumirSesionWebAuthn(clave string) (*webauthn.SessionData, error) {
+	datos, err := almacen.ConsumirSesionWebAuthn(clave)
+	if err != nil {
+		return nil, err
+	}
+	var s webauthn.SessionData
+	if err := json.Unmarshal(datos, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// IniciarRegistroWebAuthn genera las opciones para navigator.credentials.create().
+// Se excluyen los autenticadores que la cuenta ya registró.
+func IniciarRegistroWebAuthn(c *Cuenta) (*protocol.CredentialCreation, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	u, err := cargarUsuarioWebAuthn(c)
+	if err != nil {
+		return nil, err
+	}
+
+	excluir := make([]protocol.CredentialDescriptor, 0, len(u.credenciales))
+	for _, cred := range u.credenciales {
+		excluir = append(excluir, cred.Descriptor())
+	}
+	opciones, sesion, err := rp.BeginRegistration(u, webauthn.WithExclusions(excluir))
+	if err != nil {
+		return nil, err
+	}
+	if err := guardarSesionWebAuthn("registro:"+c.IdentidadID, sesion); err != nil {
+		return nil, err
+	}
+	return opciones, nil
+}
+
+// FinalizarRegistroWebAuthn valida la respuesta de navigator.credentials.create()
+// y guarda el autenticador con el nombre que le dio el usuario
+func FinalizarRegistroWebAuthn(c *Cuenta, nombre string, respuesta []byte) (*CredencialWebAuthn, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	sesion, err := consumirSesionWebAuthn("registro:" + c.IdentidadID)
+	if err != nil {
+		return nil, err
+	}
+	u, err := cargarUsuarioWebAuthn(c)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(respuesta)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := rp.CreateCredential(u, *sesion, parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	transportes := make([]string, 0, len(cred.Transport))
+	for _, t := range cred.Transport {
+		transportes = append(transportes, string(t))
+	}
+	ahora := time.Now()
+	if err := almacen.GuardarCredencialWebAuthn(c.IdentidadID, nombre, cred, ahora); err != nil {
+		return nil, err
+	}
+	c.Passkeys++
+
+	return &CredencialWebAuthn{
+		ID:           base64.RawURLEncoding.EncodeToString(cred.ID),
+		Nombre:       nombre,
+		Transportes:  transportes,
+		Sincronizada: cred.Flags.BackupState,
+		CreadoEn:     ahora,
+	}, nil
+}
+
+// IniciarAsercionWebAuthn genera las opciones para navigator.credentials.get()
+// durante el login. La ceremonia queda ligada al token temporal (jti) del login.
+func IniciarAsercionWebAuthn(c *Cuenta, jti string) (*protocol.CredentialAssertion, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return nil, err
+	}
+	u, err := cargarUsuarioWebAuthn(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(u.credenciales) == 0 {
+		return nil, ErrWebAuthnSinSesion
+	}
+
+	opciones, sesion, err := rp.BeginLogin(u)
+	if err != nil {
+		return nil, err
+	}
+	if err := guardarSesionWebAuthn("login:"+jti, sesion); err != nil {
+		return nil, err
+	}
+	return opciones, nil
+}
+
+// VerificarAsercionWebAuthn valida la respuesta de navigator.credentials.get()
+// para la ceremonia ligada al token temporal y actualiza el contador de la llave
+func VerificarAsercionWebAuthn(c *Cuenta, jti string, respuesta []byte) (bool, error) {
+	rp, err := relyingParty()
+	if err != nil {
+		return false, err
+	}
+	sesion, err := consumirSesionWebAuthn("login:" + jti)
+	if err == ErrWebAuthnSinSesion {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	u, err := cargarUsuarioWebAuthn(c)
+	if err != nil {
+		return false, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(respuesta)
+	if err != nil {
+		return false, nil
+	}
+	cred, err := rp.ValidateLogin(u, *sesion, parsed)
+	if err != nil {
+		return false, nil
+	}
+	if cred.Authenticator.CloneWarning {
+		return false, ErrWebAuthnClonada
+	}
+
+	err = almacen.ActualizarCredencialWebAuthn(c.IdentidadID, cred)
+	return err == nil, err
+}
+
+// ListarCredencialesWebAuthn devuelve los autenticadores registrados por la cuenta
+func ListarCredencialesWebAuthn(c *Cuenta) ([]CredencialWebAuthn, error) {
+	return almacen.AutenticadoresWebAuthn(c.IdentidadID)
+}
+
+// EliminarCredencialWebAuthn borra un autenticador de la cuenta (id en base64url)
+func EliminarCredencialWebAuthn(c *Cuenta, id string) (bool, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return false, nil
+	}
+	ok, err := almacen.BorrarCredencialWebAuthn(c.IdentidadID, raw)
+	if ok {
+		c.Passkeys--
+	}
+	return ok, err
+}